@@ -17,16 +17,23 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/kubernetes-incubator/external-dns/endpoint"
 	"github.com/kubernetes-incubator/external-dns/plan"
+	"github.com/kubernetes-incubator/external-dns/provider"
 	"github.com/kubernetes-incubator/external-dns/registry"
 	"github.com/kubernetes-incubator/external-dns/source"
 )
 
+// runCheckInterval is how often Run checks whether a synchronization is due.
+const runCheckInterval = time.Second
+
 var (
 	registryErrors = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -56,6 +63,22 @@ var (
 			Help:      "Number of Endpoints in the registry",
 		},
 	)
+	failedEndpoints = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "external_dns",
+			Subsystem: "controller",
+			Name:      "failed_endpoints",
+			Help:      "Number of Endpoints whose changes failed in the last synchronization",
+		},
+	)
+	guardAbortsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "external_dns",
+			Subsystem: "controller",
+			Name:      "guard_aborts_total",
+			Help:      "Number of synchronizations aborted because they exceeded the change thresholds",
+		},
+	)
 )
 
 func init() {
@@ -63,6 +86,20 @@ func init() {
 	prometheus.MustRegister(sourceErrors)
 	prometheus.MustRegister(sourceEndpointsTotal)
 	prometheus.MustRegister(registryEndpointsTotal)
+	prometheus.MustRegister(failedEndpoints)
+	prometheus.MustRegister(guardAbortsTotal)
+}
+
+// RefusedChangesError is returned by RunOnce if the changes of a plan exceed the thresholds of the guard.
+type RefusedChangesError struct {
+	// Changes holds the refused changes of records owned by this instance
+	Changes *plan.Changes
+	// Err describes the exceeded threshold
+	Err error
+}
+
+func (e *RefusedChangesError) Error() string {
+	return fmt.Sprintf("refusing to apply changes: %v", e.Err)
 }
 
 // Controller is responsible for orchestrating the different components.
@@ -76,8 +113,17 @@ type Controller struct {
 	Registry registry.Registry
 	// The policy that defines which changes to DNS records are allowed
 	Policy plan.Policy
+	// The resolver that decides which resource acquires a DNS name requested by several resources
+	ConflictResolver plan.ConflictResolver
+	// The guard that refuses plans deleting or updating too many records, disabled if nil
+	Guard *plan.Guard
 	// The interval between individual synchronizations
 	Interval time.Duration
+	// The minimum interval between two synchronizations triggered by source events
+	MinEventSyncInterval time.Duration
+	// The time of the next synchronization, guarded by nextRunAtMux
+	nextRunAt    time.Time
+	nextRunAtMux sync.Mutex
 }
 
 // RunOnce runs a single iteration of a reconciliation loop.
@@ -97,14 +143,32 @@ func (c *Controller) RunOnce() error {
 	sourceEndpointsTotal.Set(float64(len(endpoints)))
 
 	plan := &plan.Plan{
-		Policies: []plan.Policy{c.Policy},
-		Current:  records,
-		Desired:  endpoints,
+		Policies:         []plan.Policy{c.Policy},
+		ConflictResolver: c.ConflictResolver,
+		Current:          records,
+		Desired:          endpoints,
 	}
 
 	plan = plan.Calculate()
 
+	if c.Guard != nil {
+		if err := c.Guard.Check(plan); err != nil {
+			guardAbortsTotal.Inc()
+			refused := c.ownedChanges(plan.Changes)
+			c.logRefusedChanges(refused)
+			return &RefusedChangesError{Changes: refused, Err: err}
+		}
+	}
+
 	err = c.Registry.ApplyChanges(plan.Changes)
+	if failed, ok := err.(*provider.FailedChangesError); ok {
+		for _, ep := range failed.Endpoints {
+			log.Errorf("Failed to apply the changes of %s", ep)
+		}
+		failedEndpoints.Set(float64(len(failed.Endpoints)))
+	} else {
+		failedEndpoints.Set(0)
+	}
 	if err != nil {
 		registryErrors.Inc()
 		return err
@@ -112,14 +176,72 @@ func (c *Controller) RunOnce() error {
 	return nil
 }
 
-// Run runs RunOnce in a loop with a delay until stopChan receives a value.
+// ownedChanges returns the changes of a plan the registry would pass on. Updates and deletes
+// of records not owned by this instance would be dropped by the registry and are skipped.
+func (c *Controller) ownedChanges(changes *plan.Changes) *plan.Changes {
+	owned := &plan.Changes{Create: changes.Create}
+	for i, ep := range changes.UpdateOld {
+		if ep.Labels[endpoint.OwnerLabelKey] == c.Guard.OwnerID {
+			owned.UpdateOld = append(owned.UpdateOld, ep)
+			owned.UpdateNew = append(owned.UpdateNew, changes.UpdateNew[i])
+		}
+	}
+	for _, ep := range changes.Delete {
+		if ep.Labels[endpoint.OwnerLabelKey] == c.Guard.OwnerID {
+			owned.Delete = append(owned.Delete, ep)
+		}
+	}
+	return owned
+}
+
+// logRefusedChanges logs the changes of a plan that exceeded the thresholds of the guard.
+func (c *Controller) logRefusedChanges(changes *plan.Changes) {
+	for _, ep := range changes.Create {
+		log.Warnf("Refused to create %s", ep)
+	}
+	for i, ep := range changes.UpdateOld {
+		log.Warnf("Refused to update %s to %s", ep, changes.UpdateNew[i])
+	}
+	for _, ep := range changes.Delete {
+		log.Warnf("Refused to delete %s", ep)
+	}
+}
+
+// ScheduleRunOnce schedules a synchronization MinEventSyncInterval from now
+// unless one is already due earlier. Events arriving in quick succession are
+// therefore batched into a single synchronization.
+func (c *Controller) ScheduleRunOnce(now time.Time) {
+	c.nextRunAtMux.Lock()
+	defer c.nextRunAtMux.Unlock()
+
+	if next := now.Add(c.MinEventSyncInterval); next.Before(c.nextRunAt) {
+		c.nextRunAt = next
+	}
+}
+
+// ShouldRunOnce reports whether a synchronization is due and, if so, schedules
+// the next regular one an Interval from now.
+func (c *Controller) ShouldRunOnce(now time.Time) bool {
+	c.nextRunAtMux.Lock()
+	defer c.nextRunAtMux.Unlock()
+
+	if now.Before(c.nextRunAt) {
+		return false
+	}
+	c.nextRunAt = now.Add(c.Interval)
+	return true
+}
+
+// Run runs RunOnce in a loop until stopChan receives a value. Synchronizations
+// happen every Interval and additionally whenever ScheduleRunOnce was called.
 func (c *Controller) Run(stopChan <-chan struct{}) {
-	ticker := time.NewTicker(c.Interval)
+	ticker := time.NewTicker(runCheckInterval)
 	defer ticker.Stop()
 	for {
-		err := c.RunOnce()
-		if err != nil {
-			log.Error(err)
+		if c.ShouldRunOnce(time.Now()) {
+			if err := c.RunOnce(); err != nil {
+				log.Error(err)
+			}
 		}
 		select {
 		case <-ticker.C:
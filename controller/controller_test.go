@@ -19,6 +19,7 @@ package controller
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 	"github.com/kubernetes-incubator/external-dns/internal/testutils"
@@ -146,3 +147,88 @@ func TestRunOnce(t *testing.T) {
 	// Validate that the mock source was called.
 	source.AssertExpectations(t)
 }
+
+// TestRunOnceGuard tests that RunOnce doesn't apply changes exceeding the thresholds of the guard.
+func TestRunOnceGuard(t *testing.T) {
+	// Fake a source that lost all its endpoints.
+	source := new(testutils.MockSource)
+	source.On("Endpoints").Return([]*endpoint.Endpoint{}, nil)
+
+	// Fake some existing records, the provider fails the test if any change is applied.
+	provider := newMockProvider(
+		[]*endpoint.Endpoint{
+			{
+				DNSName:    "delete-record",
+				RecordType: endpoint.RecordTypeA,
+				Targets:    endpoint.Targets{"4.3.2.1"},
+			},
+			{
+				DNSName:    "another-delete-record",
+				RecordType: endpoint.RecordTypeA,
+				Targets:    endpoint.Targets{"1.2.3.4"},
+			},
+		},
+		&plan.Changes{},
+	)
+
+	r, err := registry.NewNoopRegistry(provider)
+	require.NoError(t, err)
+
+	ctrl := &Controller{
+		Source:   source,
+		Registry: r,
+		Policy:   &plan.SyncPolicy{},
+		Guard:    &plan.Guard{MaxDeletesPercent: 50},
+	}
+
+	err = ctrl.RunOnce()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to apply changes")
+
+	refused, ok := err.(*RefusedChangesError)
+	require.True(t, ok)
+	assert.Len(t, refused.Changes.Delete, 2)
+	assert.Empty(t, refused.Changes.Create)
+
+	source.AssertExpectations(t)
+}
+
+// TestShouldRunOnce tests that synchronizations happen every interval and that
+// scheduled ones are batched and rate limited.
+func TestShouldRunOnce(t *testing.T) {
+	ctrl := &Controller{Interval: 10 * time.Minute, MinEventSyncInterval: 5 * time.Second}
+
+	now := time.Now()
+
+	// First run of Run loop should execute RunOnce
+	assert.True(t, ctrl.ShouldRunOnce(now))
+
+	// Second run should not
+	assert.False(t, ctrl.ShouldRunOnce(now))
+
+	now = now.Add(10 * time.Second)
+	// Changes happen in ingresses or services
+	ctrl.ScheduleRunOnce(now)
+	ctrl.ScheduleRunOnce(now)
+
+	// Because we batch changes, ShouldRunOnce returns False at first
+	assert.False(t, ctrl.ShouldRunOnce(now))
+	assert.False(t, ctrl.ShouldRunOnce(now.Add(100*time.Microsecond)))
+
+	// But after MinEventSyncInterval we should trigger the reconciliation
+	now = now.Add(5 * time.Second)
+	assert.True(t, ctrl.ShouldRunOnce(now))
+
+	// But just one time
+	assert.False(t, ctrl.ShouldRunOnce(now))
+
+	// Events arriving continuously don't postpone a scheduled run
+	ctrl.ScheduleRunOnce(now)
+	ctrl.ScheduleRunOnce(now.Add(3 * time.Second))
+	assert.True(t, ctrl.ShouldRunOnce(now.Add(5*time.Second)))
+
+	// We should wait the whole interval if nothing happens
+	now = now.Add(5 * time.Second)
+	assert.False(t, ctrl.ShouldRunOnce(now.Add(10*time.Minute-time.Second)))
+	assert.True(t, ctrl.ShouldRunOnce(now.Add(10*time.Minute)))
+}
@@ -27,12 +27,16 @@ import (
 const (
 	// RecordTypeA is a RecordType enum value
 	RecordTypeA = "A"
+	// RecordTypeAAAA is a RecordType enum value
+	RecordTypeAAAA = "AAAA"
 	// RecordTypeCNAME is a RecordType enum value
 	RecordTypeCNAME = "CNAME"
 	// RecordTypeTXT is a RecordType enum value
 	RecordTypeTXT = "TXT"
 	// RecordTypeSRV is a RecordType enum value
 	RecordTypeSRV = "SRV"
+	// RecordTypeMX is a RecordType enum value
+	RecordTypeMX = "MX"
 )
 
 // TTL is a structure defining the TTL of a DNS record
@@ -122,6 +126,10 @@ type Endpoint struct {
 	RecordType string `json:"recordType,omitempty"`
 	// TTL for the record
 	RecordTTL TTL `json:"recordTTL,omitempty"`
+	// Identifier to distinguish multiple records with the same name and type,
+	// e.g. Route53 records with a routing policy other than simple
+	// +optional
+	SetIdentifier string `json:"setIdentifier,omitempty"`
 	// Labels stores labels defined for the Endpoint
 	// +optional
 	Labels Labels `json:"labels,omitempty"`
@@ -164,6 +172,12 @@ func (e *Endpoint) WithProviderSpecific(key, value string) *Endpoint {
 	return e
 }
 
+// WithSetIdentifier applies the given set identifier to the endpoint.
+func (e *Endpoint) WithSetIdentifier(setIdentifier string) *Endpoint {
+	e.SetIdentifier = setIdentifier
+	return e
+}
+
 func (e *Endpoint) String() string {
 	return fmt.Sprintf("%s %d IN %s %s %s", e.DNSName, e.RecordTTL, e.RecordType, e.Targets, e.ProviderSpecific)
 }
@@ -34,6 +34,12 @@ const (
 	OwnerLabelKey = "owner"
 	// ResourceLabelKey is the name of the label that identifies k8s resource which wants to acquire the DNS name
 	ResourceLabelKey = "resource"
+	// CreationTimestampLabelKey is the name of the label that stores when the k8s resource was created, formatted as RFC 3339.
+	// It's only used to resolve conflicts between desired records and isn't serialized.
+	CreationTimestampLabelKey = "creation-timestamp"
+	// OwnedRecordLabelKey is the name of the label that a registry's TXT record carries with the name of the
+	// record it holds the ownership of. It isn't persisted, providers use it to change both records together.
+	OwnedRecordLabelKey = "owned-record"
 
 	// AWSSDDescriptionLabel label responsible for storing raw owner/resource combination information in the Labels
 	// supposed to be inserted by AWS SD Provider, and parsed into OwnerLabelKey and ResourceLabelKey key by AWS SD Registry
@@ -89,6 +95,9 @@ func (l Labels) Serialize(withQuotes bool) string {
 	tokens = append(tokens, fmt.Sprintf("heritage=%s", heritage))
 	var keys []string
 	for key := range l {
+		if key == CreationTimestampLabelKey {
+			continue
+		}
 		keys = append(keys, key)
 	}
 	sort.Strings(keys) // sort for consistency
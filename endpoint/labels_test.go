@@ -59,6 +59,12 @@ func (suite *LabelsSuite) SetupTest() {
 func (suite *LabelsSuite) TestSerialize() {
 	suite.Equal(suite.fooAsText, suite.foo.Serialize(false), "should serializeLabel")
 	suite.Equal(suite.fooAsTextWithQuotes, suite.foo.Serialize(true), "should serializeLabel")
+
+	withTimestamp := Labels{CreationTimestampLabelKey: "2018-10-01T12:30:00Z"}
+	for key, value := range suite.foo {
+		withTimestamp[key] = value
+	}
+	suite.Equal(suite.fooAsText, withTimestamp.Serialize(false), "should leave out the creation timestamp")
 }
 
 func (suite *LabelsSuite) TestDeserialize() {
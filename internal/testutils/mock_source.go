@@ -38,3 +38,8 @@ func (m *MockSource) Endpoints() ([]*endpoint.Endpoint, error) {
 
 	return endpoints.([]*endpoint.Endpoint), args.Error(1)
 }
+
+// AddEventHandler records the call, the handler is never invoked.
+func (m *MockSource) AddEventHandler(handler func()) {
+	m.Called(handler)
+}
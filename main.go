@@ -21,6 +21,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
@@ -83,12 +84,14 @@ func main() {
 		IstioIngressGateway:      cfg.IstioIngressGateway,
 	}
 
-	// Lookup all the selected sources by names and pass them the desired configuration.
-	sources, err := source.ByNames(&source.SingletonClientGenerator{
+	clientGenerator := &source.SingletonClientGenerator{
 		KubeConfig:     cfg.KubeConfig,
 		KubeMaster:     cfg.Master,
 		RequestTimeout: cfg.RequestTimeout,
-	}, cfg.Sources, sourceCfg)
+	}
+
+	// Lookup all the selected sources by names and pass them the desired configuration.
+	sources, err := source.ByNames(clientGenerator, cfg.Sources, sourceCfg)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -115,8 +118,12 @@ func main() {
 				BatchChangeSize:      cfg.AWSBatchChangeSize,
 				BatchChangeInterval:  cfg.AWSBatchChangeInterval,
 				EvaluateTargetHealth: cfg.AWSEvaluateTargetHealth,
+				Dualstack:            cfg.AWSDualstack,
 				AssumeRole:           cfg.AWSAssumeRole,
+				ZoneRoles:            cfg.AWSZoneRoles,
 				APIRetries:           cfg.AWSAPIRetries,
+				ManageHealthChecks:   cfg.AWSHealthChecks,
+				OwnerID:              cfg.TXTOwnerID,
 				DryRun:               cfg.DryRun,
 			},
 		)
@@ -129,10 +136,12 @@ func main() {
 		p, err = provider.NewAWSSDProvider(domainFilter, cfg.AWSZoneType, cfg.AWSAssumeRole, cfg.DryRun)
 	case "azure":
 		p, err = provider.NewAzureProvider(cfg.AzureConfigFile, domainFilter, zoneIDFilter, cfg.AzureResourceGroup, cfg.DryRun)
+	case "azure-private-dns":
+		p, err = provider.NewAzurePrivateDNSProvider(cfg.AzureConfigFile, domainFilter, zoneIDFilter, cfg.AzureResourceGroup, cfg.DryRun)
 	case "cloudflare":
 		p, err = provider.NewCloudFlareProvider(domainFilter, zoneIDFilter, cfg.CloudflareProxied, cfg.DryRun)
 	case "google":
-		p, err = provider.NewGoogleProvider(cfg.GoogleProject, domainFilter, zoneIDFilter, cfg.DryRun)
+		p, err = provider.NewGoogleProvider(cfg.GoogleProject, domainFilter, zoneIDFilter, cfg.GoogleZoneVisibility, cfg.GoogleBatchChangeSize, cfg.GoogleBatchChangeInterval, cfg.DryRun)
 	case "digitalocean":
 		p, err = provider.NewDigitalOceanProvider(domainFilter, cfg.DryRun)
 	case "linode":
@@ -167,11 +176,16 @@ func main() {
 			},
 		)
 	case "coredns", "skydns":
-		p, err = provider.NewCoreDNSProvider(domainFilter, cfg.DryRun)
+		p, err = provider.NewCoreDNSProvider(domainFilter, cfg.CoreDNSPrefix, cfg.DryRun)
 	case "exoscale":
 		p, err = provider.NewExoscaleProvider(cfg.ExoscaleEndpoint, cfg.ExoscaleAPIKey, cfg.ExoscaleAPISecret, cfg.DryRun, provider.ExoscaleWithDomain(domainFilter), provider.ExoscaleWithLogging()), nil
 	case "inmemory":
-		p, err = provider.NewInMemoryProvider(provider.InMemoryInitZones(cfg.InMemoryZones), provider.InMemoryWithDomain(domainFilter), provider.InMemoryWithLogging()), nil
+		// the state file is set last, so that the pre-configured zones don't overwrite it before it's loaded
+		im := provider.NewInMemoryProvider(provider.InMemoryInitZones(cfg.InMemoryZones), provider.InMemoryWithDomain(domainFilter), provider.InMemoryWithLogging(), provider.InMemoryWithStateFile(cfg.InMemoryStateFile))
+		p, err = im, im.LoadState()
+		if err == nil && cfg.InMemoryDNSAddress != "" {
+			err = provider.NewInMemoryDNSServer(im, cfg.InMemoryDNSAddress).Start()
+		}
 	case "designate":
 		p, err = provider.NewDesignateProvider(domainFilter, cfg.DryRun)
 	case "pdns":
@@ -195,8 +209,30 @@ func main() {
 		if err == nil {
 			p, err = provider.NewOCIProvider(*config, domainFilter, zoneIDFilter, cfg.DryRun)
 		}
+	case "webhook":
+		p, err = provider.NewWebhookProvider(
+			provider.WebhookConfig{
+				URL:                   cfg.WebhookURL,
+				DomainFilter:          domainFilter,
+				DryRun:                cfg.DryRun,
+				Timeout:               cfg.WebhookTimeout,
+				Retries:               cfg.WebhookRetries,
+				CAFilePath:            cfg.TLSCA,
+				ClientCertFilePath:    cfg.TLSClientCert,
+				ClientCertKeyFilePath: cfg.TLSClientCertKey,
+			},
+		)
+	case "zonefile":
+		p, err = provider.NewZonefileProvider(
+			provider.ZonefileConfig{
+				Directory:     cfg.ZonefileDirectory,
+				DomainFilter:  domainFilter,
+				DryRun:        cfg.DryRun,
+				ReloadCommand: cfg.ZonefileReloadCommand,
+			},
+		)
 	case "rfc2136":
-		p, err = provider.NewRfc2136Provider(cfg.RFC2136Host, cfg.RFC2136Port, cfg.RFC2136Zone, cfg.RFC2136Insecure, cfg.RFC2136TSIGKeyName, cfg.RFC2136TSIGSecret, cfg.RFC2136TSIGSecretAlg, cfg.RFC2136TAXFR, domainFilter, cfg.DryRun, nil)
+		p, err = provider.NewRfc2136Provider(cfg.RFC2136Host, cfg.RFC2136Port, cfg.RFC2136Zones, cfg.RFC2136Insecure, cfg.RFC2136TSIGKeyName, cfg.RFC2136TSIGSecret, cfg.RFC2136TSIGSecretAlg, cfg.RFC2136ZoneTSIGKeys, cfg.RFC2136TAXFR, domainFilter, cfg.DryRun, nil)
 	default:
 		log.Fatalf("unknown dns provider: %s", cfg.Provider)
 	}
@@ -204,14 +240,37 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Desired records carry the defaults of the provider, so that current records deviating from them are updated.
+	if defaulter, ok := p.(provider.ProviderSpecificDefaulter); ok {
+		endpointsSource = source.NewProviderSpecificSource(endpointsSource, defaulter.ProviderSpecificDefaults())
+	}
+
+	// The plan command records the changes the registry would send to the provider instead of applying them.
+	var recorder *provider.RecordingProvider
+	if cfg.Command == "plan" {
+		recorder = provider.NewRecordingProvider(p)
+		p = recorder
+	}
+
+	policy, exists := plan.Policies[cfg.Policy]
+	if !exists {
+		log.Fatalf("unknown policy: %s", cfg.Policy)
+	}
+
 	var r registry.Registry
 	switch cfg.Registry {
 	case "noop":
 		r, err = registry.NewNoopRegistry(p)
 	case "txt":
-		r, err = registry.NewTXTRegistry(p, cfg.TXTPrefix, cfg.TXTOwnerID, cfg.TXTCacheInterval)
+		r, err = registry.NewTXTRegistry(p, cfg.TXTPrefix, cfg.TXTSuffix, cfg.TXTNameTemplate, cfg.TXTOwnerID, cfg.TXTCacheInterval, cfg.TXTFormat, cfg.DomainFilter, policy)
 	case "aws-sd":
 		r, err = registry.NewAWSSDRegistry(p.(*provider.AWSSDProvider), cfg.TXTOwnerID)
+	case "configmap":
+		kubeClient, kubeErr := clientGenerator.KubeClient()
+		if kubeErr != nil {
+			log.Fatal(kubeErr)
+		}
+		r, err = registry.NewConfigMapRegistry(p, kubeClient, cfg.ConfigMapNamespace, cfg.ConfigMapName, cfg.TXTOwnerID, cfg.TXTCacheInterval, cfg.DryRun || cfg.Command == "plan", policy)
 	default:
 		log.Fatalf("unknown registry: %s", cfg.Registry)
 	}
@@ -220,16 +279,59 @@ func main() {
 		log.Fatal(err)
 	}
 
-	policy, exists := plan.Policies[cfg.Policy]
+	resolver, exists := plan.ConflictResolvers[cfg.ConflictResolver]
 	if !exists {
-		log.Fatalf("unknown policy: %s", cfg.Policy)
+		log.Fatalf("unknown conflict resolver: %s", cfg.ConflictResolver)
+	}
+
+	var guard *plan.Guard
+	if cfg.IgnoreChangeThresholds {
+		log.Warn("Change thresholds are ignored, all changes will be applied")
+	} else {
+		guard = &plan.Guard{
+			OwnerID:           cfg.TXTOwnerID,
+			MaxDeletes:        cfg.MaxDeletes,
+			MaxDeletesPercent: cfg.MaxDeletesPercent,
+			MaxUpdates:        cfg.MaxUpdates,
+			MaxUpdatesPercent: cfg.MaxUpdatesPercent,
+		}
+		// the noop registry doesn't track ownership, all records count as owned
+		if cfg.Registry == "noop" {
+			guard.OwnerID = ""
+		}
 	}
 
 	ctrl := controller.Controller{
-		Source:   endpointsSource,
-		Registry: r,
-		Policy:   policy,
-		Interval: cfg.Interval,
+		Source:               endpointsSource,
+		Registry:             r,
+		Policy:               policy,
+		ConflictResolver:     resolver,
+		Guard:                guard,
+		Interval:             cfg.Interval,
+		MinEventSyncInterval: cfg.MinEventSyncInterval,
+	}
+
+	if cfg.Command == "plan" {
+		if err := ctrl.RunOnce(); err != nil {
+			refused, ok := err.(*controller.RefusedChangesError)
+			if !ok {
+				log.Fatal(err)
+			}
+			// print the refused changes, the guard fails before the registry passes them on
+			if err := plan.WriteChanges(os.Stdout, refused.Changes, cfg.Output); err != nil {
+				log.Fatal(err)
+			}
+			log.Error(refused)
+			os.Exit(3)
+		}
+		if err := plan.WriteChanges(os.Stdout, recorder.Changes, cfg.Output); err != nil {
+			log.Fatal(err)
+		}
+		if !recorder.Changes.IsEmpty() {
+			os.Exit(2)
+		}
+
+		os.Exit(0)
 	}
 
 	if cfg.Once {
@@ -240,6 +342,15 @@ func main() {
 
 		os.Exit(0)
 	}
+
+	if cfg.UpdateEvents {
+		// Trigger a synchronization whenever the resources of a source change,
+		// the regular interval still applies as a fallback.
+		ctrl.Source.AddEventHandler(func() {
+			ctrl.ScheduleRunOnce(time.Now())
+		})
+	}
+
 	ctrl.Run(stopChan)
 }
 
@@ -36,147 +36,207 @@ var (
 
 // Config is a project-wide configuration
 type Config struct {
-	Master                   string
-	KubeConfig               string
-	RequestTimeout           time.Duration
-	IstioIngressGateway      string
-	Sources                  []string
-	Namespace                string
-	AnnotationFilter         string
-	FQDNTemplate             string
-	CombineFQDNAndAnnotation bool
-	Compatibility            string
-	PublishInternal          bool
-	PublishHostIP            bool
-	ConnectorSourceServer    string
-	Provider                 string
-	GoogleProject            string
-	DomainFilter             []string
-	ZoneIDFilter             []string
-	AlibabaCloudConfigFile   string
-	AlibabaCloudZoneType     string
-	AWSZoneType              string
-	AWSZoneTagFilter         []string
-	AWSAssumeRole            string
-	AWSBatchChangeSize       int
-	AWSBatchChangeInterval   time.Duration
-	AWSEvaluateTargetHealth  bool
-	AWSAPIRetries            int
-	AzureConfigFile          string
-	AzureResourceGroup       string
-	CloudflareProxied        bool
-	InfobloxGridHost         string
-	InfobloxWapiPort         int
-	InfobloxWapiUsername     string
-	InfobloxWapiPassword     string
-	InfobloxWapiVersion      string
-	InfobloxSSLVerify        bool
-	DynCustomerName          string
-	DynUsername              string
-	DynPassword              string
-	DynMinTTLSeconds         int
-	OCIConfigFile            string
-	InMemoryZones            []string
-	PDNSServer               string
-	PDNSAPIKey               string
-	PDNSTLSEnabled           bool
-	TLSCA                    string
-	TLSClientCert            string
-	TLSClientCertKey         string
-	Policy                   string
-	Registry                 string
-	TXTOwnerID               string
-	TXTPrefix                string
-	Interval                 time.Duration
-	Once                     bool
-	DryRun                   bool
-	LogFormat                string
-	MetricsAddress           string
-	LogLevel                 string
-	TXTCacheInterval         time.Duration
-	ExoscaleEndpoint         string
-	ExoscaleAPIKey           string
-	ExoscaleAPISecret        string
-	CRDSourceAPIVersion      string
-	CRDSourceKind            string
-	ServiceTypeFilter        []string
-	RFC2136Host              string
-	RFC2136Port              int
-	RFC2136Zone              string
-	RFC2136Insecure          bool
-	RFC2136TSIGKeyName       string
-	RFC2136TSIGSecret        string
-	RFC2136TSIGSecretAlg     string
-	RFC2136TAXFR             bool
+	Command                   string
+	Master                    string
+	KubeConfig                string
+	RequestTimeout            time.Duration
+	IstioIngressGateway       string
+	Sources                   []string
+	Namespace                 string
+	AnnotationFilter          string
+	FQDNTemplate              string
+	CombineFQDNAndAnnotation  bool
+	Compatibility             string
+	PublishInternal           bool
+	PublishHostIP             bool
+	ConnectorSourceServer     string
+	Provider                  string
+	GoogleProject             string
+	GoogleZoneVisibility      string
+	GoogleBatchChangeSize     int
+	GoogleBatchChangeInterval time.Duration
+	DomainFilter              []string
+	ZoneIDFilter              []string
+	AlibabaCloudConfigFile    string
+	AlibabaCloudZoneType      string
+	AWSZoneType               string
+	AWSZoneTagFilter          []string
+	AWSAssumeRole             string
+	AWSZoneRoles              []string
+	AWSBatchChangeSize        int
+	AWSBatchChangeInterval    time.Duration
+	AWSEvaluateTargetHealth   bool
+	AWSDualstack              bool
+	AWSAPIRetries             int
+	AWSHealthChecks           bool
+	AzureConfigFile           string
+	AzureResourceGroup        string
+	CloudflareProxied         bool
+	InfobloxGridHost          string
+	InfobloxWapiPort          int
+	InfobloxWapiUsername      string
+	InfobloxWapiPassword      string
+	InfobloxWapiVersion       string
+	InfobloxSSLVerify         bool
+	DynCustomerName           string
+	DynUsername               string
+	DynPassword               string
+	DynMinTTLSeconds          int
+	OCIConfigFile             string
+	InMemoryZones             []string
+	InMemoryStateFile         string
+	InMemoryDNSAddress        string
+	CoreDNSPrefix             string
+	WebhookURL                string
+	WebhookTimeout            time.Duration
+	WebhookRetries            int
+	ZonefileDirectory         string
+	ZonefileReloadCommand     string
+	PDNSServer                string
+	PDNSAPIKey                string
+	PDNSTLSEnabled            bool
+	TLSCA                     string
+	TLSClientCert             string
+	TLSClientCertKey          string
+	Policy                    string
+	ConflictResolver          string
+	MaxDeletes                int
+	MaxDeletesPercent         float64
+	MaxUpdates                int
+	MaxUpdatesPercent         float64
+	IgnoreChangeThresholds    bool
+	Registry                  string
+	TXTOwnerID                string
+	TXTPrefix                 string
+	TXTSuffix                 string
+	TXTNameTemplate           string
+	TXTFormat                 string
+	ConfigMapNamespace        string
+	ConfigMapName             string
+	Interval                  time.Duration
+	MinEventSyncInterval      time.Duration
+	Once                      bool
+	UpdateEvents              bool
+	DryRun                    bool
+	Output                    string
+	LogFormat                 string
+	MetricsAddress            string
+	LogLevel                  string
+	TXTCacheInterval          time.Duration
+	ExoscaleEndpoint          string
+	ExoscaleAPIKey            string
+	ExoscaleAPISecret         string
+	CRDSourceAPIVersion       string
+	CRDSourceKind             string
+	ServiceTypeFilter         []string
+	RFC2136Host               string
+	RFC2136Port               int
+	RFC2136Zones              []string
+	RFC2136Insecure           bool
+	RFC2136TSIGKeyName        string
+	RFC2136TSIGSecret         string
+	RFC2136TSIGSecretAlg      string
+	RFC2136ZoneTSIGKeys       []string
+	RFC2136TAXFR              bool
 }
 
 var defaultConfig = &Config{
-	Master:                   "",
-	KubeConfig:               "",
-	RequestTimeout:           time.Second * 30,
-	IstioIngressGateway:      "istio-system/istio-ingressgateway",
-	Sources:                  nil,
-	Namespace:                "",
-	AnnotationFilter:         "",
-	FQDNTemplate:             "",
-	CombineFQDNAndAnnotation: false,
-	Compatibility:            "",
-	PublishInternal:          false,
-	PublishHostIP:            false,
-	ConnectorSourceServer:    "localhost:8080",
-	Provider:                 "",
-	GoogleProject:            "",
-	DomainFilter:             []string{},
-	AlibabaCloudConfigFile:   "/etc/kubernetes/alibaba-cloud.json",
-	AWSZoneType:              "",
-	AWSZoneTagFilter:         []string{},
-	AWSAssumeRole:            "",
-	AWSBatchChangeSize:       1000,
-	AWSBatchChangeInterval:   time.Second,
-	AWSEvaluateTargetHealth:  true,
-	AWSAPIRetries:            3,
-	AzureConfigFile:          "/etc/kubernetes/azure.json",
-	AzureResourceGroup:       "",
-	CloudflareProxied:        false,
-	InfobloxGridHost:         "",
-	InfobloxWapiPort:         443,
-	InfobloxWapiUsername:     "admin",
-	InfobloxWapiPassword:     "",
-	InfobloxWapiVersion:      "2.3.1",
-	InfobloxSSLVerify:        true,
-	OCIConfigFile:            "/etc/kubernetes/oci.yaml",
-	InMemoryZones:            []string{},
-	PDNSServer:               "http://localhost:8081",
-	PDNSAPIKey:               "",
-	PDNSTLSEnabled:           false,
-	TLSCA:                    "",
-	TLSClientCert:            "",
-	TLSClientCertKey:         "",
-	Policy:                   "sync",
-	Registry:                 "txt",
-	TXTOwnerID:               "default",
-	TXTPrefix:                "",
-	TXTCacheInterval:         0,
-	Interval:                 time.Minute,
-	Once:                     false,
-	DryRun:                   false,
-	LogFormat:                "text",
-	MetricsAddress:           ":7979",
-	LogLevel:                 logrus.InfoLevel.String(),
-	ExoscaleEndpoint:         "https://api.exoscale.ch/dns",
-	ExoscaleAPIKey:           "",
-	ExoscaleAPISecret:        "",
-	CRDSourceAPIVersion:      "externaldns.k8s.io/v1alpha1",
-	CRDSourceKind:            "DNSEndpoint",
-	ServiceTypeFilter:        []string{},
-	RFC2136Host:              "",
-	RFC2136Port:              0,
-	RFC2136Zone:              "",
-	RFC2136Insecure:          false,
-	RFC2136TSIGKeyName:       "",
-	RFC2136TSIGSecret:        "",
-	RFC2136TSIGSecretAlg:     "",
-	RFC2136TAXFR:             true,
+	Command:                   "controller",
+	Master:                    "",
+	KubeConfig:                "",
+	RequestTimeout:            time.Second * 30,
+	IstioIngressGateway:       "istio-system/istio-ingressgateway",
+	Sources:                   nil,
+	Namespace:                 "",
+	AnnotationFilter:          "",
+	FQDNTemplate:              "",
+	CombineFQDNAndAnnotation:  false,
+	Compatibility:             "",
+	PublishInternal:           false,
+	PublishHostIP:             false,
+	ConnectorSourceServer:     "localhost:8080",
+	Provider:                  "",
+	GoogleProject:             "",
+	GoogleZoneVisibility:      "",
+	GoogleBatchChangeSize:     1000,
+	GoogleBatchChangeInterval: time.Second,
+	DomainFilter:              []string{},
+	AlibabaCloudConfigFile:    "/etc/kubernetes/alibaba-cloud.json",
+	AWSZoneType:               "",
+	AWSZoneTagFilter:          []string{},
+	AWSAssumeRole:             "",
+	AWSZoneRoles:              []string{},
+	AWSBatchChangeSize:        1000,
+	AWSBatchChangeInterval:    time.Second,
+	AWSEvaluateTargetHealth:   true,
+	AWSDualstack:              false,
+	AWSAPIRetries:             3,
+	AWSHealthChecks:           false,
+	AzureConfigFile:           "/etc/kubernetes/azure.json",
+	AzureResourceGroup:        "",
+	CloudflareProxied:         false,
+	InfobloxGridHost:          "",
+	InfobloxWapiPort:          443,
+	InfobloxWapiUsername:      "admin",
+	InfobloxWapiPassword:      "",
+	InfobloxWapiVersion:       "2.3.1",
+	InfobloxSSLVerify:         true,
+	OCIConfigFile:             "/etc/kubernetes/oci.yaml",
+	InMemoryZones:             []string{},
+	InMemoryStateFile:         "",
+	InMemoryDNSAddress:        "",
+	CoreDNSPrefix:             "/skydns/",
+	WebhookURL:                "",
+	WebhookTimeout:            10 * time.Second,
+	WebhookRetries:            3,
+	ZonefileDirectory:         "",
+	ZonefileReloadCommand:     "",
+	PDNSServer:                "http://localhost:8081",
+	PDNSAPIKey:                "",
+	PDNSTLSEnabled:            false,
+	TLSCA:                     "",
+	TLSClientCert:             "",
+	TLSClientCertKey:          "",
+	Policy:                    "sync",
+	ConflictResolver:          "per-resource",
+	MaxDeletes:                0,
+	MaxDeletesPercent:         0,
+	MaxUpdates:                0,
+	MaxUpdatesPercent:         0,
+	IgnoreChangeThresholds:    false,
+	Registry:                  "txt",
+	TXTOwnerID:                "default",
+	TXTPrefix:                 "",
+	TXTSuffix:                 "",
+	TXTNameTemplate:           "",
+	TXTFormat:                 "legacy",
+	ConfigMapNamespace:        "default",
+	ConfigMapName:             "external-dns-ownership",
+	TXTCacheInterval:          0,
+	Interval:                  time.Minute,
+	MinEventSyncInterval:      5 * time.Second,
+	Once:                      false,
+	UpdateEvents:              false,
+	DryRun:                    false,
+	Output:                    "table",
+	LogFormat:                 "text",
+	MetricsAddress:            ":7979",
+	LogLevel:                  logrus.InfoLevel.String(),
+	ExoscaleEndpoint:          "https://api.exoscale.ch/dns",
+	ExoscaleAPIKey:            "",
+	ExoscaleAPISecret:         "",
+	CRDSourceAPIVersion:       "externaldns.k8s.io/v1alpha1",
+	CRDSourceKind:             "DNSEndpoint",
+	ServiceTypeFilter:         []string{},
+	RFC2136Host:               "",
+	RFC2136Port:               0,
+	RFC2136Zones:              []string{},
+	RFC2136Insecure:           false,
+	RFC2136TSIGKeyName:        "",
+	RFC2136TSIGSecret:         "",
+	RFC2136TSIGSecretAlg:      "",
+	RFC2136ZoneTSIGKeys:       []string{},
+	RFC2136TAXFR:              true,
 }
 
 // NewConfig returns new Config object
@@ -196,6 +256,9 @@ func (cfg *Config) String() string {
 	if temp.PDNSAPIKey != "" {
 		temp.PDNSAPIKey = ""
 	}
+	if len(temp.RFC2136ZoneTSIGKeys) > 0 {
+		temp.RFC2136ZoneTSIGKeys = []string{passwordMask}
+	}
 
 	return fmt.Sprintf("%+v", temp)
 }
@@ -215,6 +278,10 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Version(Version)
 	app.DefaultEnvars()
 
+	// Commands
+	app.Command("controller", "Synchronizes DNS records continuously (default)").Default()
+	app.Command("plan", "Prints the changes a single synchronization would apply without applying them, exits with status 2 if changes are pending and 3 if they exceed the change thresholds")
+
 	// Flags related to Kubernetes
 	app.Flag("master", "The Kubernetes API server to connect to (default: auto-detect)").Default(defaultConfig.Master).StringVar(&cfg.Master)
 	app.Flag("kubeconfig", "Retrieve target cluster configuration from a Kubernetes configuration file (default: auto-detect)").Default(defaultConfig.KubeConfig).StringVar(&cfg.KubeConfig)
@@ -238,22 +305,28 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("service-type-filter", "The service types to take care about (default: all, expected: ClusterIP, NodePort, LoadBalancer or ExternalName)").StringsVar(&cfg.ServiceTypeFilter)
 
 	// Flags related to providers
-	app.Flag("provider", "The DNS provider where the DNS records will be created (required, options: aws, aws-sd, google, azure, cloudflare, digitalocean, dnsimple, infoblox, dyn, designate, coredns, skydns, inmemory, pdns, oci, exoscale, linode, rfc2136)").Required().PlaceHolder("provider").EnumVar(&cfg.Provider, "aws", "aws-sd", "google", "azure", "alibabacloud", "cloudflare", "digitalocean", "dnsimple", "infoblox", "dyn", "designate", "coredns", "skydns", "inmemory", "pdns", "oci", "exoscale", "linode", "rfc2136")
+	app.Flag("provider", "The DNS provider where the DNS records will be created (required, options: aws, aws-sd, google, azure, azure-private-dns, cloudflare, digitalocean, dnsimple, infoblox, dyn, designate, coredns, skydns, inmemory, pdns, oci, exoscale, linode, rfc2136, webhook, zonefile)").Required().PlaceHolder("provider").EnumVar(&cfg.Provider, "aws", "aws-sd", "google", "azure", "azure-private-dns", "alibabacloud", "cloudflare", "digitalocean", "dnsimple", "infoblox", "dyn", "designate", "coredns", "skydns", "inmemory", "pdns", "oci", "exoscale", "linode", "rfc2136", "webhook", "zonefile")
 	app.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains (optional)").Default("").StringsVar(&cfg.DomainFilter)
 	app.Flag("zone-id-filter", "Filter target zones by hosted zone id; specify multiple times for multiple zones (optional)").Default("").StringsVar(&cfg.ZoneIDFilter)
 	app.Flag("google-project", "When using the Google provider, current project is auto-detected, when running on GCP. Specify other project with this. Must be specified when running outside GCP.").Default(defaultConfig.GoogleProject).StringVar(&cfg.GoogleProject)
+	app.Flag("google-zone-visibility", "When using the Google provider, filter for zones of this visibility (optional, options: public, private)").Default(defaultConfig.GoogleZoneVisibility).EnumVar(&cfg.GoogleZoneVisibility, "", "public", "private")
+	app.Flag("google-batch-change-size", "When using the Google provider, set the maximum number of additions and deletions that will be applied in each change.").Default(strconv.Itoa(defaultConfig.GoogleBatchChangeSize)).IntVar(&cfg.GoogleBatchChangeSize)
+	app.Flag("google-batch-change-interval", "When using the Google provider, set the interval between changes.").Default(defaultConfig.GoogleBatchChangeInterval.String()).DurationVar(&cfg.GoogleBatchChangeInterval)
 	app.Flag("alibaba-cloud-config-file", "When using the Alibaba Cloud provider, specify the Alibaba Cloud configuration file (required when --provider=alibabacloud").Default(defaultConfig.AlibabaCloudConfigFile).StringVar(&cfg.AlibabaCloudConfigFile)
 	app.Flag("alibaba-cloud-zone-type", "When using the Alibaba Cloud provider, filter for zones of this type (optional, options: public, private)").Default(defaultConfig.AlibabaCloudZoneType).EnumVar(&cfg.AlibabaCloudZoneType, "", "public", "private")
 	app.Flag("aws-zone-type", "When using the AWS provider, filter for zones of this type (optional, options: public, private)").Default(defaultConfig.AWSZoneType).EnumVar(&cfg.AWSZoneType, "", "public", "private")
 	app.Flag("aws-zone-tags", "When using the AWS provider, filter for zones with these tags").Default("").StringsVar(&cfg.AWSZoneTagFilter)
 	app.Flag("aws-assume-role", "When using the AWS provider, assume this IAM role. Useful for hosted zones in another AWS account. Specify the full ARN, e.g. `arn:aws:iam::123455567:role/external-dns` (optional)").Default(defaultConfig.AWSAssumeRole).StringVar(&cfg.AWSAssumeRole)
+	app.Flag("aws-zone-role", "When using the AWS provider, assume this IAM role for the hosted zones matching the given domains or zone ids. Useful for hosted zones spread across AWS accounts, zones not matching any role are managed with the default credentials, e.g. `arn:aws:iam::123455567:role/external-dns=example.org,Z2ABCDEFGHIJKL` (optional, specify multiple times for multiple roles)").Default("").StringsVar(&cfg.AWSZoneRoles)
 	app.Flag("aws-batch-change-size", "When using the AWS provider, set the maximum number of changes that will be applied in each batch.").Default(strconv.Itoa(defaultConfig.AWSBatchChangeSize)).IntVar(&cfg.AWSBatchChangeSize)
 	app.Flag("aws-batch-change-interval", "When using the AWS provider, set the interval between batch changes.").Default(defaultConfig.AWSBatchChangeInterval.String()).DurationVar(&cfg.AWSBatchChangeInterval)
 	app.Flag("aws-evaluate-target-health", "When using the AWS provider, set whether to evaluate the health of a DNS target (default: enabled, disable with --no-aws-evaluate-target-health)").Default(strconv.FormatBool(defaultConfig.AWSEvaluateTargetHealth)).BoolVar(&cfg.AWSEvaluateTargetHealth)
+	app.Flag("aws-dualstack", "When using the AWS provider, alias load balancers by an AAAA record in addition to the A record, can be overridden per record with the aws-dualstack annotation (default: disabled)").BoolVar(&cfg.AWSDualstack)
 	app.Flag("aws-api-retries", "When using the AWS provider, set the maximum number of retries for API calls before giving up.").Default(strconv.Itoa(defaultConfig.AWSAPIRetries)).IntVar(&cfg.AWSAPIRetries)
-	app.Flag("azure-config-file", "When using the Azure provider, specify the Azure configuration file (required when --provider=azure").Default(defaultConfig.AzureConfigFile).StringVar(&cfg.AzureConfigFile)
-	app.Flag("azure-resource-group", "When using the Azure provider, override the Azure resource group to use (optional)").Default(defaultConfig.AzureResourceGroup).StringVar(&cfg.AzureResourceGroup)
-	app.Flag("cloudflare-proxied", "When using the Cloudflare provider, specify if the proxy mode must be enabled (default: disabled)").BoolVar(&cfg.CloudflareProxied)
+	app.Flag("aws-health-checks", "When using the AWS provider, manage the health checks of records annotated with a health check protocol. They are tagged with the txt-owner-id and deleted once they aren't attached to a record anymore (default: disabled)").BoolVar(&cfg.AWSHealthChecks)
+	app.Flag("azure-config-file", "When using the Azure or Azure Private DNS provider, specify the Azure configuration file (required when --provider=azure or --provider=azure-private-dns)").Default(defaultConfig.AzureConfigFile).StringVar(&cfg.AzureConfigFile)
+	app.Flag("azure-resource-group", "When using the Azure or Azure Private DNS provider, override the Azure resource group to use (optional)").Default(defaultConfig.AzureResourceGroup).StringVar(&cfg.AzureResourceGroup)
+	app.Flag("cloudflare-proxied", "When using the Cloudflare provider, specify if the proxy mode must be enabled, can be overridden per record with the cloudflare-proxied annotation (default: disabled)").BoolVar(&cfg.CloudflareProxied)
 	app.Flag("infoblox-grid-host", "When using the Infoblox provider, specify the Grid Manager host (required when --provider=infoblox)").Default(defaultConfig.InfobloxGridHost).StringVar(&cfg.InfobloxGridHost)
 	app.Flag("infoblox-wapi-port", "When using the Infoblox provider, specify the WAPI port (default: 443)").Default(strconv.Itoa(defaultConfig.InfobloxWapiPort)).IntVar(&cfg.InfobloxWapiPort)
 	app.Flag("infoblox-wapi-username", "When using the Infoblox provider, specify the WAPI username (default: admin)").Default(defaultConfig.InfobloxWapiUsername).StringVar(&cfg.InfobloxWapiUsername)
@@ -267,6 +340,14 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("oci-config-file", "When using the OCI provider, specify the OCI configuration file (required when --provider=oci").Default(defaultConfig.OCIConfigFile).StringVar(&cfg.OCIConfigFile)
 
 	app.Flag("inmemory-zone", "Provide a list of pre-configured zones for the inmemory provider; specify multiple times for multiple zones (optional)").Default("").StringsVar(&cfg.InMemoryZones)
+	app.Flag("inmemory-state-file", "When using the inmemory provider, keep the records in this JSON file to restore them after a restart (optional)").Default(defaultConfig.InMemoryStateFile).StringVar(&cfg.InMemoryStateFile)
+	app.Flag("inmemory-dns-address", "When using the inmemory provider, answer DNS queries and zone transfers for its zones over UDP and TCP on this address, e.g. 127.0.0.1:5353 (optional)").Default(defaultConfig.InMemoryDNSAddress).StringVar(&cfg.InMemoryDNSAddress)
+	app.Flag("coredns-prefix", "When using the CoreDNS provider, specify the etcd key prefix CoreDNS reads the records from (default: /skydns/)").Default(defaultConfig.CoreDNSPrefix).StringVar(&cfg.CoreDNSPrefix)
+	app.Flag("webhook-url", "When using the webhook provider, specify the base URL of the webhook serving the records at its path /records (required when --provider=webhook, optionally specify --tls-ca, --tls-client-cert and --tls-client-cert-key for https)").Default(defaultConfig.WebhookURL).StringVar(&cfg.WebhookURL)
+	app.Flag("webhook-timeout", "When using the webhook provider, specify the timeout of a request to the webhook; 0s means no timeout").Default(defaultConfig.WebhookTimeout.String()).DurationVar(&cfg.WebhookTimeout)
+	app.Flag("webhook-retries", "When using the webhook provider, specify how often a failed request is retried").Default(strconv.Itoa(defaultConfig.WebhookRetries)).IntVar(&cfg.WebhookRetries)
+	app.Flag("zonefile-directory", "When using the zonefile provider, specify the directory of the RFC 1035 master files, one per zone named after the zone, e.g. example.org.zone (required when --provider=zonefile)").Default(defaultConfig.ZonefileDirectory).StringVar(&cfg.ZonefileDirectory)
+	app.Flag("zonefile-reload-command", "When using the zonefile provider, specify a shell command run after a zone file was written, with the zone name and the file in the environment variables ZONE and ZONE_FILE, e.g. 'rndc reload $ZONE' (optional)").Default(defaultConfig.ZonefileReloadCommand).StringVar(&cfg.ZonefileReloadCommand)
 	app.Flag("pdns-server", "When using the PowerDNS/PDNS provider, specify the URL to the pdns server (required when --provider=pdns)").Default(defaultConfig.PDNSServer).StringVar(&cfg.PDNSServer)
 	app.Flag("pdns-api-key", "When using the PowerDNS/PDNS provider, specify the API key to use to authorize requests (required when --provider=pdns)").Default(defaultConfig.PDNSAPIKey).StringVar(&cfg.PDNSAPIKey)
 	app.Flag("pdns-tls-enabled", "When using the PowerDNS/PDNS provider, specify whether to use TLS (default: false, requires --tls-ca, optionally specify --tls-client-cert and --tls-client-cert-key)").Default(strconv.FormatBool(defaultConfig.PDNSTLSEnabled)).BoolVar(&cfg.PDNSTLSEnabled)
@@ -283,36 +364,52 @@ func (cfg *Config) ParseFlags(args []string) error {
 	// Flags related to RFC2136 provider
 	app.Flag("rfc2136-host", "When using the RFC2136 provider, specify the host of the DNS server").Default(defaultConfig.RFC2136Host).StringVar(&cfg.RFC2136Host)
 	app.Flag("rfc2136-port", "When using the RFC2136 provider, specify the port of the DNS server").Default(strconv.Itoa(defaultConfig.RFC2136Port)).IntVar(&cfg.RFC2136Port)
-	app.Flag("rfc2136-zone", "When using the RFC2136 provider, specify the zone entry of the DNS server to use").Default(defaultConfig.RFC2136Zone).StringVar(&cfg.RFC2136Zone)
+	app.Flag("rfc2136-zone", "When using the RFC2136 provider, specify a zone of the DNS server to manage, records are managed in the longest matching zone (specify multiple times for multiple zones)").Default("").StringsVar(&cfg.RFC2136Zones)
 	app.Flag("rfc2136-insecure", "When using the RFC2136 provider, specify whether to attach TSIG or not (default: false, requires --rfc2136-tsig-keyname and rfc2136-tsig-secret)").Default(strconv.FormatBool(defaultConfig.RFC2136Insecure)).BoolVar(&cfg.RFC2136Insecure)
 	app.Flag("rfc2136-tsig-keyname", "When using the RFC2136 provider, specify the TSIG key to attached to DNS messages (required when --rfc2136-insecure=false)").Default(defaultConfig.RFC2136TSIGKeyName).StringVar(&cfg.RFC2136TSIGKeyName)
 	app.Flag("rfc2136-tsig-secret", "When using the RFC2136 provider, specify the TSIG (base64) value to attached to DNS messages (required when --rfc2136-insecure=false)").Default(defaultConfig.RFC2136TSIGSecret).StringVar(&cfg.RFC2136TSIGSecret)
 	app.Flag("rfc2136-tsig-secret-alg", "When using the RFC2136 provider, specify the TSIG (base64) value to attached to DNS messages (required when --rfc2136-insecure=false)").Default(defaultConfig.RFC2136TSIGSecretAlg).StringVar(&cfg.RFC2136TSIGSecretAlg)
+	app.Flag("rfc2136-zone-tsig-key", "When using the RFC2136 provider, sign the DNS messages of the given zones with this TSIG key instead, e.g. `keyname:hmac-sha256:c2VjcmV0=example.org,example.com` (optional, specify multiple times for multiple keys)").Default("").StringsVar(&cfg.RFC2136ZoneTSIGKeys)
 	app.Flag("rfc2136-tsig-axfr", "When using the RFC2136 provider, specify the TSIG (base64) value to attached to DNS messages (required when --rfc2136-insecure=false)").BoolVar(&cfg.RFC2136TAXFR)
 
 	// Flags related to policies
 	app.Flag("policy", "Modify how DNS records are synchronized between sources and providers (default: sync, options: sync, upsert-only)").Default(defaultConfig.Policy).EnumVar(&cfg.Policy, "sync", "upsert-only")
+	app.Flag("conflict-resolver", "Modify how a DNS name requested by several resources is assigned (default: per-resource, options: per-resource, oldest-resource-wins, merge-targets)").Default(defaultConfig.ConflictResolver).EnumVar(&cfg.ConflictResolver, "per-resource", "oldest-resource-wins", "merge-targets")
+	app.Flag("max-deletes", "Refuse to synchronize if more owned records would be deleted at once (default: 0, disabled)").Default(strconv.Itoa(defaultConfig.MaxDeletes)).IntVar(&cfg.MaxDeletes)
+	app.Flag("max-deletes-percent", "Refuse to synchronize if a larger percentage of owned records would be deleted at once (default: 0, disabled)").Default(strconv.FormatFloat(defaultConfig.MaxDeletesPercent, 'f', -1, 64)).Float64Var(&cfg.MaxDeletesPercent)
+	app.Flag("max-updates", "Refuse to synchronize if more owned records would be updated at once (default: 0, disabled)").Default(strconv.Itoa(defaultConfig.MaxUpdates)).IntVar(&cfg.MaxUpdates)
+	app.Flag("max-updates-percent", "Refuse to synchronize if a larger percentage of owned records would be updated at once (default: 0, disabled)").Default(strconv.FormatFloat(defaultConfig.MaxUpdatesPercent, 'f', -1, 64)).Float64Var(&cfg.MaxUpdatesPercent)
+	app.Flag("ignore-change-thresholds", "When enabled, applies all changes even if they exceed the --max-deletes and --max-updates thresholds (default: disabled)").BoolVar(&cfg.IgnoreChangeThresholds)
 
 	// Flags related to the registry
-	app.Flag("registry", "The registry implementation to use to keep track of DNS record ownership (default: txt, options: txt, noop, aws-sd)").Default(defaultConfig.Registry).EnumVar(&cfg.Registry, "txt", "noop", "aws-sd")
+	app.Flag("registry", "The registry implementation to use to keep track of DNS record ownership (default: txt, options: txt, noop, aws-sd, configmap)").Default(defaultConfig.Registry).EnumVar(&cfg.Registry, "txt", "noop", "aws-sd", "configmap")
 	app.Flag("txt-owner-id", "When using the TXT registry, a name that identifies this instance of ExternalDNS (default: default)").Default(defaultConfig.TXTOwnerID).StringVar(&cfg.TXTOwnerID)
 	app.Flag("txt-prefix", "When using the TXT registry, a custom string that's prefixed to each ownership DNS record (optional)").Default(defaultConfig.TXTPrefix).StringVar(&cfg.TXTPrefix)
+	app.Flag("txt-suffix", "When using the TXT registry, a custom string that's appended to the first label of each ownership DNS record, mutually exclusive with txt-prefix and txt-name-template (optional)").Default(defaultConfig.TXTSuffix).StringVar(&cfg.TXTSuffix)
+	app.Flag("txt-name-template", "When using the TXT registry, a Go template for the names of ownership DNS records over {{.Name}}, {{.Type}} and {{.Zone}}, e.g. {{.Name}}.txt.{{.Zone}}, mutually exclusive with txt-prefix and txt-suffix (optional)").Default(defaultConfig.TXTNameTemplate).StringVar(&cfg.TXTNameTemplate)
+	app.Flag("txt-format", "When using the TXT registry, the naming of ownership records; legacy shares one per name, typed creates one per record type and name, migrate writes both (default: legacy, options: legacy, migrate, typed)").Default(defaultConfig.TXTFormat).EnumVar(&cfg.TXTFormat, "legacy", "migrate", "typed")
+	app.Flag("configmap-namespace", "When using the ConfigMap registry, the namespace of the ConfigMap storing the ownership of records (default: default)").Default(defaultConfig.ConfigMapNamespace).StringVar(&cfg.ConfigMapNamespace)
+	app.Flag("configmap-name", "When using the ConfigMap registry, the name of the ConfigMap storing the ownership of records (default: external-dns-ownership)").Default(defaultConfig.ConfigMapName).StringVar(&cfg.ConfigMapName)
 
 	// Flags related to the main control loop
-	app.Flag("txt-cache-interval", "The interval between cache synchronizations in duration format (default: disabled)").Default(defaultConfig.TXTCacheInterval.String()).DurationVar(&cfg.TXTCacheInterval)
+	app.Flag("txt-cache-interval", "The interval between cache synchronizations of the TXT and ConfigMap registries in duration format (default: disabled)").Default(defaultConfig.TXTCacheInterval.String()).DurationVar(&cfg.TXTCacheInterval)
 	app.Flag("interval", "The interval between two consecutive synchronizations in duration format (default: 1m)").Default(defaultConfig.Interval.String()).DurationVar(&cfg.Interval)
+	app.Flag("min-event-sync-interval", "The minimum interval between two consecutive synchronizations triggered from kubernetes events in duration format (default: 5s)").Default(defaultConfig.MinEventSyncInterval.String()).DurationVar(&cfg.MinEventSyncInterval)
 	app.Flag("once", "When enabled, exits the synchronization loop after the first iteration (default: disabled)").BoolVar(&cfg.Once)
+	app.Flag("events", "When enabled, in addition to running every interval, the reconciliation loop will get triggered when supported sources change (default: disabled)").BoolVar(&cfg.UpdateEvents)
 	app.Flag("dry-run", "When enabled, prints DNS record changes rather than actually performing them (default: disabled)").BoolVar(&cfg.DryRun)
+	app.Flag("output", "The format in which the plan command prints changes (default: table, options: table, json, yaml)").Default(defaultConfig.Output).EnumVar(&cfg.Output, "table", "json", "yaml")
 
 	// Miscellaneous flags
 	app.Flag("log-format", "The format in which log messages are printed (default: text, options: text, json)").Default(defaultConfig.LogFormat).EnumVar(&cfg.LogFormat, "text", "json")
 	app.Flag("metrics-address", "Specify where to serve the metrics and health check endpoint (default: :7979)").Default(defaultConfig.MetricsAddress).StringVar(&cfg.MetricsAddress)
 	app.Flag("log-level", "Set the level of logging. (default: info, options: panic, debug, info, warning, error, fatal").Default(defaultConfig.LogLevel).EnumVar(&cfg.LogLevel, allLogLevelsAsStrings()...)
 
-	_, err := app.Parse(args)
+	command, err := app.Parse(args)
 	if err != nil {
 		return err
 	}
+	cfg.Command = command
 
 	return nil
 }
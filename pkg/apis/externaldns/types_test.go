@@ -29,114 +29,176 @@ import (
 
 var (
 	minimalConfig = &Config{
-		Master:                  "",
-		KubeConfig:              "",
-		RequestTimeout:          time.Second * 30,
-		IstioIngressGateway:     "istio-system/istio-ingressgateway",
-		Sources:                 []string{"service"},
-		Namespace:               "",
-		FQDNTemplate:            "",
-		Compatibility:           "",
-		Provider:                "google",
-		GoogleProject:           "",
-		DomainFilter:            []string{""},
-		ZoneIDFilter:            []string{""},
-		AlibabaCloudConfigFile:  "/etc/kubernetes/alibaba-cloud.json",
-		AWSZoneType:             "",
-		AWSZoneTagFilter:        []string{""},
-		AWSAssumeRole:           "",
-		AWSBatchChangeSize:      1000,
-		AWSBatchChangeInterval:  time.Second,
-		AWSEvaluateTargetHealth: true,
-		AWSAPIRetries:           3,
-		AzureConfigFile:         "/etc/kubernetes/azure.json",
-		AzureResourceGroup:      "",
-		CloudflareProxied:       false,
-		InfobloxGridHost:        "",
-		InfobloxWapiPort:        443,
-		InfobloxWapiUsername:    "admin",
-		InfobloxWapiPassword:    "",
-		InfobloxWapiVersion:     "2.3.1",
-		InfobloxSSLVerify:       true,
-		OCIConfigFile:           "/etc/kubernetes/oci.yaml",
-		InMemoryZones:           []string{""},
-		PDNSServer:              "http://localhost:8081",
-		PDNSAPIKey:              "",
-		Policy:                  "sync",
-		Registry:                "txt",
-		TXTOwnerID:              "default",
-		TXTPrefix:               "",
-		TXTCacheInterval:        0,
-		Interval:                time.Minute,
-		Once:                    false,
-		DryRun:                  false,
-		LogFormat:               "text",
-		MetricsAddress:          ":7979",
-		LogLevel:                logrus.InfoLevel.String(),
-		ConnectorSourceServer:   "localhost:8080",
-		ExoscaleEndpoint:        "https://api.exoscale.ch/dns",
-		ExoscaleAPIKey:          "",
-		ExoscaleAPISecret:       "",
-		CRDSourceAPIVersion:     "externaldns.k8s.io/v1alpha1",
-		CRDSourceKind:           "DNSEndpoint",
+		Command:                   "controller",
+		Master:                    "",
+		KubeConfig:                "",
+		RequestTimeout:            time.Second * 30,
+		IstioIngressGateway:       "istio-system/istio-ingressgateway",
+		Sources:                   []string{"service"},
+		Namespace:                 "",
+		FQDNTemplate:              "",
+		Compatibility:             "",
+		Provider:                  "google",
+		GoogleProject:             "",
+		GoogleZoneVisibility:      "",
+		GoogleBatchChangeSize:     1000,
+		GoogleBatchChangeInterval: time.Second,
+		DomainFilter:              []string{""},
+		ZoneIDFilter:              []string{""},
+		AlibabaCloudConfigFile:    "/etc/kubernetes/alibaba-cloud.json",
+		AWSZoneType:               "",
+		AWSZoneTagFilter:          []string{""},
+		AWSZoneRoles:              []string{""},
+		AWSAssumeRole:             "",
+		AWSBatchChangeSize:        1000,
+		AWSBatchChangeInterval:    time.Second,
+		AWSEvaluateTargetHealth:   true,
+		AWSAPIRetries:             3,
+		AzureConfigFile:           "/etc/kubernetes/azure.json",
+		AzureResourceGroup:        "",
+		CloudflareProxied:         false,
+		InfobloxGridHost:          "",
+		InfobloxWapiPort:          443,
+		InfobloxWapiUsername:      "admin",
+		InfobloxWapiPassword:      "",
+		InfobloxWapiVersion:       "2.3.1",
+		InfobloxSSLVerify:         true,
+		OCIConfigFile:             "/etc/kubernetes/oci.yaml",
+		InMemoryZones:             []string{""},
+		InMemoryStateFile:         "",
+		InMemoryDNSAddress:        "",
+		CoreDNSPrefix:             "/skydns/",
+		WebhookURL:                "",
+		WebhookTimeout:            10 * time.Second,
+		WebhookRetries:            3,
+		ZonefileDirectory:         "",
+		ZonefileReloadCommand:     "",
+		PDNSServer:                "http://localhost:8081",
+		PDNSAPIKey:                "",
+		Policy:                    "sync",
+		ConflictResolver:          "per-resource",
+		MaxDeletes:                0,
+		MaxDeletesPercent:         0,
+		MaxUpdates:                0,
+		MaxUpdatesPercent:         0,
+		IgnoreChangeThresholds:    false,
+		Registry:                  "txt",
+		TXTOwnerID:                "default",
+		TXTPrefix:                 "",
+		TXTSuffix:                 "",
+		TXTNameTemplate:           "",
+		TXTFormat:                 "legacy",
+		ConfigMapNamespace:        "default",
+		ConfigMapName:             "external-dns-ownership",
+		TXTCacheInterval:          0,
+		Interval:                  time.Minute,
+		MinEventSyncInterval:      5 * time.Second,
+		Once:                      false,
+		UpdateEvents:              false,
+		DryRun:                    false,
+		Output:                    "table",
+		LogFormat:                 "text",
+		MetricsAddress:            ":7979",
+		LogLevel:                  logrus.InfoLevel.String(),
+		ConnectorSourceServer:     "localhost:8080",
+		ExoscaleEndpoint:          "https://api.exoscale.ch/dns",
+		ExoscaleAPIKey:            "",
+		ExoscaleAPISecret:         "",
+		CRDSourceAPIVersion:       "externaldns.k8s.io/v1alpha1",
+		CRDSourceKind:             "DNSEndpoint",
 	}
 
 	overriddenConfig = &Config{
-		Master:                  "http://127.0.0.1:8080",
-		KubeConfig:              "/some/path",
-		RequestTimeout:          time.Second * 77,
-		IstioIngressGateway:     "istio-other/istio-otheringressgateway",
-		Sources:                 []string{"service", "ingress", "connector"},
-		Namespace:               "namespace",
-		FQDNTemplate:            "{{.Name}}.service.example.com",
-		Compatibility:           "mate",
-		Provider:                "google",
-		GoogleProject:           "project",
-		DomainFilter:            []string{"example.org", "company.com"},
-		ZoneIDFilter:            []string{"/hostedzone/ZTST1", "/hostedzone/ZTST2"},
-		AlibabaCloudConfigFile:  "/etc/kubernetes/alibaba-cloud.json",
-		AWSZoneType:             "private",
-		AWSZoneTagFilter:        []string{"tag=foo"},
-		AWSAssumeRole:           "some-other-role",
-		AWSBatchChangeSize:      100,
-		AWSBatchChangeInterval:  time.Second * 2,
-		AWSEvaluateTargetHealth: false,
-		AWSAPIRetries:           13,
-		AzureConfigFile:         "azure.json",
-		AzureResourceGroup:      "arg",
-		CloudflareProxied:       true,
-		InfobloxGridHost:        "127.0.0.1",
-		InfobloxWapiPort:        8443,
-		InfobloxWapiUsername:    "infoblox",
-		InfobloxWapiPassword:    "infoblox",
-		InfobloxWapiVersion:     "2.6.1",
-		InfobloxSSLVerify:       false,
-		OCIConfigFile:           "oci.yaml",
-		InMemoryZones:           []string{"example.org", "company.com"},
-		PDNSServer:              "http://ns.example.com:8081",
-		PDNSAPIKey:              "some-secret-key",
-		PDNSTLSEnabled:          true,
-		TLSCA:                   "/path/to/ca.crt",
-		TLSClientCert:           "/path/to/cert.pem",
-		TLSClientCertKey:        "/path/to/key.pem",
-		Policy:                  "upsert-only",
-		Registry:                "noop",
-		TXTOwnerID:              "owner-1",
-		TXTPrefix:               "associated-txt-record",
-		TXTCacheInterval:        12 * time.Hour,
-		Interval:                10 * time.Minute,
-		Once:                    true,
-		DryRun:                  true,
-		LogFormat:               "json",
-		MetricsAddress:          "127.0.0.1:9099",
-		LogLevel:                logrus.DebugLevel.String(),
-		ConnectorSourceServer:   "localhost:8081",
-		ExoscaleEndpoint:        "https://api.foo.ch/dns",
-		ExoscaleAPIKey:          "1",
-		ExoscaleAPISecret:       "2",
-		CRDSourceAPIVersion:     "test.k8s.io/v1alpha1",
-		CRDSourceKind:           "Endpoint",
+		Command:                   "controller",
+		Master:                    "http://127.0.0.1:8080",
+		KubeConfig:                "/some/path",
+		RequestTimeout:            time.Second * 77,
+		IstioIngressGateway:       "istio-other/istio-otheringressgateway",
+		Sources:                   []string{"service", "ingress", "connector"},
+		Namespace:                 "namespace",
+		FQDNTemplate:              "{{.Name}}.service.example.com",
+		Compatibility:             "mate",
+		Provider:                  "google",
+		GoogleProject:             "project",
+		GoogleZoneVisibility:      "private",
+		GoogleBatchChangeSize:     100,
+		GoogleBatchChangeInterval: time.Second * 2,
+		DomainFilter:              []string{"example.org", "company.com"},
+		ZoneIDFilter:              []string{"/hostedzone/ZTST1", "/hostedzone/ZTST2"},
+		AlibabaCloudConfigFile:    "/etc/kubernetes/alibaba-cloud.json",
+		AWSZoneType:               "private",
+		AWSZoneTagFilter:          []string{"tag=foo"},
+		AWSZoneRoles:              []string{"arn:aws:iam::123455567:role/external-dns=example.org", "arn:aws:iam::765455321:role/external-dns=company.com"},
+		AWSAssumeRole:             "some-other-role",
+		AWSBatchChangeSize:        100,
+		AWSBatchChangeInterval:    time.Second * 2,
+		AWSEvaluateTargetHealth:   false,
+		AWSDualstack:              true,
+		AWSAPIRetries:             13,
+		AWSHealthChecks:           true,
+		AzureConfigFile:           "azure.json",
+		AzureResourceGroup:        "arg",
+		CloudflareProxied:         true,
+		InfobloxGridHost:          "127.0.0.1",
+		InfobloxWapiPort:          8443,
+		InfobloxWapiUsername:      "infoblox",
+		InfobloxWapiPassword:      "infoblox",
+		InfobloxWapiVersion:       "2.6.1",
+		InfobloxSSLVerify:         false,
+		OCIConfigFile:             "oci.yaml",
+		InMemoryZones:             []string{"example.org", "company.com"},
+		InMemoryStateFile:         "/var/lib/external-dns/inmemory.json",
+		InMemoryDNSAddress:        "127.0.0.1:5353",
+		CoreDNSPrefix:             "/coredns/",
+		WebhookURL:                "https://dns.example.org:8443",
+		WebhookTimeout:            30 * time.Second,
+		WebhookRetries:            5,
+		ZonefileDirectory:         "/var/named/zones",
+		ZonefileReloadCommand:     "rndc reload $ZONE",
+		PDNSServer:                "http://ns.example.com:8081",
+		PDNSAPIKey:                "some-secret-key",
+		PDNSTLSEnabled:            true,
+		TLSCA:                     "/path/to/ca.crt",
+		TLSClientCert:             "/path/to/cert.pem",
+		TLSClientCertKey:          "/path/to/key.pem",
+		Policy:                    "upsert-only",
+		ConflictResolver:          "merge-targets",
+		MaxDeletes:                10,
+		MaxDeletesPercent:         25.5,
+		MaxUpdates:                20,
+		MaxUpdatesPercent:         50,
+		IgnoreChangeThresholds:    true,
+		Registry:                  "noop",
+		TXTOwnerID:                "owner-1",
+		TXTPrefix:                 "associated-txt-record",
+		TXTSuffix:                 "-txt",
+		TXTNameTemplate:           "{{.Name}}.txt.{{.Zone}}",
+		TXTFormat:                 "typed",
+		ConfigMapNamespace:        "kube-system",
+		ConfigMapName:             "ownership",
+		TXTCacheInterval:          12 * time.Hour,
+		Interval:                  10 * time.Minute,
+		MinEventSyncInterval:      10 * time.Second,
+		Once:                      true,
+		UpdateEvents:              true,
+		DryRun:                    true,
+		Output:                    "json",
+		LogFormat:                 "json",
+		MetricsAddress:            "127.0.0.1:9099",
+		LogLevel:                  logrus.DebugLevel.String(),
+		ConnectorSourceServer:     "localhost:8081",
+		ExoscaleEndpoint:          "https://api.foo.ch/dns",
+		ExoscaleAPIKey:            "1",
+		ExoscaleAPISecret:         "2",
+		CRDSourceAPIVersion:       "test.k8s.io/v1alpha1",
+		CRDSourceKind:             "Endpoint",
 	}
+
+	planConfig = func() *Config {
+		cfg := *minimalConfig
+		cfg.Command = "plan"
+		return &cfg
+	}()
 )
 
 func TestParseFlags(t *testing.T) {
@@ -155,6 +217,16 @@ func TestParseFlags(t *testing.T) {
 			envVars:  map[string]string{},
 			expected: minimalConfig,
 		},
+		{
+			title: "plan command with minimal flags defined",
+			args: []string{
+				"plan",
+				"--source=service",
+				"--provider=google",
+			},
+			envVars:  map[string]string{},
+			expected: planConfig,
+		},
 		{
 			title: "override everything via flags",
 			args: []string{
@@ -170,6 +242,9 @@ func TestParseFlags(t *testing.T) {
 				"--compatibility=mate",
 				"--provider=google",
 				"--google-project=project",
+				"--google-zone-visibility=private",
+				"--google-batch-change-size=100",
+				"--google-batch-change-interval=2s",
 				"--azure-config-file=azure.json",
 				"--azure-resource-group=arg",
 				"--cloudflare-proxied",
@@ -180,6 +255,14 @@ func TestParseFlags(t *testing.T) {
 				"--infoblox-wapi-version=2.6.1",
 				"--inmemory-zone=example.org",
 				"--inmemory-zone=company.com",
+				"--inmemory-state-file=/var/lib/external-dns/inmemory.json",
+				"--inmemory-dns-address=127.0.0.1:5353",
+				"--coredns-prefix=/coredns/",
+				"--webhook-url=https://dns.example.org:8443",
+				"--webhook-timeout=30s",
+				"--webhook-retries=5",
+				"--zonefile-directory=/var/named/zones",
+				"--zonefile-reload-command=rndc reload $ZONE",
 				"--pdns-server=http://ns.example.com:8081",
 				"--pdns-api-key=some-secret-key",
 				"--pdns-tls-enabled",
@@ -194,19 +277,37 @@ func TestParseFlags(t *testing.T) {
 				"--zone-id-filter=/hostedzone/ZTST2",
 				"--aws-zone-type=private",
 				"--aws-zone-tags=tag=foo",
+				"--aws-zone-role=arn:aws:iam::123455567:role/external-dns=example.org",
+				"--aws-zone-role=arn:aws:iam::765455321:role/external-dns=company.com",
 				"--aws-assume-role=some-other-role",
 				"--aws-batch-change-size=100",
 				"--aws-batch-change-interval=2s",
 				"--aws-api-retries=13",
 				"--no-aws-evaluate-target-health",
+				"--aws-dualstack",
+				"--aws-health-checks",
 				"--policy=upsert-only",
+				"--conflict-resolver=merge-targets",
+				"--max-deletes=10",
+				"--max-deletes-percent=25.5",
+				"--max-updates=20",
+				"--max-updates-percent=50",
+				"--ignore-change-thresholds",
 				"--registry=noop",
 				"--txt-owner-id=owner-1",
 				"--txt-prefix=associated-txt-record",
+				"--txt-suffix=-txt",
+				"--txt-name-template={{.Name}}.txt.{{.Zone}}",
+				"--txt-format=typed",
+				"--configmap-namespace=kube-system",
+				"--configmap-name=ownership",
 				"--txt-cache-interval=12h",
 				"--interval=10m",
+				"--min-event-sync-interval=10s",
 				"--once",
+				"--events",
 				"--dry-run",
+				"--output=json",
 				"--log-format=json",
 				"--metrics-address=127.0.0.1:9099",
 				"--log-level=debug",
@@ -224,59 +325,87 @@ func TestParseFlags(t *testing.T) {
 			title: "override everything via environment variables",
 			args:  []string{},
 			envVars: map[string]string{
-				"EXTERNAL_DNS_MASTER":                     "http://127.0.0.1:8080",
-				"EXTERNAL_DNS_KUBECONFIG":                 "/some/path",
-				"EXTERNAL_DNS_REQUEST_TIMEOUT":            "77s",
-				"EXTERNAL_DNS_ISTIO_INGRESS_GATEWAY":      "istio-other/istio-otheringressgateway",
-				"EXTERNAL_DNS_SOURCE":                     "service\ningress\nconnector",
-				"EXTERNAL_DNS_NAMESPACE":                  "namespace",
-				"EXTERNAL_DNS_FQDN_TEMPLATE":              "{{.Name}}.service.example.com",
-				"EXTERNAL_DNS_COMPATIBILITY":              "mate",
-				"EXTERNAL_DNS_PROVIDER":                   "google",
-				"EXTERNAL_DNS_GOOGLE_PROJECT":             "project",
-				"EXTERNAL_DNS_AZURE_CONFIG_FILE":          "azure.json",
-				"EXTERNAL_DNS_AZURE_RESOURCE_GROUP":       "arg",
-				"EXTERNAL_DNS_CLOUDFLARE_PROXIED":         "1",
-				"EXTERNAL_DNS_INFOBLOX_GRID_HOST":         "127.0.0.1",
-				"EXTERNAL_DNS_INFOBLOX_WAPI_PORT":         "8443",
-				"EXTERNAL_DNS_INFOBLOX_WAPI_USERNAME":     "infoblox",
-				"EXTERNAL_DNS_INFOBLOX_WAPI_PASSWORD":     "infoblox",
-				"EXTERNAL_DNS_INFOBLOX_WAPI_VERSION":      "2.6.1",
-				"EXTERNAL_DNS_INFOBLOX_SSL_VERIFY":        "0",
-				"EXTERNAL_DNS_OCI_CONFIG_FILE":            "oci.yaml",
-				"EXTERNAL_DNS_INMEMORY_ZONE":              "example.org\ncompany.com",
-				"EXTERNAL_DNS_DOMAIN_FILTER":              "example.org\ncompany.com",
-				"EXTERNAL_DNS_PDNS_SERVER":                "http://ns.example.com:8081",
-				"EXTERNAL_DNS_PDNS_API_KEY":               "some-secret-key",
-				"EXTERNAL_DNS_PDNS_TLS_ENABLED":           "1",
-				"EXTERNAL_DNS_TLS_CA":                     "/path/to/ca.crt",
-				"EXTERNAL_DNS_TLS_CLIENT_CERT":            "/path/to/cert.pem",
-				"EXTERNAL_DNS_TLS_CLIENT_CERT_KEY":        "/path/to/key.pem",
-				"EXTERNAL_DNS_ZONE_ID_FILTER":             "/hostedzone/ZTST1\n/hostedzone/ZTST2",
-				"EXTERNAL_DNS_AWS_ZONE_TYPE":              "private",
-				"EXTERNAL_DNS_AWS_ZONE_TAGS":              "tag=foo",
-				"EXTERNAL_DNS_AWS_ASSUME_ROLE":            "some-other-role",
-				"EXTERNAL_DNS_AWS_BATCH_CHANGE_SIZE":      "100",
-				"EXTERNAL_DNS_AWS_BATCH_CHANGE_INTERVAL":  "2s",
-				"EXTERNAL_DNS_AWS_EVALUATE_TARGET_HEALTH": "0",
-				"EXTERNAL_DNS_AWS_API_RETRIES":            "13",
-				"EXTERNAL_DNS_POLICY":                     "upsert-only",
-				"EXTERNAL_DNS_REGISTRY":                   "noop",
-				"EXTERNAL_DNS_TXT_OWNER_ID":               "owner-1",
-				"EXTERNAL_DNS_TXT_PREFIX":                 "associated-txt-record",
-				"EXTERNAL_DNS_TXT_CACHE_INTERVAL":         "12h",
-				"EXTERNAL_DNS_INTERVAL":                   "10m",
-				"EXTERNAL_DNS_ONCE":                       "1",
-				"EXTERNAL_DNS_DRY_RUN":                    "1",
-				"EXTERNAL_DNS_LOG_FORMAT":                 "json",
-				"EXTERNAL_DNS_METRICS_ADDRESS":            "127.0.0.1:9099",
-				"EXTERNAL_DNS_LOG_LEVEL":                  "debug",
-				"EXTERNAL_DNS_CONNECTOR_SOURCE_SERVER":    "localhost:8081",
-				"EXTERNAL_DNS_EXOSCALE_ENDPOINT":          "https://api.foo.ch/dns",
-				"EXTERNAL_DNS_EXOSCALE_APIKEY":            "1",
-				"EXTERNAL_DNS_EXOSCALE_APISECRET":         "2",
-				"EXTERNAL_DNS_CRD_SOURCE_APIVERSION":      "test.k8s.io/v1alpha1",
-				"EXTERNAL_DNS_CRD_SOURCE_KIND":            "Endpoint",
+				"EXTERNAL_DNS_MASTER":                       "http://127.0.0.1:8080",
+				"EXTERNAL_DNS_KUBECONFIG":                   "/some/path",
+				"EXTERNAL_DNS_REQUEST_TIMEOUT":              "77s",
+				"EXTERNAL_DNS_ISTIO_INGRESS_GATEWAY":        "istio-other/istio-otheringressgateway",
+				"EXTERNAL_DNS_SOURCE":                       "service\ningress\nconnector",
+				"EXTERNAL_DNS_NAMESPACE":                    "namespace",
+				"EXTERNAL_DNS_FQDN_TEMPLATE":                "{{.Name}}.service.example.com",
+				"EXTERNAL_DNS_COMPATIBILITY":                "mate",
+				"EXTERNAL_DNS_PROVIDER":                     "google",
+				"EXTERNAL_DNS_GOOGLE_PROJECT":               "project",
+				"EXTERNAL_DNS_GOOGLE_ZONE_VISIBILITY":       "private",
+				"EXTERNAL_DNS_GOOGLE_BATCH_CHANGE_SIZE":     "100",
+				"EXTERNAL_DNS_GOOGLE_BATCH_CHANGE_INTERVAL": "2s",
+				"EXTERNAL_DNS_AZURE_CONFIG_FILE":            "azure.json",
+				"EXTERNAL_DNS_AZURE_RESOURCE_GROUP":         "arg",
+				"EXTERNAL_DNS_CLOUDFLARE_PROXIED":           "1",
+				"EXTERNAL_DNS_INFOBLOX_GRID_HOST":           "127.0.0.1",
+				"EXTERNAL_DNS_INFOBLOX_WAPI_PORT":           "8443",
+				"EXTERNAL_DNS_INFOBLOX_WAPI_USERNAME":       "infoblox",
+				"EXTERNAL_DNS_INFOBLOX_WAPI_PASSWORD":       "infoblox",
+				"EXTERNAL_DNS_INFOBLOX_WAPI_VERSION":        "2.6.1",
+				"EXTERNAL_DNS_INFOBLOX_SSL_VERIFY":          "0",
+				"EXTERNAL_DNS_OCI_CONFIG_FILE":              "oci.yaml",
+				"EXTERNAL_DNS_INMEMORY_ZONE":                "example.org\ncompany.com",
+				"EXTERNAL_DNS_INMEMORY_STATE_FILE":          "/var/lib/external-dns/inmemory.json",
+				"EXTERNAL_DNS_INMEMORY_DNS_ADDRESS":         "127.0.0.1:5353",
+				"EXTERNAL_DNS_COREDNS_PREFIX":               "/coredns/",
+				"EXTERNAL_DNS_WEBHOOK_URL":                  "https://dns.example.org:8443",
+				"EXTERNAL_DNS_WEBHOOK_TIMEOUT":              "30s",
+				"EXTERNAL_DNS_WEBHOOK_RETRIES":              "5",
+				"EXTERNAL_DNS_ZONEFILE_DIRECTORY":           "/var/named/zones",
+				"EXTERNAL_DNS_ZONEFILE_RELOAD_COMMAND":      "rndc reload $ZONE",
+				"EXTERNAL_DNS_DOMAIN_FILTER":                "example.org\ncompany.com",
+				"EXTERNAL_DNS_PDNS_SERVER":                  "http://ns.example.com:8081",
+				"EXTERNAL_DNS_PDNS_API_KEY":                 "some-secret-key",
+				"EXTERNAL_DNS_PDNS_TLS_ENABLED":             "1",
+				"EXTERNAL_DNS_TLS_CA":                       "/path/to/ca.crt",
+				"EXTERNAL_DNS_TLS_CLIENT_CERT":              "/path/to/cert.pem",
+				"EXTERNAL_DNS_TLS_CLIENT_CERT_KEY":          "/path/to/key.pem",
+				"EXTERNAL_DNS_ZONE_ID_FILTER":               "/hostedzone/ZTST1\n/hostedzone/ZTST2",
+				"EXTERNAL_DNS_AWS_ZONE_TYPE":                "private",
+				"EXTERNAL_DNS_AWS_ZONE_TAGS":                "tag=foo",
+				"EXTERNAL_DNS_AWS_ZONE_ROLE":                "arn:aws:iam::123455567:role/external-dns=example.org\narn:aws:iam::765455321:role/external-dns=company.com",
+				"EXTERNAL_DNS_AWS_ASSUME_ROLE":              "some-other-role",
+				"EXTERNAL_DNS_AWS_BATCH_CHANGE_SIZE":        "100",
+				"EXTERNAL_DNS_AWS_BATCH_CHANGE_INTERVAL":    "2s",
+				"EXTERNAL_DNS_AWS_EVALUATE_TARGET_HEALTH":   "0",
+				"EXTERNAL_DNS_AWS_DUALSTACK":                "1",
+				"EXTERNAL_DNS_AWS_API_RETRIES":              "13",
+				"EXTERNAL_DNS_AWS_HEALTH_CHECKS":            "1",
+				"EXTERNAL_DNS_POLICY":                       "upsert-only",
+				"EXTERNAL_DNS_CONFLICT_RESOLVER":            "merge-targets",
+				"EXTERNAL_DNS_MAX_DELETES":                  "10",
+				"EXTERNAL_DNS_MAX_DELETES_PERCENT":          "25.5",
+				"EXTERNAL_DNS_MAX_UPDATES":                  "20",
+				"EXTERNAL_DNS_MAX_UPDATES_PERCENT":          "50",
+				"EXTERNAL_DNS_IGNORE_CHANGE_THRESHOLDS":     "1",
+				"EXTERNAL_DNS_REGISTRY":                     "noop",
+				"EXTERNAL_DNS_TXT_OWNER_ID":                 "owner-1",
+				"EXTERNAL_DNS_TXT_PREFIX":                   "associated-txt-record",
+				"EXTERNAL_DNS_TXT_SUFFIX":                   "-txt",
+				"EXTERNAL_DNS_TXT_NAME_TEMPLATE":            "{{.Name}}.txt.{{.Zone}}",
+				"EXTERNAL_DNS_TXT_FORMAT":                   "typed",
+				"EXTERNAL_DNS_CONFIGMAP_NAMESPACE":          "kube-system",
+				"EXTERNAL_DNS_CONFIGMAP_NAME":               "ownership",
+				"EXTERNAL_DNS_TXT_CACHE_INTERVAL":           "12h",
+				"EXTERNAL_DNS_INTERVAL":                     "10m",
+				"EXTERNAL_DNS_MIN_EVENT_SYNC_INTERVAL":      "10s",
+				"EXTERNAL_DNS_ONCE":                         "1",
+				"EXTERNAL_DNS_EVENTS":                       "1",
+				"EXTERNAL_DNS_DRY_RUN":                      "1",
+				"EXTERNAL_DNS_OUTPUT":                       "json",
+				"EXTERNAL_DNS_LOG_FORMAT":                   "json",
+				"EXTERNAL_DNS_METRICS_ADDRESS":              "127.0.0.1:9099",
+				"EXTERNAL_DNS_LOG_LEVEL":                    "debug",
+				"EXTERNAL_DNS_CONNECTOR_SOURCE_SERVER":      "localhost:8081",
+				"EXTERNAL_DNS_EXOSCALE_ENDPOINT":            "https://api.foo.ch/dns",
+				"EXTERNAL_DNS_EXOSCALE_APIKEY":              "1",
+				"EXTERNAL_DNS_EXOSCALE_APISECRET":           "2",
+				"EXTERNAL_DNS_CRD_SOURCE_APIVERSION":        "test.k8s.io/v1alpha1",
+				"EXTERNAL_DNS_CRD_SOURCE_KIND":              "Endpoint",
 			},
 			expected: overriddenConfig,
 		},
@@ -316,6 +445,7 @@ func TestPasswordsNotLogged(t *testing.T) {
 		DynPassword:          "dyn-pass",
 		InfobloxWapiPassword: "infoblox-pass",
 		PDNSAPIKey:           "pdns-api-key",
+		RFC2136ZoneTSIGKeys:  []string{"key:hmac-sha256:rfc2136-secret=example.org"},
 	}
 
 	s := cfg.String()
@@ -323,4 +453,5 @@ func TestPasswordsNotLogged(t *testing.T) {
 	assert.False(t, strings.Contains(s, "dyn-pass"))
 	assert.False(t, strings.Contains(s, "infoblox-pass"))
 	assert.False(t, strings.Contains(s, "pdns-api-key"))
+	assert.False(t, strings.Contains(s, "rfc2136-secret"))
 }
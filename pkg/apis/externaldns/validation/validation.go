@@ -35,6 +35,9 @@ func ValidateConfig(cfg *externaldns.Config) error {
 	if cfg.Provider == "" {
 		return errors.New("no provider specified")
 	}
+	if cfg.Command == "plan" && cfg.Registry == "aws-sd" {
+		return errors.New("the plan command doesn't support the aws-sd registry")
+	}
 
 	// Azure provider specific validations
 	if cfg.Provider == "azure" {
@@ -65,5 +68,11 @@ func ValidateConfig(cfg *externaldns.Config) error {
 			return errors.New("TTL specified for Dyn is negative")
 		}
 	}
+
+	if cfg.Provider == "zonefile" {
+		if cfg.ZonefileDirectory == "" {
+			return errors.New("no zone file directory specified")
+		}
+	}
 	return nil
 }
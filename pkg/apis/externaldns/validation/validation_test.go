@@ -50,6 +50,15 @@ func TestValidateFlags(t *testing.T) {
 	cfg = newValidConfig(t)
 	cfg.Provider = ""
 	assert.Error(t, ValidateConfig(cfg))
+
+	cfg = newValidConfig(t)
+	cfg.Command = "plan"
+	assert.NoError(t, ValidateConfig(cfg))
+
+	cfg = newValidConfig(t)
+	cfg.Command = "plan"
+	cfg.Registry = "aws-sd"
+	assert.Error(t, ValidateConfig(cfg))
 }
 
 func newValidConfig(t *testing.T) *externaldns.Config {
@@ -116,3 +125,12 @@ func TestValidateGoodDynConfig(t *testing.T) {
 		assert.Nil(t, err, "Configuration should be valid, got this error instead", err)
 	}
 }
+
+func TestValidateZonefileConfig(t *testing.T) {
+	cfg := newValidConfig(t)
+	cfg.Provider = "zonefile"
+	assert.Error(t, ValidateConfig(cfg))
+
+	cfg.ZonefileDirectory = "/var/named/zones"
+	assert.NoError(t, ValidateConfig(cfg))
+}
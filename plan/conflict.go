@@ -18,6 +18,7 @@ package plan
 
 import (
 	"sort"
+	"time"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 )
@@ -29,6 +30,13 @@ type ConflictResolver interface {
 	ResolveUpdate(current *endpoint.Endpoint, candidates []*endpoint.Endpoint) *endpoint.Endpoint
 }
 
+// ConflictResolvers is a registry of available conflict resolvers.
+var ConflictResolvers = map[string]ConflictResolver{
+	"per-resource":         PerResource{},
+	"oldest-resource-wins": OldestResource{},
+	"merge-targets":        MergeTargets{},
+}
+
 // PerResource allows only one resource to own a given dns name
 type PerResource struct{}
 
@@ -67,4 +75,110 @@ func (s PerResource) less(x, y *endpoint.Endpoint) bool {
 	return x.Targets.IsLess(y.Targets)
 }
 
-// TODO: with cross-resource/cross-cluster setup alternative variations of ConflictResolver can be used
+// OldestResource allows only one resource to own a given dns name, the resource
+// created first wins. Resources are compared by their creation timestamp label,
+// candidates without one lose against those having it.
+type OldestResource struct{}
+
+// ResolveCreate is invoked when dns name is not owned by any resource
+// ResolveCreate takes the oldest endpoint to acquire the DNS record, ties are broken like in PerResource
+func (s OldestResource) ResolveCreate(candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	var oldest *endpoint.Endpoint
+	for _, ep := range candidates {
+		if oldest == nil || s.less(ep, oldest) {
+			oldest = ep
+		}
+	}
+	return oldest
+}
+
+// ResolveUpdate is invoked when dns name is already owned by "current" endpoint
+// ResolveUpdate keeps the "current" resource unless an older one asks for the same dns name
+func (s OldestResource) ResolveUpdate(current *endpoint.Endpoint, candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	currentResource := current.Labels[endpoint.ResourceLabelKey]
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return s.less(candidates[i], candidates[j])
+	})
+	oldest := s.ResolveCreate(candidates)
+	for _, ep := range candidates {
+		if ep.Labels[endpoint.ResourceLabelKey] == currentResource && !s.older(oldest, ep) {
+			return ep
+		}
+	}
+	return oldest
+}
+
+// less returns true if endpoint x is less than y
+func (s OldestResource) less(x, y *endpoint.Endpoint) bool {
+	if s.older(x, y) {
+		return true
+	}
+	if s.older(y, x) {
+		return false
+	}
+	return PerResource{}.less(x, y)
+}
+
+// older returns true if the resource of endpoint x was created before the one of y
+func (s OldestResource) older(x, y *endpoint.Endpoint) bool {
+	tx, okx := creationTimestamp(x)
+	ty, oky := creationTimestamp(y)
+	if !okx {
+		return false
+	}
+	return !oky || tx.Before(ty)
+}
+
+func creationTimestamp(ep *endpoint.Endpoint) (time.Time, bool) {
+	value, ok := ep.Labels[endpoint.CreationTimestampLabelKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// MergeTargets allows several resources to share a given dns name, e.g. for
+// round-robin records. The resource picked by PerResource owns the record,
+// its targets are the union of the targets of all candidates of the same type.
+// CNAME records can only have a single target and are resolved like in PerResource.
+type MergeTargets struct{}
+
+// ResolveCreate is invoked when dns name is not owned by any resource
+func (s MergeTargets) ResolveCreate(candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	return s.merge(PerResource{}.ResolveCreate(candidates), candidates)
+}
+
+// ResolveUpdate is invoked when dns name is already owned by "current" endpoint
+func (s MergeTargets) ResolveUpdate(current *endpoint.Endpoint, candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	return s.merge(PerResource{}.ResolveUpdate(current, candidates), candidates)
+}
+
+// merge returns a copy of owner with the targets of all candidates of the same record type
+func (s MergeTargets) merge(owner *endpoint.Endpoint, candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	if owner == nil || owner.RecordType == endpoint.RecordTypeCNAME {
+		return owner
+	}
+
+	seen := map[string]bool{}
+	targets := endpoint.Targets{}
+	for _, ep := range candidates {
+		if ep.RecordType != owner.RecordType {
+			continue
+		}
+		for _, target := range ep.Targets {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+	sort.Sort(targets)
+
+	merged := *owner
+	merged.Targets = targets
+	return &merged
+}
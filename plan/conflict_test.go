@@ -24,10 +24,14 @@ import (
 )
 
 var _ ConflictResolver = PerResource{}
+var _ ConflictResolver = OldestResource{}
+var _ ConflictResolver = MergeTargets{}
 
 type ResolverSuite struct {
 	// resolvers
-	perResource PerResource
+	perResource    PerResource
+	oldestResource OldestResource
+	mergeTargets   MergeTargets
 	// endpoints
 	fooV1Cname          *endpoint.Endpoint
 	fooV2Cname          *endpoint.Endpoint
@@ -42,6 +46,8 @@ type ResolverSuite struct {
 
 func (suite *ResolverSuite) SetupTest() {
 	suite.perResource = PerResource{}
+	suite.oldestResource = OldestResource{}
+	suite.mergeTargets = MergeTargets{}
 	// initialize endpoints used in tests
 	suite.fooV1Cname = &endpoint.Endpoint{
 		DNSName:    "foo",
@@ -132,6 +138,53 @@ func (suite *ResolverSuite) TestStrictResolver() {
 	suite.Equal(suite.bar127A, suite.perResource.ResolveUpdate(suite.legacyBar192A, []*endpoint.Endpoint{suite.bar127A, suite.bar192A}), " legacy record's resource value will not match, should pick minimum")
 }
 
+func (suite *ResolverSuite) TestOldestResourceResolver() {
+	oldBar192A := withCreationTimestamp(suite.bar192A, "2018-01-01T00:00:00Z")
+	newBar127A := withCreationTimestamp(suite.bar127A, "2018-06-01T00:00:00Z")
+	sameAgeBar127A := withCreationTimestamp(suite.bar127A, "2018-01-01T00:00:00Z")
+
+	suite.Equal(oldBar192A, suite.oldestResource.ResolveCreate([]*endpoint.Endpoint{newBar127A, oldBar192A}), "should pick oldest one")
+	suite.Equal(oldBar192A, suite.oldestResource.ResolveCreate([]*endpoint.Endpoint{suite.bar127A, oldBar192A}), "should prefer endpoints with creation timestamp")
+	suite.Equal(suite.bar127A, suite.oldestResource.ResolveCreate([]*endpoint.Endpoint{suite.bar192A, suite.bar127A}), "should pick min one without creation timestamps")
+	suite.Equal(sameAgeBar127A, suite.oldestResource.ResolveCreate([]*endpoint.Endpoint{oldBar192A, sameAgeBar127A}), "should pick min one of same age")
+
+	suite.Equal(oldBar192A, suite.oldestResource.ResolveUpdate(newBar127A, []*endpoint.Endpoint{newBar127A, oldBar192A}), "should pick older resource over existing one")
+	suite.Equal(oldBar192A, suite.oldestResource.ResolveUpdate(suite.bar192A, []*endpoint.Endpoint{sameAgeBar127A, oldBar192A}), "should pick existing resource of same age")
+	suite.Equal(suite.bar192A, suite.oldestResource.ResolveUpdate(suite.bar192A, []*endpoint.Endpoint{suite.bar127A, suite.bar192A}), "should pick existing resource without creation timestamps")
+	suite.Equal(newBar127A, suite.oldestResource.ResolveUpdate(suite.legacyBar192A, []*endpoint.Endpoint{newBar127A, suite.bar127AAnother}), "should pick oldest one if resource was deleted")
+}
+
+func (suite *ResolverSuite) TestMergeTargetsResolver() {
+	merged := suite.mergeTargets.ResolveCreate([]*endpoint.Endpoint{suite.bar192A, suite.bar127A, suite.bar127AAnother})
+	suite.Equal(endpoint.Targets{"127.0.0.1", "192.168.0.1", "8.8.8.8"}, merged.Targets, "should merge targets of all candidates")
+	suite.Equal(suite.bar127A.Labels, merged.Labels, "should be owned by min one")
+	suite.Equal(endpoint.Targets{"192.168.0.1"}, suite.bar192A.Targets, "should not modify candidates")
+
+	merged = suite.mergeTargets.ResolveUpdate(suite.bar192A, []*endpoint.Endpoint{suite.bar127A, suite.bar192A})
+	suite.Equal(endpoint.Targets{"127.0.0.1", "192.168.0.1"}, merged.Targets, "should merge targets of all candidates")
+	suite.Equal(suite.bar192A.Labels, merged.Labels, "should be owned by existing resource")
+
+	merged = suite.mergeTargets.ResolveCreate([]*endpoint.Endpoint{suite.fooA5, suite.fooV1Cname})
+	suite.Equal(endpoint.Targets{"5.5.5.5"}, merged.Targets, "should only merge targets of same type")
+
+	suite.Equal(suite.fooV1Cname, suite.mergeTargets.ResolveCreate([]*endpoint.Endpoint{suite.fooV2Cname, suite.fooV1Cname}), "should not merge CNAME records")
+	suite.Nil(suite.mergeTargets.ResolveCreate([]*endpoint.Endpoint{}), "should not pick any without candidates")
+}
+
+func withCreationTimestamp(ep *endpoint.Endpoint, timestamp string) *endpoint.Endpoint {
+	labels := endpoint.NewLabels()
+	for k, v := range ep.Labels {
+		labels[k] = v
+	}
+	labels[endpoint.CreationTimestampLabelKey] = timestamp
+	return &endpoint.Endpoint{
+		DNSName:    ep.DNSName,
+		Targets:    ep.Targets,
+		RecordType: ep.RecordType,
+		Labels:     labels,
+	}
+}
+
 func TestConflictResolver(t *testing.T) {
 	suite.Run(t, new(ResolverSuite))
 }
@@ -0,0 +1,80 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"fmt"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+)
+
+// Guard limits how many records a single synchronization may delete or update.
+// It protects against sources that temporarily return no or wrong endpoints,
+// e.g. while access to the Kubernetes API is broken, which would otherwise
+// remove every owned record. Thresholds set to zero are disabled.
+type Guard struct {
+	// OwnerID restricts the counted records to those owned by this instance
+	OwnerID string
+	// MaxDeletes is the maximum number of records deleted at once
+	MaxDeletes int
+	// MaxDeletesPercent is the maximum share of owned records deleted at once
+	MaxDeletesPercent float64
+	// MaxUpdates is the maximum number of records updated at once
+	MaxUpdates int
+	// MaxUpdatesPercent is the maximum share of owned records updated at once
+	MaxUpdatesPercent float64
+}
+
+// Check returns an error if the changes of the calculated plan exceed any of the thresholds.
+func (g *Guard) Check(p *Plan) error {
+	if p.Changes == nil {
+		return nil
+	}
+
+	owned := g.countOwned(filterRecordsForPlan(p.Current))
+	deletes := g.countOwned(p.Changes.Delete)
+	updates := g.countOwned(p.Changes.UpdateOld)
+
+	if err := checkThreshold("delete", deletes, owned, g.MaxDeletes, g.MaxDeletesPercent); err != nil {
+		return err
+	}
+	return checkThreshold("update", updates, owned, g.MaxUpdates, g.MaxUpdatesPercent)
+}
+
+// countOwned returns the number of records owned by the guarded instance, changes of
+// other records are dropped by the registry and don't need to be limited.
+func (g *Guard) countOwned(records []*endpoint.Endpoint) int {
+	count := 0
+	for _, r := range records {
+		if r.Labels[endpoint.OwnerLabelKey] == g.OwnerID {
+			count++
+		}
+	}
+	return count
+}
+
+func checkThreshold(action string, changed, owned, max int, maxPercent float64) error {
+	if max > 0 && changed > max {
+		return fmt.Errorf("plan would %s %d records, more than the allowed %d", action, changed, max)
+	}
+	if maxPercent > 0 && owned > 0 {
+		if percent := float64(changed) * 100 / float64(owned); percent > maxPercent {
+			return fmt.Errorf("plan would %s %d of %d records (%.1f%%), more than the allowed %.1f%%", action, changed, owned, percent, maxPercent)
+		}
+	}
+	return nil
+}
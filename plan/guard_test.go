@@ -0,0 +1,107 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+func ownedRecords(owner string, count int) []*endpoint.Endpoint {
+	records := []*endpoint.Endpoint{}
+	for i := 0; i < count; i++ {
+		ep := endpoint.NewEndpoint(fmt.Sprintf("record-%d.example.org", i), endpoint.RecordTypeA, "1.2.3.4")
+		ep.Labels[endpoint.OwnerLabelKey] = owner
+		records = append(records, ep)
+	}
+	return records
+}
+
+func TestGuardCheck(t *testing.T) {
+	owned := ownedRecords("owner", 10)
+	foreign := ownedRecords("other", 90)
+	current := append(append([]*endpoint.Endpoint{}, owned...), foreign...)
+
+	for _, tc := range []struct {
+		title   string
+		guard   Guard
+		changes *Changes
+		wantErr bool
+	}{
+		{
+			title:   "disabled guard allows everything",
+			guard:   Guard{OwnerID: "owner"},
+			changes: &Changes{Delete: current, UpdateOld: current},
+		},
+		{
+			title:   "deletes within absolute threshold",
+			guard:   Guard{OwnerID: "owner", MaxDeletes: 3},
+			changes: &Changes{Delete: owned[:3]},
+		},
+		{
+			title:   "deletes above absolute threshold",
+			guard:   Guard{OwnerID: "owner", MaxDeletes: 3},
+			changes: &Changes{Delete: owned[:4]},
+			wantErr: true,
+		},
+		{
+			title:   "foreign deletes are not counted",
+			guard:   Guard{OwnerID: "owner", MaxDeletes: 3},
+			changes: &Changes{Delete: foreign},
+		},
+		{
+			title:   "deletes within percentage threshold",
+			guard:   Guard{OwnerID: "owner", MaxDeletesPercent: 50},
+			changes: &Changes{Delete: owned[:5]},
+		},
+		{
+			title:   "deletes above percentage threshold",
+			guard:   Guard{OwnerID: "owner", MaxDeletesPercent: 50},
+			changes: &Changes{Delete: owned[:6]},
+			wantErr: true,
+		},
+		{
+			title:   "updates above absolute threshold",
+			guard:   Guard{OwnerID: "owner", MaxUpdates: 1},
+			changes: &Changes{UpdateOld: owned[:2], UpdateNew: owned[:2]},
+			wantErr: true,
+		},
+		{
+			title:   "updates above percentage threshold",
+			guard:   Guard{OwnerID: "owner", MaxUpdatesPercent: 10},
+			changes: &Changes{UpdateOld: owned[:2], UpdateNew: owned[:2]},
+			wantErr: true,
+		},
+		{
+			title:   "creates are not limited",
+			guard:   Guard{OwnerID: "owner", MaxDeletes: 1, MaxUpdates: 1},
+			changes: &Changes{Create: foreign},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			err := tc.guard.Check(&Plan{Current: current, Changes: tc.changes})
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+)
+
+// Formats supported by WriteChanges
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// IsEmpty returns true if there are no changes to apply.
+func (c *Changes) IsEmpty() bool {
+	return len(c.Create) == 0 && len(c.UpdateNew) == 0 && len(c.UpdateOld) == 0 && len(c.Delete) == 0
+}
+
+// WriteChanges renders the changes in the given format, records are sorted by name and type.
+func WriteChanges(w io.Writer, changes *Changes, format string) error {
+	sorted, err := sortedChanges(changes)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case OutputTable:
+		return writeTable(w, sorted)
+	case OutputJSON:
+		out, err := json.MarshalIndent(sorted, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	case OutputYAML:
+		out, err := yaml.Marshal(sorted)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// sortedChanges returns a copy of the changes with all lists sorted, updates
+// are sorted pairwise to keep the old and new version of a record at the same index.
+func sortedChanges(changes *Changes) (*Changes, error) {
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		return nil, fmt.Errorf("invalid changes: %d old and %d new versions of updated records", len(changes.UpdateOld), len(changes.UpdateNew))
+	}
+
+	sorted := &Changes{
+		Create:    append([]*endpoint.Endpoint{}, changes.Create...),
+		UpdateOld: []*endpoint.Endpoint{},
+		UpdateNew: []*endpoint.Endpoint{},
+		Delete:    append([]*endpoint.Endpoint{}, changes.Delete...),
+	}
+	sortEndpoints(sorted.Create)
+	sortEndpoints(sorted.Delete)
+
+	updates := make([]int, len(changes.UpdateNew))
+	for i := range updates {
+		updates[i] = i
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return lessEndpoint(changes.UpdateNew[updates[i]], changes.UpdateNew[updates[j]])
+	})
+	for _, i := range updates {
+		sorted.UpdateOld = append(sorted.UpdateOld, changes.UpdateOld[i])
+		sorted.UpdateNew = append(sorted.UpdateNew, changes.UpdateNew[i])
+	}
+
+	return sorted, nil
+}
+
+func sortEndpoints(endpoints []*endpoint.Endpoint) {
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return lessEndpoint(endpoints[i], endpoints[j])
+	})
+}
+
+func lessEndpoint(x, y *endpoint.Endpoint) bool {
+	if x.DNSName != y.DNSName {
+		return x.DNSName < y.DNSName
+	}
+	if x.RecordType != y.RecordType {
+		return x.RecordType < y.RecordType
+	}
+	return x.SetIdentifier < y.SetIdentifier
+}
+
+// writeTable renders the changes as a diff, created records are marked with "+",
+// updated ones with "~" and deleted ones with "-".
+func writeTable(w io.Writer, changes *Changes) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "\tNAME\tTYPE\tTTL\tTARGETS\tOWNER\tRESOURCE")
+	for _, ep := range changes.Create {
+		writeRow(tw, "+", ep, fmt.Sprint(ep.RecordTTL), targetsString(ep.Targets))
+	}
+	for i, ep := range changes.UpdateNew {
+		old := changes.UpdateOld[i]
+		ttl := fmt.Sprint(ep.RecordTTL)
+		if old.RecordTTL != ep.RecordTTL {
+			ttl = fmt.Sprintf("%d -> %d", old.RecordTTL, ep.RecordTTL)
+		}
+		targets := targetsString(ep.Targets)
+		if !old.Targets.Same(ep.Targets) {
+			targets = fmt.Sprintf("%s -> %s", targetsString(old.Targets), targets)
+		}
+		writeRow(tw, "~", ep, ttl, targets)
+	}
+	for _, ep := range changes.Delete {
+		writeRow(tw, "-", ep, fmt.Sprint(ep.RecordTTL), targetsString(ep.Targets))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d to create, %d to update, %d to delete.\n", len(changes.Create), len(changes.UpdateNew), len(changes.Delete))
+	return err
+}
+
+func writeRow(w io.Writer, action string, ep *endpoint.Endpoint, ttl, targets string) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", action, ep.DNSName, ep.RecordType, ttl, targets, ep.Labels[endpoint.OwnerLabelKey], ep.Labels[endpoint.ResourceLabelKey])
+}
+
+func targetsString(targets endpoint.Targets) string {
+	return strings.Join(targets, ",")
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+)
+
+func testChanges() *Changes {
+	return &Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "foo.example.org", RecordType: "A", Targets: endpoint.Targets{"1.2.3.4"}, RecordTTL: 300, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner", endpoint.ResourceLabelKey: "service/default/foo"}},
+			{DNSName: "bar.example.org", RecordType: "CNAME", Targets: endpoint.Targets{"lb.example.com"}, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner", endpoint.ResourceLabelKey: "ingress/default/bar"}},
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			{DNSName: "qux.example.org", RecordType: "A", Targets: endpoint.Targets{"8.8.8.8"}, RecordTTL: 300, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner"}},
+			{DNSName: "baz.example.org", RecordType: "A", Targets: endpoint.Targets{"1.1.1.1"}, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner"}},
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			{DNSName: "qux.example.org", RecordType: "A", Targets: endpoint.Targets{"8.8.4.4"}, RecordTTL: 300, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner", endpoint.ResourceLabelKey: "service/default/qux"}},
+			{DNSName: "baz.example.org", RecordType: "A", Targets: endpoint.Targets{"1.1.1.1"}, RecordTTL: 60, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner", endpoint.ResourceLabelKey: "service/default/baz"}},
+		},
+		Delete: []*endpoint.Endpoint{
+			{DNSName: "old.example.org", RecordType: "A", Targets: endpoint.Targets{"4.3.2.1", "4.3.2.2"}, Labels: endpoint.Labels{endpoint.OwnerLabelKey: "owner", endpoint.ResourceLabelKey: "ingress/default/old"}},
+		},
+	}
+}
+
+func TestWriteChangesTable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteChanges(buf, testChanges(), OutputTable))
+
+	expected := `   NAME             TYPE   TTL      TARGETS             OWNER  RESOURCE
++  bar.example.org  CNAME  0        lb.example.com      owner  ingress/default/bar
++  foo.example.org  A      300      1.2.3.4             owner  service/default/foo
+~  baz.example.org  A      0 -> 60  1.1.1.1             owner  service/default/baz
+~  qux.example.org  A      300      8.8.8.8 -> 8.8.4.4  owner  service/default/qux
+-  old.example.org  A      0        4.3.2.1,4.3.2.2     owner  ingress/default/old
+
+2 to create, 2 to update, 1 to delete.
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteChangesJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteChanges(buf, &Changes{Delete: testChanges().Delete}, OutputJSON))
+
+	expected := `{
+  "create": [],
+  "updateOld": [],
+  "updateNew": [],
+  "delete": [
+    {
+      "dnsName": "old.example.org",
+      "targets": [
+        "4.3.2.1",
+        "4.3.2.2"
+      ],
+      "recordType": "A",
+      "labels": {
+        "owner": "owner",
+        "resource": "ingress/default/old"
+      }
+    }
+  ]
+}
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteChangesYAML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteChanges(buf, &Changes{Create: testChanges().Create[:1]}, OutputYAML))
+
+	expected := `create:
+- dnsName: foo.example.org
+  labels:
+    owner: owner
+    resource: service/default/foo
+  recordTTL: 300
+  recordType: A
+  targets:
+  - 1.2.3.4
+delete: []
+updateNew: []
+updateOld: []
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteChangesUnknownFormat(t *testing.T) {
+	assert.Error(t, WriteChanges(&bytes.Buffer{}, &Changes{}, "xml"))
+}
+
+func TestWriteChangesUnpairedUpdates(t *testing.T) {
+	changes := testChanges()
+	changes.UpdateOld = changes.UpdateOld[:1]
+
+	for _, format := range []string{OutputTable, OutputJSON, OutputYAML} {
+		buf := &bytes.Buffer{}
+		assert.Error(t, WriteChanges(buf, changes, format), format)
+		assert.Empty(t, buf.String(), format)
+	}
+}
+
+func TestChangesIsEmpty(t *testing.T) {
+	assert.True(t, (&Changes{}).IsEmpty())
+	assert.False(t, testChanges().IsEmpty())
+}
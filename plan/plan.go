@@ -18,8 +18,11 @@ package plan
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 )
 
@@ -32,6 +35,9 @@ type Plan struct {
 	Desired []*endpoint.Endpoint
 	// Policies under which the desired changes are calculated
 	Policies []Policy
+	// ConflictResolver decides which of several desired records acquires a dns name,
+	// defaults to PerResource if not set
+	ConflictResolver ConflictResolver
 	// List of changes necessary to move towards desired state
 	// Populated after calling Calculate()
 	Changes *Changes
@@ -40,40 +46,64 @@ type Plan struct {
 // Changes holds lists of actions to be executed by dns providers
 type Changes struct {
 	// Records that need to be created
-	Create []*endpoint.Endpoint
+	Create []*endpoint.Endpoint `json:"create"`
 	// Records that need to be updated (current data)
-	UpdateOld []*endpoint.Endpoint
+	UpdateOld []*endpoint.Endpoint `json:"updateOld"`
 	// Records that need to be updated (desired data)
-	UpdateNew []*endpoint.Endpoint
-	// Records that need to be deleted
-	Delete []*endpoint.Endpoint
+	UpdateNew []*endpoint.Endpoint `json:"updateNew"`
+	// Records that need to be deleted. Providers have to apply them before the
+	// creations, a record changing its type is deleted and created again.
+	Delete []*endpoint.Endpoint `json:"delete"`
 }
 
 // planTable is a supplementary struct for Plan
-// each row correspond to a dnsName -> (current record + all desired records)
+// each row correspond to a (dnsName, recordType) -> (current record + all desired records)
 /*
 planTable: (-> = target)
---------------------------------------------------------
-DNSName | Current record | Desired Records             |
---------------------------------------------------------
-foo.com | -> 1.1.1.1     | [->1.1.1.1, ->elb.com]      |  = no action
---------------------------------------------------------
-bar.com |                | [->191.1.1.1, ->190.1.1.1]  |  = create (bar.com -> 190.1.1.1)
---------------------------------------------------------
+----------------------------------------------------------------
+DNSName | Type  | Current record | Desired Records             |
+----------------------------------------------------------------
+foo.com | A     | -> 1.1.1.1     | [->1.1.1.1]                 |  = no action
+----------------------------------------------------------------
+foo.com | CNAME |                | [->elb.com]                 |  = conflicts with A, see below
+----------------------------------------------------------------
+bar.com | A     |                | [->191.1.1.1, ->190.1.1.1]  |  = create (bar.com -> 190.1.1.1)
+----------------------------------------------------------------
+baz.com | CNAME | -> elb.com     |                             |  = delete (baz.com -> elb.com)
+----------------------------------------------------------------
+baz.com | A     |                | [->1.2.3.4]                 |  = create (baz.com -> 1.2.3.4)
+----------------------------------------------------------------
 "=", i.e. result of calculation relies on supplied ConflictResolver
+
+Per RFC 1034 a CNAME can't coexist with any other record of the same name. If
+a name has desired CNAME and non-CNAME records, the ConflictResolver picks a
+winner among all of them and the candidates of the losing types are dropped.
+A record changing its type therefore shows up as the deletion of the old row
+and the creation of the new one.
 */
 type planTable struct {
-	rows     map[string]*planTableRow
+	rows     map[planKey]*planTableRow
 	resolver ConflictResolver
 }
 
-func newPlanTable() planTable { //TODO: make resolver configurable
-	return planTable{map[string]*planTableRow{}, PerResource{}}
+// planKey identifies a row of the planTable. Records with a routing policy share
+// their name and type and are told apart by their set identifier.
+type planKey struct {
+	dnsName       string
+	recordType    string
+	setIdentifier string
+}
+
+func newPlanTable(resolver ConflictResolver) planTable {
+	if resolver == nil {
+		resolver = PerResource{}
+	}
+	return planTable{map[planKey]*planTableRow{}, resolver}
 }
 
 // planTableRow
-// current corresponds to the record currently occupying dns name on the dns provider
-// candidates corresponds to the list of records which would like to have this dnsName
+// current corresponds to the record currently occupying dns name and type on the dns provider
+// candidates corresponds to the list of records which would like to have this dnsName and type
 type planTableRow struct {
 	current    *endpoint.Endpoint
 	candidates []*endpoint.Endpoint
@@ -84,28 +114,86 @@ func (t planTableRow) String() string {
 }
 
 func (t planTable) addCurrent(e *endpoint.Endpoint) {
-	dnsName := normalizeDNSName(e.DNSName)
-	if _, ok := t.rows[dnsName]; !ok {
-		t.rows[dnsName] = &planTableRow{}
+	key := newPlanKey(e)
+	if _, ok := t.rows[key]; !ok {
+		t.rows[key] = &planTableRow{}
 	}
-	t.rows[dnsName].current = e
+	t.rows[key].current = e
 }
 
 func (t planTable) addCandidate(e *endpoint.Endpoint) {
-	dnsName := normalizeDNSName(e.DNSName)
-	if _, ok := t.rows[dnsName]; !ok {
-		t.rows[dnsName] = &planTableRow{}
+	key := newPlanKey(e)
+	if _, ok := t.rows[key]; !ok {
+		t.rows[key] = &planTableRow{}
+	}
+	t.rows[key].candidates = append(t.rows[key].candidates, e)
+}
+
+func newPlanKey(e *endpoint.Endpoint) planKey {
+	return planKey{dnsName: normalizeDNSName(e.DNSName), recordType: e.RecordType, setIdentifier: e.SetIdentifier}
+}
+
+// resolveCNAMEConflicts makes sure that a DNS name either has desired CNAME
+// records or desired records of other types, but never both.
+func (t planTable) resolveCNAMEConflicts() {
+	byName := map[string][]planKey{}
+	for key := range t.rows {
+		byName[key.dnsName] = append(byName[key.dnsName], key)
+	}
+
+	for dnsName, keys := range byName {
+		// sort for a deterministic choice of the current record below
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].recordType != keys[j].recordType {
+				return keys[i].recordType < keys[j].recordType
+			}
+			return keys[i].setIdentifier < keys[j].setIdentifier
+		})
+
+		var current *endpoint.Endpoint
+		var candidates []*endpoint.Endpoint
+		hasCNAME, hasOther := false, false
+		for _, key := range keys {
+			row := t.rows[key]
+			if len(row.candidates) == 0 {
+				continue
+			}
+			if key.recordType == endpoint.RecordTypeCNAME {
+				hasCNAME = true
+			} else {
+				hasOther = true
+			}
+			if current == nil && row.current != nil {
+				current = row.current
+			}
+			candidates = append(candidates, row.candidates...)
+		}
+		if !hasCNAME || !hasOther {
+			continue
+		}
+
+		var winner *endpoint.Endpoint
+		if current != nil {
+			winner = t.resolver.ResolveUpdate(current, candidates)
+		} else {
+			winner = t.resolver.ResolveCreate(candidates)
+		}
+		log.Warnf("Desired records of %s conflict with each other, CNAME records can't coexist with other records. Using %s records.", dnsName, winner.RecordType)
+
+		for _, key := range keys {
+			if (key.recordType == endpoint.RecordTypeCNAME) != (winner.RecordType == endpoint.RecordTypeCNAME) {
+				t.rows[key].candidates = nil
+			}
+		}
 	}
-	t.rows[dnsName].candidates = append(t.rows[dnsName].candidates, e)
 }
 
-// TODO: allows record type change, which might not be supported by all dns providers
 func (t planTable) getUpdates() (updateNew []*endpoint.Endpoint, updateOld []*endpoint.Endpoint) {
 	for _, row := range t.rows {
-		if row.current != nil && len(row.candidates) > 0 { //dns name is taken
+		if row.current != nil && len(row.candidates) > 0 { //dns name and type is taken
 			update := t.resolver.ResolveUpdate(row.current, row.candidates)
 			// compare "update" to "current" to figure out if actual update is required
-			if shouldUpdateTTL(update, row.current) || targetChanged(update, row.current) {
+			if shouldUpdateTTL(update, row.current) || targetChanged(update, row.current) || shouldUpdateProviderSpecific(update, row.current) {
 				inheritOwner(row.current, update)
 				updateNew = append(updateNew, update)
 				updateOld = append(updateOld, row.current)
@@ -118,7 +206,7 @@ func (t planTable) getUpdates() (updateNew []*endpoint.Endpoint, updateOld []*en
 
 func (t planTable) getCreates() (createList []*endpoint.Endpoint) {
 	for _, row := range t.rows {
-		if row.current == nil { //dns name not taken
+		if row.current == nil && len(row.candidates) > 0 { //dns name and type not taken
 			createList = append(createList, t.resolver.ResolveCreate(row.candidates))
 		}
 	}
@@ -138,7 +226,7 @@ func (t planTable) getDeletes() (deleteList []*endpoint.Endpoint) {
 // state. It then passes those changes to the current policy for further
 // processing. It returns a copy of Plan with the changes populated.
 func (p *Plan) Calculate() *Plan {
-	t := newPlanTable()
+	t := newPlanTable(p.ConflictResolver)
 
 	for _, current := range filterRecordsForPlan(p.Current) {
 		t.addCurrent(current)
@@ -146,6 +234,7 @@ func (p *Plan) Calculate() *Plan {
 	for _, desired := range filterRecordsForPlan(p.Desired) {
 		t.addCandidate(desired)
 	}
+	t.resolveCNAMEConflicts()
 
 	changes := &Changes{}
 	changes.Create = t.getCreates()
@@ -185,21 +274,40 @@ func shouldUpdateTTL(desired, current *endpoint.Endpoint) bool {
 	return desired.RecordTTL != current.RecordTTL
 }
 
+// shouldUpdateProviderSpecific returns true if a provider specific property known to
+// both records changed, e.g. the weight of a record. Properties which the provider
+// doesn't return for its records are not compared.
+func shouldUpdateProviderSpecific(desired, current *endpoint.Endpoint) bool {
+	for key, value := range desired.ProviderSpecific {
+		if currentValue, ok := current.ProviderSpecific[key]; ok && currentValue != value {
+			return true
+		}
+	}
+	return false
+}
+
+// ConflictingTypes reports whether the records x and y can't exist side by side.
+// Per RFC 1034, CNAME records conflict with all other records of the same name -
+// it is the only record with this property. A deletion and a creation of
+// conflicting records in the same Changes replace one record with the other,
+// so the deletion has to be applied first.
+func ConflictingTypes(x, y *endpoint.Endpoint) bool {
+	if x.RecordType == y.RecordType || normalizeDNSName(x.DNSName) != normalizeDNSName(y.DNSName) {
+		return false
+	}
+	return x.RecordType == endpoint.RecordTypeCNAME || y.RecordType == endpoint.RecordTypeCNAME
+}
+
 // filterRecordsForPlan removes records that are not relevant to the planner.
 // Currently this just removes TXT records to prevent them from being
 // deleted erroneously by the planner (only the TXT registry should do this.)
-//
-// Per RFC 1034, CNAME records conflict with all other records - it is the
-// only record with this property. The behavior of the planner may need to be
-// made more sophisticated to codify this.
 func filterRecordsForPlan(records []*endpoint.Endpoint) []*endpoint.Endpoint {
 	filtered := []*endpoint.Endpoint{}
 
 	for _, record := range records {
 		// Explicitly specify which records we want to use for planning.
-		// TODO: Add AAAA records as well when they are supported.
 		switch record.RecordType {
-		case endpoint.RecordTypeA, endpoint.RecordTypeCNAME:
+		case endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME:
 			filtered = append(filtered, record)
 		default:
 			continue
@@ -36,6 +36,8 @@ type PlanTestSuite struct {
 	bar127A                *endpoint.Endpoint
 	bar127AWithTTL         *endpoint.Endpoint
 	bar192A                *endpoint.Endpoint
+	barAAAA                *endpoint.Endpoint
+	barCname               *endpoint.Endpoint
 }
 
 func (suite *PlanTestSuite) SetupTest() {
@@ -108,6 +110,22 @@ func (suite *PlanTestSuite) SetupTest() {
 			endpoint.ResourceLabelKey: "ingress/default/bar-192",
 		},
 	}
+	suite.barAAAA = &endpoint.Endpoint{
+		DNSName:    "bar",
+		Targets:    endpoint.Targets{"2001:db8::1"},
+		RecordType: "AAAA",
+		Labels: map[string]string{
+			endpoint.ResourceLabelKey: "ingress/default/bar-127",
+		},
+	}
+	suite.barCname = &endpoint.Endpoint{
+		DNSName:    "bar",
+		Targets:    endpoint.Targets{"elb.com"},
+		RecordType: "CNAME",
+		Labels: map[string]string{
+			endpoint.ResourceLabelKey: "ingress/default/bar-127",
+		},
+	}
 }
 
 func (suite *PlanTestSuite) TestSyncFirstRound() {
@@ -249,9 +267,93 @@ func (suite *PlanTestSuite) TestIdempotency() {
 func (suite *PlanTestSuite) TestDifferentTypes() {
 	current := []*endpoint.Endpoint{suite.fooV1Cname}
 	desired := []*endpoint.Endpoint{suite.fooV2Cname, suite.fooA5}
+	expectedCreate := []*endpoint.Endpoint{suite.fooA5}
+	expectedUpdateOld := []*endpoint.Endpoint{}
+	expectedUpdateNew := []*endpoint.Endpoint{}
+	expectedDelete := []*endpoint.Endpoint{suite.fooV1Cname}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
+func (suite *PlanTestSuite) TestTypeChangeFromAToCNAME() {
+	current := []*endpoint.Endpoint{suite.fooA5}
+	desired := []*endpoint.Endpoint{suite.fooV1Cname}
+	expectedCreate := []*endpoint.Endpoint{suite.fooV1Cname}
+	expectedUpdateOld := []*endpoint.Endpoint{}
+	expectedUpdateNew := []*endpoint.Endpoint{}
+	expectedDelete := []*endpoint.Endpoint{suite.fooA5}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
+func (suite *PlanTestSuite) TestCNAMEConflictKeepsCurrentResource() {
+	current := []*endpoint.Endpoint{suite.fooV1Cname}
+	desired := []*endpoint.Endpoint{suite.fooA5, suite.fooV1Cname}
 	expectedCreate := []*endpoint.Endpoint{}
-	expectedUpdateOld := []*endpoint.Endpoint{suite.fooV1Cname}
-	expectedUpdateNew := []*endpoint.Endpoint{suite.fooA5}
+	expectedUpdateOld := []*endpoint.Endpoint{}
+	expectedUpdateNew := []*endpoint.Endpoint{}
+	expectedDelete := []*endpoint.Endpoint{}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
+func (suite *PlanTestSuite) TestCNAMEConflictReplacesDualStack() {
+	current := []*endpoint.Endpoint{suite.bar127A, suite.barAAAA}
+	desired := []*endpoint.Endpoint{suite.barCname}
+	expectedCreate := []*endpoint.Endpoint{suite.barCname}
+	expectedUpdateOld := []*endpoint.Endpoint{}
+	expectedUpdateNew := []*endpoint.Endpoint{}
+	expectedDelete := []*endpoint.Endpoint{suite.bar127A, suite.barAAAA}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
+func (suite *PlanTestSuite) TestDualStack() {
+	current := []*endpoint.Endpoint{suite.bar127A}
+	desired := []*endpoint.Endpoint{suite.bar127A, suite.barAAAA}
+	expectedCreate := []*endpoint.Endpoint{suite.barAAAA}
+	expectedUpdateOld := []*endpoint.Endpoint{}
+	expectedUpdateNew := []*endpoint.Endpoint{}
 	expectedDelete := []*endpoint.Endpoint{}
 
 	p := &Plan{
@@ -352,6 +454,98 @@ func (suite *PlanTestSuite) TestDuplicatedEndpointsForSameResourceReplace() {
 	validateEntries(suite.T(), changes.Delete, expectedDelete)
 }
 
+func (suite *PlanTestSuite) TestMergeTargetsResolver() {
+	current := []*endpoint.Endpoint{suite.bar127A}
+	desired := []*endpoint.Endpoint{suite.bar127A, suite.bar192A}
+	expectedCreate := []*endpoint.Endpoint{}
+	expectedUpdateOld := []*endpoint.Endpoint{suite.bar127A}
+	expectedUpdateNew := []*endpoint.Endpoint{{
+		DNSName:    "bar",
+		Targets:    endpoint.Targets{"127.0.0.1", "192.168.0.1"},
+		RecordType: "A",
+		Labels:     suite.bar127A.Labels,
+	}}
+	expectedDelete := []*endpoint.Endpoint{}
+
+	p := &Plan{
+		Policies:         []Policy{&SyncPolicy{}},
+		ConflictResolver: ConflictResolvers["merge-targets"],
+		Current:          current,
+		Desired:          desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
+func (suite *PlanTestSuite) TestSetIdentifiers() {
+	blue := &endpoint.Endpoint{
+		DNSName:          "bar",
+		Targets:          endpoint.Targets{"127.0.0.1"},
+		RecordType:       "A",
+		SetIdentifier:    "blue",
+		ProviderSpecific: endpoint.ProviderSpecific{"aws/weight": "100"},
+	}
+	green := &endpoint.Endpoint{
+		DNSName:          "bar",
+		Targets:          endpoint.Targets{"192.168.0.1"},
+		RecordType:       "A",
+		SetIdentifier:    "green",
+		ProviderSpecific: endpoint.ProviderSpecific{"aws/weight": "0"},
+	}
+	greenWeighted := &endpoint.Endpoint{
+		DNSName:          "bar",
+		Targets:          endpoint.Targets{"192.168.0.1"},
+		RecordType:       "A",
+		SetIdentifier:    "green",
+		ProviderSpecific: endpoint.ProviderSpecific{"aws/weight": "100"},
+	}
+	current := []*endpoint.Endpoint{blue, green}
+	desired := []*endpoint.Endpoint{blue, greenWeighted}
+	expectedCreate := []*endpoint.Endpoint{}
+	expectedUpdateOld := []*endpoint.Endpoint{green}
+	expectedUpdateNew := []*endpoint.Endpoint{greenWeighted}
+	expectedDelete := []*endpoint.Endpoint{}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
+func (suite *PlanTestSuite) TestProviderSpecificOnlyComparedIfKnown() {
+	current := []*endpoint.Endpoint{suite.bar127A.WithProviderSpecific("aws/evaluate-target-health", "true")}
+	desired := []*endpoint.Endpoint{{
+		DNSName:          "bar",
+		Targets:          endpoint.Targets{"127.0.0.1"},
+		RecordType:       "A",
+		Labels:           suite.bar127A.Labels,
+		ProviderSpecific: endpoint.ProviderSpecific{"alias": "true"},
+	}}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, []*endpoint.Endpoint{})
+	validateEntries(suite.T(), changes.UpdateNew, []*endpoint.Endpoint{})
+	validateEntries(suite.T(), changes.UpdateOld, []*endpoint.Endpoint{})
+	validateEntries(suite.T(), changes.Delete, []*endpoint.Endpoint{})
+}
+
 //TODO: remove once multiple-target per endpoint is supported
 func (suite *PlanTestSuite) TestDuplicatedEndpointsForSameResourceRetain() {
 	current := []*endpoint.Endpoint{suite.fooV1Cname, suite.bar192A}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package plan
 
+import "github.com/kubernetes-incubator/external-dns/endpoint"
+
 // Policy allows to apply different rules to a set of changes.
 type Policy interface {
 	Apply(changes *Changes) *Changes
@@ -38,11 +40,28 @@ func (p *SyncPolicy) Apply(changes *Changes) *Changes {
 // UpsertOnlyPolicy allows evrything but deleting DNS records.
 type UpsertOnlyPolicy struct{}
 
-// Apply applies the upsert-only policy which strips out any deletions.
+// Apply applies the upsert-only policy which strips out any deletions. Deletions
+// of records that are replaced by a record of a conflicting type are kept, as
+// they are part of an update rather than a removal.
 func (p *UpsertOnlyPolicy) Apply(changes *Changes) *Changes {
 	return &Changes{
 		Create:    changes.Create,
 		UpdateOld: changes.UpdateOld,
 		UpdateNew: changes.UpdateNew,
+		Delete:    replacedRecords(changes.Delete, changes.Create),
+	}
+}
+
+// replacedRecords returns the deletions that make room for one of the creations.
+func replacedRecords(deletes, creates []*endpoint.Endpoint) []*endpoint.Endpoint {
+	var replaced []*endpoint.Endpoint
+	for _, d := range deletes {
+		for _, c := range creates {
+			if ConflictingTypes(d, c) {
+				replaced = append(replaced, d)
+				break
+			}
+		}
 	}
+	return replaced
 }
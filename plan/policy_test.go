@@ -34,6 +34,9 @@ func TestApply(t *testing.T) {
 	// another two simple entries
 	bar := []*endpoint.Endpoint{{DNSName: "bar", Targets: endpoint.Targets{"v1"}}}
 	baz := []*endpoint.Endpoint{{DNSName: "baz", Targets: endpoint.Targets{"v1"}}}
+	// an entry changing its type from CNAME to A
+	quxCNAME := []*endpoint.Endpoint{{DNSName: "qux", Targets: endpoint.Targets{"v1"}, RecordType: endpoint.RecordTypeCNAME}}
+	quxA := []*endpoint.Endpoint{{DNSName: "qux", Targets: endpoint.Targets{"1.2.3.4"}, RecordType: endpoint.RecordTypeA}}
 
 	for _, tc := range []struct {
 		policy   Policy
@@ -52,6 +55,12 @@ func TestApply(t *testing.T) {
 			&Changes{Create: baz, UpdateOld: fooV1, UpdateNew: fooV2, Delete: bar},
 			&Changes{Create: baz, UpdateOld: fooV1, UpdateNew: fooV2, Delete: empty},
 		},
+		{
+			// UpsertOnlyPolicy keeps deletions of records replaced by a conflicting type.
+			&UpsertOnlyPolicy{},
+			&Changes{Create: quxA, Delete: append(bar, quxCNAME...)},
+			&Changes{Create: quxA, UpdateOld: empty, UpdateNew: empty, Delete: quxCNAME},
+		},
 	} {
 		// apply policy
 		changes := tc.policy.Apply(tc.changes)
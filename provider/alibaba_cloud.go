@@ -484,8 +484,8 @@ func (p *AlibabaCloudProvider) applyChangesForDNS(changes *plan.Changes) error {
 
 	recordMap := p.groupRecords(records)
 
-	p.createRecords(changes.Create)
 	p.deleteRecords(recordMap, changes.Delete)
+	p.createRecords(changes.Create)
 	p.updateRecords(recordMap, changes.UpdateNew)
 	return nil
 }
@@ -991,8 +991,8 @@ func (p *AlibabaCloudProvider) applyChangesForPrivateZone(changes *plan.Changes)
 		log.Debugf("%s: %++v", zoneName, zone)
 	}
 
-	p.createPrivateZoneRecords(zones, changes.Create)
 	p.deletePrivateZoneRecords(zones, changes.Delete)
+	p.createPrivateZoneRecords(zones, changes.Create)
 	p.updatePrivateZoneRecords(zones, changes.UpdateNew)
 	return nil
 }
@@ -17,8 +17,10 @@ limitations under the License.
 package provider
 
 import (
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -37,8 +39,47 @@ const (
 	// provider specific key that designates whether an AWS ALIAS record has the EvaluateTargetHealth
 	// field set to true.
 	providerSpecificEvaluateTargetHealth = "aws/evaluate-target-health"
+	// provider specific key that designates whether an alias to a load balancer is an AAAA record
+	// as well as an A record.
+	providerSpecificDualstack = "aws/dualstack"
+	// provider specific keys of the routing policies of records with a set identifier, the
+	// values are the ones of the Route53 API, e.g. PRIMARY or SECONDARY for failover records.
+	providerSpecificWeight                     = "aws/weight"
+	providerSpecificRegion                     = "aws/region"
+	providerSpecificFailover                   = "aws/failover"
+	providerSpecificGeolocationContinentCode   = "aws/geolocation-continent-code"
+	providerSpecificGeolocationCountryCode     = "aws/geolocation-country-code"
+	providerSpecificGeolocationSubdivisionCode = "aws/geolocation-subdivision-code"
+	// provider specific keys of the health check attached to a record. If health checks are
+	// managed, one is created for each record with a health check protocol.
+	providerSpecificHealthCheckID               = "aws/health-check-id"
+	providerSpecificHealthCheckProtocol         = "aws/health-check-protocol"
+	providerSpecificHealthCheckPath             = "aws/health-check-path"
+	providerSpecificHealthCheckPort             = "aws/health-check-port"
+	providerSpecificHealthCheckFailureThreshold = "aws/health-check-failure-threshold"
+	providerSpecificHealthCheckRequestInterval  = "aws/health-check-request-interval"
+	// healthCheckProtocolNone is the health check protocol of records without a health check, so that
+	// the health check of a record is detached once its annotations are removed
+	healthCheckProtocolNone = "none"
+	// tags identifying the health checks managed by ExternalDNS
+	healthCheckOwnerTag  = "external-dns/owner"
+	healthCheckRecordTag = "external-dns/record"
+	// maximum number of health checks whose tags can be listed at once
+	healthCheckTagsBatchSize = 10
 )
 
+// RoutingPolicyProviderSpecificKeys are the provider specific keys of the routing policy of a
+// record with a set identifier. Other records of the same name and set identifier, e.g. the TXT
+// record holding its ownership, need the same routing policy.
+var RoutingPolicyProviderSpecificKeys = []string{
+	providerSpecificWeight,
+	providerSpecificRegion,
+	providerSpecificFailover,
+	providerSpecificGeolocationContinentCode,
+	providerSpecificGeolocationCountryCode,
+	providerSpecificGeolocationSubdivisionCode,
+}
+
 var (
 	// see: https://docs.aws.amazon.com/general/latest/gr/rande.html#elb_region
 	canonicalHostedZones = map[string]string{
@@ -77,6 +118,28 @@ var (
 		"elb.eu-west-3.amazonaws.com":      "Z1CMS0P5QUZ6D5",
 		"elb.eu-north-1.amazonaws.com":     "Z1UDT6IFJ4EJM",
 		"elb.sa-east-1.amazonaws.com":      "ZTK26PT1VY4CU",
+		// CloudFront distributions
+		"cloudfront.net": "Z2FDTNDATAQYW2",
+		// S3 website endpoints, see: https://docs.aws.amazon.com/general/latest/gr/rande.html#s3_website_region_endpoints
+		"s3-website.us-east-2.amazonaws.com":      "Z2O1EMRO9K5GLX",
+		"s3-website-us-east-1.amazonaws.com":      "Z3AQBSTGFYJSTF",
+		"s3-website-us-west-1.amazonaws.com":      "Z2F56UZL2M1ACD",
+		"s3-website-us-west-2.amazonaws.com":      "Z3BJ6K6RIION7M",
+		"s3-website.ca-central-1.amazonaws.com":   "Z1QDHH18159H29",
+		"s3-website.ap-south-1.amazonaws.com":     "Z11RGJOFQNVJUP",
+		"s3-website.ap-northeast-2.amazonaws.com": "Z3W03O7B5YMIYP",
+		"s3-website.ap-northeast-3.amazonaws.com": "Z2YQB5RD63NC85",
+		"s3-website-ap-southeast-1.amazonaws.com": "Z3O0J2DXBE1FTB",
+		"s3-website-ap-southeast-2.amazonaws.com": "Z1WCIGYICN2BYD",
+		"s3-website-ap-northeast-1.amazonaws.com": "Z2M4EHUR26P7ZW",
+		"s3-website.eu-central-1.amazonaws.com":   "Z21DNDUVLTQW6Q",
+		"s3-website-eu-west-1.amazonaws.com":      "Z1BKCTXD74EZPE",
+		"s3-website.eu-west-2.amazonaws.com":      "Z3GKZC51ZF0DB4",
+		"s3-website.eu-west-3.amazonaws.com":      "Z3R1K369G5AVDG",
+		"s3-website.eu-north-1.amazonaws.com":     "Z3BAZG2TWCNX0D",
+		"s3-website-sa-east-1.amazonaws.com":      "Z7KQH4QJS55SO",
+		// Global Accelerator
+		"awsglobalaccelerator.com": "Z2BJ6XQ5FK7U4H",
 	}
 )
 
@@ -88,15 +151,31 @@ type Route53API interface {
 	CreateHostedZone(*route53.CreateHostedZoneInput) (*route53.CreateHostedZoneOutput, error)
 	ListHostedZonesPages(input *route53.ListHostedZonesInput, fn func(resp *route53.ListHostedZonesOutput, lastPage bool) (shouldContinue bool)) error
 	ListTagsForResource(input *route53.ListTagsForResourceInput) (*route53.ListTagsForResourceOutput, error)
+	ListTagsForResources(input *route53.ListTagsForResourcesInput) (*route53.ListTagsForResourcesOutput, error)
+	ChangeTagsForResource(input *route53.ChangeTagsForResourceInput) (*route53.ChangeTagsForResourceOutput, error)
+	ListHealthChecksPages(input *route53.ListHealthChecksInput, fn func(resp *route53.ListHealthChecksOutput, lastPage bool) (shouldContinue bool)) error
+	CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error)
+	UpdateHealthCheck(input *route53.UpdateHealthCheckInput) (*route53.UpdateHealthCheckOutput, error)
+	DeleteHealthCheck(input *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error)
 }
 
 // AWSProvider is an implementation of Provider for AWS Route53.
 type AWSProvider struct {
-	client               Route53API
+	client Route53API
+	// clients assuming the IAM roles of other accounts keyed by role ARN
+	roleClients map[string]Route53API
+	// IAM roles assumed for the hosted zones matching their filters in order of precedence,
+	// the hosted zones not matching any of them are managed by the default client
+	zoneRoles []awsZoneRole
+	// clients of the hosted zones found by the last call to Zones keyed by zone id
+	zoneClients map[string]Route53API
+
 	dryRun               bool
 	batchChangeSize      int
 	batchChangeInterval  time.Duration
 	evaluateTargetHealth bool
+	// alias load balancers by AAAA records as well unless a record says otherwise
+	dualstack bool
 	// only consider hosted zones managing domains ending in this suffix
 	domainFilter DomainFilter
 	// filter hosted zones by id
@@ -105,6 +184,86 @@ type AWSProvider struct {
 	zoneTypeFilter ZoneTypeFilter
 	// filter hosted zones by tags
 	zoneTagFilter ZoneTagFilter
+	// manage the health checks of records, they are tagged with the owner id
+	manageHealthChecks bool
+	ownerID            string
+	// owned health checks that weren't attached to a record at the last call to Records
+	orphanedHealthChecks []*healthCheck
+}
+
+// awsZoneRole is an IAM role assumed for the hosted zones whose domain or id matches one of its filters.
+type awsZoneRole struct {
+	role         string
+	domainFilter DomainFilter
+	zoneIDFilter ZoneIDFilter
+}
+
+// match returns true if the IAM role is assumed for the hosted zone.
+func (r awsZoneRole) match(zone *route53.HostedZone) bool {
+	return r.domainFilter.Match(aws.StringValue(zone.Name)) || r.zoneIDFilter.Match(aws.StringValue(zone.Id))
+}
+
+// newAWSZoneRoles parses mappings of IAM roles to the hosted zones they are assumed for,
+// e.g. `arn:aws:iam::123455567:role/external-dns=example.org,Z2ABCDEFGHIJKL`. Each hosted
+// zone is either given by a domain or by its id.
+func newAWSZoneRoles(mappings []string) ([]awsZoneRole, error) {
+	var zoneRoles []awsZoneRole
+	for _, mapping := range mappings {
+		if mapping == "" {
+			continue
+		}
+
+		// domains and zone ids can't contain "=" unlike the names of IAM roles
+		i := strings.LastIndex(mapping, "=")
+		if i <= 0 || i == len(mapping)-1 {
+			return nil, fmt.Errorf("invalid mapping of an IAM role to hosted zones: %s", mapping)
+		}
+
+		var filters []string
+		for _, filter := range strings.Split(mapping[i+1:], ",") {
+			if filter = strings.TrimSpace(filter); filter != "" {
+				filters = append(filters, filter)
+			}
+		}
+		if len(filters) == 0 {
+			return nil, fmt.Errorf("invalid mapping of an IAM role to hosted zones: %s", mapping)
+		}
+
+		zoneRoles = append(zoneRoles, awsZoneRole{
+			role:         mapping[:i],
+			domainFilter: NewDomainFilter(filters),
+			zoneIDFilter: NewZoneIDFilter(filters),
+		})
+	}
+
+	return zoneRoles, nil
+}
+
+// healthCheck is a health check owned by this instance, the record it belongs to
+// and the client of the account it was found in.
+type healthCheck struct {
+	*route53.HealthCheck
+	record string
+	client Route53API
+}
+
+// awsChange is a change of a record set along with the endpoint it was derived from.
+type awsChange struct {
+	*route53.Change
+	endpoint *endpoint.Endpoint
+}
+
+// ownedRecord returns the name of the record the change belongs to, which is the name of the
+// endpoint unless it is the TXT record of a registry holding the ownership of another record.
+// Changes belonging to the same record are submitted in the same batch.
+func (c *awsChange) ownedRecord() string {
+	if c.endpoint == nil {
+		return strings.TrimSuffix(aws.StringValue(c.ResourceRecordSet.Name), ".")
+	}
+	if name, ok := c.endpoint.Labels[endpoint.OwnedRecordLabelKey]; ok {
+		return name
+	}
+	return c.endpoint.DNSName
 }
 
 // AWSConfig contains configuration to create a new AWS provider.
@@ -116,13 +275,26 @@ type AWSConfig struct {
 	BatchChangeSize      int
 	BatchChangeInterval  time.Duration
 	EvaluateTargetHealth bool
+	Dualstack            bool
 	AssumeRole           string
+	ZoneRoles            []string
 	APIRetries           int
+	ManageHealthChecks   bool
+	OwnerID              string
 	DryRun               bool
 }
 
 // NewAWSProvider initializes a new AWS Route53 based Provider.
 func NewAWSProvider(awsConfig AWSConfig) (*AWSProvider, error) {
+	if awsConfig.ManageHealthChecks && awsConfig.OwnerID == "" {
+		return nil, errors.New("owner id cannot be empty when managing health checks")
+	}
+
+	zoneRoles, err := newAWSZoneRoles(awsConfig.ZoneRoles)
+	if err != nil {
+		return nil, err
+	}
+
 	config := aws.NewConfig().WithMaxRetries(awsConfig.APIRetries)
 
 	config.WithHTTPClient(
@@ -142,6 +314,15 @@ func NewAWSProvider(awsConfig AWSConfig) (*AWSProvider, error) {
 		return nil, err
 	}
 
+	// the roles of the hosted zones are assumed with the credentials of the session
+	roleClients := make(map[string]Route53API)
+	for _, zoneRole := range zoneRoles {
+		if _, ok := roleClients[zoneRole.role]; !ok {
+			log.Infof("Assuming role %s for hosted zones", zoneRole.role)
+			roleClients[zoneRole.role] = route53.New(session, aws.NewConfig().WithCredentials(stscreds.NewCredentials(session, zoneRole.role)))
+		}
+	}
+
 	if awsConfig.AssumeRole != "" {
 		log.Infof("Assuming role: %s", awsConfig.AssumeRole)
 		session.Config.WithCredentials(stscreds.NewCredentials(session, awsConfig.AssumeRole))
@@ -149,6 +330,8 @@ func NewAWSProvider(awsConfig AWSConfig) (*AWSProvider, error) {
 
 	provider := &AWSProvider{
 		client:               route53.New(session),
+		roleClients:          roleClients,
+		zoneRoles:            zoneRoles,
 		domainFilter:         awsConfig.DomainFilter,
 		zoneIDFilter:         awsConfig.ZoneIDFilter,
 		zoneTypeFilter:       awsConfig.ZoneTypeFilter,
@@ -156,19 +339,57 @@ func NewAWSProvider(awsConfig AWSConfig) (*AWSProvider, error) {
 		batchChangeSize:      awsConfig.BatchChangeSize,
 		batchChangeInterval:  awsConfig.BatchChangeInterval,
 		evaluateTargetHealth: awsConfig.EvaluateTargetHealth,
+		dualstack:            awsConfig.Dualstack,
+		manageHealthChecks:   awsConfig.ManageHealthChecks,
+		ownerID:              awsConfig.OwnerID,
 		dryRun:               awsConfig.DryRun,
 	}
 
 	return provider, nil
 }
 
-// Zones returns the list of hosted zones.
+// Zones returns the list of hosted zones. The hosted zones of assumed IAM roles are
+// listed with their clients, all others with the default client.
 func (p *AWSProvider) Zones() (map[string]*route53.HostedZone, error) {
 	zones := make(map[string]*route53.HostedZone)
+	zoneClients := make(map[string]Route53API)
 
+	for _, zoneRole := range p.zoneRoles {
+		if err := p.listZones(p.roleClients[zoneRole.role], zoneRole.match, zones, zoneClients); err != nil {
+			return nil, err
+		}
+	}
+
+	withoutRole := func(zone *route53.HostedZone) bool {
+		for _, zoneRole := range p.zoneRoles {
+			if zoneRole.match(zone) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := p.listZones(p.client, withoutRole, zones, zoneClients); err != nil {
+		return nil, err
+	}
+
+	for _, zone := range zones {
+		log.Debugf("Considering zone: %s (domain: %s)", aws.StringValue(zone.Id), aws.StringValue(zone.Name))
+	}
+	p.zoneClients = zoneClients
+
+	return zones, nil
+}
+
+// listZones adds the hosted zones listed by the client that match the filters of the provider
+// and the given match function to zones, which aren't in there yet.
+func (p *AWSProvider) listZones(client Route53API, match func(*route53.HostedZone) bool, zones map[string]*route53.HostedZone, zoneClients map[string]Route53API) error {
 	var tagErr error
 	f := func(resp *route53.ListHostedZonesOutput, lastPage bool) (shouldContinue bool) {
 		for _, zone := range resp.HostedZones {
+			if _, ok := zones[aws.StringValue(zone.Id)]; ok || !match(zone) {
+				continue
+			}
+
 			if !p.zoneIDFilter.Match(aws.StringValue(zone.Id)) {
 				continue
 			}
@@ -183,7 +404,7 @@ func (p *AWSProvider) Zones() (map[string]*route53.HostedZone, error) {
 
 			// Only fetch tags if a tag filter was specified
 			if !p.zoneTagFilter.IsEmpty() {
-				tags, err := p.tagsForZone(*zone.Id)
+				tags, err := tagsForZone(client, *zone.Id)
 				if err != nil {
 					tagErr = err
 					return false
@@ -194,24 +415,51 @@ func (p *AWSProvider) Zones() (map[string]*route53.HostedZone, error) {
 			}
 
 			zones[aws.StringValue(zone.Id)] = zone
+			zoneClients[aws.StringValue(zone.Id)] = client
 		}
 
 		return true
 	}
 
-	err := p.client.ListHostedZonesPages(&route53.ListHostedZonesInput{}, f)
+	err := client.ListHostedZonesPages(&route53.ListHostedZonesInput{}, f)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if tagErr != nil {
-		return nil, tagErr
+
+	return tagErr
+}
+
+// clientForZone returns the client of the hosted zone found by the last call to Zones.
+func (p *AWSProvider) clientForZone(zoneID string) Route53API {
+	if client, ok := p.zoneClients[zoneID]; ok {
+		return client
 	}
+	return p.client
+}
 
-	for _, zone := range zones {
-		log.Debugf("Considering zone: %s (domain: %s)", aws.StringValue(zone.Id), aws.StringValue(zone.Name))
+// clientForRecord returns the client of the best matching hosted zone of the record.
+func (p *AWSProvider) clientForRecord(dnsName string, zones map[string]*route53.HostedZone) Route53API {
+	matchingZones := suitableZones(ensureTrailingDot(dnsName), zones)
+	if len(matchingZones) == 0 {
+		return p.client
+	}
+	// the best matching public zone comes last
+	return p.clientForZone(aws.StringValue(matchingZones[len(matchingZones)-1].Id))
+}
+
+// clients returns the default client followed by the clients of the assumed IAM roles.
+func (p *AWSProvider) clients() []Route53API {
+	roles := make([]string, 0, len(p.roleClients))
+	for role := range p.roleClients {
+		roles = append(roles, role)
 	}
+	sort.Strings(roles)
 
-	return zones, nil
+	clients := []Route53API{p.client}
+	for _, role := range roles {
+		clients = append(clients, p.roleClients[role])
+	}
+	return clients
 }
 
 // wildcardUnescape converts \\052.abc back to *.abc
@@ -230,6 +478,26 @@ func (p *AWSProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
 		return nil, err
 	}
 
+	endpoints, err = p.records(zones)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.manageHealthChecks {
+		if err := p.addHealthChecks(endpoints); err != nil {
+			return nil, err
+		}
+	}
+
+	return endpoints, nil
+}
+
+// records returns the list of records in the given hosted zones.
+func (p *AWSProvider) records(zones map[string]*route53.HostedZone) (endpoints []*endpoint.Endpoint, _ error) {
+	// A aliases and the existence of AAAA aliases of the same record in the current hosted zone
+	var aliases map[string]*endpoint.Endpoint
+	var dualstackAliases map[string]bool
+
 	f := func(resp *route53.ListResourceRecordSetsOutput, lastPage bool) (shouldContinue bool) {
 		for _, r := range resp.ResourceRecordSets {
 			// TODO(linki, ownership): Remove once ownership system is in place.
@@ -250,14 +518,21 @@ func (p *AWSProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
 					targets[idx] = aws.StringValue(rr.Value)
 				}
 
-				endpoints = append(endpoints, endpoint.NewEndpointWithTTL(wildcardUnescape(aws.StringValue(r.Name)), aws.StringValue(r.Type), ttl, targets...))
+				ep := endpoint.NewEndpointWithTTL(wildcardUnescape(aws.StringValue(r.Name)), aws.StringValue(r.Type), ttl, targets...)
+				endpoints = append(endpoints, withHealthCheckID(withRoutingPolicy(ep, r), r))
 			}
 
-			if r.AliasTarget != nil {
+			// Alias records are reported as a single CNAME. An AAAA alias to the
+			// same target marks the alias as dual-stack.
+			if r.AliasTarget != nil && aws.StringValue(r.Type) == route53.RRTypeA {
 				ep := endpoint.
 					NewEndpointWithTTL(wildcardUnescape(aws.StringValue(r.Name)), endpoint.RecordTypeCNAME, ttl, aws.StringValue(r.AliasTarget.DNSName)).
 					WithProviderSpecific(providerSpecificEvaluateTargetHealth, fmt.Sprintf("%t", aws.BoolValue(r.AliasTarget.EvaluateTargetHealth)))
-				endpoints = append(endpoints, ep)
+				endpoints = append(endpoints, withHealthCheckID(withRoutingPolicy(ep, r), r))
+				aliases[aliasKey(r)] = ep
+			}
+			if r.AliasTarget != nil && aws.StringValue(r.Type) == route53.RRTypeAaaa {
+				dualstackAliases[aliasKey(r)] = true
 			}
 		}
 
@@ -269,46 +544,47 @@ func (p *AWSProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
 			HostedZoneId: z.Id,
 		}
 
-		if err := p.client.ListResourceRecordSetsPages(params, f); err != nil {
+		aliases, dualstackAliases = make(map[string]*endpoint.Endpoint), make(map[string]bool)
+		if err := p.clientForZone(aws.StringValue(z.Id)).ListResourceRecordSetsPages(params, f); err != nil {
 			return nil, err
 		}
+		for key, ep := range aliases {
+			ep.WithProviderSpecific(providerSpecificDualstack, fmt.Sprintf("%t", dualstackAliases[key]))
+		}
 	}
 
 	return endpoints, nil
 }
 
+// aliasKey identifies the aliases of a record set to the same target regardless of their type.
+func aliasKey(rrset *route53.ResourceRecordSet) string {
+	return aws.StringValue(rrset.Name) + "::" + aws.StringValue(rrset.SetIdentifier) + "::" + aws.StringValue(rrset.AliasTarget.DNSName)
+}
+
 // CreateRecords creates a given set of DNS records in the given hosted zone.
 func (p *AWSProvider) CreateRecords(endpoints []*endpoint.Endpoint) error {
-	return p.submitChanges(p.newChanges(route53.ChangeActionCreate, endpoints))
+	return p.ApplyChanges(&plan.Changes{Create: endpoints})
 }
 
 // UpdateRecords updates a given set of old records to a new set of records in a given hosted zone.
 func (p *AWSProvider) UpdateRecords(endpoints, _ []*endpoint.Endpoint) error {
-	return p.submitChanges(p.newChanges(route53.ChangeActionUpsert, endpoints))
+	return p.ApplyChanges(&plan.Changes{UpdateNew: endpoints})
 }
 
 // DeleteRecords deletes a given set of DNS records in a given zone.
 func (p *AWSProvider) DeleteRecords(endpoints []*endpoint.Endpoint) error {
-	return p.submitChanges(p.newChanges(route53.ChangeActionDelete, endpoints))
+	return p.ApplyChanges(&plan.Changes{Delete: endpoints})
 }
 
-// ApplyChanges applies a given set of changes in a given zone.
+// ApplyChanges applies a given set of changes in a given zone. The hosted zones are listed
+// once for all changes, their records only if a change requests an alias to another record.
+// Health checks of the records are created or updated before and orphaned ones deleted after
+// the records were changed.
 func (p *AWSProvider) ApplyChanges(changes *plan.Changes) error {
-	combinedChanges := make([]*route53.Change, 0, len(changes.Create)+len(changes.UpdateNew)+len(changes.Delete))
-
-	combinedChanges = append(combinedChanges, p.newChanges(route53.ChangeActionCreate, changes.Create)...)
-	combinedChanges = append(combinedChanges, p.newChanges(route53.ChangeActionUpsert, changes.UpdateNew)...)
-	combinedChanges = append(combinedChanges, p.newChanges(route53.ChangeActionDelete, changes.Delete)...)
-
-	return p.submitChanges(combinedChanges)
-}
-
-// submitChanges takes a zone and a collection of Changes and sends them as a single transaction.
-func (p *AWSProvider) submitChanges(changes []*route53.Change) error {
 	// return early if there is nothing to change
-	if len(changes) == 0 {
+	if len(changes.Create) == 0 && len(changes.UpdateNew) == 0 && len(changes.Delete) == 0 {
 		log.Info("All records are already up to date")
-		return nil
+		return p.deleteOrphanedHealthChecks(nil)
 	}
 
 	zones, err := p.Zones()
@@ -316,17 +592,77 @@ func (p *AWSProvider) submitChanges(changes []*route53.Change) error {
 		return err
 	}
 
+	records, err := p.aliasCandidates(zones, changes.Delete, changes.Create, changes.UpdateNew)
+	if err != nil {
+		return err
+	}
+
+	healthCheckIDs := map[*endpoint.Endpoint]string{}
+	if p.manageHealthChecks {
+		checks, err := p.healthChecks()
+		if err != nil {
+			return err
+		}
+		if err := p.ensureHealthChecks(changes.Create, zones, checks, healthCheckIDs); err != nil {
+			return err
+		}
+		if err := p.ensureHealthChecks(changes.UpdateNew, zones, checks, healthCheckIDs); err != nil {
+			return err
+		}
+	}
+
+	combinedChanges := make([]*awsChange, 0, len(changes.Create)+len(changes.UpdateNew)+len(changes.Delete))
+
+	combinedChanges = append(combinedChanges, p.newChanges(route53.ChangeActionDelete, changes.Delete, records, zones, nil)...)
+	combinedChanges = append(combinedChanges, p.newChanges(route53.ChangeActionCreate, changes.Create, records, zones, healthCheckIDs)...)
+	combinedChanges = append(combinedChanges, p.newChanges(route53.ChangeActionUpsert, changes.UpdateNew, records, zones, healthCheckIDs)...)
+
+	// the AAAA alias of a record that isn't dual-stack anymore is deleted
+	for i, old := range changes.UpdateOld {
+		if i < len(changes.UpdateNew) && p.isDualstack(old) && !p.isDualstack(changes.UpdateNew[i]) {
+			combinedChanges = append(combinedChanges, &awsChange{newDualstackChange(p.newChange(route53.ChangeActionDelete, old, records, zones)), old})
+		}
+	}
+
+	if err := p.submitChanges(combinedChanges, zones); err != nil {
+		return err
+	}
+
+	attached := map[string]bool{}
+	for _, id := range healthCheckIDs {
+		attached[id] = true
+	}
+	return p.deleteOrphanedHealthChecks(attached)
+}
+
+// aliasCandidates returns the records of the given hosted zones if one of the endpoints
+// requests an alias to another record, which has to be looked up in them.
+func (p *AWSProvider) aliasCandidates(zones map[string]*route53.HostedZone, endpointLists ...[]*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	for _, endpoints := range endpointLists {
+		for _, ep := range endpoints {
+			if ep.RecordType == endpoint.RecordTypeCNAME && ep.ProviderSpecific["alias"] == "true" {
+				return p.records(zones)
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// submitChanges takes a collection of Changes and sends them to the given hosted zones.
+// The endpoints of changes that failed or exceed the batch size are returned in a
+// FailedChangesError, the other changes are applied nevertheless.
+func (p *AWSProvider) submitChanges(changes []*awsChange, zones map[string]*route53.HostedZone) error {
 	// separate into per-zone change sets to be passed to the API.
 	changesByZone := changesByZone(zones, changes)
 	if len(changesByZone) == 0 {
 		log.Info("All records are already up to date, there are no changes for the matching hosted zones")
 	}
 
-	var failedZones []string
+	var failed []*awsChange
 	for z, cs := range changesByZone {
-		var failedUpdate bool
-
-		batchCs := batchChangeSet(cs, p.batchChangeSize)
+		batchCs, oversized := batchChangeSet(cs, p.batchChangeSize)
+		failed = append(failed, oversized...)
 
 		for i, b := range batchCs {
 			for _, c := range b {
@@ -334,53 +670,135 @@ func (p *AWSProvider) submitChanges(changes []*route53.Change) error {
 			}
 
 			if !p.dryRun {
-				params := &route53.ChangeResourceRecordSetsInput{
-					HostedZoneId: aws.String(z),
-					ChangeBatch: &route53.ChangeBatch{
-						Changes: b,
-					},
-				}
-
-				if _, err := p.client.ChangeResourceRecordSets(params); err != nil {
-					log.Error(err) //TODO(ideahitme): consider changing the interface in cases when this error might be a concern for other components
-					failedUpdate = true
-				} else {
-					log.Infof("%d record(s) in zone %s were successfully updated", len(b), aws.StringValue(zones[z].Name))
-				}
+				failed = append(failed, p.submitBatch(zones[z], b)...)
 
 				if i != len(batchCs)-1 {
 					time.Sleep(p.batchChangeInterval)
 				}
 			}
 		}
-
-		if failedUpdate {
-			failedZones = append(failedZones, z)
-		}
 	}
 
-	if len(failedZones) > 0 {
-		return fmt.Errorf("Failed to submit all changes for the following zones: %v", failedZones)
+	if len(failed) > 0 {
+		return &FailedChangesError{Endpoints: changedEndpoints(failed)}
 	}
 
 	return nil
 }
 
+// submitBatch sends a batch of changes to the hosted zone. Route53 rejects a batch as a
+// whole if one of its changes is invalid, so a failed batch is split in halves which are
+// submitted again until the changes of the failing records are isolated. The changes of
+// a record and its TXT record are never split. Returns the changes that failed.
+func (p *AWSProvider) submitBatch(zone *route53.HostedZone, batch []*awsChange) []*awsChange {
+	params := &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: zone.Id,
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: make([]*route53.Change, 0, len(batch)),
+		},
+	}
+	for _, c := range batch {
+		params.ChangeBatch.Changes = append(params.ChangeBatch.Changes, c.Change)
+	}
+
+	_, err := p.clientForZone(aws.StringValue(zone.Id)).ChangeResourceRecordSets(params)
+	if err == nil {
+		log.Infof("%d record(s) in zone %s were successfully updated", len(batch), aws.StringValue(zone.Name))
+		return nil
+	}
+
+	groups := changesByOwnedRecord(batch)
+	if len(groups) == 1 {
+		log.Errorf("Failed to submit the changes of %s in zone %s: %v", batch[0].ownedRecord(), aws.StringValue(zone.Name), err)
+		return batch
+	}
+	log.Warnf("Failed to submit %d change(s) in zone %s, retrying in smaller batches: %v", len(batch), aws.StringValue(zone.Name), err)
+
+	var failed []*awsChange
+	for _, half := range [][][]*awsChange{groups[:len(groups)/2], groups[len(groups)/2:]} {
+		var b []*awsChange
+		for _, group := range half {
+			b = append(b, group...)
+		}
+		time.Sleep(p.batchChangeInterval)
+		failed = append(failed, p.submitBatch(zone, sortChangesByActionNameType(b))...)
+	}
+	return failed
+}
+
+// changedEndpoints returns the endpoints of the changes, each of them once.
+func changedEndpoints(changes []*awsChange) []*endpoint.Endpoint {
+	seen := map[*endpoint.Endpoint]bool{}
+	endpoints := []*endpoint.Endpoint{}
+	for _, c := range changes {
+		if c.endpoint != nil && !seen[c.endpoint] {
+			seen[c.endpoint] = true
+			endpoints = append(endpoints, c.endpoint)
+		}
+	}
+	return endpoints
+}
+
 // newChanges returns a collection of Changes based on the given records and action.
-func (p *AWSProvider) newChanges(action string, endpoints []*endpoint.Endpoint) []*route53.Change {
-	changes := make([]*route53.Change, 0, len(endpoints))
+// Targets of alias records are looked up in the current records and hosted zones,
+// the given health checks are attached to the records.
+func (p *AWSProvider) newChanges(action string, endpoints, records []*endpoint.Endpoint, zones map[string]*route53.HostedZone, healthCheckIDs map[*endpoint.Endpoint]string) []*awsChange {
+	changes := make([]*awsChange, 0, len(endpoints))
 
 	for _, endpoint := range endpoints {
-		changes = append(changes, p.newChange(action, endpoint))
+		change := p.newChange(action, endpoint, records, zones)
+		if id, ok := healthCheckIDs[endpoint]; ok {
+			change.ResourceRecordSet.HealthCheckId = aws.String(id)
+		}
+		changes = append(changes, &awsChange{change, endpoint})
+
+		if p.isDualstack(endpoint) {
+			changes = append(changes, &awsChange{newDualstackChange(change), endpoint})
+		}
 	}
 
 	return changes
 }
 
+// isDualstack returns true if the endpoint is an alias to a load balancer by an AAAA record as
+// well as an A record. S3 website endpoints don't support IPv6.
+func (p *AWSProvider) isDualstack(ep *endpoint.Endpoint) bool {
+	if !isAWSLoadBalancer(ep) || strings.Contains(ep.Targets[0], "s3-website") {
+		return false
+	}
+	if value, ok := ep.ProviderSpecific[providerSpecificDualstack]; ok {
+		return value == "true"
+	}
+	return p.dualstack
+}
+
+// ProviderSpecificDefaults returns the provider specific properties of records which don't set them
+// otherwise. Added to the desired records, current records are updated when the defaults change,
+// e.g. AAAA aliases are added to existing records once dual-stack is enabled and managed health
+// checks are detached from records without a health check protocol.
+func (p *AWSProvider) ProviderSpecificDefaults() endpoint.ProviderSpecific {
+	defaults := endpoint.ProviderSpecific{providerSpecificDualstack: fmt.Sprintf("%t", p.dualstack)}
+	if p.manageHealthChecks {
+		defaults[providerSpecificHealthCheckProtocol] = healthCheckProtocolNone
+	}
+	return defaults
+}
+
+// newDualstackChange returns the change of the AAAA alias accompanying the given change of an A alias.
+func newDualstackChange(change *route53.Change) *route53.Change {
+	rrset := *change.ResourceRecordSet
+	rrset.Type = aws.String(route53.RRTypeAaaa)
+
+	return &route53.Change{
+		Action:            change.Action,
+		ResourceRecordSet: &rrset,
+	}
+}
+
 // newChange returns a Change of the given record by the given action, e.g.
 // action=ChangeActionCreate returns a change for creation of the record and
 // action=ChangeActionDelete returns a change for deletion of the record.
-func (p *AWSProvider) newChange(action string, endpoint *endpoint.Endpoint) *route53.Change {
+func (p *AWSProvider) newChange(action string, endpoint *endpoint.Endpoint, records []*endpoint.Endpoint, zones map[string]*route53.HostedZone) *route53.Change {
 	change := &route53.Change{
 		Action: aws.String(action),
 		ResourceRecordSet: &route53.ResourceRecordSet{
@@ -388,11 +806,6 @@ func (p *AWSProvider) newChange(action string, endpoint *endpoint.Endpoint) *rou
 		},
 	}
 
-	rec, err := p.Records()
-	if err != nil {
-		log.Infof("getting records failed: %v", err)
-	}
-
 	if isAWSLoadBalancer(endpoint) {
 		evalTargetHealth := p.evaluateTargetHealth
 		if _, ok := endpoint.ProviderSpecific[providerSpecificEvaluateTargetHealth]; ok {
@@ -405,18 +818,12 @@ func (p *AWSProvider) newChange(action string, endpoint *endpoint.Endpoint) *rou
 			HostedZoneId:         aws.String(canonicalHostedZone(endpoint.Targets[0])),
 			EvaluateTargetHealth: aws.Bool(evalTargetHealth),
 		}
-	} else if hostedZone := isAWSAlias(endpoint, rec); hostedZone != "" {
-		zones, err := p.Zones()
-		if err != nil {
-			log.Errorf("getting zones failed: %v", err)
-		}
-		for _, zone := range zones {
-			change.ResourceRecordSet.Type = aws.String(route53.RRTypeA)
-			change.ResourceRecordSet.AliasTarget = &route53.AliasTarget{
-				DNSName:              aws.String(endpoint.Targets[0]),
-				HostedZoneId:         aws.String(cleanZoneID(*zone.Id)),
-				EvaluateTargetHealth: aws.Bool(p.evaluateTargetHealth),
-			}
+	} else if hostedZoneID := aliasHostedZoneID(endpoint, records, zones); hostedZoneID != "" {
+		change.ResourceRecordSet.Type = aws.String(route53.RRTypeA)
+		change.ResourceRecordSet.AliasTarget = &route53.AliasTarget{
+			DNSName:              aws.String(endpoint.Targets[0]),
+			HostedZoneId:         aws.String(hostedZoneID),
+			EvaluateTargetHealth: aws.Bool(p.evaluateTargetHealth),
 		}
 	} else {
 		change.ResourceRecordSet.Type = aws.String(endpoint.RecordType)
@@ -433,11 +840,409 @@ func (p *AWSProvider) newChange(action string, endpoint *endpoint.Endpoint) *rou
 		}
 	}
 
+	if endpoint.SetIdentifier != "" {
+		change.ResourceRecordSet.SetIdentifier = aws.String(endpoint.SetIdentifier)
+		setRoutingPolicy(change.ResourceRecordSet, endpoint)
+	}
+
+	if id := healthCheckID(endpoint); id != "" {
+		change.ResourceRecordSet.HealthCheckId = aws.String(id)
+	}
+
 	return change
 }
 
-func (p *AWSProvider) tagsForZone(zoneID string) (map[string]string, error) {
-	response, err := p.client.ListTagsForResource(&route53.ListTagsForResourceInput{
+// setRoutingPolicy sets the routing policy given by the provider specific properties
+// of the endpoint on the record set.
+func setRoutingPolicy(rrset *route53.ResourceRecordSet, ep *endpoint.Endpoint) {
+	if value, ok := ep.ProviderSpecific[providerSpecificWeight]; ok {
+		weight, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Errorf("Failed parsing value of %s: %s: %v; using weight of 0", providerSpecificWeight, value, err)
+			weight = 0
+		}
+		rrset.Weight = aws.Int64(weight)
+	}
+	if value, ok := ep.ProviderSpecific[providerSpecificRegion]; ok {
+		rrset.Region = aws.String(value)
+	}
+	if value, ok := ep.ProviderSpecific[providerSpecificFailover]; ok {
+		rrset.Failover = aws.String(value)
+	}
+
+	geolocation := &route53.GeoLocation{}
+	if value, ok := ep.ProviderSpecific[providerSpecificGeolocationContinentCode]; ok {
+		geolocation.ContinentCode = aws.String(value)
+		rrset.GeoLocation = geolocation
+	}
+	if value, ok := ep.ProviderSpecific[providerSpecificGeolocationCountryCode]; ok {
+		geolocation.CountryCode = aws.String(value)
+		rrset.GeoLocation = geolocation
+	}
+	if value, ok := ep.ProviderSpecific[providerSpecificGeolocationSubdivisionCode]; ok {
+		geolocation.SubdivisionCode = aws.String(value)
+		rrset.GeoLocation = geolocation
+	}
+}
+
+// withRoutingPolicy adds the set identifier and routing policy of the record set to the endpoint.
+func withRoutingPolicy(ep *endpoint.Endpoint, rrset *route53.ResourceRecordSet) *endpoint.Endpoint {
+	if rrset.SetIdentifier == nil {
+		return ep
+	}
+	ep.SetIdentifier = aws.StringValue(rrset.SetIdentifier)
+
+	if rrset.Weight != nil {
+		ep.WithProviderSpecific(providerSpecificWeight, fmt.Sprintf("%d", aws.Int64Value(rrset.Weight)))
+	}
+	if rrset.Region != nil {
+		ep.WithProviderSpecific(providerSpecificRegion, aws.StringValue(rrset.Region))
+	}
+	if rrset.Failover != nil {
+		ep.WithProviderSpecific(providerSpecificFailover, aws.StringValue(rrset.Failover))
+	}
+	if rrset.GeoLocation != nil {
+		if rrset.GeoLocation.ContinentCode != nil {
+			ep.WithProviderSpecific(providerSpecificGeolocationContinentCode, aws.StringValue(rrset.GeoLocation.ContinentCode))
+		}
+		if rrset.GeoLocation.CountryCode != nil {
+			ep.WithProviderSpecific(providerSpecificGeolocationCountryCode, aws.StringValue(rrset.GeoLocation.CountryCode))
+		}
+		if rrset.GeoLocation.SubdivisionCode != nil {
+			ep.WithProviderSpecific(providerSpecificGeolocationSubdivisionCode, aws.StringValue(rrset.GeoLocation.SubdivisionCode))
+		}
+	}
+	return ep
+}
+
+// withHealthCheckID adds the id of the health check attached to the record set to the endpoint.
+func withHealthCheckID(ep *endpoint.Endpoint, rrset *route53.ResourceRecordSet) *endpoint.Endpoint {
+	if rrset.HealthCheckId == nil {
+		return ep
+	}
+	return ep.WithProviderSpecific(providerSpecificHealthCheckID, aws.StringValue(rrset.HealthCheckId))
+}
+
+// healthCheckID returns the id of the health check attached to the endpoint. The TXT records
+// of the registry share the provider specific properties of their record, but never have one.
+func healthCheckID(ep *endpoint.Endpoint) string {
+	if ep.RecordType == endpoint.RecordTypeTXT {
+		return ""
+	}
+	return ep.ProviderSpecific[providerSpecificHealthCheckID]
+}
+
+// healthCheckRecord returns the name of the record a health check belongs to, records
+// with a routing policy are told apart by their set identifier.
+func healthCheckRecord(ep *endpoint.Endpoint) string {
+	if ep.SetIdentifier == "" {
+		return ep.DNSName
+	}
+	return ep.DNSName + "/" + ep.SetIdentifier
+}
+
+// healthCheckConfig returns the config of the health check given by the provider specific
+// properties of the endpoint, the first target of the endpoint is checked.
+func healthCheckConfig(ep *endpoint.Endpoint) (*route53.HealthCheckConfig, error) {
+	config := &route53.HealthCheckConfig{
+		Type: aws.String(ep.ProviderSpecific[providerSpecificHealthCheckProtocol]),
+	}
+	if ep.RecordType == endpoint.RecordTypeA || ep.RecordType == endpoint.RecordTypeAAAA {
+		config.IPAddress = aws.String(ep.Targets[0])
+	} else {
+		config.FullyQualifiedDomainName = aws.String(ep.Targets[0])
+	}
+	if value, ok := ep.ProviderSpecific[providerSpecificHealthCheckPath]; ok {
+		config.ResourcePath = aws.String(value)
+	}
+
+	var err error
+	if config.Port, err = providerSpecificInt64(ep, providerSpecificHealthCheckPort); err != nil {
+		return nil, err
+	}
+	if config.FailureThreshold, err = providerSpecificInt64(ep, providerSpecificHealthCheckFailureThreshold); err != nil {
+		return nil, err
+	}
+	if config.RequestInterval, err = providerSpecificInt64(ep, providerSpecificHealthCheckRequestInterval); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// providerSpecificInt64 returns the integer value of the provider specific property or nil if it isn't set.
+func providerSpecificInt64(ep *endpoint.Endpoint, key string) (*int64, error) {
+	value, ok := ep.ProviderSpecific[key]
+	if !ok {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing value of %s: %s: %v", key, value, err)
+	}
+	return aws.Int64(i), nil
+}
+
+// healthChecks returns the health checks of all accounts tagged with the owner id of this
+// instance keyed by their id.
+func (p *AWSProvider) healthChecks() (map[string]*healthCheck, error) {
+	owned := make(map[string]*healthCheck)
+	for _, client := range p.clients() {
+		if err := p.listHealthChecks(client, owned); err != nil {
+			return nil, err
+		}
+	}
+	return owned, nil
+}
+
+// listHealthChecks adds the health checks listed by the client that are tagged with the
+// owner id of this instance to owned.
+func (p *AWSProvider) listHealthChecks(client Route53API, owned map[string]*healthCheck) error {
+	var checks []*route53.HealthCheck
+	f := func(resp *route53.ListHealthChecksOutput, lastPage bool) (shouldContinue bool) {
+		checks = append(checks, resp.HealthChecks...)
+		return true
+	}
+	if err := client.ListHealthChecksPages(&route53.ListHealthChecksInput{}, f); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(checks); start += healthCheckTagsBatchSize {
+		end := start + healthCheckTagsBatchSize
+		if end > len(checks) {
+			end = len(checks)
+		}
+
+		checksByID := make(map[string]*route53.HealthCheck)
+		ids := make([]*string, 0, end-start)
+		for _, check := range checks[start:end] {
+			checksByID[aws.StringValue(check.Id)] = check
+			ids = append(ids, check.Id)
+		}
+
+		response, err := client.ListTagsForResources(&route53.ListTagsForResourcesInput{
+			ResourceType: aws.String("healthcheck"),
+			ResourceIds:  ids,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, tagSet := range response.ResourceTagSets {
+			tags := map[string]string{}
+			for _, tag := range tagSet.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			id := aws.StringValue(tagSet.ResourceId)
+			if tags[healthCheckOwnerTag] != p.ownerID || checksByID[id] == nil {
+				continue
+			}
+			owned[id] = &healthCheck{HealthCheck: checksByID[id], record: tags[healthCheckRecordTag], client: client}
+		}
+	}
+
+	return nil
+}
+
+// addHealthChecks adds the config of the owned health checks to the endpoints they are attached to,
+// endpoints without a health check get the protocol none. The owned health checks not attached to
+// any of them are remembered to delete them later on.
+func (p *AWSProvider) addHealthChecks(endpoints []*endpoint.Endpoint) error {
+	checks, err := p.healthChecks()
+	if err != nil {
+		return err
+	}
+
+	attached := map[string]bool{}
+	for _, ep := range endpoints {
+		if healthCheckID(ep) == "" {
+			ep.WithProviderSpecific(providerSpecificHealthCheckProtocol, healthCheckProtocolNone)
+			continue
+		}
+		check, ok := checks[healthCheckID(ep)]
+		if !ok {
+			continue
+		}
+		attached[aws.StringValue(check.Id)] = true
+
+		config := check.HealthCheckConfig
+		ep.WithProviderSpecific(providerSpecificHealthCheckProtocol, aws.StringValue(config.Type))
+		if config.ResourcePath != nil {
+			ep.WithProviderSpecific(providerSpecificHealthCheckPath, aws.StringValue(config.ResourcePath))
+		}
+		if config.Port != nil {
+			ep.WithProviderSpecific(providerSpecificHealthCheckPort, fmt.Sprintf("%d", aws.Int64Value(config.Port)))
+		}
+		if config.FailureThreshold != nil {
+			ep.WithProviderSpecific(providerSpecificHealthCheckFailureThreshold, fmt.Sprintf("%d", aws.Int64Value(config.FailureThreshold)))
+		}
+		if config.RequestInterval != nil {
+			ep.WithProviderSpecific(providerSpecificHealthCheckRequestInterval, fmt.Sprintf("%d", aws.Int64Value(config.RequestInterval)))
+		}
+	}
+
+	p.orphanedHealthChecks = nil
+	for id, check := range checks {
+		if !attached[id] {
+			p.orphanedHealthChecks = append(p.orphanedHealthChecks, check)
+		}
+	}
+	sort.Slice(p.orphanedHealthChecks, func(i, j int) bool {
+		return aws.StringValue(p.orphanedHealthChecks[i].Id) < aws.StringValue(p.orphanedHealthChecks[j].Id)
+	})
+
+	return nil
+}
+
+// ensureHealthChecks creates or updates the health checks of the endpoints with a health check
+// protocol and adds their ids to healthCheckIDs. The health checks are managed in the account
+// of the hosted zone of their record.
+func (p *AWSProvider) ensureHealthChecks(endpoints []*endpoint.Endpoint, zones map[string]*route53.HostedZone, checks map[string]*healthCheck, healthCheckIDs map[*endpoint.Endpoint]string) error {
+	for _, ep := range endpoints {
+		if protocol, ok := ep.ProviderSpecific[providerSpecificHealthCheckProtocol]; !ok || protocol == healthCheckProtocolNone || ep.RecordType == endpoint.RecordTypeTXT {
+			continue
+		}
+
+		config, err := healthCheckConfig(ep)
+		if err != nil {
+			log.Errorf("Skipping health check of %s: %v", healthCheckRecord(ep), err)
+			continue
+		}
+
+		id, err := p.ensureHealthCheck(p.clientForRecord(ep.DNSName, zones), healthCheckRecord(ep), config, checks)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			// the health check wasn't created in dry run mode
+			continue
+		}
+		healthCheckIDs[ep] = id
+	}
+
+	return nil
+}
+
+// ensureHealthCheck returns the id of the health check of the record with the given config. An owned
+// health check of the record is updated if necessary, a new one is created if there is none or the
+// existing one can't be changed to the given config.
+func (p *AWSProvider) ensureHealthCheck(client Route53API, record string, config *route53.HealthCheckConfig, checks map[string]*healthCheck) (string, error) {
+	for id, check := range checks {
+		current := check.HealthCheckConfig
+		if check.client != client || check.record != record || !healthCheckUpdatable(current, config) {
+			continue
+		}
+		if healthCheckUpToDate(current, config) {
+			return id, nil
+		}
+
+		log.Infof("Updating health check %s of %s", id, record)
+		if p.dryRun {
+			return id, nil
+		}
+		response, err := client.UpdateHealthCheck(&route53.UpdateHealthCheckInput{
+			HealthCheckId:            aws.String(id),
+			HealthCheckVersion:       check.HealthCheckVersion,
+			IPAddress:                config.IPAddress,
+			FullyQualifiedDomainName: config.FullyQualifiedDomainName,
+			Port:                     config.Port,
+			ResourcePath:             config.ResourcePath,
+			FailureThreshold:         config.FailureThreshold,
+		})
+		if err != nil {
+			return "", err
+		}
+		check.HealthCheck = response.HealthCheck
+		return id, nil
+	}
+
+	log.Infof("Creating health check of %s", record)
+	if p.dryRun {
+		return "", nil
+	}
+	response, err := client.CreateHealthCheck(&route53.CreateHealthCheckInput{
+		CallerReference:   aws.String(fmt.Sprintf("external-dns-%d", time.Now().UnixNano())),
+		HealthCheckConfig: config,
+	})
+	if err != nil {
+		return "", err
+	}
+	id := aws.StringValue(response.HealthCheck.Id)
+
+	_, err = client.ChangeTagsForResource(&route53.ChangeTagsForResourceInput{
+		ResourceType: aws.String("healthcheck"),
+		ResourceId:   aws.String(id),
+		AddTags: []*route53.Tag{
+			{Key: aws.String("Name"), Value: aws.String(record)},
+			{Key: aws.String(healthCheckOwnerTag), Value: aws.String(p.ownerID)},
+			{Key: aws.String(healthCheckRecordTag), Value: aws.String(record)},
+		},
+	})
+	if err != nil {
+		// an untagged health check would never be deleted again
+		if _, deleteErr := client.DeleteHealthCheck(&route53.DeleteHealthCheckInput{HealthCheckId: aws.String(id)}); deleteErr != nil {
+			log.Errorf("Failed to delete untagged health check %s: %v", id, deleteErr)
+		}
+		return "", err
+	}
+
+	checks[id] = &healthCheck{HealthCheck: response.HealthCheck, record: record, client: client}
+	return id, nil
+}
+
+// healthCheckUpdatable returns true if the health check can be updated to the desired config,
+// the protocol, the request interval and whether an IP address is checked can't be changed.
+func healthCheckUpdatable(current, desired *route53.HealthCheckConfig) bool {
+	return aws.StringValue(current.Type) == aws.StringValue(desired.Type) &&
+		sameIfSet(current.RequestInterval, desired.RequestInterval) &&
+		(current.IPAddress == nil) == (desired.IPAddress == nil)
+}
+
+// healthCheckUpToDate returns true if the health check has the desired config. Properties
+// that aren't set in the desired config keep their current value.
+func healthCheckUpToDate(current, desired *route53.HealthCheckConfig) bool {
+	return aws.StringValue(current.IPAddress) == aws.StringValue(desired.IPAddress) &&
+		aws.StringValue(current.FullyQualifiedDomainName) == aws.StringValue(desired.FullyQualifiedDomainName) &&
+		(desired.ResourcePath == nil || aws.StringValue(current.ResourcePath) == aws.StringValue(desired.ResourcePath)) &&
+		sameIfSet(current.Port, desired.Port) &&
+		sameIfSet(current.FailureThreshold, desired.FailureThreshold)
+}
+
+func sameIfSet(current, desired *int64) bool {
+	return desired == nil || aws.Int64Value(current) == aws.Int64Value(desired)
+}
+
+// deleteOrphanedHealthChecks deletes the owned health checks that weren't attached to a record
+// at the last call to Records, unless they were attached again in the meantime.
+func (p *AWSProvider) deleteOrphanedHealthChecks(attached map[string]bool) error {
+	orphaned := p.orphanedHealthChecks
+	p.orphanedHealthChecks = nil
+
+	var failed []string
+	for _, check := range orphaned {
+		id := aws.StringValue(check.Id)
+		if attached[id] {
+			continue
+		}
+		log.Infof("Deleting orphaned health check %s", id)
+		if p.dryRun {
+			continue
+		}
+		if _, err := check.client.DeleteHealthCheck(&route53.DeleteHealthCheckInput{HealthCheckId: check.Id}); err != nil {
+			log.Error(err)
+			failed = append(failed, id)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to delete the following health checks: %v", failed)
+	}
+
+	return nil
+}
+
+func tagsForZone(client Route53API, zoneID string) (map[string]string, error) {
+	response, err := client.ListTagsForResource(&route53.ListTagsForResourceInput{
 		ResourceType: aws.String("hostedzone"),
 		ResourceId:   aws.String(zoneID),
 	})
@@ -451,43 +1256,36 @@ func (p *AWSProvider) tagsForZone(zoneID string) (map[string]string, error) {
 	return tagMap, nil
 }
 
-func batchChangeSet(cs []*route53.Change, batchSize int) [][]*route53.Change {
+// batchChangeSet splits the changes into batches of at most batchSize changes. The changes
+// of a record, including the TXT record holding its ownership, are put into the same batch,
+// so that a record is never created or deleted without its TXT record. The changes of records
+// that don't fit into a single batch are returned separately.
+func batchChangeSet(cs []*awsChange, batchSize int) ([][]*awsChange, []*awsChange) {
 	if len(cs) <= batchSize {
-		return [][]*route53.Change{cs}
+		return [][]*awsChange{cs}, nil
 	}
 
-	batchChanges := make([][]*route53.Change, 0)
+	batchChanges := make([][]*awsChange, 0)
+	var oversized []*awsChange
 
-	changesByName := make(map[string][]*route53.Change, 0)
-	for _, v := range cs {
-		changesByName[*v.ResourceRecordSet.Name] = append(changesByName[*v.ResourceRecordSet.Name], v)
-	}
-
-	names := make([]string, 0)
-	for v := range changesByName {
-		names = append(names, v)
-	}
-	sort.Strings(names)
-
-	for _, name := range names {
-		totalChangesByName := len(changesByName[name])
-
-		if totalChangesByName > batchSize {
-			log.Warnf("Total changes for %s exceeds max batch size of %d, total changes: %d", name,
-				batchSize, totalChangesByName)
+	for _, group := range changesByOwnedRecord(cs) {
+		if len(group) > batchSize {
+			log.Errorf("Total changes for %s exceeds max batch size of %d, total changes: %d", group[0].ownedRecord(),
+				batchSize, len(group))
+			oversized = append(oversized, group...)
 			continue
 		}
 
 		var existingBatch bool
 		for i, b := range batchChanges {
-			if len(b)+totalChangesByName <= batchSize {
-				batchChanges[i] = append(batchChanges[i], changesByName[name]...)
+			if len(b)+len(group) <= batchSize {
+				batchChanges[i] = append(batchChanges[i], group...)
 				existingBatch = true
 				break
 			}
 		}
 		if !existingBatch {
-			batchChanges = append(batchChanges, changesByName[name])
+			batchChanges = append(batchChanges, group)
 		}
 	}
 
@@ -495,10 +1293,30 @@ func batchChangeSet(cs []*route53.Change, batchSize int) [][]*route53.Change {
 		batchChanges[i] = sortChangesByActionNameType(batch)
 	}
 
-	return batchChanges
+	return batchChanges, oversized
 }
 
-func sortChangesByActionNameType(cs []*route53.Change) []*route53.Change {
+// changesByOwnedRecord groups the changes by the record they belong to, sorted by its name.
+func changesByOwnedRecord(cs []*awsChange) [][]*awsChange {
+	changesByName := make(map[string][]*awsChange)
+	for _, c := range cs {
+		changesByName[c.ownedRecord()] = append(changesByName[c.ownedRecord()], c)
+	}
+
+	names := make([]string, 0, len(changesByName))
+	for name := range changesByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([][]*awsChange, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, changesByName[name])
+	}
+	return groups
+}
+
+func sortChangesByActionNameType(cs []*awsChange) []*awsChange {
 	sort.SliceStable(cs, func(i, j int) bool {
 		if *cs[i].Action < *cs[j].Action {
 			return true
@@ -519,11 +1337,11 @@ func sortChangesByActionNameType(cs []*route53.Change) []*route53.Change {
 }
 
 // changesByZone separates a multi-zone change into a single change per zone.
-func changesByZone(zones map[string]*route53.HostedZone, changeSet []*route53.Change) map[string][]*route53.Change {
-	changes := make(map[string][]*route53.Change)
+func changesByZone(zones map[string]*route53.HostedZone, changeSet []*awsChange) map[string][]*awsChange {
+	changes := make(map[string][]*awsChange)
 
 	for _, z := range zones {
-		changes[aws.StringValue(z.Id)] = []*route53.Change{}
+		changes[aws.StringValue(z.Id)] = []*awsChange{}
 	}
 
 	for _, c := range changeSet {
@@ -577,7 +1395,8 @@ func suitableZones(hostname string, zones map[string]*route53.HostedZone) []*rou
 	return matchingZones
 }
 
-// isAWSLoadBalancer determines if a given hostname belongs to an AWS load balancer.
+// isAWSLoadBalancer determines if a given hostname belongs to an AWS load balancer or
+// another AWS resource that can be the target of an alias, e.g. a CloudFront distribution.
 func isAWSLoadBalancer(ep *endpoint.Endpoint) bool {
 	if ep.RecordType == endpoint.RecordTypeCNAME {
 		return canonicalHostedZone(ep.Targets[0]) != ""
@@ -601,6 +1420,21 @@ func isAWSAlias(ep *endpoint.Endpoint, addrs []*endpoint.Endpoint) string {
 	return ""
 }
 
+// aliasHostedZoneID returns the id of the hosted zone containing the target of an
+// AWS Alias record, the best matching public zone is preferred over private ones.
+func aliasHostedZoneID(ep *endpoint.Endpoint, addrs []*endpoint.Endpoint, zones map[string]*route53.HostedZone) string {
+	if isAWSAlias(ep, addrs) == "" {
+		return ""
+	}
+
+	matchingZones := suitableZones(ensureTrailingDot(ep.Targets[0]), zones)
+	if len(matchingZones) == 0 {
+		return ""
+	}
+
+	return cleanZoneID(aws.StringValue(matchingZones[len(matchingZones)-1].Id))
+}
+
 // canonicalHostedZone returns the matching canonical zone for a given hostname.
 func canonicalHostedZone(hostname string) string {
 	for suffix, zone := range canonicalHostedZones {
@@ -51,7 +51,12 @@ type Route53APIStub struct {
 	zones      map[string]*route53.HostedZone
 	recordSets map[string]map[string][]*route53.ResourceRecordSet
 	zoneTags   map[string][]*route53.Tag
-	m          dynamicMock
+	// health checks and their tags by id
+	healthChecks    map[string]*route53.HealthCheck
+	healthCheckTags map[string][]*route53.Tag
+	m               dynamicMock
+	// number of calls by method name
+	calls map[string]int
 }
 
 // MockMethod starts a description of an expectation of the specified method
@@ -68,10 +73,15 @@ func NewRoute53APIStub() *Route53APIStub {
 		zones:      make(map[string]*route53.HostedZone),
 		recordSets: make(map[string]map[string][]*route53.ResourceRecordSet),
 		zoneTags:   make(map[string][]*route53.Tag),
+		calls:      make(map[string]int),
+
+		healthChecks:    make(map[string]*route53.HealthCheck),
+		healthCheckTags: make(map[string][]*route53.Tag),
 	}
 }
 
 func (r *Route53APIStub) ListResourceRecordSetsPages(input *route53.ListResourceRecordSetsInput, fn func(p *route53.ListResourceRecordSetsOutput, lastPage bool) (shouldContinue bool)) error {
+	r.calls["ListResourceRecordSetsPages"]++
 	output := route53.ListResourceRecordSetsOutput{} // TODO: Support optional input args.
 	if len(r.recordSets) <= 0 {
 		output.ResourceRecordSets = []*route53.ResourceRecordSet{}
@@ -98,6 +108,7 @@ func wildcardEscape(s string) string {
 }
 
 func (r *Route53APIStub) ListTagsForResource(input *route53.ListTagsForResourceInput) (*route53.ListTagsForResourceOutput, error) {
+	r.calls["ListTagsForResource"]++
 	if aws.StringValue(input.ResourceType) == "hostedzone" {
 		tags := r.zoneTags[aws.StringValue(input.ResourceId)]
 		return &route53.ListTagsForResourceOutput{
@@ -112,6 +123,7 @@ func (r *Route53APIStub) ListTagsForResource(input *route53.ListTagsForResourceI
 }
 
 func (r *Route53APIStub) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	r.calls["ChangeResourceRecordSets"]++
 	if r.m.isMocked("ChangeResourceRecordSets", input) {
 		return r.m.ChangeResourceRecordSets(input)
 	}
@@ -125,10 +137,11 @@ func (r *Route53APIStub) ChangeResourceRecordSets(input *route53.ChangeResourceR
 		return nil, fmt.Errorf("ChangeBatch doesn't contain any changes")
 	}
 
+	// changes are applied to a copy of the record sets, a batch is rejected as a whole like by Route53
 	output := &route53.ChangeResourceRecordSetsOutput{}
-	recordSets, ok := r.recordSets[aws.StringValue(input.HostedZoneId)]
-	if !ok {
-		recordSets = make(map[string][]*route53.ResourceRecordSet)
+	recordSets := make(map[string][]*route53.ResourceRecordSet)
+	for key, rrsets := range r.recordSets[aws.StringValue(input.HostedZoneId)] {
+		recordSets[key] = rrsets
 	}
 
 	for _, change := range input.ChangeBatch.Changes {
@@ -146,7 +159,7 @@ func (r *Route53APIStub) ChangeResourceRecordSets(input *route53.ChangeResourceR
 			change.ResourceRecordSet.AliasTarget.DNSName = aws.String(wildcardEscape(ensureTrailingDot(aws.StringValue(change.ResourceRecordSet.AliasTarget.DNSName))))
 		}
 
-		key := aws.StringValue(change.ResourceRecordSet.Name) + "::" + aws.StringValue(change.ResourceRecordSet.Type)
+		key := aws.StringValue(change.ResourceRecordSet.Name) + "::" + aws.StringValue(change.ResourceRecordSet.Type) + "::" + aws.StringValue(change.ResourceRecordSet.SetIdentifier)
 		switch aws.StringValue(change.Action) {
 		case route53.ChangeActionCreate:
 			if _, found := recordSets[key]; found {
@@ -157,6 +170,9 @@ func (r *Route53APIStub) ChangeResourceRecordSets(input *route53.ChangeResourceR
 			if _, found := recordSets[key]; !found {
 				return nil, fmt.Errorf("Attempt to delete non-existent rrset %s", key) // TODO: Check other fields too
 			}
+			if aws.StringValue(recordSets[key][0].HealthCheckId) != aws.StringValue(change.ResourceRecordSet.HealthCheckId) {
+				return nil, fmt.Errorf("Attempt to delete rrset %s with a different health check", key)
+			}
 			delete(recordSets, key)
 		case route53.ChangeActionUpsert:
 			recordSets[key] = []*route53.ResourceRecordSet{change.ResourceRecordSet}
@@ -167,6 +183,7 @@ func (r *Route53APIStub) ChangeResourceRecordSets(input *route53.ChangeResourceR
 }
 
 func (r *Route53APIStub) ListHostedZonesPages(input *route53.ListHostedZonesInput, fn func(p *route53.ListHostedZonesOutput, lastPage bool) (shouldContinue bool)) error {
+	r.calls["ListHostedZonesPages"]++
 	output := &route53.ListHostedZonesOutput{}
 	for _, zone := range r.zones {
 		output.HostedZones = append(output.HostedZones, zone)
@@ -190,6 +207,110 @@ func (r *Route53APIStub) CreateHostedZone(input *route53.CreateHostedZoneInput)
 	return &route53.CreateHostedZoneOutput{HostedZone: r.zones[id]}, nil
 }
 
+func (r *Route53APIStub) ListTagsForResources(input *route53.ListTagsForResourcesInput) (*route53.ListTagsForResourcesOutput, error) {
+	r.calls["ListTagsForResources"]++
+	if aws.StringValue(input.ResourceType) != "healthcheck" || len(input.ResourceIds) > 10 {
+		return nil, fmt.Errorf("Invalid request to list tags of %d %s resources", len(input.ResourceIds), aws.StringValue(input.ResourceType))
+	}
+	output := &route53.ListTagsForResourcesOutput{}
+	for _, id := range input.ResourceIds {
+		output.ResourceTagSets = append(output.ResourceTagSets, &route53.ResourceTagSet{
+			ResourceId:   id,
+			ResourceType: input.ResourceType,
+			Tags:         r.healthCheckTags[aws.StringValue(id)],
+		})
+	}
+	return output, nil
+}
+
+func (r *Route53APIStub) ChangeTagsForResource(input *route53.ChangeTagsForResourceInput) (*route53.ChangeTagsForResourceOutput, error) {
+	r.calls["ChangeTagsForResource"]++
+	id := aws.StringValue(input.ResourceId)
+	if _, ok := r.healthChecks[id]; !ok || aws.StringValue(input.ResourceType) != "healthcheck" {
+		return nil, fmt.Errorf("Health check doesn't exist: %s", id)
+	}
+	r.healthCheckTags[id] = append(r.healthCheckTags[id], input.AddTags...)
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+func (r *Route53APIStub) ListHealthChecksPages(input *route53.ListHealthChecksInput, fn func(p *route53.ListHealthChecksOutput, lastPage bool) (shouldContinue bool)) error {
+	r.calls["ListHealthChecksPages"]++
+	output := &route53.ListHealthChecksOutput{}
+	for _, check := range r.healthChecks {
+		output.HealthChecks = append(output.HealthChecks, check)
+	}
+	lastPage := true
+	fn(output, lastPage)
+	return nil
+}
+
+func (r *Route53APIStub) CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error) {
+	r.calls["CreateHealthCheck"]++
+	id := fmt.Sprintf("health-check-%d", r.calls["CreateHealthCheck"])
+	r.healthChecks[id] = &route53.HealthCheck{
+		Id:                 aws.String(id),
+		CallerReference:    input.CallerReference,
+		HealthCheckConfig:  input.HealthCheckConfig,
+		HealthCheckVersion: aws.Int64(1),
+	}
+	return &route53.CreateHealthCheckOutput{HealthCheck: r.healthChecks[id]}, nil
+}
+
+func (r *Route53APIStub) UpdateHealthCheck(input *route53.UpdateHealthCheckInput) (*route53.UpdateHealthCheckOutput, error) {
+	r.calls["UpdateHealthCheck"]++
+	check, ok := r.healthChecks[aws.StringValue(input.HealthCheckId)]
+	if !ok {
+		return nil, fmt.Errorf("Health check doesn't exist: %s", aws.StringValue(input.HealthCheckId))
+	}
+	if aws.Int64Value(check.HealthCheckVersion) != aws.Int64Value(input.HealthCheckVersion) {
+		return nil, fmt.Errorf("Health check %s was modified concurrently", aws.StringValue(input.HealthCheckId))
+	}
+	config := *check.HealthCheckConfig
+	if input.IPAddress != nil {
+		config.IPAddress = input.IPAddress
+	}
+	if input.FullyQualifiedDomainName != nil {
+		config.FullyQualifiedDomainName = input.FullyQualifiedDomainName
+	}
+	if input.Port != nil {
+		config.Port = input.Port
+	}
+	if input.ResourcePath != nil {
+		config.ResourcePath = input.ResourcePath
+	}
+	if input.FailureThreshold != nil {
+		config.FailureThreshold = input.FailureThreshold
+	}
+	updated := &route53.HealthCheck{
+		Id:                 check.Id,
+		CallerReference:    check.CallerReference,
+		HealthCheckConfig:  &config,
+		HealthCheckVersion: aws.Int64(aws.Int64Value(check.HealthCheckVersion) + 1),
+	}
+	r.healthChecks[aws.StringValue(check.Id)] = updated
+	return &route53.UpdateHealthCheckOutput{HealthCheck: updated}, nil
+}
+
+func (r *Route53APIStub) DeleteHealthCheck(input *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error) {
+	r.calls["DeleteHealthCheck"]++
+	id := aws.StringValue(input.HealthCheckId)
+	if _, ok := r.healthChecks[id]; !ok {
+		return nil, fmt.Errorf("Health check doesn't exist: %s", id)
+	}
+	for _, recordSets := range r.recordSets {
+		for _, rrsets := range recordSets {
+			for _, rrset := range rrsets {
+				if aws.StringValue(rrset.HealthCheckId) == id {
+					return nil, fmt.Errorf("Health check %s is still in use", id)
+				}
+			}
+		}
+	}
+	delete(r.healthChecks, id)
+	delete(r.healthCheckTags, id)
+	return &route53.DeleteHealthCheckOutput{}, nil
+}
+
 type dynamicMock struct {
 	mock.Mock
 }
@@ -275,6 +396,7 @@ func TestAWSRecords(t *testing.T) {
 		endpoint.NewEndpoint("*.wildcard-test-alias.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "false"),
 		endpoint.NewEndpoint("list-test-alias-evaluate.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true"),
 		endpoint.NewEndpointWithTTL("list-test-multiple.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "8.8.8.8", "8.8.4.4"),
+		endpoint.NewEndpointWithTTL("list-test-ipv6.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeAAAA, endpoint.TTL(recordTTL), "2001:db8::1"),
 		endpoint.NewEndpointWithTTL("prefix-*.wildcard.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "random"),
 	})
 
@@ -285,10 +407,11 @@ func TestAWSRecords(t *testing.T) {
 		endpoint.NewEndpointWithTTL("list-test.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4"),
 		endpoint.NewEndpointWithTTL("list-test.zone-2.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "8.8.8.8"),
 		endpoint.NewEndpointWithTTL("*.wildcard-test.zone-2.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "8.8.8.8"),
-		endpoint.NewEndpoint("list-test-alias.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "false"),
-		endpoint.NewEndpoint("*.wildcard-test-alias.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "false"),
-		endpoint.NewEndpoint("list-test-alias-evaluate.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true"),
+		endpoint.NewEndpoint("list-test-alias.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "false").WithProviderSpecific(providerSpecificDualstack, "false"),
+		endpoint.NewEndpoint("*.wildcard-test-alias.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "false").WithProviderSpecific(providerSpecificDualstack, "false"),
+		endpoint.NewEndpoint("list-test-alias-evaluate.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true").WithProviderSpecific(providerSpecificDualstack, "false"),
 		endpoint.NewEndpointWithTTL("list-test-multiple.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "8.8.8.8", "8.8.4.4"),
+		endpoint.NewEndpointWithTTL("list-test-ipv6.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeAAAA, endpoint.TTL(recordTTL), "2001:db8::1"),
 		endpoint.NewEndpointWithTTL("prefix-*.wildcard.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "random"),
 	})
 }
@@ -445,6 +568,397 @@ func TestAWSApplyChanges(t *testing.T) {
 	})
 }
 
+func TestAWSApplyChangesAPICalls(t *testing.T) {
+	provider, clientStub := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("lb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, endpoint.TTL(recordTTL), "foo.eu-central-1.elb.amazonaws.com"),
+	})
+
+	var creates, aliases []*endpoint.Endpoint
+	for i := 0; i < 100; i++ {
+		creates = append(creates, endpoint.NewEndpoint(fmt.Sprintf("create-test-%d.zone-2.ext-dns-test-2.teapot.zalan.do", i), endpoint.RecordTypeA, "8.8.8.8"))
+		aliases = append(aliases, endpoint.NewEndpoint(fmt.Sprintf("alias-test-%d.zone-2.ext-dns-test-2.teapot.zalan.do", i), endpoint.RecordTypeCNAME, "lb.zone-1.ext-dns-test-2.teapot.zalan.do").WithProviderSpecific("alias", "true"))
+	}
+
+	// records are only listed if a change requests an alias
+	clientStub.calls = map[string]int{}
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: creates}))
+	assert.Equal(t, 1, clientStub.calls["ListHostedZonesPages"])
+	assert.Equal(t, 0, clientStub.calls["ListResourceRecordSetsPages"])
+
+	// the records of all three hosted zones are listed once for all aliases
+	clientStub.calls = map[string]int{}
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: aliases[:50], UpdateNew: aliases[50:], Delete: creates}))
+	assert.Equal(t, 1, clientStub.calls["ListHostedZonesPages"])
+	assert.Equal(t, 3, clientStub.calls["ListResourceRecordSetsPages"])
+
+	// the alias points to the hosted zone of its target
+	for _, recordSet := range listAWSRecords(t, provider.client, "/hostedzone/zone-2.ext-dns-test-2.teapot.zalan.do.") {
+		require.NotNil(t, recordSet.AliasTarget)
+		assert.Equal(t, "zone-1.ext-dns-test-2.teapot.zalan.do.", aws.StringValue(recordSet.AliasTarget.HostedZoneId))
+	}
+
+	// nothing is listed without changes
+	clientStub.calls = map[string]int{}
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{}))
+	assert.Empty(t, clientStub.calls)
+}
+
+func TestAWSRoutingPolicies(t *testing.T) {
+	provider, _ := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("weighted.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4").WithSetIdentifier("blue").WithProviderSpecific(providerSpecificWeight, "10"),
+		endpoint.NewEndpointWithTTL("weighted.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "4.3.2.1").WithSetIdentifier("green").WithProviderSpecific(providerSpecificWeight, "90"),
+		endpoint.NewEndpointWithTTL("latency.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4").WithSetIdentifier("eu").WithProviderSpecific(providerSpecificRegion, "eu-central-1"),
+		endpoint.NewEndpointWithTTL("failover.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4").WithSetIdentifier("primary").WithProviderSpecific(providerSpecificFailover, route53.ResourceRecordSetFailoverPrimary),
+		endpoint.NewEndpointWithTTL("geolocation.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4").WithSetIdentifier("germany").WithProviderSpecific(providerSpecificGeolocationCountryCode, "DE"),
+		endpoint.NewEndpoint("alias.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com").WithSetIdentifier("blue").WithProviderSpecific(providerSpecificWeight, "100").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true").WithProviderSpecific(providerSpecificDualstack, "false"),
+	}
+	require.NoError(t, provider.CreateRecords(endpoints))
+
+	recordSets := listAWSRecords(t, provider.client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.")
+	require.Len(t, recordSets, len(endpoints))
+	for _, recordSet := range recordSets {
+		if aws.StringValue(recordSet.Name) == "geolocation.zone-1.ext-dns-test-2.teapot.zalan.do." {
+			assert.Equal(t, "germany", aws.StringValue(recordSet.SetIdentifier))
+			assert.Equal(t, &route53.GeoLocation{CountryCode: aws.String("DE")}, recordSet.GeoLocation)
+		}
+	}
+
+	// validateEndpoints sorts the expected endpoints
+	green := endpoints[1]
+	records, err := provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, endpoints)
+
+	// the weight of a record is updated in place
+	updated := endpoint.NewEndpointWithTTL("weighted.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "4.3.2.1").WithSetIdentifier("green").WithProviderSpecific(providerSpecificWeight, "50")
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{green},
+		UpdateNew: []*endpoint.Endpoint{updated},
+	}))
+
+	expected := []*endpoint.Endpoint{updated}
+	for _, ep := range endpoints {
+		if ep != green {
+			expected = append(expected, ep)
+		}
+	}
+	records, err = provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, expected)
+}
+
+func TestAWSHealthChecks(t *testing.T) {
+	provider, client := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+	provider.manageHealthChecks = true
+	provider.ownerID = "owner"
+
+	for id, owner := range map[string]string{"orphaned": "owner", "foreign": "other"} {
+		client.healthChecks[id] = &route53.HealthCheck{
+			Id:                aws.String(id),
+			HealthCheckConfig: &route53.HealthCheckConfig{Type: aws.String(route53.HealthCheckTypeTcp), IPAddress: aws.String("8.8.8.8")},
+		}
+		client.healthCheckTags[id] = []*route53.Tag{{Key: aws.String(healthCheckOwnerTag), Value: aws.String(owner)}}
+	}
+
+	primary := endpoint.NewEndpointWithTTL("failover.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4").
+		WithSetIdentifier("primary").
+		WithProviderSpecific(providerSpecificFailover, route53.ResourceRecordSetFailoverPrimary).
+		WithProviderSpecific(providerSpecificHealthCheckProtocol, route53.HealthCheckTypeHttp).
+		WithProviderSpecific(providerSpecificHealthCheckPath, "/healthz").
+		WithProviderSpecific(providerSpecificHealthCheckPort, "8080")
+	txt := endpoint.NewEndpoint("failover.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=owner\"").
+		WithSetIdentifier("primary")
+	txt.ProviderSpecific = primary.ProviderSpecific
+
+	// the orphaned health check is deleted after the record got its health check
+	_, err := provider.Records()
+	require.NoError(t, err)
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{primary, txt}}))
+
+	require.Len(t, client.healthChecks, 2)
+	assert.Contains(t, client.healthChecks, "foreign")
+	check := client.healthChecks["health-check-1"]
+	require.NotNil(t, check)
+	assert.Equal(t, &route53.HealthCheckConfig{
+		Type:         aws.String(route53.HealthCheckTypeHttp),
+		IPAddress:    aws.String("1.2.3.4"),
+		ResourcePath: aws.String("/healthz"),
+		Port:         aws.Int64(8080),
+	}, check.HealthCheckConfig)
+	assert.ElementsMatch(t, []*route53.Tag{
+		{Key: aws.String("Name"), Value: aws.String("failover.zone-1.ext-dns-test-2.teapot.zalan.do/primary")},
+		{Key: aws.String(healthCheckOwnerTag), Value: aws.String("owner")},
+		{Key: aws.String(healthCheckRecordTag), Value: aws.String("failover.zone-1.ext-dns-test-2.teapot.zalan.do/primary")},
+	}, client.healthCheckTags["health-check-1"])
+
+	for _, recordSet := range listAWSRecords(t, provider.client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.") {
+		if aws.StringValue(recordSet.Type) == route53.RRTypeA {
+			assert.Equal(t, "health-check-1", aws.StringValue(recordSet.HealthCheckId))
+		} else {
+			assert.Nil(t, recordSet.HealthCheckId)
+		}
+	}
+
+	// the health check is read back with the record
+	records, err := provider.Records()
+	require.NoError(t, err)
+	current := primary.DeepCopy().WithProviderSpecific(providerSpecificHealthCheckID, "health-check-1")
+	currentTXT := endpoint.NewEndpointWithTTL(txt.DNSName, txt.RecordType, endpoint.TTL(recordTTL), txt.Targets...).
+		WithSetIdentifier("primary").
+		WithProviderSpecific(providerSpecificFailover, route53.ResourceRecordSetFailoverPrimary).
+		WithProviderSpecific(providerSpecificHealthCheckProtocol, healthCheckProtocolNone)
+	validateEndpoints(t, records, []*endpoint.Endpoint{current, currentTXT})
+
+	// a changed path updates the health check in place
+	updated := primary.DeepCopy().WithProviderSpecific(providerSpecificHealthCheckPath, "/ready")
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{current},
+		UpdateNew: []*endpoint.Endpoint{updated},
+	}))
+	assert.Equal(t, 1, client.calls["CreateHealthCheck"])
+	assert.Equal(t, 1, client.calls["UpdateHealthCheck"])
+	assert.Equal(t, "/ready", aws.StringValue(client.healthChecks["health-check-1"].HealthCheckConfig.ResourcePath))
+
+	// the health check of a deleted record is deleted on the next run
+	records, err = provider.Records()
+	require.NoError(t, err)
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Delete: records}))
+	assert.Contains(t, client.healthChecks, "health-check-1")
+
+	_, err = provider.Records()
+	require.NoError(t, err)
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{}))
+	assert.Len(t, client.healthChecks, 1)
+	assert.Contains(t, client.healthChecks, "foreign")
+}
+
+func TestAWSHealthCheckRemoved(t *testing.T) {
+	provider, client := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+	provider.manageHealthChecks = true
+	provider.ownerID = "owner"
+
+	_, err := provider.Records()
+	require.NoError(t, err)
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("checked.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4").
+			WithProviderSpecific(providerSpecificHealthCheckProtocol, route53.HealthCheckTypeTcp),
+	}}))
+	require.Contains(t, client.healthChecks, "health-check-1")
+
+	// the id of the managed health check isn't known to the desired record
+	records, err := provider.Records()
+	require.NoError(t, err)
+	desired := endpoint.NewEndpointWithTTL("checked.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4")
+	desired.ProviderSpecific = provider.ProviderSpecificDefaults()
+	desired.WithProviderSpecific(providerSpecificHealthCheckProtocol, route53.HealthCheckTypeTcp)
+	changes := (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes
+	assert.True(t, changes.IsEmpty())
+
+	// removing the annotations detaches the health check from the record, the desired
+	// record gets the protocol none from the defaults
+	desired.ProviderSpecific = provider.ProviderSpecificDefaults()
+	changes = (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes
+	require.Len(t, changes.UpdateNew, 1)
+	require.NoError(t, provider.ApplyChanges(changes))
+
+	recordSets := listAWSRecords(t, client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.")
+	require.Len(t, recordSets, 1)
+	assert.Nil(t, recordSets[0].HealthCheckId)
+
+	// the detached health check is deleted on the next run, which doesn't change the record anymore
+	records, err = provider.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, endpoint.ProviderSpecific{providerSpecificHealthCheckProtocol: healthCheckProtocolNone}, records[0].ProviderSpecific)
+	changes = (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes
+	assert.True(t, changes.IsEmpty())
+	require.NoError(t, provider.ApplyChanges(changes))
+	assert.Empty(t, client.healthChecks)
+}
+
+func TestAWSDualstack(t *testing.T) {
+	provider, client := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+	provider.dualstack = true
+
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("alb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "dualstack.foo.eu-central-1.elb.amazonaws.com"),
+		endpoint.NewEndpoint("nlb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.elb.eu-central-1.amazonaws.com").WithProviderSpecific(providerSpecificDualstack, "false"),
+		endpoint.NewEndpoint("cdn.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "d111111abcdef8.cloudfront.net"),
+		endpoint.NewEndpoint("website.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "bucket.s3-website.eu-central-1.amazonaws.com"),
+	}
+	require.NoError(t, provider.CreateRecords(endpoints))
+
+	types := map[string][]string{}
+	for _, recordSet := range listAWSRecords(t, client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.") {
+		require.NotNil(t, recordSet.AliasTarget)
+		types[aws.StringValue(recordSet.Name)] = append(types[aws.StringValue(recordSet.Name)], aws.StringValue(recordSet.Type))
+	}
+	for name, expected := range map[string][]string{
+		"alb.zone-1.ext-dns-test-2.teapot.zalan.do.":     {route53.RRTypeA, route53.RRTypeAaaa},
+		"nlb.zone-1.ext-dns-test-2.teapot.zalan.do.":     {route53.RRTypeA},
+		"cdn.zone-1.ext-dns-test-2.teapot.zalan.do.":     {route53.RRTypeA, route53.RRTypeAaaa},
+		"website.zone-1.ext-dns-test-2.teapot.zalan.do.": {route53.RRTypeA},
+	} {
+		assert.ElementsMatch(t, expected, types[name], name)
+	}
+
+	// both aliases are read back as a single record
+	records, err := provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("alb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "dualstack.foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true").WithProviderSpecific(providerSpecificDualstack, "true"),
+		endpoint.NewEndpoint("nlb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.elb.eu-central-1.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true").WithProviderSpecific(providerSpecificDualstack, "false"),
+		endpoint.NewEndpoint("cdn.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "d111111abcdef8.cloudfront.net").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true").WithProviderSpecific(providerSpecificDualstack, "true"),
+		endpoint.NewEndpoint("website.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "bucket.s3-website.eu-central-1.amazonaws.com").WithProviderSpecific(providerSpecificEvaluateTargetHealth, "true").WithProviderSpecific(providerSpecificDualstack, "false"),
+	})
+
+	// the AAAA alias is deleted once a record isn't dual-stack anymore and with the record
+	current := map[string]*endpoint.Endpoint{}
+	for _, record := range records {
+		current[record.DNSName] = record
+	}
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{current["alb.zone-1.ext-dns-test-2.teapot.zalan.do"], current["nlb.zone-1.ext-dns-test-2.teapot.zalan.do"]},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("alb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "dualstack.foo.eu-central-1.elb.amazonaws.com").WithProviderSpecific(providerSpecificDualstack, "false"),
+			endpoint.NewEndpoint("nlb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.elb.eu-central-1.amazonaws.com").WithProviderSpecific(providerSpecificDualstack, "true"),
+		},
+		Delete: []*endpoint.Endpoint{current["cdn.zone-1.ext-dns-test-2.teapot.zalan.do"]},
+	}))
+
+	types = map[string][]string{}
+	for _, recordSet := range listAWSRecords(t, client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.") {
+		types[aws.StringValue(recordSet.Name)] = append(types[aws.StringValue(recordSet.Name)], aws.StringValue(recordSet.Type))
+	}
+	assert.Len(t, types, 3)
+	assert.ElementsMatch(t, []string{route53.RRTypeA}, types["alb.zone-1.ext-dns-test-2.teapot.zalan.do."])
+	assert.ElementsMatch(t, []string{route53.RRTypeA, route53.RRTypeAaaa}, types["nlb.zone-1.ext-dns-test-2.teapot.zalan.do."])
+}
+
+func TestAWSDualstackEnabled(t *testing.T) {
+	provider, client := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+	require.NoError(t, provider.CreateRecords([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("alb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com"),
+	}))
+
+	// turning dual-stack on updates the existing A alias, which gets its AAAA alias
+	provider.dualstack = true
+	records, err := provider.Records()
+	require.NoError(t, err)
+
+	desired := endpoint.NewEndpoint("alb.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "foo.eu-central-1.elb.amazonaws.com")
+	desired.ProviderSpecific = provider.ProviderSpecificDefaults()
+	changes := (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes
+	require.Len(t, changes.UpdateNew, 1)
+	require.NoError(t, provider.ApplyChanges(changes))
+
+	var types []string
+	for _, recordSet := range listAWSRecords(t, client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.") {
+		types = append(types, aws.StringValue(recordSet.Type))
+	}
+	assert.ElementsMatch(t, []string{route53.RRTypeA, route53.RRTypeAaaa}, types)
+
+	// nothing changes anymore afterwards
+	records, err = provider.Records()
+	require.NoError(t, err)
+	assert.True(t, (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes.IsEmpty())
+}
+
+func TestAWSZoneRoles(t *testing.T) {
+	provider, client := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+	provider.manageHealthChecks = true
+	provider.ownerID = "owner"
+
+	role := "arn:aws:iam::123456789012:role/external-dns"
+	roleClient := NewRoute53APIStub()
+	provider.roleClients = map[string]Route53API{role: roleClient}
+	provider.zoneRoles = []awsZoneRole{{
+		role:         role,
+		domainFilter: NewDomainFilter([]string{"zone-5.ext-dns-test-2.teapot.zalan.do", "zone-7"}),
+		zoneIDFilter: NewZoneIDFilter([]string{"zone-5.ext-dns-test-2.teapot.zalan.do", "zone-7"}),
+	}}
+
+	for _, zone := range []*route53.HostedZone{
+		{Id: aws.String("/hostedzone/zone-5.ext-dns-test-2.teapot.zalan.do."), Name: aws.String("zone-5.ext-dns-test-2.teapot.zalan.do.")},
+		// not matching the role
+		{Id: aws.String("/hostedzone/zone-6.ext-dns-test-2.teapot.zalan.do."), Name: aws.String("zone-6.ext-dns-test-2.teapot.zalan.do.")},
+		// matching the role by its id
+		{Id: aws.String("/hostedzone/zone-7"), Name: aws.String("zone-7.ext-dns-test-2.teapot.zalan.do.")},
+	} {
+		roleClient.zones[aws.StringValue(zone.Id)] = zone
+	}
+	// also visible to the default client, but managed with the role
+	_, err := client.CreateHostedZone(&route53.CreateHostedZoneInput{Name: aws.String("zone-5.ext-dns-test-2.teapot.zalan.do."), CallerReference: aws.String("test")})
+	require.NoError(t, err)
+
+	zones, err := provider.Zones()
+	require.NoError(t, err)
+	assert.Len(t, zones, 5)
+	for id, expected := range map[string]Route53API{
+		"/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do.": client,
+		"/hostedzone/zone-2.ext-dns-test-2.teapot.zalan.do.": client,
+		"/hostedzone/zone-3.ext-dns-test-2.teapot.zalan.do.": client,
+		"/hostedzone/zone-5.ext-dns-test-2.teapot.zalan.do.": roleClient,
+		"/hostedzone/zone-7": roleClient,
+	} {
+		require.Contains(t, zones, id)
+		assert.True(t, expected == provider.clientForZone(id), "unexpected client of zone %s", id)
+	}
+
+	endpoints := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("default.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("role.zone-5.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, "1.2.3.4").
+			WithProviderSpecific(providerSpecificHealthCheckProtocol, route53.HealthCheckTypeTcp),
+		endpoint.NewEndpoint("role.zone-7.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeCNAME, "example.org"),
+	}
+	_, err = provider.Records()
+	require.NoError(t, err)
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: endpoints}))
+
+	assert.Len(t, listAWSRecords(t, client, "/hostedzone/zone-1.ext-dns-test-2.teapot.zalan.do."), 1)
+	assert.Len(t, listAWSRecords(t, client, "/hostedzone/zone-5.ext-dns-test-2.teapot.zalan.do."), 0)
+	assert.Len(t, listAWSRecords(t, roleClient, "/hostedzone/zone-5.ext-dns-test-2.teapot.zalan.do."), 1)
+	assert.Len(t, listAWSRecords(t, roleClient, "/hostedzone/zone-7"), 1)
+
+	// the health check is created in the account of the record
+	assert.Len(t, client.healthChecks, 0)
+	assert.Len(t, roleClient.healthChecks, 1)
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+	endpoints[0].RecordTTL = recordTTL
+	endpoints[0].WithProviderSpecific(providerSpecificHealthCheckProtocol, healthCheckProtocolNone)
+	endpoints[1].RecordTTL = recordTTL
+	endpoints[1].WithProviderSpecific(providerSpecificHealthCheckID, "health-check-1")
+	endpoints[2].RecordTTL = recordTTL
+	endpoints[2].WithProviderSpecific(providerSpecificHealthCheckProtocol, healthCheckProtocolNone)
+	validateEndpoints(t, records, endpoints)
+}
+
+func TestAWSNewZoneRoles(t *testing.T) {
+	zoneRoles, err := newAWSZoneRoles([]string{
+		"",
+		"arn:aws:iam::123455567:role/external-dns=example.org, Z2ABCDEFGHIJKL",
+		"arn:aws:iam::765455321:role/with=equal-sign=company.com",
+	})
+	require.NoError(t, err)
+	require.Len(t, zoneRoles, 2)
+
+	assert.Equal(t, "arn:aws:iam::123455567:role/external-dns", zoneRoles[0].role)
+	assert.True(t, zoneRoles[0].match(&route53.HostedZone{Id: aws.String("/hostedzone/Z1"), Name: aws.String("sub.example.org.")}))
+	assert.True(t, zoneRoles[0].match(&route53.HostedZone{Id: aws.String("/hostedzone/Z2ABCDEFGHIJKL"), Name: aws.String("example.com.")}))
+	assert.False(t, zoneRoles[0].match(&route53.HostedZone{Id: aws.String("/hostedzone/Z1"), Name: aws.String("company.com.")}))
+	assert.Equal(t, "arn:aws:iam::765455321:role/with=equal-sign", zoneRoles[1].role)
+	assert.True(t, zoneRoles[1].match(&route53.HostedZone{Id: aws.String("/hostedzone/Z1"), Name: aws.String("company.com.")}))
+
+	for _, invalid := range []string{"arn:aws:iam::123455567:role/external-dns", "=example.org", "arn:aws:iam::123455567:role/external-dns=", "arn:aws:iam::123455567:role/external-dns= ,"} {
+		_, err := newAWSZoneRoles([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
 func TestAWSApplyChangesDryRun(t *testing.T) {
 	originalEndpoints := []*endpoint.Endpoint{
 		endpoint.NewEndpointWithTTL("update-test.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "8.8.8.8"),
@@ -508,31 +1022,31 @@ func TestAWSApplyChangesDryRun(t *testing.T) {
 }
 
 func TestAWSChangesByZones(t *testing.T) {
-	changes := []*route53.Change{
-		{
+	changes := []*awsChange{
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("qux.foo.example.org"), TTL: aws.Int64(1),
 			},
-		},
-		{
+		}},
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("qux.bar.example.org"), TTL: aws.Int64(2),
 			},
-		},
-		{
+		}},
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionDelete),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("wambo.foo.example.org"), TTL: aws.Int64(10),
 			},
-		},
-		{
+		}},
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionDelete),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("wambo.bar.example.org"), TTL: aws.Int64(20),
 			},
-		},
+		}},
 	}
 
 	zones := map[string]*route53.HostedZone{
@@ -558,49 +1072,49 @@ func TestAWSChangesByZones(t *testing.T) {
 	changesByZone := changesByZone(zones, changes)
 	require.Len(t, changesByZone, 3)
 
-	validateAWSChangeRecords(t, changesByZone["foo-example-org"], []*route53.Change{
-		{
+	validateAWSChangeRecords(t, changesByZone["foo-example-org"], []*awsChange{
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("qux.foo.example.org"), TTL: aws.Int64(1),
 			},
-		},
-		{
+		}},
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionDelete),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("wambo.foo.example.org"), TTL: aws.Int64(10),
 			},
-		},
+		}},
 	})
 
-	validateAWSChangeRecords(t, changesByZone["bar-example-org"], []*route53.Change{
-		{
+	validateAWSChangeRecords(t, changesByZone["bar-example-org"], []*awsChange{
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("qux.bar.example.org"), TTL: aws.Int64(2),
 			},
-		},
-		{
+		}},
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionDelete),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("wambo.bar.example.org"), TTL: aws.Int64(20),
 			},
-		},
+		}},
 	})
 
-	validateAWSChangeRecords(t, changesByZone["bar-example-org-private"], []*route53.Change{
-		{
+	validateAWSChangeRecords(t, changesByZone["bar-example-org-private"], []*awsChange{
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("qux.bar.example.org"), TTL: aws.Int64(2),
 			},
-		},
-		{
+		}},
+		{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionDelete),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String("wambo.bar.example.org"), TTL: aws.Int64(20),
 			},
-		},
+		}},
 	})
 }
 
@@ -619,10 +1133,13 @@ func TestAWSsubmitChanges(t *testing.T) {
 		}
 	}
 
-	cs := make([]*route53.Change, 0, len(endpoints))
-	cs = append(cs, provider.newChanges(route53.ChangeActionCreate, endpoints)...)
+	zones, err := provider.Zones()
+	require.NoError(t, err)
+
+	cs := make([]*awsChange, 0, len(endpoints))
+	cs = append(cs, provider.newChanges(route53.ChangeActionCreate, endpoints, nil, zones, nil)...)
 
-	require.NoError(t, provider.submitChanges(cs))
+	require.NoError(t, provider.submitChanges(cs, zones))
 
 	records, err := provider.Records()
 	require.NoError(t, err)
@@ -635,32 +1152,124 @@ func TestAWSsubmitChangesError(t *testing.T) {
 	clientStub.MockMethod("ChangeResourceRecordSets", mock.Anything).Return(nil, fmt.Errorf("Mock route53 failure"))
 
 	ep := endpoint.NewEndpointWithTTL("fail.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.0.0.1")
-	cs := provider.newChanges(route53.ChangeActionCreate, []*endpoint.Endpoint{ep})
+	zones, err := provider.Zones()
+	require.NoError(t, err)
+	cs := provider.newChanges(route53.ChangeActionCreate, []*endpoint.Endpoint{ep}, nil, zones, nil)
+
+	require.Error(t, provider.submitChanges(cs, zones))
+}
+
+func TestAWSsubmitChangesPartialError(t *testing.T) {
+	provider, clientStub := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("existing.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.0.0.1"),
+	})
+	provider.batchChangeInterval = 0
+
+	endpoints := []*endpoint.Endpoint{}
+	for i := 0; i < 8; i++ {
+		endpoints = append(endpoints, endpoint.NewEndpointWithTTL(fmt.Sprintf("host-%d.zone-1.ext-dns-test-2.teapot.zalan.do", i), endpoint.RecordTypeA, endpoint.TTL(recordTTL), fmt.Sprintf("1.1.1.%d", i)))
+	}
+	// creating a record that exists already fails the whole batch
+	existing := endpoint.NewEndpointWithTTL("existing.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.0.0.2")
+	existingTXT := endpoint.NewEndpointWithTTL("txt.existing.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "\"heritage=external-dns\"")
+	existingTXT.Labels[endpoint.OwnedRecordLabelKey] = existing.DNSName
+
+	zones, err := provider.Zones()
+	require.NoError(t, err)
+	cs := provider.newChanges(route53.ChangeActionCreate, append(endpoints, existing, existingTXT), nil, zones, nil)
+
+	calls := clientStub.calls["ChangeResourceRecordSets"]
+	err = provider.submitChanges(cs, zones)
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Equal(t, []*endpoint.Endpoint{existing, existingTXT}, err.(*FailedChangesError).Endpoints)
+
+	// the other records were created by smaller batches, the TXT record of the failed one wasn't
+	records, err := provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, append(endpoints, endpoint.NewEndpointWithTTL("existing.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.0.0.1")))
+	// the batch of 9 records was split into 4 and 5, 2 and 2, 1 and 1 records until the failed one was isolated
+	assert.Equal(t, 7, clientStub.calls["ChangeResourceRecordSets"]-calls)
+}
+
+func TestAWSsubmitChangesExceedingBatchSize(t *testing.T) {
+	provider, _ := newAWSProvider(t, NewDomainFilter([]string{"ext-dns-test-2.teapot.zalan.do."}), NewZoneIDFilter([]string{}), NewZoneTypeFilter(""), defaultEvaluateTargetHealth, false, []*endpoint.Endpoint{})
+	provider.batchChangeSize = 1
+	provider.batchChangeInterval = 0
+
+	// a record and its TXT record don't fit into a single batch
+	ep := endpoint.NewEndpointWithTTL("large.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.0.0.1")
+	txt := endpoint.NewEndpointWithTTL("txt.large.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "\"heritage=external-dns\"")
+	txt.Labels[endpoint.OwnedRecordLabelKey] = ep.DNSName
+	small := endpoint.NewEndpointWithTTL("small.zone-1.ext-dns-test-2.teapot.zalan.do", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.0.0.2")
+
+	zones, err := provider.Zones()
+	require.NoError(t, err)
+	cs := provider.newChanges(route53.ChangeActionCreate, []*endpoint.Endpoint{ep, txt, small}, nil, zones, nil)
+
+	err = provider.submitChanges(cs, zones)
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Equal(t, []*endpoint.Endpoint{ep, txt}, err.(*FailedChangesError).Endpoints)
 
-	require.Error(t, provider.submitChanges(cs))
+	records, err := provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, []*endpoint.Endpoint{small})
+}
+
+func TestAWSBatchChangeSetOwnedRecord(t *testing.T) {
+	newChange := func(name, recordType, ownedRecord string) *awsChange {
+		ep := endpoint.NewEndpoint(name, recordType)
+		if ownedRecord != "" {
+			ep.Labels[endpoint.OwnedRecordLabelKey] = ownedRecord
+		}
+		return &awsChange{
+			Change: &route53.Change{
+				Action: aws.String(route53.ChangeActionCreate),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name: aws.String(name),
+					Type: aws.String(recordType),
+				},
+			},
+			endpoint: ep,
+		}
+	}
+	cs := []*awsChange{
+		newChange("a.example.org", endpoint.RecordTypeA, ""),
+		newChange("b.example.org", endpoint.RecordTypeA, ""),
+		newChange("b.example.org", endpoint.RecordTypeAAAA, ""),
+		newChange("txt-a.example.org", endpoint.RecordTypeTXT, "a.example.org"),
+		newChange("txt-b.example.org", endpoint.RecordTypeTXT, "b.example.org"),
+	}
+
+	batchCs, oversized := batchChangeSet(cs, 3)
+	assert.Empty(t, oversized)
+
+	require.Len(t, batchCs, 2)
+	validateAWSChangeRecords(t, batchCs[0], []*awsChange{cs[0], cs[3]})
+	validateAWSChangeRecords(t, batchCs[1], []*awsChange{cs[1], cs[2], cs[4]})
 }
 
 func TestAWSBatchChangeSet(t *testing.T) {
-	var cs []*route53.Change
+	var cs []*awsChange
 
 	for i := 1; i <= defaultBatchChangeSize; i += 2 {
-		cs = append(cs, &route53.Change{
+		cs = append(cs, &awsChange{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String(fmt.Sprintf("host-%d", i)),
 				Type: aws.String("A"),
 			},
-		})
-		cs = append(cs, &route53.Change{
+		}})
+		cs = append(cs, &awsChange{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String(fmt.Sprintf("host-%d", i)),
 				Type: aws.String("TXT"),
 			},
-		})
+		}})
 	}
 
-	batchCs := batchChangeSet(cs, defaultBatchChangeSize)
+	batchCs, oversized := batchChangeSet(cs, defaultBatchChangeSize)
+	assert.Empty(t, oversized)
 
 	require.Equal(t, 1, len(batchCs))
 
@@ -669,30 +1278,31 @@ func TestAWSBatchChangeSet(t *testing.T) {
 }
 
 func TestAWSBatchChangeSetExceeding(t *testing.T) {
-	var cs []*route53.Change
+	var cs []*awsChange
 	const testCount = 50
 	const testLimit = 11
 	const expectedBatchCount = 5
 	const expectedChangesCount = 10
 
 	for i := 1; i <= testCount; i += 2 {
-		cs = append(cs, &route53.Change{
+		cs = append(cs, &awsChange{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String(fmt.Sprintf("host-%d", i)),
 				Type: aws.String("A"),
 			},
-		})
-		cs = append(cs, &route53.Change{
+		}})
+		cs = append(cs, &awsChange{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String(fmt.Sprintf("host-%d", i)),
 				Type: aws.String("TXT"),
 			},
-		})
+		}})
 	}
 
-	batchCs := batchChangeSet(cs, testLimit)
+	batchCs, oversized := batchChangeSet(cs, testLimit)
+	assert.Empty(t, oversized)
 
 	require.Equal(t, expectedBatchCount, len(batchCs))
 
@@ -703,30 +1313,31 @@ func TestAWSBatchChangeSetExceeding(t *testing.T) {
 }
 
 func TestAWSBatchChangeSetExceedingNameChange(t *testing.T) {
-	var cs []*route53.Change
+	var cs []*awsChange
 	const testCount = 10
 	const testLimit = 1
 
 	for i := 1; i <= testCount; i += 2 {
-		cs = append(cs, &route53.Change{
+		cs = append(cs, &awsChange{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String(fmt.Sprintf("host-%d", i)),
 				Type: aws.String("A"),
 			},
-		})
-		cs = append(cs, &route53.Change{
+		}})
+		cs = append(cs, &awsChange{Change: &route53.Change{
 			Action: aws.String(route53.ChangeActionCreate),
 			ResourceRecordSet: &route53.ResourceRecordSet{
 				Name: aws.String(fmt.Sprintf("host-%d", i)),
 				Type: aws.String("TXT"),
 			},
-		})
+		}})
 	}
 
-	batchCs := batchChangeSet(cs, testLimit)
+	batchCs, oversized := batchChangeSet(cs, testLimit)
 
 	require.Equal(t, 0, len(batchCs))
+	validateAWSChangeRecords(t, oversized, sortChangesByActionNameType(cs))
 }
 
 func validateEndpoints(t *testing.T, endpoints []*endpoint.Endpoint, expected []*endpoint.Endpoint) {
@@ -746,7 +1357,7 @@ func validateAWSZone(t *testing.T, zone *route53.HostedZone, expected *route53.H
 	assert.Equal(t, aws.StringValue(expected.Name), aws.StringValue(zone.Name))
 }
 
-func validateAWSChangeRecords(t *testing.T, records []*route53.Change, expected []*route53.Change) {
+func validateAWSChangeRecords(t *testing.T, records []*awsChange, expected []*awsChange) {
 	require.Len(t, records, len(expected))
 
 	for i := range records {
@@ -754,7 +1365,7 @@ func validateAWSChangeRecords(t *testing.T, records []*route53.Change, expected
 	}
 }
 
-func validateAWSChangeRecord(t *testing.T, record *route53.Change, expected *route53.Change) {
+func validateAWSChangeRecord(t *testing.T, record *awsChange, expected *awsChange) {
 	assert.Equal(t, aws.StringValue(expected.Action), aws.StringValue(record.Action))
 	assert.Equal(t, aws.StringValue(expected.ResourceRecordSet.Name), aws.StringValue(record.ResourceRecordSet.Name))
 	assert.Equal(t, aws.StringValue(expected.ResourceRecordSet.Type), aws.StringValue(record.ResourceRecordSet.Type))
@@ -906,6 +1517,16 @@ func TestAWSCanonicalHostedZone(t *testing.T) {
 		{"foo.elb.eu-west-2.amazonaws.com", "ZD4D7Y8KGAS4G"},
 		{"foo.elb.eu-west-3.amazonaws.com", "Z1CMS0P5QUZ6D5"},
 		{"foo.elb.sa-east-1.amazonaws.com", "ZTK26PT1VY4CU"},
+		// Dual-stack Load Balancers
+		{"dualstack.foo.eu-central-1.elb.amazonaws.com", "Z215JYRZR1TBD5"},
+		{"dualstack.foo.us-east-1.elb.amazonaws.com", "Z35SXDOTRQ7X7K"},
+		// CloudFront distributions
+		{"d111111abcdef8.cloudfront.net", "Z2FDTNDATAQYW2"},
+		// S3 website endpoints
+		{"bucket.s3-website-us-east-1.amazonaws.com", "Z3AQBSTGFYJSTF"},
+		{"bucket.s3-website.eu-central-1.amazonaws.com", "Z21DNDUVLTQW6Q"},
+		// Global Accelerator
+		{"a1234567890abcdef.awsglobalaccelerator.com", "Z2BJ6XQ5FK7U4H"},
 		// No Load Balancer
 		{"foo.example.org", ""},
 	} {
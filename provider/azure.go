@@ -17,6 +17,7 @@ limitations under the License.
 package provider
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"strings"
@@ -25,7 +26,7 @@ import (
 
 	yaml "gopkg.in/yaml.v2"
 
-	"github.com/Azure/azure-sdk-for-go/arm/dns"
+	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/Azure/go-autorest/autorest/azure"
@@ -52,16 +53,14 @@ type config struct {
 
 // ZonesClient is an interface of dns.ZoneClient that can be stubbed for testing.
 type ZonesClient interface {
-	ListByResourceGroup(resourceGroupName string, top *int32) (result dns.ZoneListResult, err error)
-	ListByResourceGroupNextResults(lastResults dns.ZoneListResult) (result dns.ZoneListResult, err error)
+	ListByResourceGroupComplete(ctx context.Context, resourceGroupName string, top *int32) (result dns.ZoneListResultIterator, err error)
 }
 
 // RecordsClient is an interface of dns.RecordClient that can be stubbed for testing.
 type RecordsClient interface {
-	ListByDNSZone(resourceGroupName string, zoneName string, top *int32) (result dns.RecordSetListResult, err error)
-	ListByDNSZoneNextResults(list dns.RecordSetListResult) (result dns.RecordSetListResult, err error)
-	Delete(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (result autorest.Response, err error)
-	CreateOrUpdate(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (result dns.RecordSet, err error)
+	ListAllByDNSZoneComplete(ctx context.Context, resourceGroupName string, zoneName string, top *int32, recordSetNameSuffix string) (result dns.RecordSetListResultIterator, err error)
+	Delete(ctx context.Context, resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (result autorest.Response, err error)
+	CreateOrUpdate(ctx context.Context, resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (result dns.RecordSet, err error)
 }
 
 // AzureProvider implements the DNS provider for Microsoft's Azure cloud platform.
@@ -78,14 +77,38 @@ type AzureProvider struct {
 //
 // Returns the provider or an error if a provider could not be created.
 func NewAzureProvider(configFile string, domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, resourceGroup string, dryRun bool) (*AzureProvider, error) {
+	cfg, environment, token, err := getAzureCredentials(configFile, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	zonesClient := dns.NewZonesClientWithBaseURI(environment.ResourceManagerEndpoint, cfg.SubscriptionID)
+	zonesClient.Authorizer = autorest.NewBearerAuthorizer(token)
+	recordsClient := dns.NewRecordSetsClientWithBaseURI(environment.ResourceManagerEndpoint, cfg.SubscriptionID)
+	recordsClient.Authorizer = autorest.NewBearerAuthorizer(token)
+
+	provider := &AzureProvider{
+		domainFilter:  domainFilter,
+		zoneIDFilter:  zoneIDFilter,
+		dryRun:        dryRun,
+		resourceGroup: cfg.ResourceGroup,
+		zonesClient:   zonesClient,
+		recordsClient: recordsClient,
+	}
+	return provider, nil
+}
+
+// getAzureCredentials reads the Azure config file and retrieves an access token for the
+// environment of its cloud. A given resource group overrides the one of the config file.
+func getAzureCredentials(configFile, resourceGroup string) (config, azure.Environment, *adal.ServicePrincipalToken, error) {
 	contents, err := ioutil.ReadFile(configFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read Azure config file '%s': %v", configFile, err)
+		return config{}, azure.Environment{}, nil, fmt.Errorf("failed to read Azure config file '%s': %v", configFile, err)
 	}
 	cfg := config{}
 	err = yaml.Unmarshal(contents, &cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read Azure config file '%s': %v", configFile, err)
+		return config{}, azure.Environment{}, nil, fmt.Errorf("failed to read Azure config file '%s': %v", configFile, err)
 	}
 
 	// If a resource group was given, override what was present in the config file
@@ -99,29 +122,15 @@ func NewAzureProvider(configFile string, domainFilter DomainFilter, zoneIDFilter
 	} else {
 		environment, err = azure.EnvironmentFromName(cfg.Cloud)
 		if err != nil {
-			return nil, fmt.Errorf("invalid cloud value '%s': %v", cfg.Cloud, err)
+			return config{}, azure.Environment{}, nil, fmt.Errorf("invalid cloud value '%s': %v", cfg.Cloud, err)
 		}
 	}
 
 	token, err := getAccessToken(cfg, environment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %v", err)
-	}
-
-	zonesClient := dns.NewZonesClientWithBaseURI(environment.ResourceManagerEndpoint, cfg.SubscriptionID)
-	zonesClient.Authorizer = autorest.NewBearerAuthorizer(token)
-	recordsClient := dns.NewRecordSetsClientWithBaseURI(environment.ResourceManagerEndpoint, cfg.SubscriptionID)
-	recordsClient.Authorizer = autorest.NewBearerAuthorizer(token)
-
-	provider := &AzureProvider{
-		domainFilter:  domainFilter,
-		zoneIDFilter:  zoneIDFilter,
-		dryRun:        dryRun,
-		resourceGroup: cfg.ResourceGroup,
-		zonesClient:   zonesClient,
-		recordsClient: recordsClient,
+		return config{}, azure.Environment{}, nil, fmt.Errorf("failed to get token: %v", err)
 	}
-	return provider, nil
+	return cfg, environment, token, nil
 }
 
 // getAccessToken retrieves Azure API access token.
@@ -163,13 +172,14 @@ func getAccessToken(cfg config, environment azure.Environment) (*adal.ServicePri
 //
 // Returns the current records or an error if the operation failed.
 func (p *AzureProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
-	zones, err := p.zones()
+	ctx := context.Background()
+	zones, err := p.zones(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, zone := range zones {
-		err := p.iterateRecords(*zone.Name, func(recordSet dns.RecordSet) bool {
+		err := p.iterateRecords(ctx, *zone.Name, func(recordSet dns.RecordSet) bool {
 			if recordSet.Name == nil || recordSet.Type == nil {
 				log.Error("Skipping invalid record set with nil name or type.")
 				return true
@@ -210,45 +220,41 @@ func (p *AzureProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
 //
 // Returns nil if the operation was successful or an error if the operation failed.
 func (p *AzureProvider) ApplyChanges(changes *plan.Changes) error {
-	zones, err := p.zones()
+	ctx := context.Background()
+	zones, err := p.zones(ctx)
 	if err != nil {
 		return err
 	}
 
-	deleted, updated := p.mapChanges(zones, changes)
-	p.deleteRecords(deleted)
-	p.updateRecords(updated)
+	zoneNames := []string{}
+	for _, z := range zones {
+		if z.Name != nil {
+			zoneNames = append(zoneNames, *z.Name)
+		}
+	}
+
+	deleted, updated := mapAzureChanges(zoneNames, changes)
+	p.deleteRecords(ctx, deleted)
+	p.updateRecords(ctx, updated)
 	return nil
 }
 
-func (p *AzureProvider) zones() ([]dns.Zone, error) {
+func (p *AzureProvider) zones(ctx context.Context) ([]dns.Zone, error) {
 	log.Debug("Retrieving Azure DNS zones.")
 
 	var zones []dns.Zone
-	list, err := p.zonesClient.ListByResourceGroup(p.resourceGroup, nil)
+	iterator, err := p.zonesClient.ListByResourceGroupComplete(ctx, p.resourceGroup, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for list.Value != nil && len(*list.Value) > 0 {
-		for _, zone := range *list.Value {
-			if zone.Name == nil {
-				continue
-			}
-
-			if !p.domainFilter.Match(*zone.Name) {
-				continue
-			}
-
-			if !p.zoneIDFilter.Match(*zone.ID) {
-				continue
-			}
-
+	for iterator.NotDone() {
+		zone := iterator.Value()
+		if zone.Name != nil && p.domainFilter.Match(*zone.Name) && p.zoneIDFilter.Match(*zone.ID) {
 			zones = append(zones, zone)
 		}
 
-		list, err = p.zonesClient.ListByResourceGroupNextResults(list)
-		if err != nil {
+		if err := iterator.NextWithContext(ctx); err != nil {
 			return nil, err
 		}
 	}
@@ -256,23 +262,20 @@ func (p *AzureProvider) zones() ([]dns.Zone, error) {
 	return zones, nil
 }
 
-func (p *AzureProvider) iterateRecords(zoneName string, callback func(dns.RecordSet) bool) error {
+func (p *AzureProvider) iterateRecords(ctx context.Context, zoneName string, callback func(dns.RecordSet) bool) error {
 	log.Debugf("Retrieving Azure DNS records for zone '%s'.", zoneName)
 
-	list, err := p.recordsClient.ListByDNSZone(p.resourceGroup, zoneName, nil)
+	iterator, err := p.recordsClient.ListAllByDNSZoneComplete(ctx, p.resourceGroup, zoneName, nil, "")
 	if err != nil {
 		return err
 	}
 
-	for list.Value != nil && len(*list.Value) > 0 {
-		for _, recordSet := range *list.Value {
-			if !callback(recordSet) {
-				return nil
-			}
+	for iterator.NotDone() {
+		if !callback(iterator.Value()) {
+			return nil
 		}
 
-		list, err = p.recordsClient.ListByDNSZoneNextResults(list)
-		if err != nil {
+		if err := iterator.NextWithContext(ctx); err != nil {
 			return err
 		}
 	}
@@ -281,15 +284,15 @@ func (p *AzureProvider) iterateRecords(zoneName string, callback func(dns.Record
 
 type azureChangeMap map[string][]*endpoint.Endpoint
 
-func (p *AzureProvider) mapChanges(zones []dns.Zone, changes *plan.Changes) (azureChangeMap, azureChangeMap) {
+// mapAzureChanges maps the records to be deleted and the ones to be created or updated to
+// the zones they belong to. Records of other zones are ignored.
+func mapAzureChanges(zones []string, changes *plan.Changes) (azureChangeMap, azureChangeMap) {
 	ignored := map[string]bool{}
 	deleted := azureChangeMap{}
 	updated := azureChangeMap{}
 	zoneNameIDMapper := zoneIDName{}
 	for _, z := range zones {
-		if z.Name != nil {
-			zoneNameIDMapper.Add(*z.Name, *z.Name)
-		}
+		zoneNameIDMapper.Add(z, z)
 	}
 	mapChange := func(changeMap azureChangeMap, change *endpoint.Endpoint) {
 		zone, _ := zoneNameIDMapper.FindZone(change.DNSName)
@@ -322,16 +325,16 @@ func (p *AzureProvider) mapChanges(zones []dns.Zone, changes *plan.Changes) (azu
 	return deleted, updated
 }
 
-func (p *AzureProvider) deleteRecords(deleted azureChangeMap) {
+func (p *AzureProvider) deleteRecords(ctx context.Context, deleted azureChangeMap) {
 	// Delete records first
 	for zone, endpoints := range deleted {
 		for _, endpoint := range endpoints {
-			name := p.recordSetNameForZone(zone, endpoint)
+			name := recordSetNameForZone(zone, endpoint)
 			if p.dryRun {
 				log.Infof("Would delete %s record named '%s' for Azure DNS zone '%s'.", endpoint.RecordType, name, zone)
 			} else {
 				log.Infof("Deleting %s record named '%s' for Azure DNS zone '%s'.", endpoint.RecordType, name, zone)
-				if _, err := p.recordsClient.Delete(p.resourceGroup, zone, name, dns.RecordType(endpoint.RecordType), ""); err != nil {
+				if _, err := p.recordsClient.Delete(ctx, p.resourceGroup, zone, name, dns.RecordType(endpoint.RecordType), ""); err != nil {
 					log.Errorf(
 						"Failed to delete %s record named '%s' for Azure DNS zone '%s': %v",
 						endpoint.RecordType,
@@ -345,10 +348,10 @@ func (p *AzureProvider) deleteRecords(deleted azureChangeMap) {
 	}
 }
 
-func (p *AzureProvider) updateRecords(updated azureChangeMap) {
+func (p *AzureProvider) updateRecords(ctx context.Context, updated azureChangeMap) {
 	for zone, endpoints := range updated {
 		for _, endpoint := range endpoints {
-			name := p.recordSetNameForZone(zone, endpoint)
+			name := recordSetNameForZone(zone, endpoint)
 			if p.dryRun {
 				log.Infof(
 					"Would update %s record named '%s' to '%s' for Azure DNS zone '%s'.",
@@ -371,6 +374,7 @@ func (p *AzureProvider) updateRecords(updated azureChangeMap) {
 			recordSet, err := p.newRecordSet(endpoint)
 			if err == nil {
 				_, err = p.recordsClient.CreateOrUpdate(
+					ctx,
 					p.resourceGroup,
 					zone,
 					name,
@@ -394,7 +398,7 @@ func (p *AzureProvider) updateRecords(updated azureChangeMap) {
 	}
 }
 
-func (p *AzureProvider) recordSetNameForZone(zone string, endpoint *endpoint.Endpoint) string {
+func recordSetNameForZone(zone string, endpoint *endpoint.Endpoint) string {
 	// Remove the zone from the record set
 	name := endpoint.DNSName
 	name = name[:len(name)-len(zone)]
@@ -424,6 +428,17 @@ func (p *AzureProvider) newRecordSet(endpoint *endpoint.Endpoint) (dns.RecordSet
 				},
 			},
 		}, nil
+	case dns.AAAA:
+		return dns.RecordSet{
+			RecordSetProperties: &dns.RecordSetProperties{
+				TTL: to.Int64Ptr(ttl),
+				AaaaRecords: &[]dns.AaaaRecord{
+					{
+						Ipv6Address: to.StringPtr(endpoint.Targets[0]),
+					},
+				},
+			},
+		}, nil
 	case dns.CNAME:
 		return dns.RecordSet{
 			RecordSetProperties: &dns.RecordSetProperties{
@@ -471,6 +486,12 @@ func extractAzureTarget(recordSet *dns.RecordSet) string {
 		return *(*aRecords)[0].Ipv4Address
 	}
 
+	// Check for AAAA records
+	aaaaRecords := properties.AaaaRecords
+	if aaaaRecords != nil && len(*aaaaRecords) > 0 && (*aaaaRecords)[0].Ipv6Address != nil {
+		return *(*aaaaRecords)[0].Ipv6Address
+	}
+
 	// Check for CNAME records
 	cnameRecord := properties.CnameRecord
 	if cnameRecord != nil && cnameRecord.Cname != nil {
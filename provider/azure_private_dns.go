@@ -0,0 +1,383 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Azure/azure-sdk-for-go/services/privatedns/mgmt/2018-09-01/privatedns"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+const (
+	azurePrivateDNSRecordType = "Microsoft.Network/privateDnsZones/"
+)
+
+// PrivateZonesClient is an interface of privatedns.PrivateZonesClient that can be stubbed for testing.
+type PrivateZonesClient interface {
+	ListByResourceGroupComplete(ctx context.Context, resourceGroupName string, top *int32) (result privatedns.PrivateZoneListResultIterator, err error)
+}
+
+// PrivateRecordSetsClient is an interface of privatedns.RecordSetsClient that can be stubbed for testing.
+type PrivateRecordSetsClient interface {
+	ListComplete(ctx context.Context, resourceGroupName string, privateZoneName string, top *int32, recordsetnamesuffix string) (result privatedns.RecordSetListResultIterator, err error)
+	Delete(ctx context.Context, resourceGroupName string, privateZoneName string, recordType privatedns.RecordType, relativeRecordSetName string, ifMatch string) (result autorest.Response, err error)
+	CreateOrUpdate(ctx context.Context, resourceGroupName string, privateZoneName string, recordType privatedns.RecordType, relativeRecordSetName string, parameters privatedns.RecordSet, ifMatch string, ifNoneMatch string) (result privatedns.RecordSet, err error)
+}
+
+// AzurePrivateDNSProvider implements the DNS provider for Azure Private DNS zones, which
+// resolve within the virtual networks linked to them.
+type AzurePrivateDNSProvider struct {
+	domainFilter  DomainFilter
+	zoneIDFilter  ZoneIDFilter
+	dryRun        bool
+	resourceGroup string
+	zonesClient   PrivateZonesClient
+	recordsClient PrivateRecordSetsClient
+}
+
+// NewAzurePrivateDNSProvider creates a new Azure Private DNS provider. It is configured
+// with the same config file as the Azure provider.
+//
+// Returns the provider or an error if a provider could not be created.
+func NewAzurePrivateDNSProvider(configFile string, domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, resourceGroup string, dryRun bool) (*AzurePrivateDNSProvider, error) {
+	cfg, environment, token, err := getAzureCredentials(configFile, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	zonesClient := privatedns.NewPrivateZonesClientWithBaseURI(environment.ResourceManagerEndpoint, cfg.SubscriptionID)
+	zonesClient.Authorizer = autorest.NewBearerAuthorizer(token)
+	recordsClient := privatedns.NewRecordSetsClientWithBaseURI(environment.ResourceManagerEndpoint, cfg.SubscriptionID)
+	recordsClient.Authorizer = autorest.NewBearerAuthorizer(token)
+
+	provider := &AzurePrivateDNSProvider{
+		domainFilter:  domainFilter,
+		zoneIDFilter:  zoneIDFilter,
+		dryRun:        dryRun,
+		resourceGroup: cfg.ResourceGroup,
+		zonesClient:   zonesClient,
+		recordsClient: recordsClient,
+	}
+	return provider, nil
+}
+
+// Records gets the current records.
+//
+// Returns the current records or an error if the operation failed.
+func (p *AzurePrivateDNSProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
+	ctx := context.Background()
+	zones, err := p.zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zone := range zones {
+		err := p.iterateRecords(ctx, *zone.Name, func(recordSet privatedns.RecordSet) bool {
+			if recordSet.Name == nil || recordSet.Type == nil {
+				log.Error("Skipping invalid record set with nil name or type.")
+				return true
+			}
+			recordType := strings.TrimPrefix(*recordSet.Type, azurePrivateDNSRecordType)
+			if !supportedRecordType(recordType) {
+				return true
+			}
+			name := formatAzureDNSName(*recordSet.Name, *zone.Name)
+			targets := extractAzurePrivateDNSTargets(&recordSet)
+			if len(targets) == 0 {
+				log.Errorf("Failed to extract targets for '%s' with type '%s'.", name, recordType)
+				return true
+			}
+			var ttl endpoint.TTL
+			if recordSet.TTL != nil {
+				ttl = endpoint.TTL(*recordSet.TTL)
+			}
+
+			ep := endpoint.NewEndpointWithTTL(name, recordType, ttl, targets...)
+			log.Debugf(
+				"Found %s record for '%s' with targets '%s'.",
+				ep.RecordType,
+				ep.DNSName,
+				ep.Targets,
+			)
+			endpoints = append(endpoints, ep)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return endpoints, nil
+}
+
+// ApplyChanges applies the given changes.
+//
+// Returns nil if the operation was successful, a FailedChangesError holding the records
+// which couldn't be changed or an error if the operation failed.
+func (p *AzurePrivateDNSProvider) ApplyChanges(changes *plan.Changes) error {
+	ctx := context.Background()
+	zones, err := p.zones(ctx)
+	if err != nil {
+		return err
+	}
+
+	zoneNames := []string{}
+	for _, z := range zones {
+		zoneNames = append(zoneNames, *z.Name)
+	}
+
+	deleted, updated := mapAzureChanges(zoneNames, changes)
+	failed := p.deleteRecords(ctx, deleted)
+	failed = append(failed, p.updateRecords(ctx, updated)...)
+	if len(failed) > 0 {
+		return &FailedChangesError{Endpoints: failed}
+	}
+	return nil
+}
+
+func (p *AzurePrivateDNSProvider) zones(ctx context.Context) ([]privatedns.PrivateZone, error) {
+	log.Debug("Retrieving Azure Private DNS zones.")
+
+	var zones []privatedns.PrivateZone
+	iterator, err := p.zonesClient.ListByResourceGroupComplete(ctx, p.resourceGroup, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for iterator.NotDone() {
+		zone := iterator.Value()
+		if zone.Name != nil && zone.ID != nil && p.domainFilter.Match(*zone.Name) && p.zoneIDFilter.Match(*zone.ID) {
+			zones = append(zones, zone)
+		}
+
+		if err := iterator.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	log.Debugf("Found %d Azure Private DNS zone(s).", len(zones))
+	return zones, nil
+}
+
+func (p *AzurePrivateDNSProvider) iterateRecords(ctx context.Context, zoneName string, callback func(privatedns.RecordSet) bool) error {
+	log.Debugf("Retrieving Azure Private DNS records for zone '%s'.", zoneName)
+
+	iterator, err := p.recordsClient.ListComplete(ctx, p.resourceGroup, zoneName, nil, "")
+	if err != nil {
+		return err
+	}
+
+	for iterator.NotDone() {
+		if !callback(iterator.Value()) {
+			return nil
+		}
+
+		if err := iterator.NextWithContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRecords deletes the record sets of the given records and returns the ones that
+// couldn't be deleted.
+func (p *AzurePrivateDNSProvider) deleteRecords(ctx context.Context, deleted azureChangeMap) (failed []*endpoint.Endpoint) {
+	for zone, endpoints := range deleted {
+		for _, endpoint := range endpoints {
+			name := recordSetNameForZone(zone, endpoint)
+			if p.dryRun {
+				log.Infof("Would delete %s record named '%s' for Azure Private DNS zone '%s'.", endpoint.RecordType, name, zone)
+				continue
+			}
+
+			log.Infof("Deleting %s record named '%s' for Azure Private DNS zone '%s'.", endpoint.RecordType, name, zone)
+			if _, err := p.recordsClient.Delete(ctx, p.resourceGroup, zone, privatedns.RecordType(endpoint.RecordType), name, ""); err != nil {
+				log.Errorf(
+					"Failed to delete %s record named '%s' for Azure Private DNS zone '%s': %v",
+					endpoint.RecordType,
+					name,
+					zone,
+					err,
+				)
+				failed = append(failed, endpoint)
+			}
+		}
+	}
+	return failed
+}
+
+// updateRecords creates or replaces the record sets of the given records and returns the
+// ones that couldn't be updated.
+func (p *AzurePrivateDNSProvider) updateRecords(ctx context.Context, updated azureChangeMap) (failed []*endpoint.Endpoint) {
+	for zone, endpoints := range updated {
+		for _, endpoint := range endpoints {
+			name := recordSetNameForZone(zone, endpoint)
+			if p.dryRun {
+				log.Infof(
+					"Would update %s record named '%s' to '%s' for Azure Private DNS zone '%s'.",
+					endpoint.RecordType,
+					name,
+					endpoint.Targets,
+					zone,
+				)
+				continue
+			}
+
+			log.Infof(
+				"Updating %s record named '%s' to '%s' for Azure Private DNS zone '%s'.",
+				endpoint.RecordType,
+				name,
+				endpoint.Targets,
+				zone,
+			)
+
+			recordSet, err := newAzurePrivateDNSRecordSet(endpoint)
+			if err == nil {
+				_, err = p.recordsClient.CreateOrUpdate(
+					ctx,
+					p.resourceGroup,
+					zone,
+					privatedns.RecordType(endpoint.RecordType),
+					name,
+					recordSet,
+					"",
+					"",
+				)
+			}
+			if err != nil {
+				log.Errorf(
+					"Failed to update %s record named '%s' to '%s' for Azure Private DNS zone '%s': %v",
+					endpoint.RecordType,
+					name,
+					endpoint.Targets,
+					zone,
+					err,
+				)
+				failed = append(failed, endpoint)
+			}
+		}
+	}
+	return failed
+}
+
+func newAzurePrivateDNSRecordSet(endpoint *endpoint.Endpoint) (privatedns.RecordSet, error) {
+	var ttl int64 = azureRecordTTL
+	if endpoint.RecordTTL.IsConfigured() {
+		ttl = int64(endpoint.RecordTTL)
+	}
+	properties := &privatedns.RecordSetProperties{
+		TTL: to.Int64Ptr(ttl),
+	}
+	switch privatedns.RecordType(endpoint.RecordType) {
+	case privatedns.A:
+		records := []privatedns.ARecord{}
+		for _, target := range endpoint.Targets {
+			records = append(records, privatedns.ARecord{Ipv4Address: to.StringPtr(target)})
+		}
+		properties.ARecords = &records
+	case privatedns.AAAA:
+		records := []privatedns.AaaaRecord{}
+		for _, target := range endpoint.Targets {
+			records = append(records, privatedns.AaaaRecord{Ipv6Address: to.StringPtr(target)})
+		}
+		properties.AaaaRecords = &records
+	case privatedns.CNAME:
+		properties.CnameRecord = &privatedns.CnameRecord{
+			Cname: to.StringPtr(endpoint.Targets[0]),
+		}
+	case privatedns.SRV:
+		records := []privatedns.SrvRecord{}
+		for _, target := range endpoint.Targets {
+			var priority, weight, port int32
+			var host string
+			if _, err := fmt.Sscanf(target, "%d %d %d %s", &priority, &weight, &port, &host); err != nil {
+				return privatedns.RecordSet{}, fmt.Errorf("invalid SRV target '%s': %v", target, err)
+			}
+			records = append(records, privatedns.SrvRecord{
+				Priority: to.Int32Ptr(priority),
+				Weight:   to.Int32Ptr(weight),
+				Port:     to.Int32Ptr(port),
+				Target:   to.StringPtr(host),
+			})
+		}
+		properties.SrvRecords = &records
+	case privatedns.TXT:
+		records := []privatedns.TxtRecord{}
+		for _, target := range endpoint.Targets {
+			records = append(records, privatedns.TxtRecord{Value: &[]string{target}})
+		}
+		properties.TxtRecords = &records
+	default:
+		return privatedns.RecordSet{}, fmt.Errorf("unsupported record type '%s'", endpoint.RecordType)
+	}
+	return privatedns.RecordSet{RecordSetProperties: properties}, nil
+}
+
+// Helper function (shared with test code)
+func extractAzurePrivateDNSTargets(recordSet *privatedns.RecordSet) []string {
+	properties := recordSet.RecordSetProperties
+	if properties == nil {
+		return nil
+	}
+
+	var targets []string
+	if properties.ARecords != nil {
+		for _, record := range *properties.ARecords {
+			if record.Ipv4Address != nil {
+				targets = append(targets, *record.Ipv4Address)
+			}
+		}
+	}
+
+	if properties.AaaaRecords != nil {
+		for _, record := range *properties.AaaaRecords {
+			if record.Ipv6Address != nil {
+				targets = append(targets, *record.Ipv6Address)
+			}
+		}
+	}
+
+	if properties.CnameRecord != nil && properties.CnameRecord.Cname != nil {
+		targets = append(targets, *properties.CnameRecord.Cname)
+	}
+
+	if properties.SrvRecords != nil {
+		for _, record := range *properties.SrvRecords {
+			if record.Priority == nil || record.Weight == nil || record.Port == nil || record.Target == nil {
+				continue
+			}
+			targets = append(targets, fmt.Sprintf("%d %d %d %s", *record.Priority, *record.Weight, *record.Port, *record.Target))
+		}
+	}
+
+	// Each TXT record of the set becomes a target, its strings are joined
+	if properties.TxtRecords != nil {
+		for _, record := range *properties.TxtRecords {
+			if record.Value != nil && len(*record.Value) > 0 {
+				targets = append(targets, strings.Join(*record.Value, ""))
+			}
+		}
+	}
+	return targets
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/privatedns/mgmt/2018-09-01/privatedns"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+type mockPrivateZonesClient struct {
+	mockZoneListResult *privatedns.PrivateZoneListResult
+}
+
+type mockPrivateRecordSetsClient struct {
+	mockRecordSet    *[]privatedns.RecordSet
+	deletedEndpoints []*endpoint.Endpoint
+	updatedEndpoints []*endpoint.Endpoint
+}
+
+func createMockPrivateZone(zone string, id string) privatedns.PrivateZone {
+	return privatedns.PrivateZone{
+		ID:   to.StringPtr(id),
+		Name: to.StringPtr(zone),
+	}
+}
+
+func (client *mockPrivateZonesClient) ListByResourceGroupComplete(ctx context.Context, resourceGroupName string, top *int32) (privatedns.PrivateZoneListResultIterator, error) {
+	page := privatedns.NewPrivateZoneListResultPage(func(_ context.Context, lastResults privatedns.PrivateZoneListResult) (privatedns.PrivateZoneListResult, error) {
+		if lastResults.Value == nil {
+			return *client.mockZoneListResult, nil
+		}
+		return privatedns.PrivateZoneListResult{}, nil
+	})
+	// like the Azure SDK, the iterator starts at the first page
+	err := page.NextWithContext(ctx)
+	return privatedns.NewPrivateZoneListResultIterator(page), err
+}
+
+func createMockPrivateRecordSet(name, recordType string, ttl int64, targets ...string) privatedns.RecordSet {
+	properties := &privatedns.RecordSetProperties{
+		TTL: to.Int64Ptr(ttl),
+	}
+	switch recordType {
+	case endpoint.RecordTypeA:
+		records := []privatedns.ARecord{}
+		for _, target := range targets {
+			records = append(records, privatedns.ARecord{Ipv4Address: to.StringPtr(target)})
+		}
+		properties.ARecords = &records
+	case endpoint.RecordTypeAAAA:
+		records := []privatedns.AaaaRecord{}
+		for _, target := range targets {
+			records = append(records, privatedns.AaaaRecord{Ipv6Address: to.StringPtr(target)})
+		}
+		properties.AaaaRecords = &records
+	case endpoint.RecordTypeCNAME:
+		properties.CnameRecord = &privatedns.CnameRecord{Cname: to.StringPtr(targets[0])}
+	case endpoint.RecordTypeSRV:
+		records := []privatedns.SrvRecord{}
+		for range targets {
+			records = append(records, privatedns.SrvRecord{
+				Priority: to.Int32Ptr(10),
+				Weight:   to.Int32Ptr(5),
+				Port:     to.Int32Ptr(5060),
+				Target:   to.StringPtr("sip.example.com"),
+			})
+		}
+		properties.SrvRecords = &records
+	case endpoint.RecordTypeTXT:
+		records := []privatedns.TxtRecord{}
+		for _, target := range targets {
+			records = append(records, privatedns.TxtRecord{Value: &[]string{target}})
+		}
+		properties.TxtRecords = &records
+	}
+	return privatedns.RecordSet{
+		Name:                to.StringPtr(name),
+		Type:                to.StringPtr("Microsoft.Network/privateDnsZones/" + recordType),
+		RecordSetProperties: properties,
+	}
+}
+
+func (client *mockPrivateRecordSetsClient) ListComplete(ctx context.Context, resourceGroupName string, privateZoneName string, top *int32, recordsetnamesuffix string) (privatedns.RecordSetListResultIterator, error) {
+	page := privatedns.NewRecordSetListResultPage(func(_ context.Context, lastResults privatedns.RecordSetListResult) (privatedns.RecordSetListResult, error) {
+		if lastResults.Value == nil {
+			return privatedns.RecordSetListResult{Value: client.mockRecordSet}, nil
+		}
+		return privatedns.RecordSetListResult{}, nil
+	})
+	err := page.NextWithContext(ctx)
+	return privatedns.NewRecordSetListResultIterator(page), err
+}
+
+func (client *mockPrivateRecordSetsClient) Delete(ctx context.Context, resourceGroupName string, privateZoneName string, recordType privatedns.RecordType, relativeRecordSetName string, ifMatch string) (autorest.Response, error) {
+	if relativeRecordSetName == "locked" {
+		return autorest.Response{}, fmt.Errorf("record set '%s' is locked", relativeRecordSetName)
+	}
+	client.deletedEndpoints = append(
+		client.deletedEndpoints,
+		endpoint.NewEndpoint(
+			formatAzureDNSName(relativeRecordSetName, privateZoneName),
+			string(recordType),
+			"",
+		),
+	)
+	return autorest.Response{}, nil
+}
+
+func (client *mockPrivateRecordSetsClient) CreateOrUpdate(ctx context.Context, resourceGroupName string, privateZoneName string, recordType privatedns.RecordType, relativeRecordSetName string, parameters privatedns.RecordSet, ifMatch string, ifNoneMatch string) (privatedns.RecordSet, error) {
+	var ttl endpoint.TTL
+	if parameters.TTL != nil {
+		ttl = endpoint.TTL(*parameters.TTL)
+	}
+	client.updatedEndpoints = append(
+		client.updatedEndpoints,
+		endpoint.NewEndpointWithTTL(
+			formatAzureDNSName(relativeRecordSetName, privateZoneName),
+			string(recordType),
+			ttl,
+			extractAzurePrivateDNSTargets(&parameters)...,
+		),
+	)
+	return parameters, nil
+}
+
+func newAzurePrivateDNSProvider(domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, dryRun bool, resourceGroup string, zonesClient PrivateZonesClient, recordsClient PrivateRecordSetsClient) *AzurePrivateDNSProvider {
+	return &AzurePrivateDNSProvider{
+		domainFilter:  domainFilter,
+		zoneIDFilter:  zoneIDFilter,
+		dryRun:        dryRun,
+		resourceGroup: resourceGroup,
+		zonesClient:   zonesClient,
+		recordsClient: recordsClient,
+	}
+}
+
+func TestAzurePrivateDNSRecord(t *testing.T) {
+	zonesClient := mockPrivateZonesClient{
+		mockZoneListResult: &privatedns.PrivateZoneListResult{
+			Value: &[]privatedns.PrivateZone{
+				createMockPrivateZone("example.com", "/privateDnsZones/example.com"),
+				createMockPrivateZone("other.com", "/privateDnsZones/other.com"),
+			},
+		},
+	}
+
+	recordsClient := mockPrivateRecordSetsClient{
+		mockRecordSet: &[]privatedns.RecordSet{
+			createMockPrivateRecordSet("@", "SOA", 3600),
+			createMockPrivateRecordSet("@", endpoint.RecordTypeA, 0, "123.123.123.122"),
+			createMockPrivateRecordSet("@", endpoint.RecordTypeTXT, 0, "heritage=external-dns,external-dns/owner=default"),
+			createMockPrivateRecordSet("nginx", endpoint.RecordTypeA, 3600, "123.123.123.123", "123.123.123.124"),
+			createMockPrivateRecordSet("nginx", endpoint.RecordTypeTXT, recordTTL, "heritage=external-dns,external-dns/owner=default"),
+			createMockPrivateRecordSet("nginx", endpoint.RecordTypeAAAA, 3600, "2001:db8::1"),
+			createMockPrivateRecordSet("_sip._udp", endpoint.RecordTypeSRV, 60, "10 5 5060 sip.example.com"),
+			createMockPrivateRecordSet("hack", endpoint.RecordTypeCNAME, 10, "hack.azurewebsites.net"),
+			createMockPrivateRecordSet("empty", endpoint.RecordTypeA, 10),
+		},
+	}
+
+	provider := newAzurePrivateDNSProvider(NewDomainFilter([]string{"example.com"}), NewZoneIDFilter([]string{""}), true, "k8s", &zonesClient, &recordsClient)
+
+	actual, err := provider.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeA, "123.123.123.122"),
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default"),
+		endpoint.NewEndpointWithTTL("nginx.example.com", endpoint.RecordTypeA, 3600, "123.123.123.123", "123.123.123.124"),
+		endpoint.NewEndpointWithTTL("nginx.example.com", endpoint.RecordTypeTXT, recordTTL, "heritage=external-dns,external-dns/owner=default"),
+		endpoint.NewEndpointWithTTL("nginx.example.com", endpoint.RecordTypeAAAA, 3600, "2001:db8::1"),
+		endpoint.NewEndpointWithTTL("_sip._udp.example.com", endpoint.RecordTypeSRV, 60, "10 5 5060 sip.example.com"),
+		endpoint.NewEndpointWithTTL("hack.example.com", endpoint.RecordTypeCNAME, 10, "hack.azurewebsites.net"),
+	}
+
+	validateAzureEndpoints(t, actual, expected)
+}
+
+func TestAzurePrivateDNSZoneIDFilter(t *testing.T) {
+	zonesClient := mockPrivateZonesClient{
+		mockZoneListResult: &privatedns.PrivateZoneListResult{
+			Value: &[]privatedns.PrivateZone{
+				createMockPrivateZone("example.com", "/resourceGroups/k8s/providers/Microsoft.Network/privateDnsZones/example.com"),
+				createMockPrivateZone("other.com", "/resourceGroups/k8s/providers/Microsoft.Network/privateDnsZones/other.com"),
+			},
+		},
+	}
+
+	provider := newAzurePrivateDNSProvider(NewDomainFilter([]string{}), NewZoneIDFilter([]string{"/resourceGroups/k8s/providers/Microsoft.Network/privateDnsZones/other.com"}), true, "k8s", &zonesClient, &mockPrivateRecordSetsClient{})
+
+	zones, err := provider.zones(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, zones, 1)
+	assert.Equal(t, "other.com", *zones[0].Name)
+}
+
+func TestAzurePrivateDNSApplyChanges(t *testing.T) {
+	recordsClient := mockPrivateRecordSetsClient{}
+
+	err := testAzurePrivateDNSApplyChangesInternal(t, false, &recordsClient)
+
+	// the records which couldn't be changed are reported, the others are applied
+	failedErr, ok := err.(*FailedChangesError)
+	require.True(t, ok, "expected a FailedChangesError, got %v", err)
+	validateAzureEndpoints(t, failedErr.Endpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("locked.example.com", endpoint.RecordTypeA, "111.222.111.223"),
+		endpoint.NewEndpoint("invalid.example.com", endpoint.RecordTypeSRV, "sip.example.com"),
+	})
+
+	validateAzureEndpoints(t, recordsClient.deletedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, ""),
+		endpoint.NewEndpoint("oldcname.example.com", endpoint.RecordTypeCNAME, ""),
+		endpoint.NewEndpoint("deleted.example.com", endpoint.RecordTypeA, ""),
+		endpoint.NewEndpoint("deletedcname.example.com", endpoint.RecordTypeCNAME, ""),
+	})
+
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("example.com", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4"),
+		endpoint.NewEndpointWithTTL("example.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
+		endpoint.NewEndpointWithTTL("foo.example.com", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4", "1.2.3.5"),
+		endpoint.NewEndpointWithTTL("foo.example.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
+		endpoint.NewEndpointWithTTL("foo.example.com", endpoint.RecordTypeAAAA, endpoint.TTL(recordTTL), "2001:db8::1"),
+		endpoint.NewEndpointWithTTL("bar.example.com", endpoint.RecordTypeCNAME, endpoint.TTL(recordTTL), "other.com"),
+		endpoint.NewEndpointWithTTL("bar.example.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
+		endpoint.NewEndpointWithTTL("_sip._udp.example.com", endpoint.RecordTypeSRV, endpoint.TTL(recordTTL), "10 5 5060 sip.example.com"),
+		endpoint.NewEndpointWithTTL("other.com", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "5.6.7.8"),
+		endpoint.NewEndpointWithTTL("other.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
+		endpoint.NewEndpointWithTTL("new.example.com", endpoint.RecordTypeA, 3600, "111.222.111.222"),
+		endpoint.NewEndpointWithTTL("newcname.example.com", endpoint.RecordTypeCNAME, 10, "other.com"),
+	})
+}
+
+func TestAzurePrivateDNSApplyChangesDryRun(t *testing.T) {
+	recordsClient := mockPrivateRecordSetsClient{}
+
+	require.NoError(t, testAzurePrivateDNSApplyChangesInternal(t, true, &recordsClient))
+
+	validateAzureEndpoints(t, recordsClient.deletedEndpoints, []*endpoint.Endpoint{})
+
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{})
+}
+
+func testAzurePrivateDNSApplyChangesInternal(t *testing.T, dryRun bool, client PrivateRecordSetsClient) error {
+	provider := newAzurePrivateDNSProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		dryRun,
+		"group",
+		&mockPrivateZonesClient{
+			mockZoneListResult: &privatedns.PrivateZoneListResult{
+				Value: &[]privatedns.PrivateZone{
+					createMockPrivateZone("example.com", "/privateDnsZones/example.com"),
+					createMockPrivateZone("other.com", "/privateDnsZones/other.com"),
+				},
+			},
+		},
+		client,
+	)
+
+	createRecords := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "tag"),
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "1.2.3.4", "1.2.3.5"),
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeTXT, "tag"),
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeAAAA, "2001:db8::1"),
+		endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeCNAME, "other.com"),
+		endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeTXT, "tag"),
+		endpoint.NewEndpoint("_sip._udp.example.com", endpoint.RecordTypeSRV, "10 5 5060 sip.example.com"),
+		endpoint.NewEndpoint("invalid.example.com", endpoint.RecordTypeSRV, "sip.example.com"),
+		endpoint.NewEndpoint("other.com", endpoint.RecordTypeA, "5.6.7.8"),
+		endpoint.NewEndpoint("other.com", endpoint.RecordTypeTXT, "tag"),
+		endpoint.NewEndpoint("nope.com", endpoint.RecordTypeA, "4.4.4.4"),
+		endpoint.NewEndpoint("nope.com", endpoint.RecordTypeTXT, "tag"),
+	}
+
+	currentRecords := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.example.com", endpoint.RecordTypeA, "121.212.121.212"),
+		endpoint.NewEndpoint("oldcname.example.com", endpoint.RecordTypeCNAME, "other.com"),
+		endpoint.NewEndpoint("old.nope.com", endpoint.RecordTypeA, "121.212.121.212"),
+	}
+	updatedRecords := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("new.example.com", endpoint.RecordTypeA, 3600, "111.222.111.222"),
+		endpoint.NewEndpointWithTTL("newcname.example.com", endpoint.RecordTypeCNAME, 10, "other.com"),
+		endpoint.NewEndpoint("new.nope.com", endpoint.RecordTypeA, "222.111.222.111"),
+	}
+
+	deleteRecords := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("deleted.example.com", endpoint.RecordTypeA, "111.222.111.222"),
+		endpoint.NewEndpoint("deletedcname.example.com", endpoint.RecordTypeCNAME, "other.com"),
+		endpoint.NewEndpoint("locked.example.com", endpoint.RecordTypeA, "111.222.111.223"),
+		endpoint.NewEndpoint("deleted.nope.com", endpoint.RecordTypeA, "222.111.222.111"),
+	}
+
+	changes := &plan.Changes{
+		Create:    createRecords,
+		UpdateNew: updatedRecords,
+		UpdateOld: currentRecords,
+		Delete:    deleteRecords,
+	}
+
+	return provider.ApplyChanges(changes)
+}
@@ -17,9 +17,10 @@ limitations under the License.
 package provider
 
 import (
+	"context"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/arm/dns"
+	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
@@ -47,14 +48,18 @@ func createMockZone(zone string, id string) dns.Zone {
 	}
 }
 
-func (client *mockZonesClient) ListByResourceGroup(resourceGroupName string, top *int32) (dns.ZoneListResult, error) {
+func (client *mockZonesClient) ListByResourceGroupComplete(ctx context.Context, resourceGroupName string, top *int32) (dns.ZoneListResultIterator, error) {
 	// Don't bother filtering by resource group or implementing paging since that's the responsibility
 	// of the Azure DNS service
-	return *client.mockZoneListResult, nil
-}
-
-func (client *mockZonesClient) ListByResourceGroupNextResults(lastResults dns.ZoneListResult) (dns.ZoneListResult, error) {
-	return dns.ZoneListResult{}, nil
+	page := dns.NewZoneListResultPage(func(_ context.Context, lastResults dns.ZoneListResult) (dns.ZoneListResult, error) {
+		if lastResults.Value == nil {
+			return *client.mockZoneListResult, nil
+		}
+		return dns.ZoneListResult{}, nil
+	})
+	// like the Azure SDK, the iterator starts at the first page
+	err := page.NextWithContext(ctx)
+	return dns.NewZoneListResultIterator(page), err
 }
 
 func aRecordSetPropertiesGetter(value string, ttl int64) *dns.RecordSetProperties {
@@ -68,6 +73,17 @@ func aRecordSetPropertiesGetter(value string, ttl int64) *dns.RecordSetPropertie
 	}
 }
 
+func aaaaRecordSetPropertiesGetter(value string, ttl int64) *dns.RecordSetProperties {
+	return &dns.RecordSetProperties{
+		TTL: to.Int64Ptr(ttl),
+		AaaaRecords: &[]dns.AaaaRecord{
+			{
+				Ipv6Address: to.StringPtr(value),
+			},
+		},
+	}
+}
+
 func cNameRecordSetPropertiesGetter(value string, ttl int64) *dns.RecordSetProperties {
 	return &dns.RecordSetProperties{
 		TTL: to.Int64Ptr(ttl),
@@ -102,6 +118,8 @@ func createMockRecordSetWithTTL(name, recordType, value string, ttl int64) dns.R
 	switch recordType {
 	case endpoint.RecordTypeA:
 		getterFunc = aRecordSetPropertiesGetter
+	case endpoint.RecordTypeAAAA:
+		getterFunc = aaaaRecordSetPropertiesGetter
 	case endpoint.RecordTypeCNAME:
 		getterFunc = cNameRecordSetPropertiesGetter
 	case endpoint.RecordTypeTXT:
@@ -117,15 +135,18 @@ func createMockRecordSetWithTTL(name, recordType, value string, ttl int64) dns.R
 
 }
 
-func (client *mockRecordsClient) ListByDNSZone(resourceGroupName string, zoneName string, top *int32) (dns.RecordSetListResult, error) {
-	return dns.RecordSetListResult{Value: client.mockRecordSet}, nil
-}
-
-func (client *mockRecordsClient) ListByDNSZoneNextResults(list dns.RecordSetListResult) (dns.RecordSetListResult, error) {
-	return dns.RecordSetListResult{}, nil
+func (client *mockRecordsClient) ListAllByDNSZoneComplete(ctx context.Context, resourceGroupName string, zoneName string, top *int32, recordSetNameSuffix string) (dns.RecordSetListResultIterator, error) {
+	page := dns.NewRecordSetListResultPage(func(_ context.Context, lastResults dns.RecordSetListResult) (dns.RecordSetListResult, error) {
+		if lastResults.Value == nil {
+			return dns.RecordSetListResult{Value: client.mockRecordSet}, nil
+		}
+		return dns.RecordSetListResult{}, nil
+	})
+	err := page.NextWithContext(ctx)
+	return dns.NewRecordSetListResultIterator(page), err
 }
 
-func (client *mockRecordsClient) Delete(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (autorest.Response, error) {
+func (client *mockRecordsClient) Delete(ctx context.Context, resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (autorest.Response, error) {
 	client.deletedEndpoints = append(
 		client.deletedEndpoints,
 		endpoint.NewEndpoint(
@@ -137,7 +158,7 @@ func (client *mockRecordsClient) Delete(resourceGroupName string, zoneName strin
 	return autorest.Response{}, nil
 }
 
-func (client *mockRecordsClient) CreateOrUpdate(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (dns.RecordSet, error) {
+func (client *mockRecordsClient) CreateOrUpdate(ctx context.Context, resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (dns.RecordSet, error) {
 	var ttl endpoint.TTL
 	if parameters.TTL != nil {
 		ttl = endpoint.TTL(*parameters.TTL)
@@ -186,6 +207,7 @@ func TestAzureRecord(t *testing.T) {
 			createMockRecordSet("@", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default"),
 			createMockRecordSetWithTTL("nginx", endpoint.RecordTypeA, "123.123.123.123", 3600),
 			createMockRecordSetWithTTL("nginx", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default", recordTTL),
+			createMockRecordSetWithTTL("nginx", endpoint.RecordTypeAAAA, "2001:db8::1", 3600),
 			createMockRecordSetWithTTL("hack", endpoint.RecordTypeCNAME, "hack.azurewebsites.net", 10),
 		},
 	}
@@ -202,6 +224,7 @@ func TestAzureRecord(t *testing.T) {
 		endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default"),
 		endpoint.NewEndpointWithTTL("nginx.example.com", endpoint.RecordTypeA, 3600, "123.123.123.123"),
 		endpoint.NewEndpointWithTTL("nginx.example.com", endpoint.RecordTypeTXT, recordTTL, "heritage=external-dns,external-dns/owner=default"),
+		endpoint.NewEndpointWithTTL("nginx.example.com", endpoint.RecordTypeAAAA, 3600, "2001:db8::1"),
 		endpoint.NewEndpointWithTTL("hack.example.com", endpoint.RecordTypeCNAME, 10, "hack.azurewebsites.net"),
 	}
 
@@ -226,6 +249,7 @@ func TestAzureApplyChanges(t *testing.T) {
 		endpoint.NewEndpointWithTTL("example.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
 		endpoint.NewEndpointWithTTL("foo.example.com", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "1.2.3.4"),
 		endpoint.NewEndpointWithTTL("foo.example.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
+		endpoint.NewEndpointWithTTL("foo.example.com", endpoint.RecordTypeAAAA, endpoint.TTL(recordTTL), "2001:db8::1"),
 		endpoint.NewEndpointWithTTL("bar.example.com", endpoint.RecordTypeCNAME, endpoint.TTL(recordTTL), "other.com"),
 		endpoint.NewEndpointWithTTL("bar.example.com", endpoint.RecordTypeTXT, endpoint.TTL(recordTTL), "tag"),
 		endpoint.NewEndpointWithTTL("other.com", endpoint.RecordTypeA, endpoint.TTL(recordTTL), "5.6.7.8"),
@@ -267,6 +291,7 @@ func testAzureApplyChangesInternal(t *testing.T, dryRun bool, client RecordsClie
 		endpoint.NewEndpoint("example.com", endpoint.RecordTypeTXT, "tag"),
 		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "1.2.3.4"),
 		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeTXT, "tag"),
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeAAAA, "2001:db8::1"),
 		endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeCNAME, "other.com"),
 		endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeTXT, "tag"),
 		endpoint.NewEndpoint("other.com", endpoint.RecordTypeA, "5.6.7.8"),
@@ -17,9 +17,12 @@ limitations under the License.
 package provider
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	cloudflare "github.com/cloudflare/cloudflare-go"
 	log "github.com/sirupsen/logrus"
@@ -37,6 +40,13 @@ const (
 	cloudFlareUpdate = "UPDATE"
 	// defaultCloudFlareRecordTTL 1 = automatic
 	defaultCloudFlareRecordTTL = 1
+	// cloudFlarePageSize is the number of zones requested per page, the maximum of the API
+	cloudFlarePageSize = 50
+	// cloudFlareTimeout is the timeout of the requests to the CloudFlare API
+	cloudFlareTimeout = 30 * time.Second
+	// provider specific key that designates whether a record is proxied by CloudFlare,
+	// overriding the --cloudflare-proxied flag
+	providerSpecificCloudFlareProxied = "cloudflare/proxied"
 )
 
 var cloudFlareTypeNotSupported = map[string]bool{
@@ -52,7 +62,7 @@ var cloudFlareTypeNotSupported = map[string]bool{
 type cloudFlareDNS interface {
 	UserDetails() (cloudflare.User, error)
 	ZoneIDByName(zoneName string) (string, error)
-	ListZones(zoneID ...string) ([]cloudflare.Zone, error)
+	ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error)
 	DNSRecords(zoneID string, rr cloudflare.DNSRecord) ([]cloudflare.DNSRecord, error)
 	CreateDNSRecord(zoneID string, rr cloudflare.DNSRecord) (*cloudflare.DNSRecordResponse, error)
 	DeleteDNSRecord(zoneID, recordID string) error
@@ -67,8 +77,20 @@ func (z zoneService) UserDetails() (cloudflare.User, error) {
 	return z.service.UserDetails()
 }
 
-func (z zoneService) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return z.service.ListZones(zoneID...)
+// ListZonesPage returns the given page of the zones of the account. Responses which aren't
+// successful fail with the first error message of the API.
+func (z zoneService) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	response, err := z.service.ListZonesContext(context.Background(), cloudflare.WithPagination(cloudflare.PaginationOptions{Page: page, PerPage: cloudFlarePageSize}))
+	if err != nil {
+		return nil, cloudflare.ResultInfo{}, fmt.Errorf("failed to list zones: %v", err)
+	}
+	if !response.Success {
+		if len(response.Errors) > 0 {
+			return nil, cloudflare.ResultInfo{}, fmt.Errorf("failed to list zones: %s", response.Errors[0].Message)
+		}
+		return nil, cloudflare.ResultInfo{}, fmt.Errorf("failed to list zones: unsuccessful response without errors")
+	}
+	return response.Result, response.ResultInfo, nil
 }
 
 func (z zoneService) ZoneIDByName(zoneName string) (string, error) {
@@ -108,13 +130,13 @@ type cloudFlareChange struct {
 // NewCloudFlareProvider initializes a new CloudFlare DNS based Provider.
 func NewCloudFlareProvider(domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, proxied bool, dryRun bool) (*CloudFlareProvider, error) {
 	// initialize via API email and API key and returns new API object
-	config, err := cloudflare.New(os.Getenv("CF_API_KEY"), os.Getenv("CF_API_EMAIL"))
+	config, err := cloudflare.New(os.Getenv("CF_API_KEY"), os.Getenv("CF_API_EMAIL"), cloudflare.HTTPClient(&http.Client{Timeout: cloudFlareTimeout}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize cloudflare provider: %v", err)
 	}
 	provider := &CloudFlareProvider{
 		//Client: config,
-		Client:       zoneService{config},
+		Client:       zoneService{service: config},
 		domainFilter: domainFilter,
 		zoneIDFilter: zoneIDFilter,
 		proxied:      proxied,
@@ -127,21 +149,27 @@ func NewCloudFlareProvider(domainFilter DomainFilter, zoneIDFilter ZoneIDFilter,
 func (p *CloudFlareProvider) Zones() ([]cloudflare.Zone, error) {
 	result := []cloudflare.Zone{}
 
-	zones, err := p.Client.ListZones()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, zone := range zones {
-		if !p.domainFilter.Match(zone.Name) {
-			continue
+	for page := 1; ; page++ {
+		zones, resultInfo, err := p.Client.ListZonesPage(page)
+		if err != nil {
+			return nil, err
 		}
 
-		if !p.zoneIDFilter.Match(zone.ID) {
-			continue
+		for _, zone := range zones {
+			if !p.domainFilter.Match(zone.Name) {
+				continue
+			}
+
+			if !p.zoneIDFilter.Match(zone.ID) {
+				continue
+			}
+
+			result = append(result, zone)
 		}
 
-		result = append(result, zone)
+		if page >= resultInfo.TotalPages {
+			break
+		}
 	}
 
 	return result, nil
@@ -162,22 +190,37 @@ func (p *CloudFlareProvider) Records() ([]*endpoint.Endpoint, error) {
 		}
 
 		for _, r := range records {
-			if supportedRecordType(r.Type) {
-				endpoints = append(endpoints, endpoint.NewEndpointWithTTL(r.Name, r.Type, endpoint.TTL(r.TTL), r.Content))
+			if !supportedRecordType(r.Type) {
+				continue
+			}
+			ep := endpoint.NewEndpointWithTTL(r.Name, r.Type, endpoint.TTL(r.TTL), r.Content)
+			// only report the proxied state of records which can be proxied, so that a desired
+			// proxied wildcard isn't updated over and over again
+			if isProxiable(ep) {
+				ep.WithProviderSpecific(providerSpecificCloudFlareProxied, fmt.Sprintf("%t", r.Proxied))
 			}
+			endpoints = append(endpoints, ep)
 		}
 	}
 
 	return endpoints, nil
 }
 
+// ProviderSpecificDefaults returns the proxied state of the records which don't set it, so that
+// current records deviating from the default of the provider are updated.
+func (p *CloudFlareProvider) ProviderSpecificDefaults() endpoint.ProviderSpecific {
+	return endpoint.ProviderSpecific{
+		providerSpecificCloudFlareProxied: fmt.Sprintf("%t", p.proxied),
+	}
+}
+
 // ApplyChanges applies a given set of changes in a given zone.
 func (p *CloudFlareProvider) ApplyChanges(changes *plan.Changes) error {
 	combinedChanges := make([]*cloudFlareChange, 0, len(changes.Create)+len(changes.UpdateNew)+len(changes.Delete))
 
+	combinedChanges = append(combinedChanges, newCloudFlareChanges(cloudFlareDelete, changes.Delete, p.proxied)...)
 	combinedChanges = append(combinedChanges, newCloudFlareChanges(cloudFlareCreate, changes.Create, p.proxied)...)
 	combinedChanges = append(combinedChanges, newCloudFlareChanges(cloudFlareUpdate, changes.UpdateNew, p.proxied)...)
-	combinedChanges = append(combinedChanges, newCloudFlareChanges(cloudFlareDelete, changes.Delete, p.proxied)...)
 
 	return p.submitChanges(combinedChanges)
 }
@@ -203,11 +246,12 @@ func (p *CloudFlareProvider) submitChanges(changes []*cloudFlareChange) error {
 		}
 		for _, change := range changes {
 			logFields := log.Fields{
-				"record": change.ResourceRecordSet.Name,
-				"type":   change.ResourceRecordSet.Type,
-				"ttl":    change.ResourceRecordSet.TTL,
-				"action": change.Action,
-				"zone":   zoneID,
+				"record":  change.ResourceRecordSet.Name,
+				"type":    change.ResourceRecordSet.Type,
+				"ttl":     change.ResourceRecordSet.TTL,
+				"proxied": change.ResourceRecordSet.Proxied,
+				"action":  change.Action,
+				"zone":    zoneID,
 			}
 
 			log.WithFields(logFields).Info("Changing record.")
@@ -280,11 +324,9 @@ func newCloudFlareChanges(action string, endpoints []*endpoint.Endpoint, proxied
 	return changes
 }
 
-func newCloudFlareChange(action string, endpoint *endpoint.Endpoint, proxied bool) *cloudFlareChange {
+func newCloudFlareChange(action string, endpoint *endpoint.Endpoint, proxiedByDefault bool) *cloudFlareChange {
 	ttl := defaultCloudFlareRecordTTL
-	if proxied && (cloudFlareTypeNotSupported[endpoint.RecordType] || strings.Contains(endpoint.DNSName, "*")) {
-		proxied = false
-	}
+	proxied := shouldBeProxied(endpoint, proxiedByDefault)
 	if endpoint.RecordTTL.IsConfigured() {
 		ttl = int(endpoint.RecordTTL)
 	}
@@ -300,3 +342,20 @@ func newCloudFlareChange(action string, endpoint *endpoint.Endpoint, proxied boo
 		},
 	}
 }
+
+// shouldBeProxied returns true if the record should be proxied by CloudFlare. The proxied
+// provider specific property of the record overrides the default of the provider.
+func shouldBeProxied(endpoint *endpoint.Endpoint, proxiedByDefault bool) bool {
+	if !isProxiable(endpoint) {
+		return false
+	}
+	if value, ok := endpoint.ProviderSpecific[providerSpecificCloudFlareProxied]; ok {
+		return value == "true"
+	}
+	return proxiedByDefault
+}
+
+// isProxiable returns false for wildcard records and records of types CloudFlare can't proxy.
+func isProxiable(endpoint *endpoint.Endpoint) bool {
+	return !cloudFlareTypeNotSupported[endpoint.RecordType] && !strings.Contains(endpoint.DNSName, "*")
+}
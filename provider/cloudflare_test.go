@@ -18,6 +18,8 @@ package provider
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -30,6 +32,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// cloudFlareSinglePage is the pagination info of mocks returning all results at once.
+var cloudFlareSinglePage = cloudflare.ResultInfo{Page: 1, TotalPages: 1}
+
 type mockCloudFlareClient struct{}
 
 func (m *mockCloudFlareClient) CreateDNSRecord(zoneID string, rr cloudflare.DNSRecord) (*cloudflare.DNSRecordResponse, error) {
@@ -62,8 +67,8 @@ func (m *mockCloudFlareClient) ZoneIDByName(zoneName string) (string, error) {
 	return "1234567890", nil
 }
 
-func (m *mockCloudFlareClient) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{ID: "1234567890", Name: "ext-dns-test.zalando.to."}, {ID: "1234567891", Name: "foo.com."}}, nil
+func (m *mockCloudFlareClient) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{ID: "1234567890", Name: "ext-dns-test.zalando.to."}, {ID: "1234567891", Name: "foo.com."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareUserDetailsFail struct{}
@@ -92,8 +97,8 @@ func (m *mockCloudFlareUserDetailsFail) ZoneIDByName(zoneName string) (string, e
 	return "", nil
 }
 
-func (m *mockCloudFlareUserDetailsFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareUserDetailsFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareCreateZoneFail struct{}
@@ -122,8 +127,8 @@ func (m *mockCloudFlareCreateZoneFail) ZoneIDByName(zoneName string) (string, er
 	return "", nil
 }
 
-func (m *mockCloudFlareCreateZoneFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareCreateZoneFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareDNSRecordsFail struct{}
@@ -151,8 +156,8 @@ func (m *mockCloudFlareDNSRecordsFail) ZoneIDByName(zoneName string) (string, er
 	return "", nil
 }
 
-func (m *mockCloudFlareDNSRecordsFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareDNSRecordsFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareZoneIDByNameFail struct{}
@@ -181,8 +186,8 @@ func (m *mockCloudFlareZoneIDByNameFail) ZoneIDByName(zoneName string) (string,
 	return "", fmt.Errorf("no ID for zone found")
 }
 
-func (m *mockCloudFlareZoneIDByNameFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareZoneIDByNameFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareDeleteZoneFail struct{}
@@ -211,8 +216,8 @@ func (m *mockCloudFlareDeleteZoneFail) ZoneIDByName(zoneName string) (string, er
 	return "1234567890", nil
 }
 
-func (m *mockCloudFlareDeleteZoneFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareDeleteZoneFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareListZonesFail struct{}
@@ -241,8 +246,8 @@ func (m *mockCloudFlareListZonesFail) ZoneIDByName(zoneName string) (string, err
 	return "1234567890", nil
 }
 
-func (m *mockCloudFlareListZonesFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{}}, fmt.Errorf("no zones available")
+func (m *mockCloudFlareListZonesFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{}}, cloudFlareSinglePage, fmt.Errorf("no zones available")
 }
 
 type mockCloudFlareCreateRecordsFail struct{}
@@ -271,8 +276,8 @@ func (m *mockCloudFlareCreateRecordsFail) ZoneIDByName(zoneName string) (string,
 	return "1234567890", nil
 }
 
-func (m *mockCloudFlareCreateRecordsFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{}}, fmt.Errorf("no zones available")
+func (m *mockCloudFlareCreateRecordsFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{}}, cloudFlareSinglePage, fmt.Errorf("no zones available")
 }
 
 type mockCloudFlareDeleteRecordsFail struct{}
@@ -301,8 +306,8 @@ func (m *mockCloudFlareDeleteRecordsFail) ZoneIDByName(zoneName string) (string,
 	return "1234567890", nil
 }
 
-func (m *mockCloudFlareDeleteRecordsFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareDeleteRecordsFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
 }
 
 type mockCloudFlareUpdateRecordsFail struct{}
@@ -331,8 +336,34 @@ func (m *mockCloudFlareUpdateRecordsFail) ZoneIDByName(zoneName string) (string,
 	return "1234567890", nil
 }
 
-func (m *mockCloudFlareUpdateRecordsFail) ListZones(zoneID ...string) ([]cloudflare.Zone, error) {
-	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, nil
+func (m *mockCloudFlareUpdateRecordsFail) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return []cloudflare.Zone{{Name: "ext-dns-test.zalando.to."}}, cloudFlareSinglePage, nil
+}
+
+// mockCloudFlarePagedClient returns its zones in pages and updates its records.
+type mockCloudFlarePagedClient struct {
+	mockCloudFlareClient
+	zones   [][]cloudflare.Zone
+	records map[string][]cloudflare.DNSRecord
+}
+
+func (m *mockCloudFlarePagedClient) ListZonesPage(page int) ([]cloudflare.Zone, cloudflare.ResultInfo, error) {
+	return m.zones[page-1], cloudflare.ResultInfo{Page: page, TotalPages: len(m.zones)}, nil
+}
+
+func (m *mockCloudFlarePagedClient) DNSRecords(zoneID string, rr cloudflare.DNSRecord) ([]cloudflare.DNSRecord, error) {
+	return m.records[zoneID], nil
+}
+
+func (m *mockCloudFlarePagedClient) UpdateDNSRecord(zoneID, recordID string, rr cloudflare.DNSRecord) error {
+	for i, record := range m.records[zoneID] {
+		if record.ID == recordID {
+			rr.ID = recordID
+			m.records[zoneID][i] = rr
+			return nil
+		}
+	}
+	return fmt.Errorf("record %s not found", recordID)
 }
 
 func TestNewCloudFlareChanges(t *testing.T) {
@@ -397,6 +428,51 @@ func TestNewCloudFlareChangeProxiable(t *testing.T) {
 	assert.False(t, change.ResourceRecordSet.Proxied)
 }
 
+func TestNewCloudFlareChangeProxiedProviderSpecific(t *testing.T) {
+	for _, tc := range []struct {
+		title            string
+		endpoint         *endpoint.Endpoint
+		proxiedByDefault bool
+		expected         bool
+	}{
+		{
+			title:            "default proxied",
+			endpoint:         endpoint.NewEndpoint("new", endpoint.RecordTypeA, "target"),
+			proxiedByDefault: true,
+			expected:         true,
+		},
+		{
+			title:            "not proxied by annotation",
+			endpoint:         endpoint.NewEndpoint("new", endpoint.RecordTypeA, "target").WithProviderSpecific(providerSpecificCloudFlareProxied, "false"),
+			proxiedByDefault: true,
+			expected:         false,
+		},
+		{
+			title:            "proxied by annotation",
+			endpoint:         endpoint.NewEndpoint("new", endpoint.RecordTypeCNAME, "target").WithProviderSpecific(providerSpecificCloudFlareProxied, "true"),
+			proxiedByDefault: false,
+			expected:         true,
+		},
+		{
+			title:            "wildcard proxied by annotation",
+			endpoint:         endpoint.NewEndpoint("*.new", endpoint.RecordTypeA, "target").WithProviderSpecific(providerSpecificCloudFlareProxied, "true"),
+			proxiedByDefault: false,
+			expected:         false,
+		},
+		{
+			title:            "TXT proxied by annotation",
+			endpoint:         endpoint.NewEndpoint("new", endpoint.RecordTypeTXT, "target").WithProviderSpecific(providerSpecificCloudFlareProxied, "true"),
+			proxiedByDefault: false,
+			expected:         false,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			change := newCloudFlareChange(cloudFlareCreate, tc.endpoint, tc.proxiedByDefault)
+			assert.Equal(t, tc.expected, change.ResourceRecordSet.Proxied)
+		})
+	}
+}
+
 func TestCloudFlareZones(t *testing.T) {
 	provider := &CloudFlareProvider{
 		Client:       &mockCloudFlareClient{},
@@ -436,6 +512,109 @@ func TestRecords(t *testing.T) {
 	}
 }
 
+func TestCloudFlarePagination(t *testing.T) {
+	provider := &CloudFlareProvider{
+		Client: &mockCloudFlarePagedClient{
+			zones: [][]cloudflare.Zone{
+				{{ID: "1", Name: "foo.com"}, {ID: "2", Name: "bar.com"}},
+				{{ID: "3", Name: "baz.com"}},
+			},
+			records: map[string][]cloudflare.DNSRecord{
+				"1": {
+					{Name: "a.foo.com", Type: endpoint.RecordTypeA, Content: "1.2.3.4", TTL: 1, Proxied: true},
+					{Name: "b.foo.com", Type: endpoint.RecordTypeCNAME, Content: "a.foo.com", TTL: 120},
+				},
+				"3": {
+					{Name: "baz.com", Type: endpoint.RecordTypeTXT, Content: "heritage=external-dns", TTL: 1},
+					{Name: "*.baz.com", Type: endpoint.RecordTypeA, Content: "1.2.3.4", TTL: 1},
+				},
+			},
+		},
+		domainFilter: NewDomainFilter([]string{"foo.com", "baz.com"}),
+		zoneIDFilter: NewZoneIDFilter([]string{""}),
+	}
+
+	zones, err := provider.Zones()
+	require.NoError(t, err)
+	validateCloudFlareZones(t, zones, []cloudflare.Zone{{Name: "foo.com"}, {Name: "baz.com"}})
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+	assert.Equal(t, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("a.foo.com", endpoint.RecordTypeA, 1, "1.2.3.4").WithProviderSpecific(providerSpecificCloudFlareProxied, "true"),
+		endpoint.NewEndpointWithTTL("b.foo.com", endpoint.RecordTypeCNAME, 120, "a.foo.com").WithProviderSpecific(providerSpecificCloudFlareProxied, "false"),
+		endpoint.NewEndpointWithTTL("baz.com", endpoint.RecordTypeTXT, 1, "heritage=external-dns"),
+		endpoint.NewEndpointWithTTL("*.baz.com", endpoint.RecordTypeA, 1, "1.2.3.4"),
+	}, records)
+}
+
+func TestCloudFlareProxiedAnnotationRemoved(t *testing.T) {
+	client := &mockCloudFlarePagedClient{
+		zones: [][]cloudflare.Zone{{{ID: "1", Name: "foo.com"}}},
+		records: map[string][]cloudflare.DNSRecord{
+			"1": {{ID: "2", Name: "a.foo.com", Type: endpoint.RecordTypeA, Content: "1.2.3.4", TTL: 1, Proxied: true}},
+		},
+	}
+	provider := &CloudFlareProvider{
+		Client:       client,
+		domainFilter: NewDomainFilter([]string{"foo.com"}),
+		zoneIDFilter: NewZoneIDFilter([]string{""}),
+	}
+
+	// the record was proxied by its annotation, the desired record without it gets the default
+	records, err := provider.Records()
+	require.NoError(t, err)
+	desired := endpoint.NewEndpointWithTTL("a.foo.com", endpoint.RecordTypeA, 1, "1.2.3.4")
+	desired.ProviderSpecific = provider.ProviderSpecificDefaults()
+	changes := (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes
+	require.Len(t, changes.UpdateNew, 1)
+	require.NoError(t, provider.ApplyChanges(changes))
+	assert.False(t, client.records["1"][0].Proxied)
+
+	records, err = provider.Records()
+	require.NoError(t, err)
+	changes = (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{desired}}).Calculate().Changes
+	assert.True(t, changes.IsEmpty())
+}
+
+func TestCloudFlareZoneServiceListZonesPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "50", r.URL.Query().Get("per_page"))
+
+		switch r.URL.Query().Get("page") {
+		case "2":
+			fmt.Fprint(w, `{"success":true,"result":[{"id":"1","name":"foo.com"}],"result_info":{"page":2,"per_page":50,"total_pages":2}}`)
+		case "3":
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":1000,"message":"Internal error"}],"result":null}`)
+		case "4":
+			fmt.Fprint(w, `{"result":[]}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":9103,"message":"Unknown X-Auth-Key or X-Auth-Email"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	api, err := cloudflare.New("key", "test@test.com")
+	require.NoError(t, err)
+	api.BaseURL = server.URL
+	service := zoneService{service: api}
+
+	zones, resultInfo, err := service.ListZonesPage(2)
+	require.NoError(t, err)
+	assert.Equal(t, []cloudflare.Zone{{ID: "1", Name: "foo.com"}}, zones)
+	assert.Equal(t, 2, resultInfo.TotalPages)
+
+	_, _, err = service.ListZonesPage(1)
+	assert.Error(t, err)
+
+	// unsuccessful responses fail even with status 200
+	_, _, err = service.ListZonesPage(3)
+	assert.EqualError(t, err, "failed to list zones: Internal error")
+	_, _, err = service.ListZonesPage(4)
+	assert.EqualError(t, err, "failed to list zones: unsuccessful response without errors")
+}
+
 func TestNewCloudFlareProvider(t *testing.T) {
 	_ = os.Setenv("CF_API_KEY", "xxxxxxxxxxxxxxxxx")
 	_ = os.Setenv("CF_API_EMAIL", "test@test.com")
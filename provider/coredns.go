@@ -42,11 +42,8 @@ func init() {
 }
 
 const (
-	priority    = 10 // default priority when nothing is set
 	etcdTimeout = 5 * time.Second
 
-	coreDNSPrefix = "/skydns/"
-
 	randomPrefixLabel = "prefix"
 )
 
@@ -54,13 +51,16 @@ const (
 type coreDNSClient interface {
 	GetServices(prefix string) ([]*Service, error)
 	SaveService(value *Service) error
+	// DeleteService deletes the service of exactly the given key
 	DeleteService(key string) error
 }
 
 type coreDNSProvider struct {
-	dryRun       bool
-	domainFilter DomainFilter
-	client       coreDNSClient
+	dryRun bool
+	// coreDNSPrefix is the etcd key prefix CoreDNS reads the records from, e.g. "/skydns/"
+	coreDNSPrefix string
+	domainFilter  DomainFilter
+	client        coreDNSClient
 }
 
 // Service represents CoreDNS etcd record
@@ -123,9 +123,6 @@ func (c etcdClient) GetServices(prefix string) ([]*Service, error) {
 		bx[b] = true
 
 		svc.Key = string(n.Key)
-		if svc.Priority == 0 {
-			svc.Priority = priority
-		}
 		svcs = append(svcs, svc)
 	}
 
@@ -148,12 +145,12 @@ func (c etcdClient) SaveService(service *Service) error {
 	return nil
 }
 
-// DeleteService deletes service record from etcd
+// DeleteService deletes service record from etcd, the keys below it are kept
 func (c etcdClient) DeleteService(key string) error {
 	ctx, cancel := context.WithTimeout(c.ctx, etcdTimeout)
 	defer cancel()
 
-	_, err := c.client.Delete(ctx, key, etcdcv3.WithPrefix())
+	_, err := c.client.Delete(ctx, key)
 	return err
 }
 
@@ -244,29 +241,31 @@ func newETCDClient() (coreDNSClient, error) {
 	return etcdClient{c, context.Background()}, nil
 }
 
-// NewCoreDNSProvider is a CoreDNS provider constructor
-func NewCoreDNSProvider(domainFilter DomainFilter, dryRun bool) (Provider, error) {
+// NewCoreDNSProvider is a CoreDNS provider constructor, the records are kept below the given etcd key prefix
+func NewCoreDNSProvider(domainFilter DomainFilter, prefix string, dryRun bool) (Provider, error) {
 	client, err := newETCDClient()
 	if err != nil {
 		return nil, err
 	}
 	return coreDNSProvider{
-		client:       client,
-		dryRun:       dryRun,
-		domainFilter: domainFilter,
+		client:        client,
+		dryRun:        dryRun,
+		coreDNSPrefix: "/" + strings.Trim(prefix, "/") + "/",
+		domainFilter:  domainFilter,
 	}, nil
 }
 
 // Records returns all DNS records found in CoreDNS etcd backend. Depending on the record fields
-// it may be mapped to one or two records of type A, CNAME, TXT, A+TXT, CNAME+TXT
+// it may be mapped to one or two records of type A, AAAA, CNAME, SRV, MX, TXT or any of these
+// and TXT, e.g. A+TXT
 func (p coreDNSProvider) Records() ([]*endpoint.Endpoint, error) {
 	var result []*endpoint.Endpoint
-	services, err := p.client.GetServices(coreDNSPrefix)
+	services, err := p.client.GetServices(p.coreDNSPrefix)
 	if err != nil {
 		return nil, err
 	}
 	for _, service := range services {
-		domains := strings.Split(strings.TrimPrefix(service.Key, coreDNSPrefix), "/")
+		domains := strings.Split(strings.TrimPrefix(service.Key, p.coreDNSPrefix), "/")
 		reverse(domains)
 		dnsName := strings.Join(domains[service.TargetStrip:], ".")
 		if !p.domainFilter.Match(dnsName) {
@@ -274,11 +273,12 @@ func (p coreDNSProvider) Records() ([]*endpoint.Endpoint, error) {
 		}
 		prefix := strings.Join(domains[:service.TargetStrip], ".")
 		if service.Host != "" {
+			recordType, target := serviceTarget(service)
 			ep := endpoint.NewEndpointWithTTL(
 				dnsName,
-				guessRecordType(service.Host),
+				recordType,
 				endpoint.TTL(service.TTL),
-				service.Host,
+				target,
 			)
 			ep.Labels["originalText"] = service.Text
 			ep.Labels[randomPrefixLabel] = prefix
@@ -325,12 +325,14 @@ func (p coreDNSProvider) ApplyChanges(changes *plan.Changes) error {
 				}
 
 				service := Service{
-					Host:        target,
 					Text:        ep.Labels["originalText"],
-					Key:         etcdKeyFor(prefix + "." + dnsName),
+					Key:         p.etcdKeyFor(prefix + "." + dnsName),
 					TargetStrip: strings.Count(prefix, ".") + 1,
 					TTL:         uint32(ep.RecordTTL),
 				}
+				if err := setServiceTarget(&service, ep.RecordType, target); err != nil {
+					return err
+				}
 				services = append(services, service)
 			}
 		}
@@ -345,7 +347,7 @@ func (p coreDNSProvider) ApplyChanges(changes *plan.Changes) error {
 					prefix = fmt.Sprintf("%08x", rand.Int31())
 				}
 				services = append(services, Service{
-					Key:         etcdKeyFor(prefix + "." + dnsName),
+					Key:         p.etcdKeyFor(prefix + "." + dnsName),
 					TargetStrip: strings.Count(prefix, ".") + 1,
 					TTL:         uint32(ep.RecordTTL),
 				})
@@ -359,7 +361,7 @@ func (p coreDNSProvider) ApplyChanges(changes *plan.Changes) error {
 		}
 
 		for _, service := range services {
-			log.Infof("Add/set key %s to Host=%s, Text=%s, TTL=%d", service.Key, service.Host, service.Text, service.TTL)
+			log.Infof("Add/set key %s to Host=%s, Port=%d, Priority=%d, Weight=%d, Mail=%t, Text=%s, TTL=%d", service.Key, service.Host, service.Port, service.Priority, service.Weight, service.Mail, service.Text, service.TTL)
 			if !p.dryRun {
 				err := p.client.SaveService(&service)
 				if err != nil {
@@ -370,11 +372,12 @@ func (p coreDNSProvider) ApplyChanges(changes *plan.Changes) error {
 	}
 
 	for _, ep := range changes.Delete {
-		dnsName := ep.DNSName
-		if ep.Labels[randomPrefixLabel] != "" {
-			dnsName = ep.Labels[randomPrefixLabel] + "." + dnsName
+		// only the keys written by ExternalDNS are deleted, they have a random prefix
+		if ep.Labels[randomPrefixLabel] == "" {
+			log.Warnf("Skipping deletion of %s %s because its key wasn't written by ExternalDNS", ep.DNSName, ep.RecordType)
+			continue
 		}
-		key := etcdKeyFor(dnsName)
+		key := p.etcdKeyFor(ep.Labels[randomPrefixLabel] + "." + ep.DNSName)
 		log.Infof("Delete key %s", key)
 		if !p.dryRun {
 			err := p.client.DeleteService(key)
@@ -387,17 +390,52 @@ func (p coreDNSProvider) ApplyChanges(changes *plan.Changes) error {
 	return nil
 }
 
+// serviceTarget returns the record type and the target of a service with a host
+func serviceTarget(service *Service) (string, string) {
+	switch {
+	case service.Mail:
+		return endpoint.RecordTypeMX, fmt.Sprintf("%d %s", service.Priority, service.Host)
+	case service.Port != 0:
+		return endpoint.RecordTypeSRV, fmt.Sprintf("%d %d %d %s", service.Priority, service.Weight, service.Port, service.Host)
+	}
+	return guessRecordType(service.Host), service.Host
+}
+
+// setServiceTarget sets the host of the service and, for SRV and MX records, the fields of the target
+func setServiceTarget(service *Service, recordType, target string) error {
+	switch recordType {
+	case endpoint.RecordTypeSRV:
+		if _, err := fmt.Sscanf(target, "%d %d %d %s", &service.Priority, &service.Weight, &service.Port, &service.Host); err != nil {
+			return fmt.Errorf("invalid SRV target %q: %v", target, err)
+		}
+		service.Host = strings.TrimSuffix(service.Host, ".")
+	case endpoint.RecordTypeMX:
+		if _, err := fmt.Sscanf(target, "%d %s", &service.Priority, &service.Host); err != nil {
+			return fmt.Errorf("invalid MX target %q: %v", target, err)
+		}
+		service.Host = strings.TrimSuffix(service.Host, ".")
+		service.Mail = true
+	default:
+		service.Host = target
+	}
+	return nil
+}
+
 func guessRecordType(target string) string {
-	if net.ParseIP(target) != nil {
-		return endpoint.RecordTypeA
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return endpoint.RecordTypeCNAME
+	}
+	if ip.To4() == nil {
+		return endpoint.RecordTypeAAAA
 	}
-	return endpoint.RecordTypeCNAME
+	return endpoint.RecordTypeA
 }
 
-func etcdKeyFor(dnsName string) string {
+func (p coreDNSProvider) etcdKeyFor(dnsName string) string {
 	domains := strings.Split(dnsName, ".")
 	reverse(domains)
-	return coreDNSPrefix + strings.Join(domains, "/")
+	return p.coreDNSPrefix + strings.Join(domains, "/")
 }
 
 func reverse(slice []string) {
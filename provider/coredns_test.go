@@ -59,7 +59,7 @@ func TestAServiceTranslation(t *testing.T) {
 			"/skydns/com/example": {Host: expectedTarget},
 		},
 	}
-	provider := coreDNSProvider{client: client}
+	provider := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
 	endpoints, err := provider.Records()
 	if err != nil {
 		t.Fatal(err)
@@ -88,7 +88,7 @@ func TestCNAMEServiceTranslation(t *testing.T) {
 			"/skydns/com/example": {Host: expectedTarget},
 		},
 	}
-	provider := coreDNSProvider{client: client}
+	provider := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
 	endpoints, err := provider.Records()
 	if err != nil {
 		t.Fatal(err)
@@ -117,7 +117,7 @@ func TestTXTServiceTranslation(t *testing.T) {
 			"/skydns/com/example": {Text: expectedTarget},
 		},
 	}
-	provider := coreDNSProvider{client: client}
+	provider := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
 	endpoints, err := provider.Records()
 	if err != nil {
 		t.Fatal(err)
@@ -148,7 +148,7 @@ func TestAWithTXTServiceTranslation(t *testing.T) {
 			"/skydns/com/example": {Host: "1.2.3.4", Text: "string"},
 		},
 	}
-	provider := coreDNSProvider{client: client}
+	provider := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
 	endpoints, err := provider.Records()
 	if err != nil {
 		t.Fatal(err)
@@ -187,7 +187,7 @@ func TestCNAMEWithTXTServiceTranslation(t *testing.T) {
 			"/skydns/com/example": {Host: "example.net", Text: "string"},
 		},
 	}
-	provider := coreDNSProvider{client: client}
+	provider := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
 	endpoints, err := provider.Records()
 	if err != nil {
 		t.Fatal(err)
@@ -218,7 +218,7 @@ func TestCoreDNSApplyChanges(t *testing.T) {
 	client := fakeETCDClient{
 		map[string]*Service{},
 	}
-	coredns := coreDNSProvider{client: client}
+	coredns := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
 
 	changes1 := &plan.Changes{
 		Create: []*endpoint.Endpoint{
@@ -274,6 +274,145 @@ func TestCoreDNSApplyChanges(t *testing.T) {
 	validateServices(client.services, expectedServices3, t, 3)
 }
 
+func TestSRVAndMXServiceTranslation(t *testing.T) {
+	client := fakeETCDClient{
+		map[string]*Service{
+			"/skydns/com/example/_tcp/_sip": {Host: "sip.example.com", Port: 5060, Priority: 10, Weight: 20},
+			"/skydns/com/example/mail":      {Host: "mx.example.com", Priority: 5, Mail: true},
+		},
+	}
+	provider := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
+	endpoints, err := provider.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatalf("got unexpected number of endpoints: %d", len(endpoints))
+	}
+	for _, ep := range endpoints {
+		var expectedRecordType, expectedTarget string
+		switch ep.DNSName {
+		case "_sip._tcp.example.com":
+			expectedRecordType, expectedTarget = endpoint.RecordTypeSRV, "10 20 5060 sip.example.com"
+		case "mail.example.com":
+			expectedRecordType, expectedTarget = endpoint.RecordTypeMX, "5 mx.example.com"
+		default:
+			t.Errorf("got unexpected DNS name: %s", ep.DNSName)
+			continue
+		}
+		if ep.RecordType != expectedRecordType {
+			t.Errorf("got unexpected DNS record type: %s != %s", ep.RecordType, expectedRecordType)
+		}
+		if ep.Targets[0] != expectedTarget {
+			t.Errorf("got unexpected DNS target: %s != %s", ep.Targets[0], expectedTarget)
+		}
+	}
+}
+
+func TestCoreDNSApplyChangesSRVAndMX(t *testing.T) {
+	client := fakeETCDClient{
+		map[string]*Service{},
+	}
+	coredns := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
+
+	err := coredns.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("_sip._tcp.example.com", endpoint.RecordTypeSRV, "10 20 5060 sip.example.com."),
+			endpoint.NewEndpoint("mail.example.com", endpoint.RecordTypeMX, "5 mx.example.com"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := coredns.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"_sip._tcp.example.com SRV": "10 20 5060 sip.example.com",
+		"mail.example.com MX":       "5 mx.example.com",
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("got unexpected number of endpoints: %d", len(records))
+	}
+	for _, ep := range records {
+		key := ep.DNSName + " " + ep.RecordType
+		if ep.Targets[0] != expected[key] {
+			t.Errorf("got unexpected DNS target for %s: %s != %s", key, ep.Targets[0], expected[key])
+		}
+	}
+
+	for _, target := range []string{"10 5060 sip.example.com", "sip.example.com"} {
+		err = coredns.ApplyChanges(&plan.Changes{
+			Create: []*endpoint.Endpoint{endpoint.NewEndpoint("_sip._udp.example.com", endpoint.RecordTypeSRV, target)},
+		})
+		if err == nil {
+			t.Errorf("expected an error for invalid SRV target %q", target)
+		}
+	}
+}
+
+func TestCoreDNSPrefix(t *testing.T) {
+	client := fakeETCDClient{
+		map[string]*Service{
+			"/skydns/com/example": {Host: "1.2.3.4"},
+		},
+	}
+	coredns := coreDNSProvider{client: client, coreDNSPrefix: "/coredns/"}
+
+	err := coredns.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "5.6.7.8")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range client.services {
+		if key != "/skydns/com/example" && !strings.HasPrefix(key, "/coredns/org/example/") {
+			t.Errorf("unexpected service %s", key)
+		}
+	}
+
+	records, err := coredns.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].DNSName != "example.org" {
+		t.Errorf("got unexpected endpoints outside the prefix: %v", records)
+	}
+}
+
+func TestCoreDNSDeleteOnlyOwnKeys(t *testing.T) {
+	client := fakeETCDClient{
+		map[string]*Service{
+			"/skydns/com/example":              {Host: "1.2.3.4"},
+			"/skydns/com/example/x1":           {Host: "1.2.3.5"},
+			"/skydns/com/example/1a2b3c4d":     {Host: "1.2.3.6", TargetStrip: 1},
+			"/skydns/com/example/1a2b3c4d/sub": {Host: "1.2.3.7"},
+		},
+	}
+	coredns := coreDNSProvider{client: client, coreDNSPrefix: "/skydns/"}
+
+	unowned := endpoint.NewEndpoint("example.com", endpoint.RecordTypeA, "1.2.3.4")
+	owned := endpoint.NewEndpoint("example.com", endpoint.RecordTypeA, "1.2.3.6")
+	owned.Labels[randomPrefixLabel] = "1a2b3c4d"
+	err := coredns.ApplyChanges(&plan.Changes{
+		Delete: []*endpoint.Endpoint{unowned, owned},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"/skydns/com/example", "/skydns/com/example/x1", "/skydns/com/example/1a2b3c4d/sub"} {
+		if _, ok := client.services[key]; !ok {
+			t.Errorf("service %s was deleted", key)
+		}
+	}
+	if _, ok := client.services["/skydns/com/example/1a2b3c4d"]; ok {
+		t.Errorf("service /skydns/com/example/1a2b3c4d wasn't deleted")
+	}
+}
+
 func applyServiceChanges(provider coreDNSProvider, changes *plan.Changes) {
 	records, _ := provider.Records()
 	for _, col := range [][]*endpoint.Endpoint{changes.Create, changes.UpdateNew, changes.Delete} {
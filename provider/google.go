@@ -18,7 +18,9 @@ package provider
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	"github.com/linki/instrumented_http"
@@ -37,6 +39,9 @@ import (
 
 const (
 	googleRecordTTL = 300
+	// visibility of managed zones, zones created before private zones existed have none and are public
+	googleZoneVisibilityPublic  = "public"
+	googleZoneVisibilityPrivate = "private"
 )
 
 type managedZonesCreateCallInterface interface {
@@ -106,6 +111,11 @@ type GoogleProvider struct {
 	domainFilter DomainFilter
 	// only consider hosted zones ending with this zone id
 	zoneIDFilter ZoneIDFilter
+	// only consider managed zones of this visibility, i.e. public or private, all if empty
+	zoneVisibility string
+	// the maximum number of additions and deletions of a change and the interval between changes
+	batchChangeSize     int
+	batchChangeInterval time.Duration
 	// A client for managing resource record sets
 	resourceRecordSetsClient resourceRecordSetsClientInterface
 	// A client for managing hosted zones
@@ -115,7 +125,7 @@ type GoogleProvider struct {
 }
 
 // NewGoogleProvider initializes a new Google CloudDNS based Provider.
-func NewGoogleProvider(project string, domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, dryRun bool) (*GoogleProvider, error) {
+func NewGoogleProvider(project string, domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, zoneVisibility string, batchChangeSize int, batchChangeInterval time.Duration, dryRun bool) (*GoogleProvider, error) {
 	gcloud, err := google.DefaultClient(context.TODO(), dns.NdevClouddnsReadwriteScope)
 	if err != nil {
 		return nil, err
@@ -145,6 +155,9 @@ func NewGoogleProvider(project string, domainFilter DomainFilter, zoneIDFilter Z
 		project:                  project,
 		domainFilter:             domainFilter,
 		zoneIDFilter:             zoneIDFilter,
+		zoneVisibility:           zoneVisibility,
+		batchChangeSize:          batchChangeSize,
+		batchChangeInterval:      batchChangeInterval,
 		dryRun:                   dryRun,
 		resourceRecordSetsClient: resourceRecordSetsService{dnsClient.ResourceRecordSets},
 		managedZonesClient:       managedZonesService{dnsClient.ManagedZones},
@@ -160,7 +173,7 @@ func (p *GoogleProvider) Zones() (map[string]*dns.ManagedZone, error) {
 
 	f := func(resp *dns.ManagedZonesListResponse) error {
 		for _, zone := range resp.ManagedZones {
-			if p.domainFilter.Match(zone.DnsName) && p.zoneIDFilter.Match(fmt.Sprintf("%v", zone.Id)) {
+			if p.domainFilter.Match(zone.DnsName) && p.zoneIDFilter.Match(fmt.Sprintf("%v", zone.Id)) && p.matchVisibility(zone) {
 				zones[zone.Name] = zone
 				log.Debugf("Matched %s (zone: %s)", zone.DnsName, zone.Name)
 			} else {
@@ -191,6 +204,17 @@ func (p *GoogleProvider) Zones() (map[string]*dns.ManagedZone, error) {
 	return zones, nil
 }
 
+// matchVisibility returns true if the zone has the visibility that's filtered for.
+func (p *GoogleProvider) matchVisibility(zone *dns.ManagedZone) bool {
+	if p.zoneVisibility == "" {
+		return true
+	}
+	if zone.Visibility == "" {
+		return p.zoneVisibility == googleZoneVisibilityPublic
+	}
+	return zone.Visibility == p.zoneVisibility
+}
+
 // Records returns the list of records in all relevant zones.
 func (p *GoogleProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
 	zones, err := p.Zones()
@@ -221,60 +245,69 @@ func (p *GoogleProvider) Records() (endpoints []*endpoint.Endpoint, _ error) {
 // CreateRecords creates a given set of DNS records in the given hosted zone.
 func (p *GoogleProvider) CreateRecords(endpoints []*endpoint.Endpoint) error {
 	change := &dns.Change{}
+	recordEndpoints := map[*dns.ResourceRecordSet]*endpoint.Endpoint{}
 
-	change.Additions = append(change.Additions, p.newFilteredRecords(endpoints)...)
+	change.Additions = append(change.Additions, p.newFilteredRecords(endpoints, recordEndpoints)...)
 
-	return p.submitChange(change)
+	return p.submitChange(change, recordEndpoints)
 }
 
 // UpdateRecords updates a given set of old records to a new set of records in a given hosted zone.
 func (p *GoogleProvider) UpdateRecords(records, oldRecords []*endpoint.Endpoint) error {
 	change := &dns.Change{}
+	recordEndpoints := map[*dns.ResourceRecordSet]*endpoint.Endpoint{}
 
-	change.Additions = append(change.Additions, p.newFilteredRecords(records)...)
-	change.Deletions = append(change.Deletions, p.newFilteredRecords(oldRecords)...)
+	change.Additions = append(change.Additions, p.newFilteredRecords(records, recordEndpoints)...)
+	change.Deletions = append(change.Deletions, p.newFilteredRecords(oldRecords, recordEndpoints)...)
 
-	return p.submitChange(change)
+	return p.submitChange(change, recordEndpoints)
 }
 
 // DeleteRecords deletes a given set of DNS records in a given zone.
 func (p *GoogleProvider) DeleteRecords(endpoints []*endpoint.Endpoint) error {
 	change := &dns.Change{}
+	recordEndpoints := map[*dns.ResourceRecordSet]*endpoint.Endpoint{}
 
-	change.Deletions = append(change.Deletions, p.newFilteredRecords(endpoints)...)
+	change.Deletions = append(change.Deletions, p.newFilteredRecords(endpoints, recordEndpoints)...)
 
-	return p.submitChange(change)
+	return p.submitChange(change, recordEndpoints)
 }
 
 // ApplyChanges applies a given set of changes in a given zone.
 func (p *GoogleProvider) ApplyChanges(changes *plan.Changes) error {
 	change := &dns.Change{}
+	recordEndpoints := map[*dns.ResourceRecordSet]*endpoint.Endpoint{}
 
-	change.Additions = append(change.Additions, p.newFilteredRecords(changes.Create)...)
+	change.Additions = append(change.Additions, p.newFilteredRecords(changes.Create, recordEndpoints)...)
 
-	change.Additions = append(change.Additions, p.newFilteredRecords(changes.UpdateNew)...)
-	change.Deletions = append(change.Deletions, p.newFilteredRecords(changes.UpdateOld)...)
+	change.Additions = append(change.Additions, p.newFilteredRecords(changes.UpdateNew, recordEndpoints)...)
+	change.Deletions = append(change.Deletions, p.newFilteredRecords(changes.UpdateOld, recordEndpoints)...)
 
-	change.Deletions = append(change.Deletions, p.newFilteredRecords(changes.Delete)...)
+	change.Deletions = append(change.Deletions, p.newFilteredRecords(changes.Delete, recordEndpoints)...)
 
-	return p.submitChange(change)
+	return p.submitChange(change, recordEndpoints)
 }
 
 // newFilteredRecords returns a collection of RecordSets based on the given endpoints and domainFilter.
-func (p *GoogleProvider) newFilteredRecords(endpoints []*endpoint.Endpoint) []*dns.ResourceRecordSet {
+// The endpoint each RecordSet was created from is stored in recordEndpoints.
+func (p *GoogleProvider) newFilteredRecords(endpoints []*endpoint.Endpoint, recordEndpoints map[*dns.ResourceRecordSet]*endpoint.Endpoint) []*dns.ResourceRecordSet {
 	records := []*dns.ResourceRecordSet{}
 
 	for _, endpoint := range endpoints {
 		if p.domainFilter.Match(endpoint.DNSName) {
-			records = append(records, newRecord(endpoint))
+			record := newRecord(endpoint)
+			recordEndpoints[record] = endpoint
+			records = append(records, record)
 		}
 	}
 
 	return records
 }
 
-// submitChange takes a zone and a Change and sends it to Google.
-func (p *GoogleProvider) submitChange(change *dns.Change) error {
+// submitChange takes a zone and a Change and sends it to Google. The endpoints of records
+// exceeding the batch size are returned in a FailedChangesError, the other changes are
+// applied nevertheless.
+func (p *GoogleProvider) submitChange(change *dns.Change, recordEndpoints map[*dns.ResourceRecordSet]*endpoint.Endpoint) error {
 	if len(change.Additions) == 0 && len(change.Deletions) == 0 {
 		log.Info("All records are already up to date")
 		return nil
@@ -288,27 +321,115 @@ func (p *GoogleProvider) submitChange(change *dns.Change) error {
 	// separate into per-zone change sets to be passed to the API.
 	changes := separateChange(zones, change)
 
+	var failed []*endpoint.Endpoint
 	for z, c := range changes {
-		log.Infof("Change zone: %v", z)
-		for _, del := range c.Deletions {
-			log.Infof("Del records: %s %s %s %d", del.Name, del.Type, del.Rrdatas, del.Ttl)
+		batches, oversized := batchChange(c, p.batchChangeSize, recordEndpoints)
+		failed = append(failed, oversized...)
+
+		for i, b := range batches {
+			log.Infof("Change zone: %v batch #%d", z, i)
+			for _, del := range b.Deletions {
+				log.Infof("Del records: %s %s %s %d", del.Name, del.Type, del.Rrdatas, del.Ttl)
+			}
+			for _, add := range b.Additions {
+				log.Infof("Add records: %s %s %s %d", add.Name, add.Type, add.Rrdatas, add.Ttl)
+			}
+
+			if p.dryRun {
+				continue
+			}
+
+			if _, err := p.changesClient.Create(p.project, z, b).Do(); err != nil {
+				return err
+			}
+
+			if i != len(batches)-1 {
+				time.Sleep(p.batchChangeInterval)
+			}
 		}
-		for _, add := range c.Additions {
-			log.Infof("Add records: %s %s %s %d", add.Name, add.Type, add.Rrdatas, add.Ttl)
+	}
+
+	if len(failed) > 0 {
+		return &FailedChangesError{Endpoints: failed}
+	}
+
+	return nil
+}
+
+// batchChange splits a change of a single zone into changes of at most batchSize additions
+// and deletions. The additions and deletions of a record, including the TXT record holding
+// its ownership, are kept in the same change, so that an updated record is replaced atomically
+// and a record is never created or deleted without its TXT record. The endpoints of records
+// whose changes don't fit into a single batch are returned separately.
+func batchChange(change *dns.Change, batchSize int, recordEndpoints map[*dns.ResourceRecordSet]*endpoint.Endpoint) ([]*dns.Change, []*endpoint.Endpoint) {
+	if len(change.Additions)+len(change.Deletions) <= batchSize {
+		return []*dns.Change{change}, nil
+	}
+
+	changesByName := map[string]*dns.Change{}
+	for _, a := range change.Additions {
+		name := ownedRecordName(a, recordEndpoints)
+		if changesByName[name] == nil {
+			changesByName[name] = &dns.Change{}
 		}
+		changesByName[name].Additions = append(changesByName[name].Additions, a)
+	}
+	for _, d := range change.Deletions {
+		name := ownedRecordName(d, recordEndpoints)
+		if changesByName[name] == nil {
+			changesByName[name] = &dns.Change{}
+		}
+		changesByName[name].Deletions = append(changesByName[name].Deletions, d)
 	}
 
-	if p.dryRun {
-		return nil
+	names := make([]string, 0, len(changesByName))
+	for name := range changesByName {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	batches := []*dns.Change{}
+	oversized := []*endpoint.Endpoint{}
+	batch := &dns.Change{}
+	for _, name := range names {
+		c := changesByName[name]
+		size := len(c.Additions) + len(c.Deletions)
+
+		if size > batchSize {
+			log.Errorf("Total changes for %s exceeds max batch size of %d, total changes: %d", name, batchSize, size)
+			for _, r := range append(c.Additions, c.Deletions...) {
+				if ep, ok := recordEndpoints[r]; ok {
+					oversized = append(oversized, ep)
+				}
+			}
+			continue
+		}
 
-	for z, c := range changes {
-		if _, err := p.changesClient.Create(p.project, z, c).Do(); err != nil {
-			return err
+		if len(batch.Additions)+len(batch.Deletions)+size > batchSize {
+			batches = append(batches, batch)
+			batch = &dns.Change{}
 		}
+		batch.Additions = append(batch.Additions, c.Additions...)
+		batch.Deletions = append(batch.Deletions, c.Deletions...)
+	}
+	if len(batch.Additions)+len(batch.Deletions) > 0 {
+		batches = append(batches, batch)
 	}
 
-	return nil
+	return batches, oversized
+}
+
+// ownedRecordName returns the name of the record a RecordSet belongs to, a TXT record holding
+// the ownership of a record belongs to that record.
+func ownedRecordName(record *dns.ResourceRecordSet, recordEndpoints map[*dns.ResourceRecordSet]*endpoint.Endpoint) string {
+	ep, ok := recordEndpoints[record]
+	if !ok {
+		return strings.TrimSuffix(record.Name, ".")
+	}
+	if name, ok := ep.Labels[endpoint.OwnedRecordLabelKey]; ok {
+		return name
+	}
+	return ep.DNSName
 }
 
 // separateChange separates a multi-zone change into a single change per zone.
@@ -149,9 +149,13 @@ func (m *mockChangesCreateCall) Do(opts ...googleapi.CallOption) (*dns.Change, e
 	return m.change, nil
 }
 
-type mockChangesClient struct{}
+type mockChangesClient struct {
+	// the changes created so far
+	changes []*dns.Change
+}
 
 func (m *mockChangesClient) Create(project string, managedZone string, change *dns.Change) changesCreateCallInterface {
+	m.changes = append(m.changes, change)
 	return &mockChangesCreateCall{project: project, managedZone: managedZone, change: change}
 }
 
@@ -205,6 +209,41 @@ func TestGoogleZones(t *testing.T) {
 	})
 }
 
+func TestGoogleZonesVisibilityFilter(t *testing.T) {
+	provider := &GoogleProvider{
+		project:            "zalando-external-dns-test-visibility",
+		managedZonesClient: &mockManagedZonesClient{},
+	}
+	for _, zone := range []*dns.ManagedZone{
+		{Name: "public-example-org", DnsName: "example.org.", Visibility: googleZoneVisibilityPublic},
+		{Name: "private-example-org", DnsName: "example.org.", Visibility: googleZoneVisibilityPrivate},
+		{Name: "legacy-example-com", DnsName: "example.com."},
+	} {
+		_, err := provider.managedZonesClient.Create(provider.project, zone).Do()
+		require.NoError(t, err)
+	}
+
+	for _, tc := range []struct {
+		visibility string
+		expected   []string
+	}{
+		{"", []string{"public-example-org", "private-example-org", "legacy-example-com"}},
+		{googleZoneVisibilityPublic, []string{"public-example-org", "legacy-example-com"}},
+		{googleZoneVisibilityPrivate, []string{"private-example-org"}},
+	} {
+		provider.zoneVisibility = tc.visibility
+
+		zones, err := provider.Zones()
+		require.NoError(t, err)
+
+		names := []string{}
+		for name := range zones {
+			names = append(names, name)
+		}
+		assert.ElementsMatch(t, tc.expected, names, tc.visibility)
+	}
+}
+
 func TestGoogleRecords(t *testing.T) {
 	originalEndpoints := []*endpoint.Endpoint{
 		endpoint.NewEndpointWithTTL("list-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, endpoint.TTL(1), "1.2.3.4"),
@@ -402,6 +441,57 @@ func TestGoogleApplyChanges(t *testing.T) {
 	})
 }
 
+func TestGoogleApplyChangesBatched(t *testing.T) {
+	provider := newGoogleProvider(t, NewDomainFilter([]string{"ext-dns-test-2.gcp.zalan.do."}), NewZoneIDFilter([]string{""}), false, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("update-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.8.8"),
+	})
+	provider.batchChangeSize = 3
+	provider.batchChangeInterval = 0
+	changesClient := &mockChangesClient{}
+	provider.changesClient = changesClient
+
+	createRecords := []*endpoint.Endpoint{}
+	for i := 0; i < 4; i++ {
+		createRecords = append(createRecords, endpoint.NewEndpoint(fmt.Sprintf("create-test-%d.zone-1.ext-dns-test-2.gcp.zalan.do", i), endpoint.RecordTypeA, "8.8.8.8"))
+	}
+
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{
+		Create:    createRecords,
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("update-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, "8.8.8.8")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("update-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, "1.2.3.4")},
+	}))
+
+	// the deletion and addition of the updated record are part of the same change
+	require.Len(t, changesClient.changes, 2)
+	validateChange(t, changesClient.changes[0], &dns.Change{
+		Additions: []*dns.ResourceRecordSet{
+			{Name: "create-test-0.zone-1.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"8.8.8.8"}, Type: "A", Ttl: googleRecordTTL},
+			{Name: "create-test-1.zone-1.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"8.8.8.8"}, Type: "A", Ttl: googleRecordTTL},
+			{Name: "create-test-2.zone-1.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"8.8.8.8"}, Type: "A", Ttl: googleRecordTTL},
+		},
+	})
+	validateChange(t, changesClient.changes[1], &dns.Change{
+		Additions: []*dns.ResourceRecordSet{
+			{Name: "create-test-3.zone-1.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"8.8.8.8"}, Type: "A", Ttl: googleRecordTTL},
+			{Name: "update-test.zone-1.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"1.2.3.4"}, Type: "A", Ttl: googleRecordTTL},
+		},
+		Deletions: []*dns.ResourceRecordSet{
+			{Name: "update-test.zone-1.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"8.8.8.8"}, Type: "A", Ttl: googleRecordTTL},
+		},
+	})
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+
+	validateEndpoints(t, records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("create-test-0.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.8.8"),
+		endpoint.NewEndpointWithTTL("create-test-1.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.8.8"),
+		endpoint.NewEndpointWithTTL("create-test-2.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.8.8"),
+		endpoint.NewEndpointWithTTL("create-test-3.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.8.8"),
+		endpoint.NewEndpointWithTTL("update-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "1.2.3.4"),
+	})
+}
+
 func TestGoogleApplyChangesDryRun(t *testing.T) {
 	originalEndpoints := []*endpoint.Endpoint{
 		endpoint.NewEndpointWithTTL("update-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.8.8"),
@@ -460,6 +550,7 @@ func TestGoogleApplyChangesEmpty(t *testing.T) {
 func TestNewFilteredRecords(t *testing.T) {
 	provider := newGoogleProvider(t, NewDomainFilter([]string{"ext-dns-test-2.gcp.zalan.do."}), NewZoneIDFilter([]string{""}), false, []*endpoint.Endpoint{})
 
+	recordEndpoints := map[*dns.ResourceRecordSet]*endpoint.Endpoint{}
 	records := provider.newFilteredRecords([]*endpoint.Endpoint{
 		endpoint.NewEndpointWithTTL("update-test.zone-2.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, 1, "8.8.4.4"),
 		endpoint.NewEndpointWithTTL("delete-test.zone-2.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, 120, "8.8.4.4"),
@@ -468,7 +559,9 @@ func TestNewFilteredRecords(t *testing.T) {
 		endpoint.NewEndpointWithTTL("update-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, 0, "8.8.8.8"),
 		endpoint.NewEndpoint("delete-test.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, "8.8.8.8"),
 		endpoint.NewEndpoint("delete-test-cname.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeCNAME, "qux.elb.amazonaws.com"),
-	})
+	}, recordEndpoints)
+
+	assert.Len(t, recordEndpoints, 6)
 
 	validateChangeRecords(t, records, []*dns.ResourceRecordSet{
 		{Name: "update-test.zone-2.ext-dns-test-2.gcp.zalan.do.", Rrdatas: []string{"8.8.4.4"}, Type: "A", Ttl: 1},
@@ -529,6 +622,77 @@ func TestSeparateChanges(t *testing.T) {
 	})
 }
 
+func TestGoogleBatchChange(t *testing.T) {
+	d := endpoint.NewEndpoint("d.example.org", endpoint.RecordTypeA, "1.2.3.4")
+	txtD := endpoint.NewEndpoint("txt-d.example.org", endpoint.RecordTypeTXT, "\"heritage=external-dns\"")
+	txtD.Labels[endpoint.OwnedRecordLabelKey] = d.DNSName
+	recordD, recordTXTD := newRecord(d), newRecord(txtD)
+	recordEndpoints := map[*dns.ResourceRecordSet]*endpoint.Endpoint{recordD: d, recordTXTD: txtD}
+
+	change := &dns.Change{
+		Additions: []*dns.ResourceRecordSet{
+			{Name: "a.example.org.", Type: "A"},
+			{Name: "a.example.org.", Type: "TXT"},
+			{Name: "b.example.org.", Type: "A"},
+			{Name: "c.example.org.", Type: "A"},
+		},
+		Deletions: []*dns.ResourceRecordSet{
+			{Name: "b.example.org.", Type: "A"},
+			recordD,
+			recordTXTD,
+		},
+	}
+
+	// a change not exceeding the batch size is submitted as is
+	batches, oversized := batchChange(change, 7, recordEndpoints)
+	require.Equal(t, []*dns.Change{change}, batches)
+	assert.Empty(t, oversized)
+
+	batches, oversized = batchChange(change, 3, recordEndpoints)
+	assert.Empty(t, oversized)
+	require.Len(t, batches, 3)
+	validateChange(t, batches[0], &dns.Change{
+		Additions: []*dns.ResourceRecordSet{{Name: "a.example.org.", Type: "A"}, {Name: "a.example.org.", Type: "TXT"}},
+	})
+	validateChange(t, batches[1], &dns.Change{
+		Additions: []*dns.ResourceRecordSet{{Name: "b.example.org.", Type: "A"}, {Name: "c.example.org.", Type: "A"}},
+		Deletions: []*dns.ResourceRecordSet{{Name: "b.example.org.", Type: "A"}},
+	})
+	// the TXT record holding the ownership is deleted together with its record
+	validateChange(t, batches[2], &dns.Change{
+		Deletions: []*dns.ResourceRecordSet{recordD, recordTXTD},
+	})
+
+	// the changes of records exceeding the batch size are returned as failed
+	batches, oversized = batchChange(change, 1, recordEndpoints)
+	require.Len(t, batches, 1)
+	validateChange(t, batches[0], &dns.Change{Additions: []*dns.ResourceRecordSet{{Name: "c.example.org.", Type: "A"}}})
+	assert.Equal(t, []*endpoint.Endpoint{d, txtD}, oversized)
+}
+
+func TestGoogleApplyChangesExceedingBatchSize(t *testing.T) {
+	provider := newGoogleProvider(t, NewDomainFilter([]string{"ext-dns-test-2.gcp.zalan.do."}), NewZoneIDFilter([]string{""}), false, []*endpoint.Endpoint{})
+	provider.batchChangeSize = 1
+	provider.batchChangeInterval = 0
+
+	// a record and its TXT record don't fit into a single batch
+	large := endpoint.NewEndpoint("large.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, "8.8.8.8")
+	txt := endpoint.NewEndpoint("txt.large.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeTXT, "\"heritage=external-dns\"")
+	txt.Labels[endpoint.OwnedRecordLabelKey] = large.DNSName
+	small := endpoint.NewEndpoint("small.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, "8.8.4.4")
+
+	err := provider.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{large, txt, small}})
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Equal(t, []*endpoint.Endpoint{large, txt}, err.(*FailedChangesError).Endpoints)
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+
+	validateEndpoints(t, records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("small.zone-1.ext-dns-test-2.gcp.zalan.do", endpoint.RecordTypeA, googleRecordTTL, "8.8.4.4"),
+	})
+}
+
 func validateZones(t *testing.T, zones map[string]*dns.ManagedZone, expected map[string]*dns.ManagedZone) {
 	require.Len(t, zones, len(expected))
 
@@ -567,6 +731,8 @@ func newGoogleProvider(t *testing.T, domainFilter DomainFilter, zoneIDFilter Zon
 		project:                  "zalando-external-dns-test",
 		domainFilter:             domainFilter,
 		zoneIDFilter:             zoneIDFilter,
+		batchChangeSize:          defaultBatchChangeSize,
+		batchChangeInterval:      defaultBatchChangeInterval,
 		dryRun:                   false,
 		resourceRecordSetsClient: &mockResourceRecordSetsClient{},
 		managedZonesClient:       &mockManagedZonesClient{},
@@ -17,8 +17,15 @@ limitations under the License.
 package provider
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 
@@ -45,10 +52,15 @@ type InMemoryProvider struct {
 	domain         DomainFilter
 	client         *inMemoryClient
 	filter         *filter
+	stateFile      string
 	OnApplyChanges func(changes *plan.Changes)
 	OnRecords      func()
 }
 
+// InMemorySnapshot is a copy of the records of an InMemoryProvider by zone. It is also the
+// format of the state file of the provider.
+type InMemorySnapshot map[string][]*endpoint.Endpoint
+
 // InMemoryOption allows to extend in-memory provider
 type InMemoryOption func(*InMemoryProvider)
 
@@ -90,6 +102,14 @@ func InMemoryInitZones(zones []string) InMemoryOption {
 	}
 }
 
+// InMemoryWithStateFile persists the records in the given JSON file after every change.
+// LoadState restores the records from it.
+func InMemoryWithStateFile(stateFile string) InMemoryOption {
+	return func(p *InMemoryProvider) {
+		p.stateFile = stateFile
+	}
+}
+
 // NewInMemoryProvider returns InMemoryProvider DNS provider interface implementation
 func NewInMemoryProvider(opts ...InMemoryOption) *InMemoryProvider {
 	im := &InMemoryProvider{
@@ -109,7 +129,93 @@ func NewInMemoryProvider(opts ...InMemoryOption) *InMemoryProvider {
 
 // CreateZone adds new zone if not present
 func (im *InMemoryProvider) CreateZone(newZone string) error {
-	return im.client.CreateZone(newZone)
+	if err := im.client.CreateZone(newZone); err != nil {
+		return err
+	}
+	return im.saveState()
+}
+
+// Snapshot returns a copy of the records of all zones
+func (im *InMemoryProvider) Snapshot() InMemorySnapshot {
+	snapshot := InMemorySnapshot{}
+	for zoneID, records := range im.client.Snapshot() {
+		endpoints := make([]*endpoint.Endpoint, 0, len(records))
+		for _, record := range records {
+			endpoints = append(endpoints, record.endpoint())
+		}
+		snapshot[zoneID] = endpoints
+	}
+	return snapshot
+}
+
+// Restore replaces all zones and their records with the ones of the snapshot
+func (im *InMemoryProvider) Restore(snapshot InMemorySnapshot) error {
+	zones := map[string]zone{}
+	for zoneID, endpoints := range snapshot {
+		zones[zoneID] = zone{}
+		for _, record := range convertToInMemoryRecord(endpoints) {
+			zones[zoneID][record.Name] = append(zones[zoneID][record.Name], record)
+		}
+	}
+	im.client.Restore(zones)
+
+	return im.saveState()
+}
+
+// LoadState restores the zones of the state file on top of the existing ones, the zones
+// are kept as they are if the state file doesn't exist yet
+func (im *InMemoryProvider) LoadState() error {
+	if im.stateFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(im.stateFile)
+	if os.IsNotExist(err) {
+		log.Infof("State file %s doesn't exist yet, starting with empty zones", im.stateFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state InMemorySnapshot
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %v", im.stateFile, err)
+	}
+
+	snapshot := im.Snapshot()
+	for zoneID, endpoints := range state {
+		snapshot[zoneID] = endpoints
+	}
+	return im.Restore(snapshot)
+}
+
+// saveState writes the records to the state file if there is one. The file is replaced
+// atomically, so that it is never partially written.
+func (im *InMemoryProvider) saveState() error {
+	if im.stateFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(im.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(im.stateFile), filepath.Base(im.stateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), im.stateFile)
 }
 
 // Zones returns filtered zones as specified by domain
@@ -130,7 +236,7 @@ func (im *InMemoryProvider) Records() ([]*endpoint.Endpoint, error) {
 		}
 
 		for _, record := range records {
-			endpoints = append(endpoints, endpoint.NewEndpoint(record.Name, record.Type, record.Target))
+			endpoints = append(endpoints, record.endpoint())
 		}
 	}
 
@@ -194,21 +300,55 @@ func (im *InMemoryProvider) ApplyChanges(changes *plan.Changes) error {
 		}
 	}
 
-	return nil
+	return im.saveState()
 }
 
 func convertToInMemoryRecord(endpoints []*endpoint.Endpoint) []*inMemoryRecord {
 	records := []*inMemoryRecord{}
 	for _, ep := range endpoints {
 		records = append(records, &inMemoryRecord{
-			Type:   ep.RecordType,
-			Name:   ep.DNSName,
-			Target: ep.Targets[0],
+			Type:             ep.RecordType,
+			Name:             ep.DNSName,
+			Targets:          copyTargets(ep.Targets),
+			TTL:              ep.RecordTTL,
+			SetIdentifier:    ep.SetIdentifier,
+			Labels:           copyLabels(ep.Labels),
+			ProviderSpecific: copyProviderSpecific(ep.ProviderSpecific),
 		})
 	}
 	return records
 }
 
+func copyTargets(targets endpoint.Targets) endpoint.Targets {
+	if targets == nil {
+		return nil
+	}
+	return append(endpoint.Targets{}, targets...)
+}
+
+func copyProviderSpecific(providerSpecific endpoint.ProviderSpecific) endpoint.ProviderSpecific {
+	if providerSpecific == nil {
+		return nil
+	}
+	result := endpoint.ProviderSpecific{}
+	for k, v := range providerSpecific {
+		result[k] = v
+	}
+	return result
+}
+
+// copyLabels returns a copy of the labels, nil if there are none
+func copyLabels(labels endpoint.Labels) endpoint.Labels {
+	if len(labels) == 0 {
+		return nil
+	}
+	result := endpoint.Labels{}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
 type filter struct {
 	domain string
 }
@@ -240,11 +380,54 @@ func (f *filter) EndpointZoneID(endpoint *endpoint.Endpoint, zones map[string]st
 // inMemoryRecord - record stored in memory
 // Type - type of record
 // Name - DNS name assigned to the record
-// Target - target of the record
+// Targets - targets of the record
+// TTL - TTL of the record, not configured if 0
+// SetIdentifier - tells records with the same name and type apart
+// Labels - labels of the record
+// ProviderSpecific - provider specific configuration of the record
 type inMemoryRecord struct {
-	Type   string
-	Name   string
-	Target string
+	Type             string
+	Name             string
+	Targets          endpoint.Targets
+	TTL              endpoint.TTL
+	SetIdentifier    string
+	Labels           endpoint.Labels
+	ProviderSpecific endpoint.ProviderSpecific
+}
+
+// inMemoryRecordKey identifies a record among the records of its name
+type inMemoryRecordKey struct {
+	Type          string
+	SetIdentifier string
+}
+
+// copy returns a deep copy of the record
+func (r *inMemoryRecord) copy() *inMemoryRecord {
+	return &inMemoryRecord{
+		Type:             r.Type,
+		Name:             r.Name,
+		Targets:          copyTargets(r.Targets),
+		TTL:              r.TTL,
+		SetIdentifier:    r.SetIdentifier,
+		Labels:           copyLabels(r.Labels),
+		ProviderSpecific: copyProviderSpecific(r.ProviderSpecific),
+	}
+}
+
+// key returns the key of the record among the records of its name
+func (r *inMemoryRecord) key() inMemoryRecordKey {
+	return inMemoryRecordKey{Type: r.Type, SetIdentifier: r.SetIdentifier}
+}
+
+// endpoint returns the endpoint of the record
+func (r *inMemoryRecord) endpoint() *endpoint.Endpoint {
+	ep := endpoint.NewEndpointWithTTL(r.Name, r.Type, r.TTL, r.Targets...)
+	ep.SetIdentifier = r.SetIdentifier
+	for k, v := range r.Labels {
+		ep.Labels[k] = v
+	}
+	ep.ProviderSpecific = copyProviderSpecific(r.ProviderSpecific)
+	return ep
 }
 
 type zone map[string][]*inMemoryRecord
@@ -257,26 +440,84 @@ type inMemoryChange struct {
 }
 
 type inMemoryClient struct {
+	sync.RWMutex
 	zones map[string]zone
+	// serials are the serial numbers of the zones, increased on every change of a zone
+	serials map[string]uint32
 }
 
 func newInMemoryClient() *inMemoryClient {
-	return &inMemoryClient{map[string]zone{}}
+	return &inMemoryClient{zones: map[string]zone{}, serials: map[string]uint32{}}
 }
 
 func (c *inMemoryClient) Records(zone string) ([]*inMemoryRecord, error) {
+	c.RLock()
+	defer c.RUnlock()
+
 	if _, ok := c.zones[zone]; !ok {
 		return nil, ErrZoneNotFound
 	}
 
+	return c.records(zone), nil
+}
+
+// records returns copies of the records of the zone sorted by name, type and set identifier
+func (c *inMemoryClient) records(zone string) []*inMemoryRecord {
 	records := []*inMemoryRecord{}
 	for _, rec := range c.zones[zone] {
-		records = append(records, rec...)
+		for _, r := range rec {
+			records = append(records, r.copy())
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		if records[i].Type != records[j].Type {
+			return records[i].Type < records[j].Type
+		}
+		return records[i].SetIdentifier < records[j].SetIdentifier
+	})
+	return records
+}
+
+// Snapshot returns copies of the records of all zones
+func (c *inMemoryClient) Snapshot() map[string][]*inMemoryRecord {
+	c.RLock()
+	defer c.RUnlock()
+
+	snapshot := map[string][]*inMemoryRecord{}
+	for zone := range c.zones {
+		snapshot[zone] = c.records(zone)
 	}
-	return records, nil
+	return snapshot
+}
+
+// Restore replaces all zones with the given ones
+func (c *inMemoryClient) Restore(zones map[string]zone) {
+	c.Lock()
+	defer c.Unlock()
+
+	serials := map[string]uint32{}
+	for zone := range zones {
+		serials[zone] = c.serials[zone] + 1
+	}
+	c.zones = zones
+	c.serials = serials
+}
+
+// Serial returns the serial number of the current state of the zone
+func (c *inMemoryClient) Serial(zone string) uint32 {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.serials[zone]
 }
 
 func (c *inMemoryClient) Zones() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+
 	zones := map[string]string{}
 	for zone := range c.zones {
 		zones[zone] = zone
@@ -285,15 +526,22 @@ func (c *inMemoryClient) Zones() map[string]string {
 }
 
 func (c *inMemoryClient) CreateZone(zone string) error {
+	c.Lock()
+	defer c.Unlock()
+
 	if _, ok := c.zones[zone]; ok {
 		return ErrZoneAlreadyExists
 	}
 	c.zones[zone] = map[string][]*inMemoryRecord{}
+	c.serials[zone] = 1
 
 	return nil
 }
 
 func (c *inMemoryClient) ApplyChanges(zoneID string, changes *inMemoryChange) error {
+	c.Lock()
+	defer c.Unlock()
+
 	if err := c.validateChangeBatch(zoneID, changes); err != nil {
 		return err
 	}
@@ -305,8 +553,11 @@ func (c *inMemoryClient) ApplyChanges(zoneID string, changes *inMemoryChange) er
 	}
 	for _, updateEndpoint := range changes.UpdateNew {
 		for _, rec := range c.zones[zoneID][updateEndpoint.Name] {
-			if rec.Type == updateEndpoint.Type {
-				rec.Target = updateEndpoint.Target
+			if rec.key() == updateEndpoint.key() {
+				rec.Targets = updateEndpoint.Targets
+				rec.TTL = updateEndpoint.TTL
+				rec.Labels = updateEndpoint.Labels
+				rec.ProviderSpecific = updateEndpoint.ProviderSpecific
 				break
 			}
 		}
@@ -314,24 +565,27 @@ func (c *inMemoryClient) ApplyChanges(zoneID string, changes *inMemoryChange) er
 	for _, deleteEndpoint := range changes.Delete {
 		newSet := make([]*inMemoryRecord, 0)
 		for _, rec := range c.zones[zoneID][deleteEndpoint.Name] {
-			if rec.Type != deleteEndpoint.Type {
+			if rec.key() != deleteEndpoint.key() {
 				newSet = append(newSet, rec)
 			}
 		}
 		c.zones[zoneID][deleteEndpoint.Name] = newSet
 	}
+	if len(changes.Create)+len(changes.UpdateNew)+len(changes.Delete) > 0 {
+		c.serials[zoneID]++
+	}
 	return nil
 }
 
-func (c *inMemoryClient) updateMesh(mesh map[string]map[string]bool, record *inMemoryRecord) error {
+func (c *inMemoryClient) updateMesh(mesh map[string]map[inMemoryRecordKey]bool, record *inMemoryRecord) error {
 	if _, exists := mesh[record.Name]; exists {
-		if mesh[record.Name][record.Type] {
+		if mesh[record.Name][record.key()] {
 			return ErrDuplicateRecordFound
 		}
-		mesh[record.Name][record.Type] = true
+		mesh[record.Name][record.key()] = true
 		return nil
 	}
-	mesh[record.Name] = map[string]bool{record.Type: true}
+	mesh[record.Name] = map[inMemoryRecordKey]bool{record.key(): true}
 	return nil
 }
 
@@ -341,9 +595,9 @@ func (c *inMemoryClient) validateChangeBatch(zone string, changes *inMemoryChang
 	if !ok {
 		return ErrZoneNotFound
 	}
-	mesh := map[string]map[string]bool{}
+	mesh := map[string]map[inMemoryRecordKey]bool{}
 	for _, newEndpoint := range changes.Create {
-		if c.findByType(newEndpoint.Type, curZone[newEndpoint.Name]) != nil {
+		if c.findByType(newEndpoint.Type, newEndpoint.SetIdentifier, curZone[newEndpoint.Name]) != nil {
 			return ErrRecordAlreadyExists
 		}
 		if err := c.updateMesh(mesh, newEndpoint); err != nil {
@@ -351,7 +605,7 @@ func (c *inMemoryClient) validateChangeBatch(zone string, changes *inMemoryChang
 		}
 	}
 	for _, updateEndpoint := range changes.UpdateNew {
-		if c.findByType(updateEndpoint.Type, curZone[updateEndpoint.Name]) == nil {
+		if c.findByType(updateEndpoint.Type, updateEndpoint.SetIdentifier, curZone[updateEndpoint.Name]) == nil {
 			return ErrRecordNotFound
 		}
 		if err := c.updateMesh(mesh, updateEndpoint); err != nil {
@@ -359,12 +613,12 @@ func (c *inMemoryClient) validateChangeBatch(zone string, changes *inMemoryChang
 		}
 	}
 	for _, updateOldEndpoint := range changes.UpdateOld {
-		if rec := c.findByType(updateOldEndpoint.Type, curZone[updateOldEndpoint.Name]); rec == nil || rec.Target != updateOldEndpoint.Target {
+		if rec := c.findByType(updateOldEndpoint.Type, updateOldEndpoint.SetIdentifier, curZone[updateOldEndpoint.Name]); rec == nil || !rec.Targets.Same(updateOldEndpoint.Targets) {
 			return ErrRecordNotFound
 		}
 	}
 	for _, deleteEndpoint := range changes.Delete {
-		if rec := c.findByType(deleteEndpoint.Type, curZone[deleteEndpoint.Name]); rec == nil || rec.Target != deleteEndpoint.Target {
+		if rec := c.findByType(deleteEndpoint.Type, deleteEndpoint.SetIdentifier, curZone[deleteEndpoint.Name]); rec == nil || !rec.Targets.Same(deleteEndpoint.Targets) {
 			return ErrRecordNotFound
 		}
 		if err := c.updateMesh(mesh, deleteEndpoint); err != nil {
@@ -374,9 +628,10 @@ func (c *inMemoryClient) validateChangeBatch(zone string, changes *inMemoryChang
 	return nil
 }
 
-func (c *inMemoryClient) findByType(recordType string, records []*inMemoryRecord) *inMemoryRecord {
+// findByType returns the record of the given type and set identifier
+func (c *inMemoryClient) findByType(recordType, setIdentifier string, records []*inMemoryRecord) *inMemoryRecord {
 	for _, record := range records {
-		if record.Type == recordType {
+		if record.Type == recordType && record.SetIdentifier == setIdentifier {
 			return record
 		}
 	}
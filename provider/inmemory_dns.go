@@ -0,0 +1,344 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+)
+
+const (
+	// inMemoryDNSDefaultTTL is the TTL of records without a configured TTL
+	inMemoryDNSDefaultTTL = 300
+	// inMemoryDNSTransferChunkSize is the number of records sent in a single message of a zone transfer
+	inMemoryDNSTransferChunkSize = 100
+	// inMemoryDNSMaxCNAMEChain is the maximum number of CNAME records followed to answer a query
+	inMemoryDNSMaxCNAMEChain = 8
+)
+
+// InMemoryDNSServer is an authoritative DNS server answering queries for the zones of an
+// InMemoryProvider over UDP and TCP, e.g. to resolve the records managed by ExternalDNS in
+// end-to-end tests.
+type InMemoryDNSServer struct {
+	provider *InMemoryProvider
+	address  string
+	udp      *dns.Server
+	tcp      *dns.Server
+}
+
+// NewInMemoryDNSServer returns a server for the zones of the provider listening on the given address
+func NewInMemoryDNSServer(provider *InMemoryProvider, address string) *InMemoryDNSServer {
+	return &InMemoryDNSServer{
+		provider: provider,
+		address:  address,
+	}
+}
+
+// Start listens on the address of the server and serves queries in the background until Shutdown
+// is called. UDP and TCP share the same port, also if the address doesn't specify one.
+func (s *InMemoryDNSServer) Start() error {
+	pc, err := net.ListenPacket("udp", s.address)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return err
+	}
+
+	s.udp = &dns.Server{PacketConn: pc, Handler: s}
+	s.tcp = &dns.Server{Listener: l, Handler: s}
+	for _, server := range []*dns.Server{s.udp, s.tcp} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go func(server *dns.Server) {
+			if err := server.ActivateAndServe(); err != nil {
+				log.Errorf("Failed to serve DNS queries: %v", err)
+			}
+		}(server)
+		<-started
+	}
+
+	log.Infof("Serving the in-memory zones over DNS on %s", s.Addr())
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *InMemoryDNSServer) Addr() string {
+	return s.udp.PacketConn.LocalAddr().String()
+}
+
+// Shutdown stops the server
+func (s *InMemoryDNSServer) Shutdown() error {
+	if err := s.udp.Shutdown(); err != nil {
+		return err
+	}
+	return s.tcp.Shutdown()
+}
+
+// ServeDNS answers a query for the records of the zones
+func (s *InMemoryDNSServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+
+	if req.Opcode != dns.OpcodeQuery || len(req.Question) != 1 {
+		resp.SetRcode(req, dns.RcodeNotImplemented)
+		w.WriteMsg(resp)
+		return
+	}
+
+	q := req.Question[0]
+	zoneName, records, serial := s.zone(q.Name)
+	if zoneName == "" {
+		resp.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(resp)
+		return
+	}
+	soa := inMemorySOA(zoneName, serial)
+
+	if q.Qtype == dns.TypeAXFR || q.Qtype == dns.TypeIXFR {
+		s.transfer(w, req, zoneName, soa, records)
+		return
+	}
+
+	resp.Authoritative = true
+	resp.Answer, resp.Rcode = inMemoryAnswer(q, zoneName, soa, records)
+	if len(resp.Answer) == 0 {
+		resp.Ns = []dns.RR{soa}
+	}
+	w.WriteMsg(resp)
+}
+
+// zone returns the name, the records by name and the serial of the zone with the longest name
+// matching the given name
+func (s *InMemoryDNSServer) zone(name string) (string, map[string][]dns.RR, uint32) {
+	var zoneID, zoneName string
+	for id, n := range s.provider.Zones() {
+		n = strings.ToLower(dns.Fqdn(n))
+		if dns.IsSubDomain(n, strings.ToLower(name)) && len(n) > len(zoneName) {
+			zoneID, zoneName = id, n
+		}
+	}
+	if zoneName == "" {
+		return "", nil, 0
+	}
+
+	serial := s.provider.client.Serial(zoneID)
+	zoneRecords, err := s.provider.client.Records(zoneID)
+	if err != nil {
+		return "", nil, 0
+	}
+
+	records := map[string][]dns.RR{}
+	for _, record := range zoneRecords {
+		name := strings.ToLower(dns.Fqdn(record.Name))
+		rrs, err := record.rrs(name)
+		if err != nil {
+			log.Debugf("Skipping record %s %s which can't be served over DNS: %v", record.Name, record.Type, err)
+			continue
+		}
+		records[name] = append(records[name], rrs...)
+	}
+	return zoneName, records, serial
+}
+
+// transfer sends all records of the zone between two SOA records, only over TCP
+func (s *InMemoryDNSServer) transfer(w dns.ResponseWriter, req *dns.Msg, zoneName string, soa dns.RR, records map[string][]dns.RR) {
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); !ok || !strings.EqualFold(req.Question[0].Name, zoneName) {
+		resp := new(dns.Msg)
+		resp.SetRcode(req, dns.RcodeRefused)
+		w.WriteMsg(resp)
+		return
+	}
+
+	rrs := []dns.RR{soa}
+	for _, rr := range records {
+		rrs = append(rrs, rr...)
+	}
+	rrs = append(rrs, soa)
+
+	for len(rrs) > 0 {
+		chunk := rrs
+		if len(chunk) > inMemoryDNSTransferChunkSize {
+			chunk = chunk[:inMemoryDNSTransferChunkSize]
+		}
+		rrs = rrs[len(chunk):]
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Authoritative = true
+		resp.Answer = chunk
+		if err := w.WriteMsg(resp); err != nil {
+			log.Errorf("Failed to transfer zone %s: %v", zoneName, err)
+			return
+		}
+	}
+}
+
+// inMemoryAnswer returns the records answering the question and the response code. CNAME records
+// are followed within the zone, wildcard records are expanded to the name of the question.
+func inMemoryAnswer(q dns.Question, zoneName string, soa dns.RR, records map[string][]dns.RR) ([]dns.RR, int) {
+	var result []dns.RR
+	name := strings.ToLower(q.Name)
+
+	for i := 0; i < inMemoryDNSMaxCNAMEChain; i++ {
+		if name == zoneName && q.Qtype == dns.TypeSOA {
+			return append(result, soa), dns.RcodeSuccess
+		}
+
+		rrs, ok := inMemoryLookup(name, zoneName, records)
+		if !ok {
+			return result, dns.RcodeNameError
+		}
+
+		var matched []dns.RR
+		var cname *dns.CNAME
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == q.Qtype || q.Qtype == dns.TypeANY {
+				matched = append(matched, rr)
+			} else if rr.Header().Rrtype == dns.TypeCNAME {
+				cname = rr.(*dns.CNAME)
+			}
+		}
+		if len(matched) > 0 || cname == nil {
+			return append(result, matched...), dns.RcodeSuccess
+		}
+
+		// the target of the CNAME record is answered as well if it is part of the zone
+		result = append(result, cname)
+		name = strings.ToLower(cname.Target)
+		if !dns.IsSubDomain(zoneName, name) {
+			break
+		}
+	}
+
+	return result, dns.RcodeSuccess
+}
+
+// inMemoryLookup returns the records of the given name, synthesized from a wildcard record if
+// there are none. It returns false if the name doesn't exist.
+func inMemoryLookup(name, zoneName string, records map[string][]dns.RR) ([]dns.RR, bool) {
+	if rrs, ok := records[name]; ok {
+		return rrs, true
+	}
+	if name == zoneName || inMemoryNameExists(name, records) {
+		return nil, true
+	}
+
+	// the wildcard of the closest existing ancestor of the name applies
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		ancestor := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !dns.IsSubDomain(zoneName, ancestor) {
+			break
+		}
+		if rrs, ok := records["*."+ancestor]; ok {
+			var synthesized []dns.RR
+			for _, rr := range rrs {
+				rr = dns.Copy(rr)
+				rr.Header().Name = name
+				synthesized = append(synthesized, rr)
+			}
+			return synthesized, true
+		}
+		if ancestor == zoneName || inMemoryNameExists(ancestor, records) {
+			break
+		}
+	}
+
+	return nil, false
+}
+
+// inMemoryNameExists returns true if there are records of the name or of any of its subdomains
+func inMemoryNameExists(name string, records map[string][]dns.RR) bool {
+	for n := range records {
+		if dns.IsSubDomain(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// inMemorySOA returns the synthesized SOA record of a zone
+func inMemorySOA(zoneName string, serial uint32) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zoneName, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: inMemoryDNSDefaultTTL},
+		Ns:      "ns." + zoneName,
+		Mbox:    "hostmaster." + zoneName,
+		Serial:  serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  inMemoryDNSDefaultTTL,
+	}
+}
+
+// rrs returns the DNS records of the record, one per target
+func (r *inMemoryRecord) rrs(name string) ([]dns.RR, error) {
+	ttl := uint32(inMemoryDNSDefaultTTL)
+	if r.TTL.IsConfigured() {
+		ttl = uint32(r.TTL)
+	}
+	return targetRRs(name, r.Type, ttl, r.Targets)
+}
+
+// targetRRs returns the DNS records of the given name, type and TTL, one per target
+func targetRRs(name, recordType string, ttl uint32, targets endpoint.Targets) ([]dns.RR, error) {
+	hdr := dns.RR_Header{Name: name, Class: dns.ClassINET, Ttl: ttl}
+
+	var rrs []dns.RR
+	for _, target := range targets {
+		switch recordType {
+		case endpoint.RecordTypeTXT:
+			hdr.Rrtype = dns.TypeTXT
+			rrs = append(rrs, &dns.TXT{Hdr: hdr, Txt: splitTXT(strings.TrimSuffix(strings.TrimPrefix(target, `"`), `"`))})
+		case endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeSRV, endpoint.RecordTypeMX:
+			if recordType != endpoint.RecordTypeA && recordType != endpoint.RecordTypeAAAA {
+				// host names are stored without the trailing dot, it's always the last field of a target
+				target = dns.Fqdn(target)
+			}
+			rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", name, ttl, recordType, target))
+			if err != nil {
+				return nil, err
+			}
+			if rr == nil {
+				return nil, fmt.Errorf("empty %s target", recordType)
+			}
+			rrs = append(rrs, rr)
+		default:
+			return nil, fmt.Errorf("unsupported record type %s", recordType)
+		}
+	}
+	return rrs, nil
+}
+
+// splitTXT splits the text of a TXT record into strings of at most 255 characters
+func splitTXT(text string) []string {
+	var txt []string
+	for len(text) > 255 {
+		txt = append(txt, text[:255])
+		text = text[255:]
+	}
+	return append(txt, text)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+func newInMemoryDNSTestServer(t *testing.T) (*InMemoryProvider, *InMemoryDNSServer) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org", "sub.example.org", "example.com"}))
+	require.NoError(t, im.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 60, "1.2.3.4", "1.2.3.5"),
+			endpoint.NewEndpoint("example.org", endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`),
+			endpoint.NewEndpoint("v6.example.org", endpoint.RecordTypeAAAA, "2001:db8::1"),
+			endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeCNAME, "example.org"),
+			endpoint.NewEndpoint("ext.example.org", endpoint.RecordTypeCNAME, "example.net"),
+			endpoint.NewEndpoint("_http._tcp.example.org", endpoint.RecordTypeSRV, "10 5 8080 www.example.org"),
+			endpoint.NewEndpoint("*.apps.example.org", endpoint.RecordTypeA, "1.2.3.6"),
+			endpoint.NewEndpoint("foo.apps.example.org", endpoint.RecordTypeA, "1.2.3.7"),
+			endpoint.NewEndpoint("foo.sub.example.org", endpoint.RecordTypeA, "1.2.3.8"),
+		},
+	}))
+
+	server := NewInMemoryDNSServer(im, "127.0.0.1:0")
+	require.NoError(t, server.Start())
+	return im, server
+}
+
+func TestInMemoryDNSServerQuery(t *testing.T) {
+	_, server := newInMemoryDNSTestServer(t)
+	defer server.Shutdown()
+
+	for _, ti := range []struct {
+		title    string
+		name     string
+		qtype    uint16
+		rcode    int
+		expected []string
+	}{
+		{"multiple targets with TTL", "example.org.", dns.TypeA, dns.RcodeSuccess, []string{
+			"example.org.\t60\tIN\tA\t1.2.3.4",
+			"example.org.\t60\tIN\tA\t1.2.3.5",
+		}},
+		{"TXT record", "example.org.", dns.TypeTXT, dns.RcodeSuccess, []string{
+			"example.org.\t300\tIN\tTXT\t\"heritage=external-dns,external-dns/owner=default\"",
+		}},
+		{"case insensitive", "V6.Example.ORG.", dns.TypeAAAA, dns.RcodeSuccess, []string{
+			"v6.example.org.\t300\tIN\tAAAA\t2001:db8::1",
+		}},
+		{"CNAME record within the zone", "www.example.org.", dns.TypeA, dns.RcodeSuccess, []string{
+			"www.example.org.\t300\tIN\tCNAME\texample.org.",
+			"example.org.\t60\tIN\tA\t1.2.3.4",
+			"example.org.\t60\tIN\tA\t1.2.3.5",
+		}},
+		{"CNAME record outside the zone", "ext.example.org.", dns.TypeA, dns.RcodeSuccess, []string{
+			"ext.example.org.\t300\tIN\tCNAME\texample.net.",
+		}},
+		{"CNAME query", "www.example.org.", dns.TypeCNAME, dns.RcodeSuccess, []string{
+			"www.example.org.\t300\tIN\tCNAME\texample.org.",
+		}},
+		{"SRV record", "_http._tcp.example.org.", dns.TypeSRV, dns.RcodeSuccess, []string{
+			"_http._tcp.example.org.\t300\tIN\tSRV\t10 5 8080 www.example.org.",
+		}},
+		{"wildcard record", "bar.apps.example.org.", dns.TypeA, dns.RcodeSuccess, []string{
+			"bar.apps.example.org.\t300\tIN\tA\t1.2.3.6",
+		}},
+		{"record next to wildcard record", "foo.apps.example.org.", dns.TypeA, dns.RcodeSuccess, []string{
+			"foo.apps.example.org.\t300\tIN\tA\t1.2.3.7",
+		}},
+		{"record of the longest matching zone", "foo.sub.example.org.", dns.TypeA, dns.RcodeSuccess, []string{
+			"foo.sub.example.org.\t300\tIN\tA\t1.2.3.8",
+		}},
+		{"SOA record", "example.com.", dns.TypeSOA, dns.RcodeSuccess, []string{
+			"example.com.\t300\tIN\tSOA\tns.example.com. hostmaster.example.com. 1 3600 600 86400 300",
+		}},
+		{"missing type", "v6.example.org.", dns.TypeA, dns.RcodeSuccess, nil},
+		{"empty non-terminal", "apps.example.org.", dns.TypeA, dns.RcodeSuccess, nil},
+		{"missing name", "missing.example.org.", dns.TypeA, dns.RcodeNameError, nil},
+		{"name outside the zones", "example.net.", dns.TypeA, dns.RcodeRefused, nil},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion(ti.name, ti.qtype)
+			resp, err := dns.Exchange(m, server.Addr())
+			require.NoError(t, err)
+
+			assert.Equal(t, ti.rcode, resp.Rcode)
+			var answers []string
+			for _, rr := range resp.Answer {
+				answers = append(answers, rr.String())
+			}
+			assert.Equal(t, ti.expected, answers)
+			if ti.rcode != dns.RcodeRefused {
+				assert.True(t, resp.Authoritative)
+			}
+			if len(ti.expected) == 0 && ti.rcode != dns.RcodeRefused {
+				require.Len(t, resp.Ns, 1)
+				assert.Equal(t, dns.TypeSOA, resp.Ns[0].Header().Rrtype)
+			}
+		})
+	}
+}
+
+func TestInMemoryDNSServerTransfer(t *testing.T) {
+	im, server := newInMemoryDNSTestServer(t)
+	defer server.Shutdown()
+
+	m := new(dns.Msg)
+	m.SetAxfr("sub.example.org.")
+	env, err := new(dns.Transfer).In(m, server.Addr())
+	require.NoError(t, err)
+
+	var records []string
+	for e := range env {
+		require.NoError(t, e.Error)
+		for _, rr := range e.RR {
+			records = append(records, rr.String())
+		}
+	}
+	assert.Equal(t, []string{
+		"sub.example.org.\t300\tIN\tSOA\tns.sub.example.org. hostmaster.sub.example.org. 2 3600 600 86400 300",
+		"foo.sub.example.org.\t300\tIN\tA\t1.2.3.8",
+		"sub.example.org.\t300\tIN\tSOA\tns.sub.example.org. hostmaster.sub.example.org. 2 3600 600 86400 300",
+	}, records)
+
+	// zone transfers aren't answered over UDP
+	m = new(dns.Msg)
+	m.SetAxfr("example.org.")
+	resp, err := dns.Exchange(m, server.Addr())
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+
+	// changes are served right away with a new serial
+	require.NoError(t, im.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.sub.example.org", endpoint.RecordTypeA, "1.2.3.9")},
+	}))
+
+	m = new(dns.Msg)
+	m.SetAxfr("sub.example.org.")
+	env, err = new(dns.Transfer).In(m, server.Addr())
+	require.NoError(t, err)
+
+	records = nil
+	for e := range env {
+		require.NoError(t, e.Error)
+		for _, rr := range e.RR {
+			records = append(records, rr.String())
+		}
+	}
+	sort.Strings(records[1 : len(records)-1])
+	assert.Equal(t, []string{
+		"sub.example.org.\t300\tIN\tSOA\tns.sub.example.org. hostmaster.sub.example.org. 3 3600 600 86400 300",
+		"bar.sub.example.org.\t300\tIN\tA\t1.2.3.9",
+		"foo.sub.example.org.\t300\tIN\tA\t1.2.3.8",
+		"sub.example.org.\t300\tIN\tSOA\tns.sub.example.org. hostmaster.sub.example.org. 3 3600 600 86400 300",
+	}, records)
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package provider
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
@@ -38,12 +41,17 @@ func TestInMemoryProvider(t *testing.T) {
 	t.Run("ApplyChanges", testInMemoryApplyChanges)
 	t.Run("NewInMemoryProvider", testNewInMemoryProvider)
 	t.Run("CreateZone", testInMemoryCreateZone)
+	t.Run("EndpointData", testInMemoryEndpointData)
+	t.Run("SetIdentifier", testInMemorySetIdentifier)
+	t.Run("SnapshotRestore", testInMemorySnapshotRestore)
+	t.Run("StateFile", testInMemoryStateFile)
 }
 
 func testInMemoryFindByType(t *testing.T) {
 	for _, ti := range []struct {
 		title         string
 		findType      string
+		findSetID     string
 		records       []*inMemoryRecord
 		expected      *inMemoryRecord
 		expectedEmpty bool
@@ -111,10 +119,41 @@ func testInMemoryFindByType(t *testing.T) {
 				Type: endpoint.RecordTypeA,
 			},
 		},
+		{
+			title:     "multiple records, right type and set identifier",
+			findType:  endpoint.RecordTypeA,
+			findSetID: "eu",
+			records: []*inMemoryRecord{
+				{
+					Type:          endpoint.RecordTypeA,
+					SetIdentifier: "us",
+				},
+				{
+					Type:          endpoint.RecordTypeA,
+					SetIdentifier: "eu",
+				},
+			},
+			expected: &inMemoryRecord{
+				Type:          endpoint.RecordTypeA,
+				SetIdentifier: "eu",
+			},
+		},
+		{
+			title:    "one record, wrong set identifier",
+			findType: endpoint.RecordTypeA,
+			records: []*inMemoryRecord{
+				{
+					Type:          endpoint.RecordTypeA,
+					SetIdentifier: "eu",
+				},
+			},
+			expected:      nil,
+			expectedEmpty: true,
+		},
 	} {
 		t.Run(ti.title, func(t *testing.T) {
 			c := newInMemoryClient()
-			record := c.findByType(ti.findType, ti.records)
+			record := c.findByType(ti.findType, ti.findSetID, ti.records)
 			if ti.expectedEmpty {
 				assert.Nil(t, record)
 			} else {
@@ -155,29 +194,30 @@ func testInMemoryRecords(t *testing.T) {
 				"org": {
 					"example.org": []*inMemoryRecord{
 						{
-							Name:   "example.org",
-							Target: "8.8.8.8",
-							Type:   endpoint.RecordTypeA,
+							Name:    "example.org",
+							Targets: endpoint.Targets{"8.8.8.8"},
+							Type:    endpoint.RecordTypeA,
 						},
 						{
-							Name: "example.org",
-							Type: endpoint.RecordTypeTXT,
+							Name:    "example.org",
+							Targets: endpoint.Targets{""},
+							Type:    endpoint.RecordTypeTXT,
 						},
 					},
 					"foo.org": []*inMemoryRecord{
 						{
-							Name:   "foo.org",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "foo.org",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
 				"com": {
 					"example.com": []*inMemoryRecord{
 						{
-							Name:   "example.com",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "example.com",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
@@ -226,9 +266,9 @@ func testInMemoryValidateChangeBatch(t *testing.T) {
 		"org": {
 			"example.org": []*inMemoryRecord{
 				{
-					Name:   "example.org",
-					Target: "8.8.8.8",
-					Type:   endpoint.RecordTypeA,
+					Name:    "example.org",
+					Targets: endpoint.Targets{"8.8.8.8"},
+					Type:    endpoint.RecordTypeA,
 				},
 				{
 					Name: "example.org",
@@ -236,25 +276,25 @@ func testInMemoryValidateChangeBatch(t *testing.T) {
 			},
 			"foo.org": []*inMemoryRecord{
 				{
-					Name:   "foo.org",
-					Target: "bar.org",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "foo.org",
+					Targets: endpoint.Targets{"bar.org"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 			"foo.bar.org": []*inMemoryRecord{
 				{
-					Name:   "foo.bar.org",
-					Target: "5.5.5.5",
-					Type:   endpoint.RecordTypeA,
+					Name:    "foo.bar.org",
+					Targets: endpoint.Targets{"5.5.5.5"},
+					Type:    endpoint.RecordTypeA,
 				},
 			},
 		},
 		"com": {
 			"example.com": []*inMemoryRecord{
 				{
-					Name:   "example.com",
-					Target: "another-example.com",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "example.com",
+					Targets: endpoint.Targets{"another-example.com"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 		},
@@ -536,7 +576,7 @@ func testInMemoryValidateChangeBatch(t *testing.T) {
 		},
 	} {
 		t.Run(ti.title, func(t *testing.T) {
-			c := &inMemoryClient{}
+			c := newInMemoryClient()
 			c.zones = ti.init
 			ichanges := &inMemoryChange{
 				Create:    convertToInMemoryRecord(ti.changes.Create),
@@ -559,9 +599,9 @@ func getInitData() map[string]zone {
 		"org": {
 			"example.org": []*inMemoryRecord{
 				{
-					Name:   "example.org",
-					Target: "8.8.8.8",
-					Type:   endpoint.RecordTypeA,
+					Name:    "example.org",
+					Targets: endpoint.Targets{"8.8.8.8"},
+					Type:    endpoint.RecordTypeA,
 				},
 				{
 					Name: "example.org",
@@ -570,25 +610,25 @@ func getInitData() map[string]zone {
 			},
 			"foo.org": []*inMemoryRecord{
 				{
-					Name:   "foo.org",
-					Target: "4.4.4.4",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "foo.org",
+					Targets: endpoint.Targets{"4.4.4.4"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 			"foo.bar.org": []*inMemoryRecord{
 				{
-					Name:   "foo.bar.org",
-					Target: "5.5.5.5",
-					Type:   endpoint.RecordTypeA,
+					Name:    "foo.bar.org",
+					Targets: endpoint.Targets{"5.5.5.5"},
+					Type:    endpoint.RecordTypeA,
 				},
 			},
 		},
 		"com": {
 			"example.com": []*inMemoryRecord{
 				{
-					Name:   "example.com",
-					Target: "4.4.4.4",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "example.com",
+					Targets: endpoint.Targets{"4.4.4.4"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 		},
@@ -660,9 +700,9 @@ func testInMemoryApplyChanges(t *testing.T) {
 					"example.org": []*inMemoryRecord{
 						{
 
-							Name:   "example.org",
-							Target: "8.8.8.8",
-							Type:   endpoint.RecordTypeA,
+							Name:    "example.org",
+							Targets: endpoint.Targets{"8.8.8.8"},
+							Type:    endpoint.RecordTypeA,
 						},
 						{
 
@@ -673,9 +713,9 @@ func testInMemoryApplyChanges(t *testing.T) {
 					"foo.org": []*inMemoryRecord{
 						{
 
-							Name:   "foo.org",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "foo.org",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 					"foo.bar.org": []*inMemoryRecord{},
@@ -683,9 +723,9 @@ func testInMemoryApplyChanges(t *testing.T) {
 				"com": {
 					"example.com": []*inMemoryRecord{
 						{
-							Name:   "example.com",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "example.com",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
@@ -734,32 +774,32 @@ func testInMemoryApplyChanges(t *testing.T) {
 					},
 					"foo.org": []*inMemoryRecord{
 						{
-							Name:   "foo.org",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "foo.org",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 					"foo.bar.org": []*inMemoryRecord{
 						{
-							Name:   "foo.bar.org",
-							Target: "4.8.8.4",
-							Type:   endpoint.RecordTypeA,
+							Name:    "foo.bar.org",
+							Targets: endpoint.Targets{"4.8.8.4"},
+							Type:    endpoint.RecordTypeA,
 						},
 					},
 					"foo.bar.new.org": []*inMemoryRecord{
 						{
-							Name:   "foo.bar.new.org",
-							Target: "4.8.8.9",
-							Type:   endpoint.RecordTypeA,
+							Name:    "foo.bar.new.org",
+							Targets: endpoint.Targets{"4.8.8.9"},
+							Type:    endpoint.RecordTypeA,
 						},
 					},
 				},
 				"com": {
 					"example.com": []*inMemoryRecord{
 						{
-							Name:   "example.com",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "example.com",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
@@ -769,7 +809,7 @@ func testInMemoryApplyChanges(t *testing.T) {
 		t.Run(ti.title, func(t *testing.T) {
 
 			im := NewInMemoryProvider()
-			c := &inMemoryClient{}
+			c := newInMemoryClient()
 			c.zones = getInitData()
 			im.client = c
 
@@ -796,3 +836,128 @@ func testInMemoryCreateZone(t *testing.T) {
 	err = im.CreateZone("zone")
 	assert.EqualError(t, err, ErrZoneAlreadyExists.Error())
 }
+
+func testInMemoryEndpointData(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"org"}))
+
+	created := endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 300, "1.2.3.4", "1.2.3.5").WithProviderSpecific("alias", "false")
+	require.NoError(t, im.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{created}}))
+
+	records, err := im.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{created}, records), "Endpoints not the same: Expected: %+v Records: %+v", created, records)
+
+	updated := endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 600, "1.2.3.6").WithProviderSpecific("alias", "true")
+	require.NoError(t, im.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 300, "1.2.3.5", "1.2.3.4")},
+		UpdateNew: []*endpoint.Endpoint{updated},
+	}))
+
+	records, err = im.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{updated}, records), "Endpoints not the same: Expected: %+v Records: %+v", updated, records)
+
+	// modifying the returned endpoints doesn't modify the stored records
+	records[0].Targets[0] = "8.8.8.8"
+	records[0].ProviderSpecific["alias"] = "false"
+	records, err = im.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{updated}, records), "Endpoints not the same: Expected: %+v Records: %+v", updated, records)
+
+	// deleting the record requires all of its targets
+	assert.EqualError(t, im.ApplyChanges(&plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "1.2.3.4")},
+	}), ErrRecordNotFound.Error())
+}
+
+func testInMemorySetIdentifier(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"org"}))
+
+	eu := endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "1.2.3.4")
+	eu.SetIdentifier = "eu"
+	eu.Labels[endpoint.OwnerLabelKey] = "owner"
+	us := endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "1.2.3.5")
+	us.SetIdentifier = "us"
+	require.NoError(t, im.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{eu, us}}))
+
+	records, err := im.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{eu, us}, records), "Endpoints not the same: Expected: %+v Records: %+v", []*endpoint.Endpoint{eu, us}, records)
+
+	// records with the same name and type are told apart by their set identifier
+	duplicate := endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "1.2.3.6")
+	duplicate.SetIdentifier = "eu"
+	assert.EqualError(t, im.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{duplicate}}), ErrRecordAlreadyExists.Error())
+
+	updated := endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "1.2.3.6")
+	updated.SetIdentifier = "us"
+	require.NoError(t, im.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{us},
+		UpdateNew: []*endpoint.Endpoint{updated},
+		Delete:    []*endpoint.Endpoint{eu},
+	}))
+
+	records, err = im.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{updated}, records), "Endpoints not the same: Expected: %+v Records: %+v", updated, records)
+}
+
+func testInMemorySnapshotRestore(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"org", "com"}))
+	require.NoError(t, im.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 300, "1.2.3.4"),
+	}}))
+
+	snapshot := im.Snapshot()
+	assert.Len(t, snapshot, 2)
+	assert.Len(t, snapshot["org"], 1)
+	assert.Len(t, snapshot["com"], 0)
+
+	require.NoError(t, im.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("example.com", endpoint.RecordTypeCNAME, "example.org")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("example.org", endpoint.RecordTypeA, "1.2.3.4")},
+	}))
+	require.NoError(t, im.CreateZone("net"))
+
+	require.NoError(t, im.Restore(snapshot))
+	assert.Equal(t, map[string]string{"org": "org", "com": "com"}, im.Zones())
+
+	records, err := im.Records()
+	require.NoError(t, err)
+	expected := []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 300, "1.2.3.4")}
+	assert.True(t, testutils.SameEndpoints(expected, records), "Endpoints not the same: Expected: %+v Records: %+v", expected, records)
+}
+
+func testInMemoryStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inmemory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	// a missing state file is created with the first change
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"org"}), InMemoryWithStateFile(stateFile))
+	require.NoError(t, im.LoadState())
+	weighted := endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeCNAME, "example.org")
+	weighted.SetIdentifier = "blue"
+	weighted.Labels[endpoint.OwnerLabelKey] = "owner"
+	require.NoError(t, im.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeA, 300, "1.2.3.4", "1.2.3.5").WithProviderSpecific("alias", "false"),
+		weighted,
+	}}))
+
+	// the records of the state file are restored on top of the pre-configured zones
+	restored := NewInMemoryProvider(InMemoryInitZones([]string{"org", "com"}), InMemoryWithStateFile(stateFile))
+	require.NoError(t, restored.LoadState())
+	assert.Equal(t, im.Snapshot(), InMemorySnapshot{"org": restored.Snapshot()["org"]})
+	assert.Len(t, restored.Snapshot()["com"], 0)
+	records, err := restored.Records()
+	require.NoError(t, err)
+	assert.Contains(t, records, weighted)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files should be removed")
+
+	require.NoError(t, ioutil.WriteFile(stateFile, []byte("{"), 0644))
+	assert.Error(t, restored.LoadState())
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package provider
 
 import (
+	"fmt"
 	"net"
 	"strings"
 
@@ -30,6 +31,22 @@ type Provider interface {
 	ApplyChanges(changes *plan.Changes) error
 }
 
+// ProviderSpecificDefaulter is implemented by providers whose records have provider specific
+// properties with defaults, e.g. set by flags.
+type ProviderSpecificDefaulter interface {
+	ProviderSpecificDefaults() endpoint.ProviderSpecific
+}
+
+// FailedChangesError is returned by ApplyChanges if only some of the changes failed while
+// the others were applied. It holds the endpoints of the failed changes.
+type FailedChangesError struct {
+	Endpoints []*endpoint.Endpoint
+}
+
+func (e *FailedChangesError) Error() string {
+	return fmt.Sprintf("failed to apply the changes of %d endpoint(s): %v", len(e.Endpoints), e.Endpoints)
+}
+
 // ensureTrailingDot ensures that the hostname receives a trailing dot if it hasn't already.
 func ensureTrailingDot(hostname string) string {
 	if net.ParseIP(hostname) != nil {
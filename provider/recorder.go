@@ -0,0 +1,53 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+// RecordingProvider reads records from the wrapped provider but only records
+// the changes passed to it instead of applying them. It allows inspecting
+// the exact changes a registry would send to the DNS provider.
+type RecordingProvider struct {
+	provider Provider
+	// Changes holds all changes passed to ApplyChanges
+	Changes *plan.Changes
+}
+
+// NewRecordingProvider returns a RecordingProvider wrapping the given provider.
+func NewRecordingProvider(provider Provider) *RecordingProvider {
+	return &RecordingProvider{
+		provider: provider,
+		Changes:  &plan.Changes{},
+	}
+}
+
+// Records returns the records of the wrapped provider.
+func (p *RecordingProvider) Records() ([]*endpoint.Endpoint, error) {
+	return p.provider.Records()
+}
+
+// ApplyChanges records the changes without applying them.
+func (p *RecordingProvider) ApplyChanges(changes *plan.Changes) error {
+	p.Changes.Create = append(p.Changes.Create, changes.Create...)
+	p.Changes.UpdateOld = append(p.Changes.UpdateOld, changes.UpdateOld...)
+	p.Changes.UpdateNew = append(p.Changes.UpdateNew, changes.UpdateNew...)
+	p.Changes.Delete = append(p.Changes.Delete, changes.Delete...)
+	return nil
+}
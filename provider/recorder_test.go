@@ -0,0 +1,53 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/internal/testutils"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+func TestRecordingProvider(t *testing.T) {
+	inmemory := NewInMemoryProvider()
+	require.NoError(t, inmemory.CreateZone("example.org"))
+	existing := endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")
+	require.NoError(t, inmemory.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{existing}}))
+
+	p := NewRecordingProvider(inmemory)
+
+	records, err := p.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{existing}, records))
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeA, "5.6.7.8")},
+		Delete: []*endpoint.Endpoint{existing},
+	}
+	require.NoError(t, p.ApplyChanges(changes))
+	assert.Equal(t, changes.Create, p.Changes.Create)
+	assert.Equal(t, changes.Delete, p.Changes.Delete)
+
+	records, err = inmemory.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{existing}, records), "should not apply changes")
+}
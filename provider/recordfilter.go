@@ -17,10 +17,10 @@ limitations under the License.
 package provider
 
 // supportedRecordType returns true only for supported record types.
-// Currently A, CNAME, SRV, and TXT record types are supported.
+// Currently A, AAAA, CNAME, SRV, and TXT record types are supported.
 func supportedRecordType(recordType string) bool {
 	switch recordType {
-	case "A", "CNAME", "SRV", "TXT":
+	case "A", "AAAA", "CNAME", "SRV", "TXT":
 		return true
 	default:
 		return false
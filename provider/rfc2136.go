@@ -19,7 +19,9 @@ package provider
 import (
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
@@ -32,13 +34,12 @@ import (
 
 // rfc2136 provider type
 type rfc2136Provider struct {
-	nameserver    string
-	zoneName      string
-	tsigKeyName   string
-	tsigSecret    string
-	tsigSecretAlg string
-	insecure      bool
-	axfr          bool
+	nameserver string
+	// zones maps the names of the managed zones to themselves, to find the zone of a record
+	zones zoneIDName
+	// tsigKeys maps the names of the zones to the TSIG keys messages of the zones are signed with
+	tsigKeys map[string]rfc2136TSIGKey
+	axfr     bool
 
 	// only consider hosted zones managing domains ending in this suffix
 	domainFilter DomainFilter
@@ -46,6 +47,13 @@ type rfc2136Provider struct {
 	actions      rfc2136Actions
 }
 
+// rfc2136TSIGKey is the TSIG key messages of a zone are signed with.
+type rfc2136TSIGKey struct {
+	name   string
+	secret string
+	alg    string
+}
+
 var (
 	// Map of supported TSIG algorithms
 	tsigAlgs = map[string]string{
@@ -56,22 +64,24 @@ var (
 	}
 )
 
+const (
+	// rfc2136BatchSize is the maximum number of endpoints changed by a single UPDATE message
+	rfc2136BatchSize = 100
+)
+
 type rfc2136Actions interface {
 	SendMessage(msg *dns.Msg) error
 	IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelope, err error)
 }
 
-// NewRfc2136Provider is a factory function for OpenStack rfc2136 providers
-func NewRfc2136Provider(host string, port int, zoneName string, insecure bool, keyName string, secret string, secretAlg string, axfr bool, domainFilter DomainFilter, dryRun bool, actions rfc2136Actions) (Provider, error) {
-	secretAlgChecked, ok := tsigAlgs[secretAlg]
-	if !ok {
-		return nil, errors.Errorf("%s is not supported TSIG algorithm", secretAlg)
-	}
-
+// NewRfc2136Provider is a factory function for OpenStack rfc2136 providers. Messages of all
+// zones are signed with the given TSIG key unless insecure is set, zoneTSIGKeys override the
+// key of some zones, e.g. `keyname:hmac-sha256:c2VjcmV0=example.org,example.com`.
+func NewRfc2136Provider(host string, port int, zoneNames []string, insecure bool, keyName string, secret string, secretAlg string, zoneTSIGKeys []string, axfr bool, domainFilter DomainFilter, dryRun bool, actions rfc2136Actions) (Provider, error) {
 	r := &rfc2136Provider{
 		nameserver:   net.JoinHostPort(host, strconv.Itoa(port)),
-		zoneName:     dns.Fqdn(zoneName),
-		insecure:     insecure,
+		zones:        zoneIDName{},
+		tsigKeys:     map[string]rfc2136TSIGKey{},
 		domainFilter: domainFilter,
 		dryRun:       dryRun,
 		axfr:         axfr,
@@ -82,21 +92,91 @@ func NewRfc2136Provider(host string, port int, zoneName string, insecure bool, k
 		r.actions = r
 	}
 
-	if !insecure {
-		r.tsigKeyName = dns.Fqdn(keyName)
-		r.tsigSecret = secret
-		r.tsigSecretAlg = secretAlgChecked
+	for _, zoneName := range zoneNames {
+		if zoneName == "" {
+			continue
+		}
+		zoneName = dns.Fqdn(zoneName)
+		r.zones.Add(zoneName, zoneName)
+
+		if !insecure {
+			key, err := newRfc2136TSIGKey(keyName, secretAlg, secret)
+			if err != nil {
+				return nil, err
+			}
+			r.tsigKeys[zoneName] = key
+		}
+	}
+	if len(r.zones) == 0 {
+		return nil, errors.New("no zone specified")
 	}
 
-	log.Infof("Configured RFC2136 with zone '%s' and nameserver '%s'", r.zoneName, r.nameserver)
+	for _, mapping := range zoneTSIGKeys {
+		if mapping == "" {
+			continue
+		}
+
+		// zone names can't contain "=" unlike base64 encoded secrets
+		i := strings.LastIndex(mapping, "=")
+		if i <= 0 || i == len(mapping)-1 {
+			return nil, errors.Errorf("invalid mapping of a TSIG key to zones: %s", mapping)
+		}
+		parts := strings.SplitN(mapping[:i], ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid mapping of a TSIG key to zones: %s", mapping)
+		}
+		key, err := newRfc2136TSIGKey(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, zoneName := range strings.Split(mapping[i+1:], ",") {
+			zoneName = dns.Fqdn(strings.TrimSpace(zoneName))
+			if _, ok := r.zones[zoneName]; !ok {
+				return nil, errors.Errorf("TSIG key %s is mapped to the unknown zone %s", key.name, zoneName)
+			}
+			r.tsigKeys[zoneName] = key
+		}
+	}
+
+	for _, zoneName := range r.zoneNames() {
+		log.Infof("Configured RFC2136 with zone '%s' and nameserver '%s'", zoneName, r.nameserver)
+	}
 	return r, nil
 }
 
+// zoneNames returns the sorted names of the managed zones.
+func (r rfc2136Provider) zoneNames() []string {
+	zoneNames := make([]string, 0, len(r.zones))
+	for zoneName := range r.zones {
+		zoneNames = append(zoneNames, zoneName)
+	}
+	sort.Strings(zoneNames)
+	return zoneNames
+}
+
+// newRfc2136TSIGKey returns the TSIG key of the given name, algorithm and secret.
+func newRfc2136TSIGKey(keyName, secretAlg, secret string) (rfc2136TSIGKey, error) {
+	secretAlgChecked, ok := tsigAlgs[secretAlg]
+	if !ok {
+		return rfc2136TSIGKey{}, errors.Errorf("%s is not supported TSIG algorithm", secretAlg)
+	}
+	return rfc2136TSIGKey{
+		name:   dns.Fqdn(keyName),
+		secret: secret,
+		alg:    secretAlgChecked,
+	}, nil
+}
+
 // Records returns the list of records.
 func (r rfc2136Provider) Records() ([]*endpoint.Endpoint, error) {
-	rrs, err := r.List()
-	if err != nil {
-		return nil, err
+	var rrs []dns.RR
+	for _, zoneName := range r.zoneNames() {
+		zoneRRs, err := r.List(zoneName)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, zoneRRs...)
 	}
 
 	var eps []*endpoint.Endpoint
@@ -152,39 +232,40 @@ OuterLoop:
 
 func (r rfc2136Provider) IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelope, err error) {
 	t := new(dns.Transfer)
-	if !r.insecure {
-		t.TsigSecret = map[string]string{r.tsigKeyName: r.tsigSecret}
+	if key, ok := r.tsigKeys[m.Question[0].Name]; ok {
+		t.TsigSecret = map[string]string{key.name: key.secret}
 	}
 
 	return t.In(m, r.nameserver)
 }
 
-func (r rfc2136Provider) List() ([]dns.RR, error) {
+// List returns the records of the given zone.
+func (r rfc2136Provider) List(zoneName string) ([]dns.RR, error) {
 	if !r.axfr {
 		log.Info("axfr is disabled")
 		return make([]dns.RR, 0), nil
 	}
 
-	log.Debugf("Fetching records for '%s'", r.zoneName)
+	log.Debugf("Fetching records for '%s'", zoneName)
 
 	m := new(dns.Msg)
-	m.SetAxfr(r.zoneName)
-	if !r.insecure {
-		m.SetTsig(r.tsigKeyName, r.tsigSecretAlg, 300, time.Now().Unix())
+	m.SetAxfr(zoneName)
+	if key, ok := r.tsigKeys[zoneName]; ok {
+		m.SetTsig(key.name, key.alg, 300, time.Now().Unix())
 	}
 
 	env, err := r.actions.IncomeTransfer(m, r.nameserver)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch records via AXFR: %v", err)
+		return nil, fmt.Errorf("failed to fetch records of zone '%s' via AXFR: %v", zoneName, err)
 	}
 
 	records := make([]dns.RR, 0)
 	for e := range env {
 		if e.Error != nil {
 			if e.Error == dns.ErrSoa {
-				log.Error("AXFR error: unexpected response received from the server")
+				log.Errorf("AXFR error for zone '%s': unexpected response received from the server", zoneName)
 			} else {
-				log.Errorf("AXFR error: %v", e.Error)
+				log.Errorf("AXFR error for zone '%s': %v", zoneName, e.Error)
 			}
 			continue
 		}
@@ -194,101 +275,145 @@ func (r rfc2136Provider) List() ([]dns.RR, error) {
 	return records, nil
 }
 
-// ApplyChanges applies a given set of changes in a given zone.
+// rfc2136Change is the change of an endpoint, either a deletion or an update which replaces
+// the records of an endpoint, i.e. a creation if it doesn't have any yet.
+type rfc2136Change struct {
+	endpoint *endpoint.Endpoint
+	remove   bool
+	insert   bool
+}
+
+// ApplyChanges applies the given changes. The changes of each zone are sent in UPDATE
+// messages of at most rfc2136BatchSize endpoints.
 func (r rfc2136Provider) ApplyChanges(changes *plan.Changes) error {
 	log.Debugf("ApplyChanges")
 
-	for _, ep := range changes.Create {
+	changesByZone := map[string][]rfc2136Change{}
+	addChanges := func(endpoints []*endpoint.Endpoint, remove, insert bool) {
+		for _, ep := range endpoints {
+			if !r.domainFilter.Match(ep.DNSName) {
+				log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
+				continue
+			}
+
+			zoneName, _ := r.zones.FindZone(dns.Fqdn(ep.DNSName))
+			if zoneName == "" {
+				log.Debugf("Skipping record %s because no zone matching record DNS Name was detected", ep.DNSName)
+				continue
+			}
 
-		if !r.domainFilter.Match(ep.DNSName) {
-			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
-			continue
+			changesByZone[zoneName] = append(changesByZone[zoneName], rfc2136Change{endpoint: ep, remove: remove, insert: insert})
 		}
-
-		r.AddRecord(ep)
 	}
-	for _, ep := range changes.UpdateNew {
+	addChanges(changes.Delete, true, false)
+	addChanges(changes.Create, false, true)
+	addChanges(changes.UpdateNew, true, true)
+
+	var failed []*endpoint.Endpoint
+	for _, zoneName := range r.zoneNames() {
+		zoneChanges := changesByZone[zoneName]
+		for len(zoneChanges) > 0 {
+			batch := zoneChanges
+			if len(batch) > rfc2136BatchSize {
+				batch = batch[:rfc2136BatchSize]
+			}
+			zoneChanges = zoneChanges[len(batch):]
 
-		if !r.domainFilter.Match(ep.DNSName) {
-			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
-			continue
+			failed = append(failed, r.sendChanges(zoneName, batch)...)
 		}
+	}
 
-		r.UpdateRecord(ep)
+	if len(failed) > 0 {
+		return &FailedChangesError{Endpoints: failed}
 	}
-	for _, ep := range changes.Delete {
+	return nil
+}
 
-		if !r.domainFilter.Match(ep.DNSName) {
-			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
+// sendChanges sends the given changes of a zone in a single UPDATE message and returns the
+// endpoints of the failed ones. The records are removed before any are inserted. Changes whose
+// records can't be built are left out of the message, the others fail together with it.
+func (r rfc2136Provider) sendChanges(zoneName string, changes []rfc2136Change) []*endpoint.Endpoint {
+	var failed, sent []*endpoint.Endpoint
+	var removed, inserted []dns.RR
+	for _, change := range changes {
+		removedRR, insertedRRs, err := r.changeRRs(change)
+		if err != nil {
+			log.Errorf("Failed to change record %s %s in zone '%s': %v", change.endpoint.DNSName, change.endpoint.RecordType, zoneName, err)
+			failed = append(failed, change.endpoint)
 			continue
 		}
+		if removedRR != nil {
+			removed = append(removed, removedRR)
+		}
+		inserted = append(inserted, insertedRRs...)
+		sent = append(sent, change.endpoint)
+	}
+	if len(sent) == 0 {
+		return failed
+	}
 
-		r.RemoveRecord(ep)
+	m := new(dns.Msg)
+	m.SetUpdate(zoneName)
+	if len(removed) > 0 {
+		m.RemoveRRset(removed)
+	}
+	if len(inserted) > 0 {
+		m.Insert(inserted)
 	}
 
-	return nil
+	if err := r.actions.SendMessage(m); err != nil {
+		log.Errorf("Failed to update zone '%s': RFC2136 query failed: %v", zoneName, err)
+		return append(failed, sent...)
+	}
+	return failed
 }
 
-func (r rfc2136Provider) UpdateRecord(ep *endpoint.Endpoint) error {
-	err := r.RemoveRecord(ep)
-	if err != nil {
-		return err
+// changeRRs returns the record removing the RRset of the endpoint of the change, if it's
+// removed, and the records inserted by the change.
+func (r rfc2136Provider) changeRRs(change rfc2136Change) (dns.RR, []dns.RR, error) {
+	var removed dns.RR
+	var inserted []dns.RR
+	var err error
+	if change.remove {
+		if removed, err = r.newRemovedRR(change.endpoint); err != nil {
+			return nil, nil, err
+		}
 	}
-
-	return r.AddRecord(ep)
+	if change.insert {
+		if inserted, err = r.newRRs(change.endpoint); err != nil {
+			return nil, nil, err
+		}
+	}
+	return removed, inserted, nil
 }
 
-func (r rfc2136Provider) AddRecord(ep *endpoint.Endpoint) error {
+// newRRs returns the records of the endpoint, one per target.
+func (r rfc2136Provider) newRRs(ep *endpoint.Endpoint) ([]dns.RR, error) {
 	log.Debugf("AddRecord.ep=%s", ep)
+	var rrs []dns.RR
 	for _, target := range ep.Targets {
 		newRR := fmt.Sprintf("%s %d %s %s", ep.DNSName, ep.RecordTTL, ep.RecordType, target)
 		log.Debugf("Adding RR: %s", newRR)
 
 		rr, err := dns.NewRR(newRR)
 		if err != nil {
-			return fmt.Errorf("failed to build RR: %v", err)
-		}
-
-		rrs := make([]dns.RR, 1)
-		rrs[0] = rr
-
-		m := new(dns.Msg)
-		m.SetUpdate(r.zoneName)
-		m.Insert(rrs)
-
-		err = r.actions.SendMessage(m)
-		if err != nil {
-			return fmt.Errorf("RFC2136 query failed: %v", err)
+			return nil, fmt.Errorf("failed to build RR: %v", err)
 		}
+		rrs = append(rrs, rr)
 	}
 
-	return nil
+	return rrs, nil
 }
 
-func (r rfc2136Provider) RemoveRecord(ep *endpoint.Endpoint) error {
+// newRemovedRR returns a record whose name and type designate the RRset of the endpoint.
+func (r rfc2136Provider) newRemovedRR(ep *endpoint.Endpoint) (dns.RR, error) {
 	log.Debugf("RemoveRecord.ep=%s", ep)
 
-	newRR := fmt.Sprintf("%s 0 %s 0.0.0.0", ep.DNSName, ep.RecordType)
-	log.Debugf("Adding RR: %s", newRR)
-
-	rr, err := dns.NewRR(newRR)
-	if err != nil {
-		return fmt.Errorf("failed to build RR: %v", err)
-	}
-
-	rrs := make([]dns.RR, 1)
-	rrs[0] = rr
-
-	m := new(dns.Msg)
-	m.SetUpdate(r.zoneName)
-	m.RemoveRRset(rrs)
-
-	err = r.actions.SendMessage(m)
-	if err != nil {
-		return fmt.Errorf("RFC2136 query failed: %v", err)
+	rrType, ok := dns.StringToType[ep.RecordType]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %s", ep.RecordType)
 	}
-
-	return nil
+	return &dns.ANY{Hdr: dns.RR_Header{Name: dns.Fqdn(ep.DNSName), Rrtype: rrType, Class: dns.ClassANY}}, nil
 }
 
 func (r rfc2136Provider) SendMessage(msg *dns.Msg) error {
@@ -300,10 +425,14 @@ func (r rfc2136Provider) SendMessage(msg *dns.Msg) error {
 
 	c := new(dns.Client)
 	c.SingleInflight = true
+	// batched updates easily exceed the size of a UDP message
+	if msg.Len() > dns.MinMsgSize {
+		c.Net = "tcp"
+	}
 
-	if !r.insecure {
-		c.TsigSecret = map[string]string{r.tsigKeyName: r.tsigSecret}
-		msg.SetTsig(r.tsigKeyName, r.tsigSecretAlg, 300, time.Now().Unix())
+	if key, ok := r.tsigKeys[msg.Question[0].Name]; ok {
+		c.TsigSecret = map[string]string{key.name: key.secret}
+		msg.SetTsig(key.name, key.alg, 300, time.Now().Unix())
 	}
 
 	resp, _, err := c.Exchange(msg, r.nameserver)
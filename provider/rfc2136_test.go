@@ -17,14 +17,19 @@ limitations under the License.
 package provider
 
 import (
+	"fmt"
+	"net"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 	"github.com/kubernetes-incubator/external-dns/plan"
 	"github.com/miekg/dns"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type rfc2136Stub struct {
@@ -81,7 +86,9 @@ func (r *rfc2136Stub) IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelo
 	outChan := make(chan *dns.Envelope)
 	go func() {
 		for _, e := range r.output {
-			outChan <- e
+			if dns.IsSubDomain(m.Question[0].Name, e.RR[0].Header().Name) {
+				outChan <- e
+			}
 		}
 		close(outChan)
 	}()
@@ -90,7 +97,44 @@ func (r *rfc2136Stub) IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelo
 }
 
 func createRfc2136StubProvider(stub *rfc2136Stub) (Provider, error) {
-	return NewRfc2136Provider("", 0, "", false, "key", "secret", "hmac-sha512", true, DomainFilter{}, false, stub)
+	return NewRfc2136Provider("", 0, []string{"foo.com", "bar.com", "foobar.com", "barfoo.com"}, false, "key", "secret", "hmac-sha512", nil, true, DomainFilter{}, false, stub)
+}
+
+func TestNewRfc2136Provider(t *testing.T) {
+	p, err := NewRfc2136Provider("", 0, []string{"foo.com", "bar.com.", "baz.com", ""}, true, "", "", "", []string{"key1:hmac-sha256:c2VjcmV0=foo.com,bar.com", "key2:hmac-md5:c2VjcmV0Mg===baz.com.", ""}, true, DomainFilter{}, false, nil)
+	assert.NoError(t, err)
+
+	r := p.(*rfc2136Provider)
+	assert.Equal(t, []string{"bar.com.", "baz.com.", "foo.com."}, r.zoneNames())
+	assert.Equal(t, map[string]rfc2136TSIGKey{
+		"foo.com.": {name: "key1.", secret: "c2VjcmV0", alg: dns.HmacSHA256},
+		"bar.com.": {name: "key1.", secret: "c2VjcmV0", alg: dns.HmacSHA256},
+		"baz.com.": {name: "key2.", secret: "c2VjcmV0Mg==", alg: dns.HmacMD5},
+	}, r.tsigKeys)
+
+	p, err = NewRfc2136Provider("", 0, []string{"foo.com", "bar.com"}, false, "key", "c2VjcmV0", "hmac-sha512", []string{"key1:hmac-sha256:c2VjcmV0=bar.com"}, true, DomainFilter{}, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]rfc2136TSIGKey{
+		"foo.com.": {name: "key.", secret: "c2VjcmV0", alg: dns.HmacSHA512},
+		"bar.com.": {name: "key1.", secret: "c2VjcmV0", alg: dns.HmacSHA256},
+	}, p.(*rfc2136Provider).tsigKeys)
+
+	for _, ti := range []struct {
+		title        string
+		zones        []string
+		zoneTSIGKeys []string
+	}{
+		{"no zones", []string{""}, nil},
+		{"missing zones of key", []string{"foo.com"}, []string{"key1:hmac-sha256:c2VjcmV0"}},
+		{"missing algorithm of key", []string{"foo.com"}, []string{"key1:c2VjcmV0=foo.com"}},
+		{"unsupported algorithm of key", []string{"foo.com"}, []string{"key1:hmac-sha3:c2VjcmV0=foo.com"}},
+		{"unknown zone of key", []string{"foo.com"}, []string{"key1:hmac-sha256:c2VjcmV0=bar.com"}},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			_, err := NewRfc2136Provider("", 0, ti.zones, true, "", "", "", ti.zoneTSIGKeys, true, DomainFilter{}, false, nil)
+			assert.Error(t, err)
+		})
+	}
 }
 
 func TestRfc2136GetRecords(t *testing.T) {
@@ -152,15 +196,256 @@ func TestRfc2136ApplyChanges(t *testing.T) {
 	err = provider.ApplyChanges(p)
 	assert.NoError(t, err)
 
-	assert.Equal(t, 2, len(stub.createMsgs))
-	assert.True(t, strings.Contains(stub.createMsgs[0].String(), "v1.foo.com"))
-	assert.True(t, strings.Contains(stub.createMsgs[0].String(), "1.2.3.4"))
-
-	assert.True(t, strings.Contains(stub.createMsgs[1].String(), "v1.foobar.com"))
-	assert.True(t, strings.Contains(stub.createMsgs[1].String(), "boom"))
-
+	// the deletion and creation of each zone are sent in the same message
+	assert.Equal(t, 0, len(stub.createMsgs))
 	assert.Equal(t, 2, len(stub.updateMsgs))
+
+	assert.Equal(t, "foo.com.", stub.updateMsgs[0].Question[0].Name)
 	assert.True(t, strings.Contains(stub.updateMsgs[0].String(), "v2.foo.com"))
+	assert.True(t, strings.Contains(stub.updateMsgs[0].String(), "v1.foo.com"))
+	assert.True(t, strings.Contains(stub.updateMsgs[0].String(), "1.2.3.4"))
+
+	assert.Equal(t, "foobar.com.", stub.updateMsgs[1].Question[0].Name)
 	assert.True(t, strings.Contains(stub.updateMsgs[1].String(), "v2.foobar.com"))
+	assert.True(t, strings.Contains(stub.updateMsgs[1].String(), "v1.foobar.com"))
+	assert.True(t, strings.Contains(stub.updateMsgs[1].String(), "boom"))
+}
+
+func TestRfc2136ApplyChangesSkipsUnknownZones(t *testing.T) {
+	stub := newStub()
+	provider, err := createRfc2136StubProvider(stub)
+	assert.NoError(t, err)
+
+	err = provider.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("v1.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+			endpoint.NewEndpoint("v1.barfoo.com", endpoint.RecordTypeA, "1.2.3.4"),
+		},
+	})
+	assert.NoError(t, err)
 
+	assert.Equal(t, 1, len(stub.createMsgs))
+	assert.Equal(t, "barfoo.com.", stub.createMsgs[0].Question[0].Name)
+	assert.False(t, strings.Contains(stub.createMsgs[0].String(), "example.org"))
+}
+
+// rfc2136Server is an in-process DNS server hosting zones which accepts UPDATE and AXFR
+// messages signed with the TSIG keys of its zones.
+type rfc2136Server struct {
+	sync.Mutex
+	udp     *dns.Server
+	tcp     *dns.Server
+	addr    *net.UDPAddr
+	keys    map[string]string
+	records map[string][]dns.RR
+}
+
+func newRfc2136Server(t *testing.T, keys map[string]string, tsigSecrets map[string]string, zones ...string) *rfc2136Server {
+	s := &rfc2136Server{keys: keys, records: map[string][]dns.RR{}}
+	for _, zone := range zones {
+		s.records[zone] = nil
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s.addr = pc.LocalAddr().(*net.UDPAddr)
+	l, err := net.Listen("tcp", s.addr.String())
+	require.NoError(t, err)
+
+	s.udp = &dns.Server{PacketConn: pc, Handler: s, TsigSecret: tsigSecrets}
+	s.tcp = &dns.Server{Listener: l, Handler: s, TsigSecret: tsigSecrets}
+	for _, server := range []*dns.Server{s.udp, s.tcp} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go server.ActivateAndServe()
+		<-started
+	}
+
+	return s
+}
+
+// zoneRecords returns the records of the zone.
+func (s *rfc2136Server) zoneRecords(zone string) []dns.RR {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.records[zone]
+}
+
+func (s *rfc2136Server) shutdown() {
+	s.udp.Shutdown()
+	s.tcp.Shutdown()
+}
+
+func (s *rfc2136Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	s.Lock()
+	defer s.Unlock()
+
+	zone := req.Question[0].Name
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+
+	tsig := req.IsTsig()
+	records, ok := s.records[zone]
+	switch {
+	case !ok:
+		resp.Rcode = dns.RcodeNotAuth
+	case tsig == nil || w.TsigStatus() != nil || tsig.Hdr.Name != s.keys[zone]:
+		resp.Rcode = dns.RcodeRefused
+	case req.Opcode == dns.OpcodeUpdate:
+		for _, rr := range req.Ns {
+			if rr.Header().Class == dns.ClassANY {
+				var kept []dns.RR
+				for _, existing := range records {
+					if existing.Header().Name != rr.Header().Name || existing.Header().Rrtype != rr.Header().Rrtype {
+						kept = append(kept, existing)
+					}
+				}
+				records = kept
+			} else {
+				records = append(records, rr)
+			}
+		}
+		s.records[zone] = records
+	case req.Question[0].Qtype == dns.TypeAXFR:
+		soa, _ := dns.NewRR(zone + " 3600 SOA ns." + zone + " admin." + zone + " 1 3600 600 86400 300")
+		resp.Answer = append(append([]dns.RR{soa}, records...), soa)
+	}
+
+	if tsig != nil {
+		resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+	}
+	w.WriteMsg(resp)
+}
+
+func TestRfc2136Server(t *testing.T) {
+	secrets := map[string]string{"key.": "c2VjcmV0", "bar-key.": "YmFyLXNlY3JldA=="}
+	server := newRfc2136Server(t, map[string]string{"foo.com.": "key.", "bar.com.": "bar-key.", "sub.foo.com.": "key."}, secrets, "foo.com.", "bar.com.", "sub.foo.com.")
+	defer server.shutdown()
+
+	provider, err := NewRfc2136Provider(server.addr.IP.String(), server.addr.Port, []string{"foo.com", "bar.com", "sub.foo.com"}, false, "key", secrets["key."], "hmac-sha256", []string{"bar-key:hmac-sha512:" + secrets["bar-key."] + "=bar.com"}, true, DomainFilter{}, false, nil)
+	require.NoError(t, err)
+
+	err = provider.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 300, "1.2.3.4", "1.2.3.5"),
+			endpoint.NewEndpointWithTTL("v1.sub.foo.com", endpoint.RecordTypeA, 300, "1.2.3.6"),
+			endpoint.NewEndpointWithTTL("v1.bar.com", endpoint.RecordTypeTXT, 300, "bar"),
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, server.zoneRecords("foo.com."), 2)
+	assert.Len(t, server.zoneRecords("sub.foo.com."), 1)
+	assert.Len(t, server.zoneRecords("bar.com."), 1)
+
+	err = provider.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 300, "1.2.3.4", "1.2.3.5"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 300, "1.2.3.7"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.bar.com", endpoint.RecordTypeTXT, 300, "bar"),
+		},
+	})
+	require.NoError(t, err)
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("v1.foo.com.", endpoint.RecordTypeA, 300, "1.2.3.7"),
+		endpoint.NewEndpointWithTTL("v1.sub.foo.com.", endpoint.RecordTypeA, 300, "1.2.3.6"),
+	})
+}
+
+func TestRfc2136ServerLargeUpdate(t *testing.T) {
+	secrets := map[string]string{"key.": "c2VjcmV0"}
+	server := newRfc2136Server(t, map[string]string{"foo.com.": "key."}, secrets, "foo.com.")
+	defer server.shutdown()
+
+	provider, err := NewRfc2136Provider(server.addr.IP.String(), server.addr.Port, []string{"foo.com"}, false, "key", secrets["key."], "hmac-sha256", nil, true, DomainFilter{}, false, nil)
+	require.NoError(t, err)
+
+	// more endpoints than fit into a single UPDATE message
+	var endpoints []*endpoint.Endpoint
+	for i := 0; i < rfc2136BatchSize+10; i++ {
+		endpoints = append(endpoints, endpoint.NewEndpointWithTTL(fmt.Sprintf("v%d.foo.com", i), endpoint.RecordTypeA, 300, "1.2.3.4"))
+	}
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: endpoints}))
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+	assert.Len(t, records, rfc2136BatchSize+10)
+}
+
+func TestRfc2136ServerFailedChanges(t *testing.T) {
+	secrets := map[string]string{"key.": "c2VjcmV0", "bar-key.": "YmFyLXNlY3JldA=="}
+	server := newRfc2136Server(t, map[string]string{"foo.com.": "key.", "bar.com.": "bar-key."}, secrets, "foo.com.", "bar.com.")
+	defer server.shutdown()
+
+	// the server rejects the messages of bar.com which aren't signed with its own key
+	provider, err := NewRfc2136Provider(server.addr.IP.String(), server.addr.Port, []string{"foo.com", "bar.com"}, false, "key", secrets["key."], "hmac-sha256", nil, true, DomainFilter{}, false, nil)
+	require.NoError(t, err)
+
+	failed := endpoint.NewEndpointWithTTL("v1.bar.com", endpoint.RecordTypeA, 300, "1.2.3.5")
+	err = provider.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 300, "1.2.3.4"),
+			failed,
+		},
+	})
+	require.Error(t, err)
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Equal(t, []*endpoint.Endpoint{failed}, err.(*FailedChangesError).Endpoints)
+	assert.Len(t, server.zoneRecords("foo.com."), 1)
+	assert.Len(t, server.zoneRecords("bar.com."), 0)
+}
+
+func TestRfc2136ServerDeleteSRVAndMX(t *testing.T) {
+	secrets := map[string]string{"key.": "c2VjcmV0"}
+	server := newRfc2136Server(t, map[string]string{"foo.com.": "key."}, secrets, "foo.com.")
+	defer server.shutdown()
+
+	provider, err := NewRfc2136Provider(server.addr.IP.String(), server.addr.Port, []string{"foo.com"}, false, "key", secrets["key."], "hmac-sha256", nil, true, DomainFilter{}, false, nil)
+	require.NoError(t, err)
+
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("_sip._tcp.foo.com", endpoint.RecordTypeSRV, 300, "10 5 5060 sip.foo.com."),
+		endpoint.NewEndpointWithTTL("foo.com", endpoint.RecordTypeMX, 300, "10 mail.foo.com."),
+		endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeTXT, 300, "\"bar\""),
+	}
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Create: records}))
+	assert.Len(t, server.zoneRecords("foo.com."), 3)
+
+	require.NoError(t, provider.ApplyChanges(&plan.Changes{Delete: records}))
+	assert.Empty(t, server.zoneRecords("foo.com."))
+}
+
+func TestRfc2136ServerInvalidEndpoint(t *testing.T) {
+	secrets := map[string]string{"key.": "c2VjcmV0"}
+	server := newRfc2136Server(t, map[string]string{"foo.com.": "key."}, secrets, "foo.com.")
+	defer server.shutdown()
+
+	provider, err := NewRfc2136Provider(server.addr.IP.String(), server.addr.Port, []string{"foo.com"}, false, "key", secrets["key."], "hmac-sha256", nil, true, DomainFilter{}, false, nil)
+	require.NoError(t, err)
+
+	// the endpoints whose records can't be built fail, the others of the batch are applied
+	invalidTarget := endpoint.NewEndpointWithTTL("v2.foo.com", endpoint.RecordTypeA, 300, "not-an-ip")
+	invalidType := endpoint.NewEndpointWithTTL("v3.foo.com", "UNKNOWN", 300, "1.2.3.4")
+	err = provider.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 300, "1.2.3.4"),
+			invalidTarget,
+		},
+		UpdateNew: []*endpoint.Endpoint{invalidType},
+	})
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Equal(t, []*endpoint.Endpoint{invalidTarget, invalidType}, err.(*FailedChangesError).Endpoints)
+
+	records, err := provider.Records()
+	require.NoError(t, err)
+	validateEndpoints(t, records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("v1.foo.com.", endpoint.RecordTypeA, 300, "1.2.3.4"),
+	})
 }
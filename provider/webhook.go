@@ -0,0 +1,293 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/pkg/tlsutils"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+const (
+	// WebhookMediaType is the media type of the request and response bodies of the webhook API
+	WebhookMediaType = "application/external.dns.webhook+json"
+	// WebhookAPIVersion is the version of the webhook API, sent as the version parameter of the media type
+	WebhookAPIVersion = "1"
+
+	webhookRecordsPath = "/records"
+	// webhookRetryInterval is the time to wait before the first retry of a failed request, it doubles with every retry
+	webhookRetryInterval = time.Second
+)
+
+// webhookContentType is the media type with the version of the webhook API this provider speaks
+var webhookContentType = mime.FormatMediaType(WebhookMediaType, map[string]string{"version": WebhookAPIVersion})
+
+// webhookInternalLabels are the labels of endpoints which are only used within ExternalDNS, they aren't sent to the webhook
+var webhookInternalLabels = []string{endpoint.OwnedRecordLabelKey, endpoint.CreationTimestampLabelKey}
+
+// WebhookConfig is comprised of the fields necessary to create a new WebhookProvider
+type WebhookConfig struct {
+	// URL is the base URL of the webhook, the records are served at its path /records
+	URL          string
+	DomainFilter DomainFilter
+	DryRun       bool
+	// Timeout is the timeout of a single request, 0 means no timeout
+	Timeout time.Duration
+	// Retries is the number of times a request is retried after a network error or a server error,
+	// changes are only retried if they weren't sent yet or the webhook asked to retry them later
+	Retries int
+	// CAFilePath, ClientCertFilePath and ClientCertKeyFilePath configure TLS for https URLs,
+	// the system's certificate authorities are used if no CA file is given
+	CAFilePath            string
+	ClientCertFilePath    string
+	ClientCertKeyFilePath string
+}
+
+// webhookError is the body of a response of the webhook API to a request which failed
+type webhookError struct {
+	Message string `json:"message"`
+	// Failed holds the endpoints of the changes which failed while the others were applied
+	Failed []*endpoint.Endpoint `json:"failed,omitempty"`
+}
+
+// WebhookProvider implements the DNS provider interface by calling an HTTP API, so that DNS
+// backends can be supported outside of this repository. The API is served e.g. by a WebhookHandler.
+type WebhookProvider struct {
+	recordsURL    string
+	client        *http.Client
+	domainFilter  DomainFilter
+	dryRun        bool
+	retries       int
+	retryInterval time.Duration
+}
+
+// NewWebhookProvider initializes a new webhook based provider
+func NewWebhookProvider(config WebhookConfig) (*WebhookProvider, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL %q: %v", config.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q: must be an absolute http or https URL", config.URL)
+	}
+
+	// Timeouts taken from net.http.DefaultTransport
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if u.Scheme == "https" {
+		transport.TLSClientConfig, err = tlsutils.NewTLSConfig(config.ClientCertFilePath, config.ClientCertKeyFilePath, config.CAFilePath, "", false, tls.VersionTLS12)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &WebhookProvider{
+		recordsURL: strings.TrimSuffix(u.String(), "/") + webhookRecordsPath,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+		},
+		domainFilter:  config.DomainFilter,
+		dryRun:        config.DryRun,
+		retries:       config.Retries,
+		retryInterval: webhookRetryInterval,
+	}, nil
+}
+
+// Records returns the records served by the webhook
+func (p *WebhookProvider) Records() ([]*endpoint.Endpoint, error) {
+	var records []*endpoint.Endpoint
+	if err := p.do(http.MethodGet, nil, &records); err != nil {
+		return nil, err
+	}
+
+	endpoints := []*endpoint.Endpoint{}
+	for _, ep := range records {
+		if !p.domainFilter.Match(ep.DNSName) {
+			continue
+		}
+		if ep.Labels == nil {
+			ep.Labels = endpoint.NewLabels()
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// ApplyChanges sends the changes matching the domain filter to the webhook
+func (p *WebhookProvider) ApplyChanges(changes *plan.Changes) error {
+	filtered := &plan.Changes{
+		Create:    p.filter(changes.Create),
+		UpdateOld: p.filter(changes.UpdateOld),
+		UpdateNew: p.filter(changes.UpdateNew),
+		Delete:    p.filter(changes.Delete),
+	}
+	if len(filtered.Create)+len(filtered.UpdateNew)+len(filtered.Delete) == 0 {
+		log.Debug("No changes to send to the webhook")
+		return nil
+	}
+
+	for _, ep := range filtered.Create {
+		log.Infof("Create record %s %s %s", ep.DNSName, ep.RecordType, ep.Targets)
+	}
+	for _, ep := range filtered.UpdateNew {
+		log.Infof("Update record %s %s %s", ep.DNSName, ep.RecordType, ep.Targets)
+	}
+	for _, ep := range filtered.Delete {
+		log.Infof("Delete record %s %s %s", ep.DNSName, ep.RecordType, ep.Targets)
+	}
+	if p.dryRun {
+		return nil
+	}
+
+	return p.do(http.MethodPost, filtered, nil)
+}
+
+// filter returns copies of the endpoints matching the domain filter without their internal labels,
+// the updates keep their order so that the old and new endpoints still pair up
+func (p *WebhookProvider) filter(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	filtered := []*endpoint.Endpoint{}
+	for _, ep := range endpoints {
+		if !p.domainFilter.Match(ep.DNSName) {
+			continue
+		}
+		ep = ep.DeepCopy()
+		for _, key := range webhookInternalLabels {
+			delete(ep.Labels, key)
+		}
+		filtered = append(filtered, ep)
+	}
+	return filtered
+}
+
+// do sends a request with the JSON of the body to the records URL and decodes the response into
+// the result, if any. Failed requests are retried with an exponential backoff, see request.
+func (p *WebhookProvider) do(method string, body, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; ; i++ {
+		retry, err := p.request(method, data, result)
+		if err == nil || !retry || i >= p.retries {
+			return err
+		}
+		log.Debugf("Retrying webhook request %s %s after error: %v", method, p.recordsURL, err)
+		time.Sleep(p.retryInterval * (1 << uint(i)))
+	}
+}
+
+// request sends a single request, it returns true along with the error if the request may be retried.
+// Reading the records is retried after network errors and server errors. Changes might have been applied
+// already when such an error occurs, they are only retried if the request couldn't be sent at all or the
+// webhook responded with 429 Too Many Requests.
+func (p *WebhookProvider) request(method string, data []byte, result interface{}) (bool, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, p.recordsURL, body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", webhookContentType)
+	if data != nil {
+		req.Header.Set("Content-Type", webhookContentType)
+	}
+
+	var sent int32
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { atomic.StoreInt32(&sent, 1) },
+	}))
+	idempotent := method == http.MethodGet
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return idempotent || atomic.LoadInt32(&sent) == 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return idempotent, err
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var webhookErr webhookError
+		if checkWebhookMediaType(resp.Header.Get("Content-Type")) == nil && json.Unmarshal(respBody, &webhookErr) == nil {
+			if len(webhookErr.Failed) > 0 {
+				return false, &FailedChangesError{Endpoints: webhookErr.Failed}
+			}
+		} else {
+			webhookErr.Message = strings.TrimSpace(string(respBody))
+		}
+		err := fmt.Errorf("webhook request %s %s failed with status %d: %s", method, p.recordsURL, resp.StatusCode, webhookErr.Message)
+		return idempotent && resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests, err
+	}
+
+	if result == nil {
+		return false, nil
+	}
+	if err := checkWebhookMediaType(resp.Header.Get("Content-Type")); err != nil {
+		return false, fmt.Errorf("webhook request %s %s: %v", method, p.recordsURL, err)
+	}
+	return false, json.Unmarshal(respBody, result)
+}
+
+// checkWebhookMediaType returns an error if the content type isn't the one of the supported
+// version of the webhook API. A missing version means the current version.
+func checkWebhookMediaType(contentType string) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %v", contentType, err)
+	}
+	if mediaType != WebhookMediaType || params["version"] != "" && params["version"] != WebhookAPIVersion {
+		return fmt.Errorf("unsupported content type %q, expected %s", contentType, webhookContentType)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+// WebhookHandler serves the webhook API called by the WebhookProvider for the records of another
+// provider. It's the reference implementation of the API, e.g. on top of the InMemoryProvider for tests.
+type WebhookHandler struct {
+	provider Provider
+}
+
+// NewWebhookHandler returns a handler serving the records of the provider at the path /records
+func NewWebhookHandler(provider Provider) *WebhookHandler {
+	return &WebhookHandler{provider: provider}
+}
+
+// ServeHTTP returns the records for GET requests and applies the changes of POST requests
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != webhookRecordsPath {
+		writeWebhookError(w, http.StatusNotFound, webhookError{Message: "not found"})
+		return
+	}
+	if !webhookAccepts(r.Header.Get("Accept")) {
+		writeWebhookError(w, http.StatusNotAcceptable, webhookError{Message: "only " + webhookContentType + " is supported"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		records, err := h.provider.Records()
+		if err != nil {
+			log.Errorf("Failed to get the records: %v", err)
+			writeWebhookError(w, http.StatusInternalServerError, webhookError{Message: err.Error()})
+			return
+		}
+		if records == nil {
+			records = []*endpoint.Endpoint{}
+		}
+		w.Header().Set("Content-Type", webhookContentType)
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			log.Errorf("Failed to write the records: %v", err)
+		}
+	case http.MethodPost:
+		if err := checkWebhookMediaType(r.Header.Get("Content-Type")); err != nil {
+			writeWebhookError(w, http.StatusUnsupportedMediaType, webhookError{Message: err.Error()})
+			return
+		}
+		changes := &plan.Changes{}
+		if err := json.NewDecoder(r.Body).Decode(changes); err != nil {
+			writeWebhookError(w, http.StatusBadRequest, webhookError{Message: "invalid changes: " + err.Error()})
+			return
+		}
+		if len(changes.UpdateOld) != len(changes.UpdateNew) {
+			writeWebhookError(w, http.StatusBadRequest, webhookError{Message: "invalid changes: updateOld and updateNew differ in length"})
+			return
+		}
+		for _, endpoints := range [][]*endpoint.Endpoint{changes.Create, changes.UpdateOld, changes.UpdateNew, changes.Delete} {
+			for _, ep := range endpoints {
+				if ep.Labels == nil {
+					ep.Labels = endpoint.NewLabels()
+				}
+			}
+		}
+
+		if err := h.provider.ApplyChanges(changes); err != nil {
+			log.Errorf("Failed to apply the changes: %v", err)
+			if failed, ok := err.(*FailedChangesError); ok {
+				writeWebhookError(w, http.StatusUnprocessableEntity, webhookError{Message: err.Error(), Failed: failed.Endpoints})
+				return
+			}
+			writeWebhookError(w, http.StatusInternalServerError, webhookError{Message: err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeWebhookError(w, http.StatusMethodNotAllowed, webhookError{Message: "method not allowed"})
+	}
+}
+
+// webhookAccepts returns true if the Accept header of a request allows the supported version of the
+// webhook API. A missing header, wildcards and a media type without version accept any version.
+func webhookAccepts(accept string) bool {
+	if accept == "" {
+		return true
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "*/*", "application/*":
+			return true
+		case WebhookMediaType:
+			if version, ok := params["version"]; !ok || version == WebhookAPIVersion {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeWebhookError writes the JSON of the error with the status code
+func writeWebhookError(w http.ResponseWriter, status int, webhookErr webhookError) {
+	w.Header().Set("Content-Type", webhookContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(webhookErr); err != nil {
+		log.Errorf("Failed to write the error: %v", err)
+	}
+}
@@ -0,0 +1,405 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/internal/testutils"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+// failingProvider fails to apply all changes except the creations
+type failingProvider struct {
+	Provider
+}
+
+func (p failingProvider) ApplyChanges(changes *plan.Changes) error {
+	if err := p.Provider.ApplyChanges(&plan.Changes{Create: changes.Create}); err != nil {
+		return err
+	}
+	if len(changes.Delete) > 0 {
+		return &FailedChangesError{Endpoints: changes.Delete}
+	}
+	return nil
+}
+
+func newWebhookTestProvider(t *testing.T, handler http.Handler, config WebhookConfig) (*WebhookProvider, func()) {
+	server := httptest.NewServer(handler)
+	config.URL = server.URL
+	p, err := NewWebhookProvider(config)
+	require.NoError(t, err)
+	p.retryInterval = time.Millisecond
+	return p, server.Close
+}
+
+func TestNewWebhookProvider(t *testing.T) {
+	for _, u := range []string{"", "example.org", "/records", "ftp://example.org", "http://%zz"} {
+		_, err := NewWebhookProvider(WebhookConfig{URL: u})
+		assert.Error(t, err, u)
+	}
+
+	p, err := NewWebhookProvider(WebhookConfig{URL: "https://dns.example.org/api/"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://dns.example.org/api/records", p.recordsURL)
+
+	_, err = NewWebhookProvider(WebhookConfig{URL: "https://dns.example.org", CAFilePath: "/non/existing/ca.crt"})
+	assert.Error(t, err)
+}
+
+func TestWebhookProvider(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org", "example.com"}))
+	p, stop := newWebhookTestProvider(t, NewWebhookHandler(im), WebhookConfig{DomainFilter: NewDomainFilter([]string{"example.org"})})
+	defer stop()
+
+	records, err := p.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	a := endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 60, "1.2.3.4", "1.2.3.5")
+	txt := endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`)
+	srv := endpoint.NewEndpoint("_http._tcp.example.org", endpoint.RecordTypeSRV, "10 5 8080 foo.example.org")
+	cname := endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeCNAME, "foo.example.org").WithProviderSpecific("alias", "true")
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{a, txt, srv, cname, endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "1.2.3.6")},
+	}))
+
+	records, err = p.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{a, txt, srv, cname}, records), "got %v", records)
+	for _, ep := range records {
+		assert.NotNil(t, ep.Labels)
+	}
+	// changes outside the domain filter aren't sent to the webhook
+	zoneRecords, err := im.Records()
+	require.NoError(t, err)
+	assert.Len(t, zoneRecords, 4)
+
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{a},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 300, "1.2.3.7")},
+		Delete:    []*endpoint.Endpoint{srv, cname},
+	}))
+
+	records, err = p.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 300, "1.2.3.7"),
+		txt,
+	}, records), "got %v", records)
+
+	// errors of the provider behind the webhook are returned
+	err = p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeTXT, "text")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrRecordAlreadyExists.Error())
+}
+
+func TestWebhookProviderDryRun(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	p, stop := newWebhookTestProvider(t, NewWebhookHandler(im), WebhookConfig{DryRun: true})
+	defer stop()
+
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")},
+	}))
+
+	records, err := im.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestWebhookProviderFailedChanges(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	p, stop := newWebhookTestProvider(t, NewWebhookHandler(failingProvider{im}), WebhookConfig{Retries: 3})
+	defer stop()
+
+	err := p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeA, "1.2.3.5")},
+	})
+	require.IsType(t, &FailedChangesError{}, err)
+	failed := err.(*FailedChangesError).Endpoints
+	require.Len(t, failed, 1)
+	assert.Equal(t, "bar.example.org", failed[0].DNSName)
+
+	// partial failures aren't retried
+	records, err := im.Records()
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestWebhookProviderRetries(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	handler := NewWebhookHandler(im)
+
+	var requests, failures int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	p, stop := newWebhookTestProvider(t, flaky, WebhookConfig{Retries: 2})
+	defer stop()
+
+	atomic.StoreInt32(&failures, 2)
+	_, err := p.Records()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&failures, 3)
+	_, err = p.Records()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "temporarily unavailable")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// client errors aren't retried
+	atomic.StoreInt32(&requests, 0)
+	err = p.ApplyChanges(&plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeA, "1.2.3.5")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 400")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// changes aren't retried after server errors, they might have been applied
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&failures, 1)
+	err = p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 503")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestWebhookProviderRetriesChanges(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	handler := NewWebhookHandler(im)
+
+	var requests, throttled int32
+	limited := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&throttled, -1) >= 0 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	p, stop := newWebhookTestProvider(t, limited, WebhookConfig{Retries: 2})
+	defer stop()
+	refused := &refusingTransport{RoundTripper: p.client.Transport}
+	p.client.Transport = refused
+
+	// changes are retried if the webhook asks for it or they couldn't be sent at all
+	atomic.StoreInt32(&throttled, 1)
+	refused.failures = 1
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")},
+	}))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, 3, refused.requests)
+
+	records, err := im.Records()
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+// refusingTransport fails the first requests before sending them, like a refused connection
+type refusingTransport struct {
+	http.RoundTripper
+	failures int
+	requests int
+}
+
+func (t *refusingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	if t.failures > 0 {
+		t.failures--
+		return nil, errors.New("connection refused")
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+func TestWebhookProviderInternalLabels(t *testing.T) {
+	var changes plan.Changes
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&changes))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	p, stop := newWebhookTestProvider(t, handler, WebhookConfig{})
+	defer stop()
+
+	txt := endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`)
+	txt.Labels[endpoint.OwnerLabelKey] = "default"
+	txt.Labels[endpoint.OwnedRecordLabelKey] = "foo.example.org"
+	txt.Labels[endpoint.CreationTimestampLabelKey] = "2018-10-01T12:30:00Z"
+	require.NoError(t, p.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{txt}}))
+
+	require.Len(t, changes.Create, 1)
+	assert.Equal(t, endpoint.Labels{endpoint.OwnerLabelKey: "default"}, changes.Create[0].Labels)
+	// the changes of the caller are left untouched
+	assert.Equal(t, "foo.example.org", txt.Labels[endpoint.OwnedRecordLabelKey])
+}
+
+func TestWebhookProviderTimeout(t *testing.T) {
+	done := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	})
+
+	p, stop := newWebhookTestProvider(t, slow, WebhookConfig{Timeout: 10 * time.Millisecond})
+	defer stop()
+	defer close(done)
+
+	_, err := p.Records()
+	assert.Error(t, err)
+}
+
+func TestWebhookProviderTLS(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	require.NoError(t, im.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")},
+	}))
+	server := httptest.NewTLSServer(NewWebhookHandler(im))
+	defer server.Close()
+
+	// the certificate of the server isn't trusted without the CA file
+	p, err := NewWebhookProvider(WebhookConfig{URL: server.URL})
+	require.NoError(t, err)
+	_, err = p.Records()
+	assert.Error(t, err)
+
+	caFile, err := ioutil.TempFile("", "webhook-ca")
+	require.NoError(t, err)
+	defer os.Remove(caFile.Name())
+	require.NoError(t, pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	require.NoError(t, caFile.Close())
+
+	p, err = NewWebhookProvider(WebhookConfig{URL: server.URL, CAFilePath: caFile.Name()})
+	require.NoError(t, err)
+	records, err := p.Records()
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestWebhookHandler(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	server := httptest.NewServer(NewWebhookHandler(im))
+	defer server.Close()
+
+	for _, ti := range []struct {
+		title       string
+		method      string
+		path        string
+		accept      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"records", http.MethodGet, "/records", webhookContentType, "", "", http.StatusOK},
+		{"no accept header", http.MethodGet, "/records", "", "", "", http.StatusOK},
+		{"media type without version", http.MethodGet, "/records", WebhookMediaType, "", "", http.StatusOK},
+		{"wildcard", http.MethodGet, "/records", "text/html, */*;q=0.1", "", "", http.StatusOK},
+		{"unsupported version", http.MethodGet, "/records", WebhookMediaType + ";version=2", "", "", http.StatusNotAcceptable},
+		{"unsupported media type", http.MethodGet, "/records", "text/html", "", "", http.StatusNotAcceptable},
+		{"unknown path", http.MethodGet, "/zones", webhookContentType, "", "", http.StatusNotFound},
+		{"unsupported method", http.MethodDelete, "/records", webhookContentType, "", "", http.StatusMethodNotAllowed},
+		{"changes", http.MethodPost, "/records", webhookContentType, webhookContentType, `{"create":[]}`, http.StatusNoContent},
+		{"changes with unsupported content type", http.MethodPost, "/records", webhookContentType, "application/json", `{"create":[]}`, http.StatusUnsupportedMediaType},
+		{"changes with unsupported version", http.MethodPost, "/records", webhookContentType, WebhookMediaType + ";version=2", `{"create":[]}`, http.StatusUnsupportedMediaType},
+		{"invalid changes", http.MethodPost, "/records", webhookContentType, webhookContentType, `{"create":`, http.StatusBadRequest},
+		{"unpaired updates", http.MethodPost, "/records", webhookContentType, webhookContentType, `{"updateOld":[{"dnsName":"foo.example.org"}]}`, http.StatusBadRequest},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			req, err := http.NewRequest(ti.method, server.URL+ti.path, strings.NewReader(ti.body))
+			require.NoError(t, err)
+			if ti.accept != "" {
+				req.Header.Set("Accept", ti.accept)
+			}
+			if ti.contentType != "" {
+				req.Header.Set("Content-Type", ti.contentType)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, ti.status, resp.StatusCode)
+			if ti.status != http.StatusNoContent {
+				assert.Equal(t, webhookContentType, resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestWebhookProviderUnsupportedVersion(t *testing.T) {
+	v2 := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", WebhookMediaType+";version=2")
+		w.Write([]byte("[]"))
+	})
+	p, stop := newWebhookTestProvider(t, v2, WebhookConfig{})
+	defer stop()
+
+	_, err := p.Records()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported content type")
+}
+
+func TestCheckWebhookMediaType(t *testing.T) {
+	for contentType, expected := range map[string]error{
+		webhookContentType:                   nil,
+		WebhookMediaType:                     nil,
+		WebhookMediaType + "; version=\"1\"": nil,
+		WebhookMediaType + ";version=2":      errors.New("unsupported"),
+		"application/json":                   errors.New("unsupported"),
+		"":                                   errors.New("invalid"),
+	} {
+		err := checkWebhookMediaType(contentType)
+		if expected == nil {
+			assert.NoError(t, err, contentType)
+		} else if assert.Error(t, err, contentType) {
+			assert.Contains(t, err.Error(), expected.Error())
+		}
+	}
+}
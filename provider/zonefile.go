@@ -0,0 +1,606 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+const (
+	// zonefileSuffix is the suffix of the zone files in the directory, the zone name is the file name without it
+	zonefileSuffix = ".zone"
+	// zonefileBegin and zonefileEnd enclose the records owned by ExternalDNS in a zone file
+	zonefileBegin = "; BEGIN external-dns"
+	zonefileEnd   = "; END external-dns"
+	// zonefileDefaultTTL is the TTL of records without a configured TTL
+	zonefileDefaultTTL = 300
+)
+
+// ZonefileConfig is comprised of the fields necessary to create a new ZonefileProvider
+type ZonefileConfig struct {
+	// Directory holds one RFC 1035 master file per zone, named after the zone, e.g. example.org.zone
+	Directory    string
+	DomainFilter DomainFilter
+	DryRun       bool
+	// ReloadCommand is run by the shell after a zone file was written, with the zone name and the
+	// path of the file in the environment variables ZONE and ZONE_FILE
+	ReloadCommand string
+}
+
+// ZonefileProvider manages the records of RFC 1035 master files, e.g. for BIND, NSD or Knot. The records
+// created by ExternalDNS are kept in a block of each file, all other records and comments are left as they are.
+type ZonefileProvider struct {
+	directory     string
+	domainFilter  DomainFilter
+	dryRun        bool
+	reloadCommand string
+	// reloadPending holds the paths of the zone files which were written but failed to reload,
+	// the reload is retried until it succeeds
+	reloadPending map[string]bool
+	// now returns the current time for date based SOA serials, it's replaced in tests
+	now func() time.Time
+}
+
+// zonefile is a parsed zone file
+type zonefile struct {
+	path   string
+	origin string
+	mode   os.FileMode
+	// lines holds the text of the file, the lines begin and end enclose the records owned by
+	// ExternalDNS or are -1 if the file has no such block yet
+	lines      []string
+	begin, end int
+	soa        *dns.SOA
+	records    []dns.RR
+	owned      []dns.RR
+}
+
+// zonefileKey identifies the record set of a name and type
+type zonefileKey struct {
+	name       string
+	recordType uint16
+}
+
+// NewZonefileProvider initializes a new provider for the zone files of the directory
+func NewZonefileProvider(config ZonefileConfig) (*ZonefileProvider, error) {
+	info, err := os.Stat(config.Directory)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", config.Directory)
+	}
+
+	return &ZonefileProvider{
+		directory:     config.Directory,
+		domainFilter:  config.DomainFilter,
+		dryRun:        config.DryRun,
+		reloadCommand: config.ReloadCommand,
+		reloadPending: map[string]bool{},
+		now:           time.Now,
+	}, nil
+}
+
+// zones returns the paths of the zone files by zone name
+func (p *ZonefileProvider) zones() (zoneIDName, error) {
+	files, err := ioutil.ReadDir(p.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := zoneIDName{}
+	for _, file := range files {
+		if !file.Mode().IsRegular() || !strings.HasSuffix(file.Name(), zonefileSuffix) {
+			continue
+		}
+		zoneName := strings.TrimSuffix(strings.TrimSuffix(file.Name(), zonefileSuffix), ".")
+		if !p.domainFilter.Match(zoneName) {
+			continue
+		}
+		zones.Add(filepath.Join(p.directory, file.Name()), zoneName)
+	}
+	return zones, nil
+}
+
+// Records returns the A, AAAA, CNAME, TXT, SRV and MX records of all zone files
+func (p *ZonefileProvider) Records() ([]*endpoint.Endpoint, error) {
+	zones, err := p.zones()
+	if err != nil {
+		return nil, err
+	}
+	// a failed reload is reported by ApplyChanges, which retries it again
+	if err := p.reloadPendingZones(zones); err != nil {
+		log.Error(err)
+	}
+
+	var endpoints []*endpoint.Endpoint
+	for path, zoneName := range zones {
+		zf, err := loadZonefile(path, zoneName)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, zonefileEndpoints(zf.records)...)
+	}
+	return endpoints, nil
+}
+
+// ApplyChanges changes the records owned by ExternalDNS in the zone files. Changes of records
+// which aren't owned by ExternalDNS fail, the other changes are applied nevertheless. Zones whose
+// reload failed before are reloaded again, even without changes.
+func (p *ZonefileProvider) ApplyChanges(changes *plan.Changes) error {
+	zones, err := p.zones()
+	if err != nil {
+		return err
+	}
+
+	type zoneChanges struct {
+		create, updateOld, updateNew, delete []*endpoint.Endpoint
+	}
+	perZone := map[string]*zoneChanges{}
+	var failed []*endpoint.Endpoint
+	add := func(ep *endpoint.Endpoint, f func(*zoneChanges) *[]*endpoint.Endpoint) {
+		path, _ := zones.FindZone(strings.TrimSuffix(ep.DNSName, "."))
+		if path == "" {
+			log.Debugf("Skipping record %s because no zone file matches it", ep.DNSName)
+			return
+		}
+		if perZone[path] == nil {
+			perZone[path] = &zoneChanges{}
+		}
+		list := f(perZone[path])
+		*list = append(*list, ep)
+	}
+	for _, ep := range changes.Create {
+		add(ep, func(c *zoneChanges) *[]*endpoint.Endpoint { return &c.create })
+	}
+	for i, ep := range changes.UpdateNew {
+		add(changes.UpdateOld[i], func(c *zoneChanges) *[]*endpoint.Endpoint { return &c.updateOld })
+		add(ep, func(c *zoneChanges) *[]*endpoint.Endpoint { return &c.updateNew })
+	}
+	for _, ep := range changes.Delete {
+		add(ep, func(c *zoneChanges) *[]*endpoint.Endpoint { return &c.delete })
+	}
+
+	paths := make([]string, 0, len(perZone))
+	for path := range perZone {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		c := perZone[path]
+		zf, err := loadZonefile(path, zones[path])
+		if err != nil {
+			return err
+		}
+
+		existing := map[zonefileKey]bool{}
+		for _, rr := range zf.records {
+			existing[zonefileKeyOf(rr)] = true
+		}
+		owned := map[zonefileKey][]dns.RR{}
+		for _, rr := range zf.owned {
+			owned[zonefileKeyOf(rr)] = append(owned[zonefileKeyOf(rr)], rr)
+		}
+
+		changed := false
+		for _, ep := range c.delete {
+			key, _, err := zonefileEndpointRRs(ep)
+			if err != nil || owned[key] == nil {
+				log.Errorf("Failed to delete record %s %s from %s: %v", ep.DNSName, ep.RecordType, path, zonefileOwnershipError(err))
+				failed = append(failed, ep)
+				continue
+			}
+			log.Infof("Delete record %s %s from %s", ep.DNSName, ep.RecordType, path)
+			delete(owned, key)
+			changed = true
+		}
+		for i, ep := range c.updateNew {
+			oldKey, _, err := zonefileEndpointRRs(c.updateOld[i])
+			key, rrs, newErr := zonefileEndpointRRs(ep)
+			if err == nil {
+				err = newErr
+			}
+			if err != nil || owned[oldKey] == nil || key != oldKey {
+				log.Errorf("Failed to update record %s %s in %s: %v", ep.DNSName, ep.RecordType, path, zonefileOwnershipError(err))
+				failed = append(failed, ep)
+				continue
+			}
+			log.Infof("Update record %s %s in %s to %s", ep.DNSName, ep.RecordType, path, ep.Targets)
+			owned[key] = rrs
+			changed = true
+		}
+		for _, ep := range c.create {
+			key, rrs, err := zonefileEndpointRRs(ep)
+			if err == nil && (existing[key] || owned[key] != nil) {
+				err = ErrRecordAlreadyExists
+			}
+			if err != nil {
+				log.Errorf("Failed to create record %s %s in %s: %v", ep.DNSName, ep.RecordType, path, err)
+				failed = append(failed, ep)
+				continue
+			}
+			log.Infof("Create record %s %s in %s with %s", ep.DNSName, ep.RecordType, path, ep.Targets)
+			owned[key] = rrs
+			changed = true
+		}
+
+		if !changed || p.dryRun {
+			continue
+		}
+		if err := p.write(zf, owned); err != nil {
+			return err
+		}
+	}
+
+	if err := p.reloadPendingZones(zones); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &FailedChangesError{Endpoints: failed}
+	}
+	return nil
+}
+
+// write replaces the records owned by ExternalDNS in the zone file, bumps its SOA serial and runs
+// the reload command, a failed reload stays pending
+func (p *ZonefileProvider) write(zf *zonefile, owned map[zonefileKey][]dns.RR) error {
+	serial := zonefileNextSerial(zf.soa.Serial, p.now())
+	if !zf.setSOASerial(serial) {
+		return fmt.Errorf("failed to find the serial of the SOA record of %s", zf.path)
+	}
+
+	keys := make([]zonefileKey, 0, len(owned))
+	for key := range owned {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].recordType < keys[j].recordType
+	})
+	block := []string{zonefileBegin}
+	for _, key := range keys {
+		for _, rr := range owned[key] {
+			block = append(block, rr.String())
+		}
+	}
+	block = append(block, zonefileEnd)
+
+	var lines []string
+	if zf.begin < 0 {
+		lines = zf.lines
+		// the block is appended in its own paragraph
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			lines = lines[:n-1]
+		}
+		lines = append(append(lines, ""), block...)
+		lines = append(lines, "")
+	} else {
+		lines = append(append(append([]string{}, zf.lines[:zf.begin]...), block...), zf.lines[zf.end+1:]...)
+	}
+	data := []byte(strings.Join(lines, "\n"))
+
+	// the file is only written if it's still valid
+	if _, err := parseZonefile(data, zf.origin, zf.path); err != nil {
+		return err
+	}
+
+	if err := writeZonefile(zf.path, data, zf.mode); err != nil {
+		return err
+	}
+	log.Infof("Wrote %s with SOA serial %d", zf.path, serial)
+
+	if p.reloadCommand == "" {
+		return nil
+	}
+	p.reloadPending[zf.path] = true
+	return p.reload(zf.path, strings.TrimSuffix(zf.origin, "."))
+}
+
+// reloadPendingZones retries the reload of the zone files which failed to reload before
+func (p *ZonefileProvider) reloadPendingZones(zones zoneIDName) error {
+	paths := make([]string, 0, len(p.reloadPending))
+	for path := range p.reloadPending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs []string
+	for _, path := range paths {
+		zoneName, ok := zones[path]
+		if !ok {
+			// the zone file was removed or doesn't match the domain filter anymore
+			delete(p.reloadPending, path)
+			continue
+		}
+		if err := p.reload(path, zoneName); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// reload runs the reload command for the zone file, it isn't pending anymore once the command succeeded
+func (p *ZonefileProvider) reload(path, zoneName string) error {
+	cmd := exec.Command("/bin/sh", "-c", p.reloadCommand)
+	cmd.Env = append(os.Environ(), "ZONE="+zoneName, "ZONE_FILE="+path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to reload zone %s: %v: %s", zoneName, err, strings.TrimSpace(string(output)))
+	}
+	delete(p.reloadPending, path)
+	log.Infof("Reloaded zone %s", zoneName)
+	return nil
+}
+
+// loadZonefile reads and parses the zone file at the path
+func loadZonefile(path, zoneName string) (*zonefile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	zf := &zonefile{
+		path:   path,
+		origin: dns.Fqdn(zoneName),
+		mode:   info.Mode().Perm(),
+		lines:  strings.Split(string(data), "\n"),
+		begin:  -1,
+		end:    -1,
+	}
+
+	for i, line := range zf.lines {
+		switch strings.TrimSpace(line) {
+		case zonefileBegin:
+			if zf.begin >= 0 {
+				return nil, fmt.Errorf("%s:%d: duplicate %q", path, i+1, zonefileBegin)
+			}
+			zf.begin = i
+		case zonefileEnd:
+			if zf.begin < 0 || zf.end >= 0 {
+				return nil, fmt.Errorf("%s:%d: unexpected %q", path, i+1, zonefileEnd)
+			}
+			zf.end = i
+		}
+	}
+	if zf.begin >= 0 && zf.end < 0 {
+		return nil, fmt.Errorf("%s: missing %q", path, zonefileEnd)
+	}
+
+	if zf.records, err = parseZonefile(data, zf.origin, path); err != nil {
+		return nil, err
+	}
+	for _, rr := range zf.records {
+		if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, zf.origin) {
+			zf.soa = soa
+			break
+		}
+	}
+	if zf.soa == nil {
+		return nil, fmt.Errorf("%s: missing SOA record of %s", path, zf.origin)
+	}
+
+	if zf.begin >= 0 {
+		block := strings.Join(zf.lines[zf.begin+1:zf.end], "\n")
+		if zf.owned, err = parseZonefile([]byte(block), zf.origin, path); err != nil {
+			return nil, err
+		}
+	}
+	return zf, nil
+}
+
+// parseZonefile returns the records of the zone file data
+func parseZonefile(data []byte, origin, path string) ([]dns.RR, error) {
+	var rrs []dns.RR
+	var err error
+	// the tokens have to be read until the channel is closed, also after an error
+	for token := range dns.ParseZone(bytes.NewReader(data), origin, path) {
+		if token.Error != nil {
+			if err == nil {
+				err = token.Error
+			}
+			continue
+		}
+		if err == nil {
+			rrs = append(rrs, token.RR)
+		}
+	}
+	return rrs, err
+}
+
+// setSOASerial replaces the serial in the text of the SOA record, it returns false if it's not found.
+// The text of the zone is split into tokens, skipping comments and parentheses, the serial is the
+// third token after the SOA type.
+func (zf *zonefile) setSOASerial(serial uint32) bool {
+	soaToken := -1
+	for i, line := range zf.lines {
+		if zf.begin >= 0 && i >= zf.begin && i <= zf.end {
+			continue
+		}
+		for pos := 0; pos < len(line); {
+			switch c := line[pos]; {
+			case c == ';':
+				pos = len(line)
+				continue
+			case c == ' ' || c == '\t' || c == '\r' || c == '(' || c == ')':
+				pos++
+				continue
+			}
+
+			start := pos
+			if line[pos] == '"' {
+				for pos++; pos < len(line) && line[pos] != '"'; pos++ {
+					if line[pos] == '\\' {
+						pos++
+					}
+				}
+				pos++
+			} else {
+				for pos < len(line) && !strings.ContainsRune(" \t\r();", rune(line[pos])) {
+					pos++
+				}
+			}
+			if pos > len(line) {
+				pos = len(line)
+			}
+			token := line[start:pos]
+
+			switch {
+			case soaToken < 0 && strings.EqualFold(token, "SOA"):
+				soaToken = 0
+			case soaToken >= 0:
+				soaToken++
+				if soaToken < 3 {
+					continue
+				}
+				if value, err := strconv.ParseUint(token, 10, 32); err == nil && uint32(value) == zf.soa.Serial {
+					zf.lines[i] = line[:start] + strconv.FormatUint(uint64(serial), 10) + line[pos:]
+					return true
+				}
+				// the token wasn't a SOA type, e.g. an owner name, the search goes on
+				soaToken = -1
+			}
+		}
+	}
+	return false
+}
+
+// zonefileNextSerial returns the SOA serial following the given one. Serials in the date format
+// YYYYMMDDnn continue with the current date.
+func zonefileNextSerial(serial uint32, now time.Time) uint32 {
+	now = now.UTC()
+	date := uint32(now.Year()*1000000 + int(now.Month())*10000 + now.Day()*100)
+	if serial >= 1970010100 && serial < date {
+		return date
+	}
+	return serial + 1
+}
+
+// writeZonefile replaces the file atomically, so that the DNS server never reads a partially written file
+func writeZonefile(path string, data []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// zonefileEndpoints returns the endpoints of the records, one per record set
+func zonefileEndpoints(rrs []dns.RR) []*endpoint.Endpoint {
+	var endpoints []*endpoint.Endpoint
+	index := map[zonefileKey]*endpoint.Endpoint{}
+	for _, rr := range rrs {
+		if rr.Header().Class != dns.ClassINET {
+			continue
+		}
+
+		var recordType, target string
+		switch rr := rr.(type) {
+		case *dns.A:
+			recordType, target = endpoint.RecordTypeA, rr.A.String()
+		case *dns.AAAA:
+			recordType, target = endpoint.RecordTypeAAAA, rr.AAAA.String()
+		case *dns.CNAME:
+			recordType, target = endpoint.RecordTypeCNAME, strings.TrimSuffix(rr.Target, ".")
+		case *dns.TXT:
+			recordType, target = endpoint.RecordTypeTXT, strings.Join(rr.Txt, "")
+		case *dns.SRV:
+			recordType, target = endpoint.RecordTypeSRV, fmt.Sprintf("%d %d %d %s", rr.Priority, rr.Weight, rr.Port, strings.TrimSuffix(rr.Target, "."))
+		case *dns.MX:
+			recordType, target = endpoint.RecordTypeMX, fmt.Sprintf("%d %s", rr.Preference, strings.TrimSuffix(rr.Mx, "."))
+		default:
+			continue
+		}
+
+		key := zonefileKeyOf(rr)
+		if ep, ok := index[key]; ok {
+			ep.Targets = append(ep.Targets, target)
+			continue
+		}
+		ep := endpoint.NewEndpointWithTTL(strings.TrimSuffix(rr.Header().Name, "."), recordType, endpoint.TTL(rr.Header().Ttl), target)
+		index[key] = ep
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints
+}
+
+// zonefileEndpointRRs returns the key and the records of the endpoint
+func zonefileEndpointRRs(ep *endpoint.Endpoint) (zonefileKey, []dns.RR, error) {
+	ttl := uint32(zonefileDefaultTTL)
+	if ep.RecordTTL.IsConfigured() {
+		ttl = uint32(ep.RecordTTL)
+	}
+	rrs, err := targetRRs(dns.Fqdn(ep.DNSName), ep.RecordType, ttl, ep.Targets)
+	if err != nil {
+		return zonefileKey{}, nil, err
+	}
+	return zonefileKey{name: strings.ToLower(dns.Fqdn(ep.DNSName)), recordType: dns.StringToType[ep.RecordType]}, rrs, nil
+}
+
+// zonefileKeyOf returns the key of the record set of the record
+func zonefileKeyOf(rr dns.RR) zonefileKey {
+	return zonefileKey{name: strings.ToLower(rr.Header().Name), recordType: rr.Header().Rrtype}
+}
+
+// zonefileOwnershipError returns the error of a change of a record set, which isn't owned by ExternalDNS
+// if there is no other error
+func zonefileOwnershipError(err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("record isn't owned by ExternalDNS, it's missing or outside of the %q block", zonefileBegin)
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/internal/testutils"
+	"github.com/kubernetes-incubator/external-dns/plan"
+)
+
+const testZonefile = `; example.org, maintained in git
+$ORIGIN example.org.
+$TTL 3600
+@	IN	SOA	ns1 hostmaster (
+		2018010101 ; serial
+		3600       ; refresh
+		600        ; retry
+		86400      ; expire
+		300 )      ; minimum
+
+	IN	NS	ns1
+	IN	MX	10 mail ; the mail server
+ns1	IN	A	192.0.2.1
+mail	IN	A	192.0.2.2
+www	300	IN	A	192.0.2.3
+	300	IN	A	192.0.2.4
+ftp	IN	CNAME	www
+_ldap._tcp	IN	SRV	10 5 389 ns1
+info	IN	TXT	"managed by hand"
+`
+
+const testSubZonefile = `$ORIGIN sub.example.org.
+@ 3600 IN SOA ns1.example.org. hostmaster.example.org. 7 3600 600 86400 300
+@ 3600 IN NS ns1.example.org.
+`
+
+func newZonefileTestProvider(t *testing.T, config ZonefileConfig) (*ZonefileProvider, string, func()) {
+	dir, err := ioutil.TempDir("", "external-dns-zonefile")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "example.org.zone"), []byte(testZonefile), 0640))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub.example.org.zone"), []byte(testSubZonefile), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a zone"), 0644))
+
+	config.Directory = dir
+	p, err := NewZonefileProvider(config)
+	require.NoError(t, err)
+	p.now = func() time.Time { return time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC) }
+	return p, dir, func() { os.RemoveAll(dir) }
+}
+
+func readTestZonefile(t *testing.T, dir, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestNewZonefileProvider(t *testing.T) {
+	_, err := NewZonefileProvider(ZonefileConfig{Directory: "/non/existing/directory"})
+	assert.Error(t, err)
+
+	file, err := ioutil.TempFile("", "external-dns-zonefile")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.Close()
+	_, err = NewZonefileProvider(ZonefileConfig{Directory: file.Name()})
+	assert.Error(t, err)
+}
+
+func TestZonefileRecords(t *testing.T) {
+	p, _, cleanup := newZonefileTestProvider(t, ZonefileConfig{})
+	defer cleanup()
+
+	records, err := p.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeMX, 3600, "10 mail.example.org"),
+		endpoint.NewEndpointWithTTL("ns1.example.org", endpoint.RecordTypeA, 3600, "192.0.2.1"),
+		endpoint.NewEndpointWithTTL("mail.example.org", endpoint.RecordTypeA, 3600, "192.0.2.2"),
+		endpoint.NewEndpointWithTTL("www.example.org", endpoint.RecordTypeA, 300, "192.0.2.3", "192.0.2.4"),
+		endpoint.NewEndpointWithTTL("ftp.example.org", endpoint.RecordTypeCNAME, 3600, "www.example.org"),
+		endpoint.NewEndpointWithTTL("_ldap._tcp.example.org", endpoint.RecordTypeSRV, 3600, "10 5 389 ns1.example.org"),
+		endpoint.NewEndpointWithTTL("info.example.org", endpoint.RecordTypeTXT, 3600, "managed by hand"),
+	}, records), "got %v", records)
+
+	p.domainFilter = NewDomainFilter([]string{"sub.example.org"})
+	records, err = p.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestZonefileRecordsInvalidFiles(t *testing.T) {
+	for _, content := range []string{
+		"$ORIGIN example.org.\nwww IN A 192.0.2.1\n",
+		testZonefile + "www IN A 300.0.0.1\n",
+		testZonefile + zonefileBegin + "\nnew 300 IN A 192.0.2.5\n",
+		testZonefile + zonefileEnd + "\n",
+		testZonefile + zonefileBegin + "\n" + zonefileBegin + "\n" + zonefileEnd + "\n",
+	} {
+		p, dir, cleanup := newZonefileTestProvider(t, ZonefileConfig{})
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "example.org.zone"), []byte(content), 0644))
+
+		_, err := p.Records()
+		assert.Error(t, err, content)
+		cleanup()
+	}
+}
+
+func TestZonefileApplyChanges(t *testing.T) {
+	p, dir, cleanup := newZonefileTestProvider(t, ZonefileConfig{})
+	defer cleanup()
+
+	records, err := p.Records()
+	require.NoError(t, err)
+
+	a := endpoint.NewEndpoint("new.example.org", endpoint.RecordTypeA, "192.0.2.5", "192.0.2.6")
+	txt := endpoint.NewEndpoint("new.example.org", endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`)
+	srv := endpoint.NewEndpointWithTTL("_http._tcp.example.org", endpoint.RecordTypeSRV, 60, "10 5 8080 new.example.org")
+	sub := endpoint.NewEndpoint("foo.sub.example.org", endpoint.RecordTypeCNAME, "new.example.org")
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{a, txt, srv, sub, endpoint.NewEndpoint("example.com", endpoint.RecordTypeA, "192.0.2.7")},
+	}))
+
+	// the records and comments of the file are kept, the owned records are appended
+	assert.Equal(t, strings.Replace(testZonefile, "2018010101 ; serial", "2018060100 ; serial", 1)+`
+; BEGIN external-dns
+_http._tcp.example.org.	60	IN	SRV	10 5 8080 new.example.org.
+new.example.org.	300	IN	A	192.0.2.5
+new.example.org.	300	IN	A	192.0.2.6
+new.example.org.	300	IN	TXT	"heritage=external-dns,external-dns/owner=default"
+; END external-dns
+`, readTestZonefile(t, dir, "example.org.zone"))
+	assert.Equal(t, strings.Replace(testSubZonefile, " 7 ", " 8 ", 1)+`
+; BEGIN external-dns
+foo.sub.example.org.	300	IN	CNAME	new.example.org.
+; END external-dns
+`, readTestZonefile(t, dir, "sub.example.org.zone"))
+
+	updated, err := p.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(append(records,
+		endpoint.NewEndpointWithTTL("new.example.org", endpoint.RecordTypeA, 300, "192.0.2.5", "192.0.2.6"),
+		endpoint.NewEndpointWithTTL("new.example.org", endpoint.RecordTypeTXT, 300, "heritage=external-dns,external-dns/owner=default"),
+		endpoint.NewEndpointWithTTL("_http._tcp.example.org", endpoint.RecordTypeSRV, 60, "10 5 8080 new.example.org"),
+		endpoint.NewEndpointWithTTL("foo.sub.example.org", endpoint.RecordTypeCNAME, 300, "new.example.org"),
+	), updated), "got %v", updated)
+
+	// the block is replaced, also the serial of a date is bumped on the same day
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{a},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("new.example.org", endpoint.RecordTypeA, 60, "192.0.2.8")},
+		Delete:    []*endpoint.Endpoint{srv},
+	}))
+	assert.Equal(t, strings.Replace(testZonefile, "2018010101 ; serial", "2018060101 ; serial", 1)+`
+; BEGIN external-dns
+new.example.org.	60	IN	A	192.0.2.8
+new.example.org.	300	IN	TXT	"heritage=external-dns,external-dns/owner=default"
+; END external-dns
+`, readTestZonefile(t, dir, "example.org.zone"))
+
+	// the file mode is kept and no temporary files are left
+	info, err := os.Stat(filepath.Join(dir, "example.org.zone"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestZonefileApplyChangesUnownedRecords(t *testing.T) {
+	p, dir, cleanup := newZonefileTestProvider(t, ZonefileConfig{})
+	defer cleanup()
+
+	err := p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "192.0.2.5"),
+			endpoint.NewEndpoint("new.example.org", "NS", "ns1.example.org"),
+		},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.org", endpoint.RecordTypeA, 300, "192.0.2.3", "192.0.2.4")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeA, "192.0.2.5")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("ftp.example.org", endpoint.RecordTypeCNAME, "www.example.org")},
+	})
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Len(t, err.(*FailedChangesError).Endpoints, 4)
+
+	// the file isn't written without changes
+	assert.Equal(t, testZonefile, readTestZonefile(t, dir, "example.org.zone"))
+
+	// the other changes are applied
+	err = p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.org", endpoint.RecordTypeA, "192.0.2.5")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("ftp.example.org", endpoint.RecordTypeCNAME, "www.example.org")},
+	})
+	require.IsType(t, &FailedChangesError{}, err)
+	assert.Len(t, err.(*FailedChangesError).Endpoints, 1)
+	assert.Contains(t, readTestZonefile(t, dir, "example.org.zone"), "new.example.org.\t300\tIN\tA\t192.0.2.5")
+}
+
+func TestZonefileApplyChangesDryRun(t *testing.T) {
+	p, dir, cleanup := newZonefileTestProvider(t, ZonefileConfig{DryRun: true, ReloadCommand: "exit 1"})
+	defer cleanup()
+
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.org", endpoint.RecordTypeA, "192.0.2.5")},
+	}))
+	assert.Equal(t, testZonefile, readTestZonefile(t, dir, "example.org.zone"))
+}
+
+func TestZonefileReloadCommand(t *testing.T) {
+	p, dir, cleanup := newZonefileTestProvider(t, ZonefileConfig{})
+	defer cleanup()
+
+	p.reloadCommand = `echo "$ZONE $ZONE_FILE" >> ` + filepath.Join(dir, "reloaded")
+	require.NoError(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.org", endpoint.RecordTypeA, "192.0.2.5")},
+	}))
+	assert.Equal(t, "example.org "+filepath.Join(dir, "example.org.zone")+"\n", readTestZonefile(t, dir, "reloaded"))
+
+	p.reloadCommand = "echo zone not found >&2; exit 1"
+	err := p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new2.example.org", endpoint.RecordTypeA, "192.0.2.6")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "zone not found")
+}
+
+func TestZonefileReloadRetried(t *testing.T) {
+	p, dir, cleanup := newZonefileTestProvider(t, ZonefileConfig{})
+	defer cleanup()
+
+	reloaded := filepath.Join(dir, "reloaded")
+	p.reloadCommand = "echo zone not found >&2; exit 1"
+	err := p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.org", endpoint.RecordTypeA, "192.0.2.5")},
+	})
+	require.Error(t, err)
+
+	// the next sync reloads the zone although nothing changed
+	p.reloadCommand = `echo "$ZONE $ZONE_FILE" >> ` + reloaded
+	_, err = p.Records()
+	require.NoError(t, err)
+	require.NoError(t, p.ApplyChanges(&plan.Changes{}))
+	assert.Equal(t, "example.org "+filepath.Join(dir, "example.org.zone")+"\n", readTestZonefile(t, dir, "reloaded"))
+
+	// and only once
+	_, err = p.Records()
+	require.NoError(t, err)
+	require.NoError(t, p.ApplyChanges(&plan.Changes{}))
+	assert.Equal(t, "example.org "+filepath.Join(dir, "example.org.zone")+"\n", readTestZonefile(t, dir, "reloaded"))
+
+	// a reload failing again fails the sync again
+	p.reloadCommand = "echo zone not found >&2; exit 1"
+	require.Error(t, p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new2.example.org", endpoint.RecordTypeA, "192.0.2.6")},
+	}))
+	err = p.ApplyChanges(&plan.Changes{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "zone not found")
+}
+
+func TestZonefileNextSerial(t *testing.T) {
+	now := time.Date(2018, 6, 1, 23, 0, 0, 0, time.FixedZone("UTC-2", -2*3600))
+	for _, ti := range []struct {
+		serial   uint32
+		expected uint32
+	}{
+		{1, 2},
+		{2018010101, 2018060200},
+		{2018060200, 2018060201},
+		{2018060299, 2018060300},
+		{2018070100, 2018070101},
+		{4294967295, 0},
+	} {
+		assert.Equal(t, ti.expected, zonefileNextSerial(ti.serial, now), "serial %d", ti.serial)
+	}
+}
@@ -66,7 +66,7 @@ func (sdr *AWSSDRegistry) Records() ([]*endpoint.Endpoint, error) {
 // inserted in the AWS SD instance as a CreateID field
 func (sdr *AWSSDRegistry) ApplyChanges(changes *plan.Changes) error {
 	filteredChanges := &plan.Changes{
-		Create:    changes.Create,
+		Create:    filterReplacingRecords(sdr.ownerID, changes.Create, changes.Delete),
 		UpdateNew: filterOwnedRecords(sdr.ownerID, changes.UpdateNew),
 		UpdateOld: filterOwnedRecords(sdr.ownerID, changes.UpdateOld),
 		Delete:    filterOwnedRecords(sdr.ownerID, changes.Delete),
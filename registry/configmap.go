@@ -0,0 +1,373 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/plan"
+	"github.com/kubernetes-incubator/external-dns/provider"
+)
+
+// configMapUpdateRetries is how often an update of the ConfigMap is retried after
+// it was modified concurrently.
+const configMapUpdateRetries = 5
+
+// ConfigMapRegistry implements registry interface with ownership stored in a Kubernetes ConfigMap
+// instead of the DNS provider. Every record has an entry keyed by its type, name and set identifier
+// holding its labels.
+type ConfigMapRegistry struct {
+	provider  provider.Provider
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	ownerID   string //refers to the owner id of the current instance
+	dryRun    bool
+
+	// keys of owned entries whose record didn't exist at the last call to the provider's Records,
+	// by the record standing in for them. They are deleted as far as the policy allows the deletion
+	// of these records, they are kept without a policy
+	staleEntries map[*endpoint.Endpoint]string
+	policy       plan.Policy
+
+	// cache the records in memory and update on an interval instead.
+	recordsCache            []*endpoint.Endpoint
+	recordsCacheRefreshTime time.Time
+	cacheInterval           time.Duration
+}
+
+// NewConfigMapRegistry returns new ConfigMapRegistry object storing the ownership in the named ConfigMap.
+// Entries of owned records which don't exist anymore are deleted if the policy allows it, a nil policy keeps them.
+func NewConfigMapRegistry(provider provider.Provider, client kubernetes.Interface, namespace, name, ownerID string, cacheInterval time.Duration, dryRun bool, policy plan.Policy) (*ConfigMapRegistry, error) {
+	if ownerID == "" {
+		return nil, errors.New("owner id cannot be empty")
+	}
+	if namespace == "" || name == "" {
+		return nil, errors.New("namespace and name of the ConfigMap cannot be empty")
+	}
+
+	return &ConfigMapRegistry{
+		provider:      provider,
+		client:        client,
+		namespace:     namespace,
+		name:          name,
+		ownerID:       ownerID,
+		dryRun:        dryRun,
+		policy:        policy,
+		cacheInterval: cacheInterval,
+	}, nil
+}
+
+// Records returns the current records from the dns provider with the labels of
+// their entry in the ConfigMap
+func (im *ConfigMapRegistry) Records() ([]*endpoint.Endpoint, error) {
+	// If we have the zones cached AND we have refreshed the cache since the
+	// last given interval, then just use the cached results.
+	if im.recordsCache != nil && time.Since(im.recordsCacheRefreshTime) < im.cacheInterval {
+		log.Debug("Using cached records.")
+		return im.recordsCache, nil
+	}
+
+	records, err := im.provider.Records()
+	if err != nil {
+		return nil, err
+	}
+	data, err := im.getConfigMapData()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, record := range records {
+		key := ownershipKey(record)
+		keys[key] = true
+
+		record.Labels = endpoint.NewLabels()
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		labels, err := endpoint.NewLabelsFromString(value)
+		if err == endpoint.ErrInvalidHeritage {
+			// entries not written by ExternalDNS are ignored, the record will have an empty owner
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range labels {
+			record.Labels[k] = v
+		}
+	}
+
+	// entries of records deleted by someone else are removed, so that records created
+	// again under their name aren't taken over
+	im.staleEntries = map[*endpoint.Endpoint]string{}
+	for key, value := range data {
+		if labels, err := endpoint.NewLabelsFromString(value); err == nil && labels[endpoint.OwnerLabelKey] == im.ownerID && !keys[key] {
+			im.staleEntries[staleRecord(key, labels)] = key
+		}
+	}
+
+	// Update the cache.
+	if im.cacheInterval > 0 {
+		im.recordsCache = records
+		im.recordsCacheRefreshTime = time.Now()
+	}
+
+	return records, nil
+}
+
+// ApplyChanges claims the ownership of created records in the ConfigMap, propagates the
+// changes to the dns provider and releases the ownership of deleted records afterwards.
+// Records that another instance claimed in the meantime are skipped.
+func (im *ConfigMapRegistry) ApplyChanges(changes *plan.Changes) error {
+	filteredChanges := &plan.Changes{
+		Create:    filterReplacingRecords(im.ownerID, changes.Create, changes.Delete),
+		UpdateNew: filterOwnedRecords(im.ownerID, changes.UpdateNew),
+		UpdateOld: filterOwnedRecords(im.ownerID, changes.UpdateOld),
+		Delete:    filterOwnedRecords(im.ownerID, changes.Delete),
+	}
+	for _, r := range filteredChanges.Create {
+		if r.Labels == nil {
+			r.Labels = make(map[string]string)
+		}
+		r.Labels[endpoint.OwnerLabelKey] = im.ownerID
+	}
+
+	staleKeys := im.deletableStaleKeys()
+	creates, updateOld, updateNew := filteredChanges.Create, filteredChanges.UpdateOld, filteredChanges.UpdateNew
+	err := im.updateConfigMap(func(data map[string]string) {
+		for _, key := range staleKeys {
+			if im.ownedEntry(data, key) {
+				delete(data, key)
+			}
+		}
+
+		// the ConfigMap may have changed since the records were read
+		filteredChanges.Create = []*endpoint.Endpoint{}
+		for _, r := range creates {
+			if _, exists := data[ownershipKey(r)]; exists && !im.ownedEntry(data, ownershipKey(r)) {
+				log.Warnf("Skipping endpoint %v because its ownership was claimed by another instance", r)
+				continue
+			}
+			data[ownershipKey(r)] = r.Labels.Serialize(false)
+			filteredChanges.Create = append(filteredChanges.Create, r)
+		}
+
+		filteredChanges.UpdateOld, filteredChanges.UpdateNew = []*endpoint.Endpoint{}, []*endpoint.Endpoint{}
+		for i, r := range updateNew {
+			if !im.ownedEntry(data, ownershipKey(r)) {
+				log.Warnf("Skipping endpoint %v because it is not owned by this instance anymore", r)
+				continue
+			}
+			data[ownershipKey(r)] = r.Labels.Serialize(false)
+			filteredChanges.UpdateOld = append(filteredChanges.UpdateOld, updateOld[i])
+			filteredChanges.UpdateNew = append(filteredChanges.UpdateNew, r)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	im.staleEntries = nil
+
+	if err := im.provider.ApplyChanges(filteredChanges); err != nil {
+		return err
+	}
+
+	if len(filteredChanges.Delete) > 0 {
+		err = im.updateConfigMap(func(data map[string]string) {
+			for _, r := range filteredChanges.Delete {
+				if im.ownedEntry(data, ownershipKey(r)) {
+					delete(data, ownershipKey(r))
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if im.cacheInterval > 0 {
+		for _, r := range filteredChanges.Create {
+			im.addToCache(r)
+		}
+		for i, r := range filteredChanges.UpdateOld {
+			// replace old version of record in cache
+			im.removeFromCache(r)
+			im.addToCache(filteredChanges.UpdateNew[i])
+		}
+		for _, r := range filteredChanges.Delete {
+			im.removeFromCache(r)
+		}
+	}
+	return nil
+}
+
+/**
+  ConfigMap registry specific private methods
+*/
+
+// ownershipKey returns the key of the record's entry, e.g. cname.foo.example.org, and
+// a.foo.example.org..blue for a record with the set identifier blue, as DNS names can't contain
+// empty labels. Wildcards are replaced as they aren't valid in keys of a ConfigMap. Keys which
+// are still invalid or too long are replaced by their SHA-256 hash after the type, e.g.
+// a.<hash>, which can't clash with a name as it's longer than a DNS label.
+func ownershipKey(r *endpoint.Endpoint) string {
+	key := strings.ToLower(r.RecordType) + "." + encodeWildcard(r.DNSName)
+	if r.SetIdentifier != "" {
+		key += ".." + r.SetIdentifier
+	}
+	if len(validation.IsConfigMapKey(key)) == 0 {
+		return key
+	}
+	hash := sha256.Sum256([]byte(key))
+	return strings.ToLower(r.RecordType) + "." + hex.EncodeToString(hash[:])
+}
+
+// staleRecord returns the record the entry of the key belongs to, so that the policy can decide
+// on the deletion of the entry like on the deletion of its record. The name of a record whose
+// key was replaced by its hash can't be restored and is left as the hash.
+func staleRecord(key string, labels endpoint.Labels) *endpoint.Endpoint {
+	parts := strings.SplitN(key, ".", 2)
+	r := &endpoint.Endpoint{RecordType: strings.ToUpper(parts[0]), Labels: labels}
+	if len(parts) == 2 {
+		name := strings.SplitN(parts[1], "..", 2)
+		r.DNSName = decodeWildcard(name[0])
+		if len(name) == 2 {
+			r.SetIdentifier = name[1]
+		}
+	}
+	return r
+}
+
+// deletableStaleKeys returns the keys of the stale entries whose deletion the policy keeps.
+func (im *ConfigMapRegistry) deletableStaleKeys() []string {
+	if im.policy == nil || len(im.staleEntries) == 0 {
+		return nil
+	}
+
+	records := make([]*endpoint.Endpoint, 0, len(im.staleEntries))
+	for r := range im.staleEntries {
+		records = append(records, r)
+	}
+
+	var keys []string
+	for _, r := range im.policy.Apply(&plan.Changes{Delete: records}).Delete {
+		keys = append(keys, im.staleEntries[r])
+	}
+	return keys
+}
+
+// ownedEntry returns true if the entry of the key is owned by the current instance.
+func (im *ConfigMapRegistry) ownedEntry(data map[string]string, key string) bool {
+	labels, err := endpoint.NewLabelsFromString(data[key])
+	return err == nil && labels[endpoint.OwnerLabelKey] == im.ownerID
+}
+
+// getConfigMapData returns the entries of the ConfigMap, which has none if it doesn't exist yet.
+func (im *ConfigMapRegistry) getConfigMapData() (map[string]string, error) {
+	configMap, err := im.client.CoreV1().ConfigMaps(im.namespace).Get(im.name, metav1.GetOptions{})
+	if kubeerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
+// updateConfigMap applies the modification to the data of the current ConfigMap and writes it
+// if it changed. The update is rejected if the ConfigMap's resourceVersion changed since it
+// was read, in which case it is read and modified again.
+func (im *ConfigMapRegistry) updateConfigMap(modify func(data map[string]string)) error {
+	for i := 0; i < configMapUpdateRetries; i++ {
+		configMap, err := im.client.CoreV1().ConfigMaps(im.namespace).Get(im.name, metav1.GetOptions{})
+		exists := !kubeerrors.IsNotFound(err)
+		if !exists {
+			configMap = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: im.namespace,
+					Name:      im.name,
+				},
+			}
+		} else if err != nil {
+			return err
+		}
+
+		data := map[string]string{}
+		for k, v := range configMap.Data {
+			data[k] = v
+		}
+		modify(data)
+		if reflect.DeepEqual(data, configMap.Data) || len(data) == 0 && len(configMap.Data) == 0 {
+			return nil
+		}
+		if im.dryRun {
+			log.Infof("Would update the ownership of records in ConfigMap %s/%s", im.namespace, im.name)
+			return nil
+		}
+		configMap.Data = data
+
+		// the update carries the resourceVersion of the ConfigMap read above
+		if exists {
+			_, err = im.client.CoreV1().ConfigMaps(im.namespace).Update(configMap)
+		} else {
+			_, err = im.client.CoreV1().ConfigMaps(im.namespace).Create(configMap)
+		}
+		if kubeerrors.IsConflict(err) || kubeerrors.IsAlreadyExists(err) {
+			log.Debugf("ConfigMap %s/%s was modified concurrently, retrying", im.namespace, im.name)
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("failed to update ConfigMap %s/%s: modified concurrently %d times", im.namespace, im.name, configMapUpdateRetries)
+}
+
+func (im *ConfigMapRegistry) addToCache(ep *endpoint.Endpoint) {
+	if im.recordsCache != nil {
+		im.recordsCache = append(im.recordsCache, ep)
+	}
+}
+
+func (im *ConfigMapRegistry) removeFromCache(ep *endpoint.Endpoint) {
+	if im.recordsCache == nil || ep == nil {
+		return
+	}
+
+	for i, e := range im.recordsCache {
+		if e.DNSName == ep.DNSName && e.RecordType == ep.RecordType && e.SetIdentifier == ep.SetIdentifier && e.Targets.Same(ep.Targets) {
+			// We found a match delete the endpoint from the cache.
+			im.recordsCache = append(im.recordsCache[:i], im.recordsCache[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,393 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/internal/testutils"
+	"github.com/kubernetes-incubator/external-dns/plan"
+	"github.com/kubernetes-incubator/external-dns/provider"
+)
+
+func TestConfigMapRegistry(t *testing.T) {
+	t.Run("TestNewConfigMapRegistry", testConfigMapRegistryNew)
+	t.Run("TestRecords", testConfigMapRegistryRecords)
+	t.Run("TestApplyChanges", testConfigMapRegistryApplyChanges)
+	t.Run("TestClaimedConcurrently", testConfigMapRegistryClaimedConcurrently)
+	t.Run("TestConflict", testConfigMapRegistryConflict)
+	t.Run("TestDryRun", testConfigMapRegistryDryRun)
+	t.Run("TestSetIdentifiers", testConfigMapRegistrySetIdentifiers)
+	t.Run("TestUpsertOnly", testConfigMapRegistryUpsertOnly)
+	t.Run("TestStaleEntriesPolicy", testConfigMapRegistryStaleEntriesPolicy)
+}
+
+func testConfigMapRegistryNew(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	client := fake.NewSimpleClientset()
+
+	_, err := NewConfigMapRegistry(p, client, "default", "external-dns", "", time.Hour, false, &plan.SyncPolicy{})
+	require.Error(t, err)
+
+	_, err = NewConfigMapRegistry(p, client, "default", "", "owner", time.Hour, false, &plan.SyncPolicy{})
+	require.Error(t, err)
+
+	r, err := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", time.Hour, false, &plan.SyncPolicy{})
+	require.NoError(t, err)
+	assert.Equal(t, "owner", r.ownerID)
+	assert.Equal(t, p, r.provider)
+}
+
+func newOwnershipConfigMap(data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "external-dns",
+			ResourceVersion: "1",
+		},
+		Data: data,
+	}
+}
+
+func getOwnershipData(t *testing.T, r *ConfigMapRegistry) map[string]string {
+	configMap, err := r.client.CoreV1().ConfigMaps("default").Get("external-dns", metav1.GetOptions{})
+	require.NoError(t, err)
+	return configMap.Data
+}
+
+func testConfigMapRegistryRecords(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("*.foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("baz.test-zone.example.org", "\"v=spf1 -all\"", endpoint.RecordTypeTXT, ""),
+		},
+	})
+	client := fake.NewSimpleClientset(newOwnershipConfigMap(map[string]string{
+		"a.foo.test-zone.example.org":           "heritage=external-dns,external-dns/owner=owner,external-dns/resource=service/default/foo",
+		"a._wildcard.foo.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner",
+		"cname.bar.test-zone.example.org":       "heritage=external-dns,external-dns/owner=owner-2",
+		"txt.baz.test-zone.example.org":         "not written by external-dns",
+		"a.gone.test-zone.example.org":          "heritage=external-dns,external-dns/owner=owner",
+		"a.foreign.test-zone.example.org":       "heritage=external-dns,external-dns/owner=owner-2",
+	}))
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, false, &plan.SyncPolicy{})
+
+	records, err := r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		newEndpointWithOwnerResource("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner", "service/default/foo"),
+		newEndpointWithOwner("*.foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+		newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner-2"),
+		endpoint.NewEndpoint("baz.test-zone.example.org", endpoint.RecordTypeTXT, "\"v=spf1 -all\""),
+	}))
+
+	// the owned entry without a record is removed with the next changes
+	require.NoError(t, r.ApplyChanges(&plan.Changes{}))
+	data := getOwnershipData(t, r)
+	assert.NotContains(t, data, "a.gone.test-zone.example.org")
+	assert.Contains(t, data, "a.foreign.test-zone.example.org")
+	assert.Len(t, data, 5)
+}
+
+func testConfigMapRegistryApplyChanges(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+		},
+	})
+	client := fake.NewSimpleClientset()
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", time.Hour, false, &plan.SyncPolicy{})
+
+	// the ConfigMap is created with the first claimed record
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("new.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, "", "ingress/default/new"),
+		},
+	}))
+	assert.Equal(t, map[string]string{
+		"a.new.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner,external-dns/resource=ingress/default/new",
+	}, getOwnershipData(t, r))
+
+	records, err := r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+		newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+		newEndpointWithOwnerResource("new.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, "owner", "ingress/default/new"),
+	}))
+
+	// records not owned are neither updated nor deleted
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("new.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, "owner", "ingress/default/new"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("new.test-zone.example.org", "1.2.3.6", endpoint.RecordTypeA, "owner", "ingress/default/other"),
+		},
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+		},
+	}))
+	assert.Equal(t, map[string]string{
+		"a.new.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner,external-dns/resource=ingress/default/other",
+	}, getOwnershipData(t, r))
+
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("new.test-zone.example.org", "1.2.3.6", endpoint.RecordTypeA, "owner", "ingress/default/other"),
+		},
+	}))
+	assert.Empty(t, getOwnershipData(t, r))
+
+	records, err = p.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.test-zone.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("bar.test-zone.example.org", endpoint.RecordTypeCNAME, "bar.loadbalancer.com"),
+	}))
+}
+
+func testConfigMapRegistryClaimedConcurrently(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	client := fake.NewSimpleClientset(newOwnershipConfigMap(map[string]string{}))
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, false, &plan.SyncPolicy{})
+
+	_, err := r.Records()
+	require.NoError(t, err)
+
+	// another instance claims the record after the records were read
+	_, err = client.CoreV1().ConfigMaps("default").Update(newOwnershipConfigMap(map[string]string{
+		"a.new.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner-2",
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("new.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, ""),
+		},
+	}))
+	records, err := p.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Equal(t, map[string]string{
+		"a.new.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner-2",
+	}, getOwnershipData(t, r))
+}
+
+func testConfigMapRegistryConflict(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	client := fake.NewSimpleClientset(newOwnershipConfigMap(map[string]string{}))
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, false, &plan.SyncPolicy{})
+
+	conflicts := 0
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts < 2 {
+			conflicts++
+			return true, nil, kubeerrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "external-dns", nil)
+		}
+		return false, nil, nil
+	})
+
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("new.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, ""),
+		},
+	}))
+	assert.Equal(t, 2, conflicts)
+	assert.Contains(t, getOwnershipData(t, r), "a.new.test-zone.example.org")
+
+	// the update fails if the ConfigMap keeps being modified
+	conflicts = -configMapUpdateRetries
+	assert.Error(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("other.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, ""),
+		},
+	}))
+}
+
+func testConfigMapRegistryDryRun(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	client := fake.NewSimpleClientset()
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, true, &plan.SyncPolicy{})
+
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("new.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, ""),
+		},
+	}))
+
+	_, err = client.CoreV1().ConfigMaps("default").Get("external-dns", metav1.GetOptions{})
+	assert.True(t, kubeerrors.IsNotFound(err))
+}
+
+func testConfigMapRegistrySetIdentifiers(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	client := fake.NewSimpleClientset(newOwnershipConfigMap(map[string]string{
+		"a.foo.test-zone.example.org..green": "heritage=external-dns,external-dns/owner=owner-2",
+	}))
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, false, &plan.SyncPolicy{})
+
+	// records of the same name and type are told apart by their set identifier
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "").WithSetIdentifier("blue"),
+		},
+	}))
+	assert.Equal(t, map[string]string{
+		"a.foo.test-zone.example.org..blue":  "heritage=external-dns,external-dns/owner=owner",
+		"a.foo.test-zone.example.org..green": "heritage=external-dns,external-dns/owner=owner-2",
+	}, getOwnershipData(t, r))
+}
+
+func testConfigMapRegistryUpsertOnly(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	data := map[string]string{
+		"a.gone.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner",
+	}
+	client := fake.NewSimpleClientset(newOwnershipConfigMap(data))
+
+	// entries of records deleted by someone else are kept unless the policy allows deletions
+	for _, policy := range []plan.Policy{&plan.UpsertOnlyPolicy{}, nil} {
+		r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, false, policy)
+		_, err := r.Records()
+		require.NoError(t, err)
+		require.NoError(t, r.ApplyChanges(&plan.Changes{}))
+		assert.Equal(t, data, getOwnershipData(t, r))
+	}
+}
+
+// cnameDeletesPolicy only keeps the deletion of CNAME records.
+type cnameDeletesPolicy struct{}
+
+func (p *cnameDeletesPolicy) Apply(changes *plan.Changes) *plan.Changes {
+	deletes := []*endpoint.Endpoint{}
+	for _, r := range changes.Delete {
+		if r.RecordType == endpoint.RecordTypeCNAME {
+			deletes = append(deletes, r)
+		}
+	}
+	return &plan.Changes{Create: changes.Create, UpdateOld: changes.UpdateOld, UpdateNew: changes.UpdateNew, Delete: deletes}
+}
+
+func testConfigMapRegistryStaleEntriesPolicy(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	client := fake.NewSimpleClientset(newOwnershipConfigMap(map[string]string{
+		"a.gone.test-zone.example.org":               "heritage=external-dns,external-dns/owner=owner",
+		"cname._wildcard.test-zone.example.org":      "heritage=external-dns,external-dns/owner=owner",
+		"cname.weighted.test-zone.example.org..blue": "heritage=external-dns,external-dns/owner=owner",
+		"cname.other-owner.test-zone.example.org":    "heritage=external-dns,external-dns/owner=owner-2",
+	}))
+
+	// the stale entries are passed through the policy as deletions of their records
+	r, _ := NewConfigMapRegistry(p, client, "default", "external-dns", "owner", 0, false, &cnameDeletesPolicy{})
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{}))
+	assert.Equal(t, map[string]string{
+		"a.gone.test-zone.example.org":            "heritage=external-dns,external-dns/owner=owner",
+		"cname.other-owner.test-zone.example.org": "heritage=external-dns,external-dns/owner=owner-2",
+	}, getOwnershipData(t, r))
+}
+
+func TestStaleRecord(t *testing.T) {
+	labels := endpoint.Labels{endpoint.OwnerLabelKey: "owner"}
+	for _, tc := range []struct {
+		title    string
+		key      string
+		expected *endpoint.Endpoint
+	}{
+		{"record", "cname.foo.example.org", &endpoint.Endpoint{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeCNAME, Labels: labels}},
+		{"wildcard", "a._wildcard.example.org", &endpoint.Endpoint{DNSName: "*.example.org", RecordType: endpoint.RecordTypeA, Labels: labels}},
+		{"set identifier", "a.foo.example.org..eu-west-1", &endpoint.Endpoint{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, SetIdentifier: "eu-west-1", Labels: labels}},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.expected, staleRecord(tc.key, labels))
+		})
+	}
+}
+
+func TestOwnershipKey(t *testing.T) {
+	for _, tc := range []struct {
+		title    string
+		endpoint *endpoint.Endpoint
+		expected string
+	}{
+		{
+			"record",
+			endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeCNAME, "bar.example.org"),
+			"cname.foo.example.org",
+		},
+		{
+			"wildcard",
+			endpoint.NewEndpoint("*.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+			"a._wildcard.example.org",
+		},
+		{
+			"set identifier",
+			endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4").WithSetIdentifier("eu-west-1"),
+			"a.foo.example.org..eu-west-1",
+		},
+		{
+			"set identifier with characters invalid in keys",
+			endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4").WithSetIdentifier("blue green"),
+			"a.c9129e7e13c0d61e00a5e44040add2000484b262f90864138c922df90e0e396d",
+		},
+		{
+			"too long",
+			endpoint.NewEndpoint(strings.Repeat("foo.", 62)+"example.org", endpoint.RecordTypeA, "1.2.3.4"),
+			"a.ccfa09544fa4f63ea112f19a0884a67e4ddecf02de40a6710618465c59022f91",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ownershipKey(tc.endpoint))
+		})
+	}
+}
@@ -43,3 +43,27 @@ func filterOwnedRecords(ownerID string, eps []*endpoint.Endpoint) []*endpoint.En
 	}
 	return filtered
 }
+
+// filterReplacingRecords removes the records from creates which replace one of
+// the deletes that is not owned by ownerID. Such a deletion is skipped, so the
+// replacing record of a conflicting type can't be created either.
+func filterReplacingRecords(ownerID string, creates, deletes []*endpoint.Endpoint) []*endpoint.Endpoint {
+	filtered := []*endpoint.Endpoint{}
+	for _, ep := range creates {
+		if replaced := replacedForeignRecord(ownerID, ep, deletes); replaced != nil {
+			log.Debugf(`Skipping endpoint %v because it conflicts with endpoint %v which is not owned by "%s"`, ep, replaced, ownerID)
+			continue
+		}
+		filtered = append(filtered, ep)
+	}
+	return filtered
+}
+
+func replacedForeignRecord(ownerID string, ep *endpoint.Endpoint, deletes []*endpoint.Endpoint) *endpoint.Endpoint {
+	for _, d := range deletes {
+		if d.Labels[endpoint.OwnerLabelKey] != ownerID && plan.ConflictingTypes(ep, d) {
+			return d
+		}
+	}
+	return nil
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package registry
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"text/template"
 	"time"
 
 	"strings"
@@ -25,14 +28,65 @@ import (
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 	"github.com/kubernetes-incubator/external-dns/plan"
 	"github.com/kubernetes-incubator/external-dns/provider"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	orphanedTXTRecords = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "external_dns",
+			Subsystem: "registry",
+			Name:      "orphaned_txt_records",
+			Help:      "Number of owned TXT records without the record they hold the ownership of",
+		},
+	)
+	orphanedTXTRecordsRemoved = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "external_dns",
+			Subsystem: "registry",
+			Name:      "orphaned_txt_records_removed_total",
+			Help:      "Number of orphaned TXT records deleted",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(orphanedTXTRecords)
+	prometheus.MustRegister(orphanedTXTRecordsRemoved)
+}
+
+// Formats of the TXT records holding the ownership of records
+const (
+	// TXTFormatLegacy stores the ownership of all records of a name in a single TXT record named <prefix><name>
+	TXTFormatLegacy = "legacy"
+	// TXTFormatMigrate writes both formats, so that instances still using the legacy format keep working
+	TXTFormatMigrate = "migrate"
+	// TXTFormatTyped stores the ownership of each record in a TXT record named <type>-<prefix><name>
+	// and removes owned TXT records of the legacy format
+	TXTFormatTyped = "typed"
+)
+
 // TXTRegistry implements registry interface with ownership implemented via associated TXT records
 type TXTRegistry struct {
-	provider provider.Provider
-	ownerID  string //refers to the owner id of the current instance
-	mapper   nameMapper
+	provider    provider.Provider
+	ownerID     string //refers to the owner id of the current instance
+	format      string
+	mapper      nameMapper
+	typedMapper nameMapper
+
+	// names of the TXT records found by the last call to the provider, used to only
+	// update and delete typed TXT records which exist
+	txtNames map[recordKey]bool
+	// owned records found by the last call to the provider and changed since, used to
+	// keep legacy TXT records which are shared with records of other types
+	owned map[typedName]bool
+	// TXT records to create or delete for migrating owned records to the typed format
+	migration *plan.Changes
+	// owned TXT records without the record they belong to, deleted as far as the
+	// policy allows, garbage collection is disabled without a policy
+	orphans []*endpoint.Endpoint
+	policy  plan.Policy
 
 	// cache the records in memory and update on an interval instead.
 	recordsCache            []*endpoint.Endpoint
@@ -40,18 +94,45 @@ type TXTRegistry struct {
 	cacheInterval           time.Duration
 }
 
-// NewTXTRegistry returns new TXTRegistry object
-func NewTXTRegistry(provider provider.Provider, txtPrefix, ownerID string, cacheInterval time.Duration) (*TXTRegistry, error) {
+// NewTXTRegistry returns new TXTRegistry object. The names of the TXT records are built
+// from either a prefix, a suffix or a template, zones are used to render the template.
+// Orphaned TXT records are deleted if the policy allows it, a nil policy keeps them.
+func NewTXTRegistry(provider provider.Provider, txtPrefix, txtSuffix, txtNameTemplate, ownerID string, cacheInterval time.Duration, txtFormat string, zones []string, policy plan.Policy) (*TXTRegistry, error) {
 	if ownerID == "" {
 		return nil, errors.New("owner id cannot be empty")
 	}
+	switch txtFormat {
+	case TXTFormatLegacy, TXTFormatMigrate, TXTFormatTyped:
+	default:
+		return nil, fmt.Errorf("unknown TXT format: %s", txtFormat)
+	}
 
-	mapper := newPrefixNameMapper(txtPrefix)
+	var mapper nameMapper
+	switch {
+	case countNonEmpty(txtPrefix, txtSuffix, txtNameTemplate) > 1:
+		return nil, errors.New("only one of TXT prefix, suffix and name template can be set")
+	case txtSuffix != "":
+		mapper = newSuffixNameMapper(txtSuffix)
+	case txtNameTemplate != "":
+		tm, err := newTemplateNameMapper(txtNameTemplate, zones)
+		if err != nil {
+			return nil, err
+		}
+		if tm.typed && txtFormat != TXTFormatLegacy {
+			return nil, errors.New("TXT name templates including the record type already name one TXT record per type, they require the legacy format")
+		}
+		mapper = tm
+	default:
+		mapper = newPrefixNameMapper(txtPrefix)
+	}
 
 	return &TXTRegistry{
 		provider:      provider,
 		ownerID:       ownerID,
+		format:        txtFormat,
 		mapper:        mapper,
+		typedMapper:   newTypedNameMapper(mapper),
+		policy:        policy,
 		cacheInterval: cacheInterval,
 	}, nil
 }
@@ -73,12 +154,16 @@ func (im *TXTRegistry) Records() ([]*endpoint.Endpoint, error) {
 	}
 
 	endpoints := []*endpoint.Endpoint{}
-
-	labelMap := map[string]endpoint.Labels{}
+	txtRecords := []*endpoint.Endpoint{}
+	txtLabels := []endpoint.Labels{}
+	names := map[recordKey]bool{}
+	typedNames := map[typedName]bool{}
 
 	for _, record := range records {
 		if record.RecordType != endpoint.RecordTypeTXT {
 			endpoints = append(endpoints, record)
+			names[recordKey{record.DNSName, record.SetIdentifier}] = true
+			typedNames[typedName{record.DNSName, record.RecordType, record.SetIdentifier}] = true
 			continue
 		}
 		// We simply assume that TXT records for the registry will always have only one target.
@@ -93,19 +178,81 @@ func (im *TXTRegistry) Records() ([]*endpoint.Endpoint, error) {
 		if err != nil {
 			return nil, err
 		}
-		endpointDNSName := im.mapper.toEndpointName(record.DNSName)
-		labelMap[endpointDNSName] = labels
+		txtRecords = append(txtRecords, record)
+		txtLabels = append(txtLabels, labels)
+	}
+
+	labelMap := map[recordKey]endpoint.Labels{}
+	typedLabelMap := map[typedName]endpoint.Labels{}
+	ownedLegacyRecords := map[recordKey]*endpoint.Endpoint{}
+	im.txtNames = map[recordKey]bool{}
+	im.orphans = nil
+
+	for i, record := range txtRecords {
+		labels := txtLabels[i]
+		im.txtNames[recordKey{record.DNSName, record.SetIdentifier}] = true
+
+		if labels[endpoint.OwnerLabelKey] == im.ownerID && im.isOrphaned(record, names, typedNames) {
+			im.orphans = append(im.orphans, record)
+		}
+
+		// typed TXT records are ignored in the legacy format. A TXT record is only
+		// considered typed if the record it belongs to exists, without prefix its
+		// name can't be told apart from a legacy one otherwise.
+		if im.format != TXTFormatLegacy {
+			endpointDNSName, recordType := im.typedMapper.toEndpointName(record.DNSName)
+			if key := (typedName{endpointDNSName, recordType, record.SetIdentifier}); endpointDNSName != "" && typedNames[key] {
+				typedLabelMap[key] = labels
+				continue
+			}
+		}
+		endpointDNSName, recordType := im.mapper.toEndpointName(record.DNSName)
+		if recordType != "" {
+			typedLabelMap[typedName{endpointDNSName, recordType, record.SetIdentifier}] = labels
+			continue
+		}
+		key := recordKey{endpointDNSName, record.SetIdentifier}
+		labelMap[key] = labels
+		if endpointDNSName != "" && labels[endpoint.OwnerLabelKey] == im.ownerID {
+			ownedLegacyRecords[key] = record
+		}
 	}
 
+	im.migration = &plan.Changes{}
+	im.owned = map[typedName]bool{}
+	// names of owned records which are still missing their typed TXT record
+	unmigrated := map[recordKey]bool{}
+
 	for _, ep := range endpoints {
 		ep.Labels = endpoint.NewLabels()
-		if labels, ok := labelMap[ep.DNSName]; ok {
-			for k, v := range labels {
-				ep.Labels[k] = v
+		labels, typed := typedLabelMap[typedName{ep.DNSName, ep.RecordType, ep.SetIdentifier}]
+		if !typed {
+			labels = labelMap[recordKey{ep.DNSName, ep.SetIdentifier}]
+		}
+		for k, v := range labels {
+			ep.Labels[k] = v
+		}
+		if ep.Labels[endpoint.OwnerLabelKey] == im.ownerID {
+			im.owned[typedName{ep.DNSName, ep.RecordType, ep.SetIdentifier}] = true
+		}
+
+		if im.format != TXTFormatLegacy && !typed && ep.Labels[endpoint.OwnerLabelKey] == im.ownerID {
+			im.migration.Create = append(im.migration.Create, im.newTXT(im.typedMapper, ep))
+			unmigrated[recordKey{ep.DNSName, ep.SetIdentifier}] = true
+		}
+	}
+
+	// legacy TXT records are removed once all owned records of their name have a typed one
+	if im.format == TXTFormatTyped {
+		for key, record := range ownedLegacyRecords {
+			if !unmigrated[key] {
+				im.migration.Delete = append(im.migration.Delete, record)
 			}
 		}
 	}
 
+	orphanedTXTRecords.Set(float64(len(im.orphans)))
+
 	// Update the cache.
 	if im.cacheInterval > 0 {
 		im.recordsCache = endpoints
@@ -119,30 +266,38 @@ func (im *TXTRegistry) Records() ([]*endpoint.Endpoint, error) {
 // for each created/deleted record it will also take into account TXT records for creation/deletion
 func (im *TXTRegistry) ApplyChanges(changes *plan.Changes) error {
 	filteredChanges := &plan.Changes{
-		Create:    changes.Create,
+		Create:    filterReplacingRecords(im.ownerID, changes.Create, changes.Delete),
 		UpdateNew: filterOwnedRecords(im.ownerID, changes.UpdateNew),
 		UpdateOld: filterOwnedRecords(im.ownerID, changes.UpdateOld),
 		Delete:    filterOwnedRecords(im.ownerID, changes.Delete),
 	}
+	txtChanges := &plan.Changes{}
+
 	for _, r := range filteredChanges.Create {
 		if r.Labels == nil {
 			r.Labels = make(map[string]string)
 		}
 		r.Labels[endpoint.OwnerLabelKey] = im.ownerID
-		txt := endpoint.NewEndpoint(im.mapper.toTXTName(r.DNSName), endpoint.RecordTypeTXT, r.Labels.Serialize(true))
-		filteredChanges.Create = append(filteredChanges.Create, txt)
+		for _, mapper := range im.writtenMappers() {
+			txtChanges.Create = appendUniqueName(txtChanges.Create, im.newTXT(mapper, r))
+		}
 
 		if im.cacheInterval > 0 {
 			im.addToCache(r)
 		}
 	}
 
+	inUse := im.legacyTXTsInUse(filteredChanges)
 	for _, r := range filteredChanges.Delete {
-		txt := endpoint.NewEndpoint(im.mapper.toTXTName(r.DNSName), endpoint.RecordTypeTXT, r.Labels.Serialize(true))
-
 		// when we delete TXT records for which value has changed (due to new label) this would still work because
 		// !!! TXT record value is uniquely generated from the Labels of the endpoint. Hence old TXT record can be uniquely reconstructed
-		filteredChanges.Delete = append(filteredChanges.Delete, txt)
+		for _, mapper := range im.existingMappers(r) {
+			if txtName := mapper.toTXTName(r.DNSName, r.RecordType); mapper == im.mapper && inUse[recordKey{txtName, r.SetIdentifier}] {
+				log.Debugf("Keeping TXT record %s, it holds the ownership of other records of %s", txtName, r.DNSName)
+				continue
+			}
+			txtChanges.Delete = appendUniqueName(txtChanges.Delete, im.newTXT(mapper, r))
+		}
 
 		if im.cacheInterval > 0 {
 			im.removeFromCache(r)
@@ -150,42 +305,240 @@ func (im *TXTRegistry) ApplyChanges(changes *plan.Changes) error {
 	}
 
 	// make sure TXT records are consistently updated as well
-	for _, r := range filteredChanges.UpdateOld {
-		txt := endpoint.NewEndpoint(im.mapper.toTXTName(r.DNSName), endpoint.RecordTypeTXT, r.Labels.Serialize(true))
-		// when we updateOld TXT records for which value has changed (due to new label) this would still work because
-		// !!! TXT record value is uniquely generated from the Labels of the endpoint. Hence old TXT record can be uniquely reconstructed
-		filteredChanges.UpdateOld = append(filteredChanges.UpdateOld, txt)
-		// remove old version of record from cache
+	for i, r := range filteredChanges.UpdateOld {
+		updated := filteredChanges.UpdateNew[i]
+		existing := im.existingMappers(r)
+		for _, mapper := range existing {
+			// when we updateOld TXT records for which value has changed (due to new label) this would still work because
+			// !!! TXT record value is uniquely generated from the Labels of the endpoint. Hence old TXT record can be uniquely reconstructed
+			txtChanges.UpdateOld, txtChanges.UpdateNew = appendUniqueNamePair(txtChanges.UpdateOld, txtChanges.UpdateNew, im.newTXT(mapper, r), im.newTXT(mapper, updated))
+		}
+		// a typed TXT record is created if the record was owned through a legacy one only
+		for _, mapper := range im.writtenMappers() {
+			if !containsMapper(existing, mapper) {
+				txtChanges.Create = appendUniqueName(txtChanges.Create, im.newTXT(mapper, updated))
+			}
+		}
+
 		if im.cacheInterval > 0 {
+			// replace old version of record in cache
 			im.removeFromCache(r)
+			im.addToCache(updated)
 		}
 	}
 
-	// make sure TXT records are consistently updated as well
-	for _, r := range filteredChanges.UpdateNew {
-		txt := endpoint.NewEndpoint(im.mapper.toTXTName(r.DNSName), endpoint.RecordTypeTXT, r.Labels.Serialize(true))
-		filteredChanges.UpdateNew = append(filteredChanges.UpdateNew, txt)
-		// add new version of record to cache
-		if im.cacheInterval > 0 {
-			im.addToCache(r)
+	orphans := im.addOrphans(txtChanges)
+
+	// a record changing its type is deleted and created again under the same name,
+	// its TXT record must be kept and is updated instead. The same applies to an
+	// orphaned TXT record of a record created again.
+	for i := 0; i < len(txtChanges.Create); i++ {
+		for j, deleted := range txtChanges.Delete {
+			if !sameTXT(txtChanges.Create[i], deleted) {
+				continue
+			}
+			if !txtChanges.Create[i].Targets.Same(deleted.Targets) {
+				txtChanges.UpdateOld = append(txtChanges.UpdateOld, deleted)
+				txtChanges.UpdateNew = append(txtChanges.UpdateNew, txtChanges.Create[i])
+			}
+			txtChanges.Create = append(txtChanges.Create[:i], txtChanges.Create[i+1:]...)
+			txtChanges.Delete = append(txtChanges.Delete[:j], txtChanges.Delete[j+1:]...)
+			i--
+			break
 		}
 	}
 
-	return im.provider.ApplyChanges(filteredChanges)
+	im.addMigration(filteredChanges, txtChanges)
+
+	records := *filteredChanges
+	filteredChanges.Create = append(filteredChanges.Create, txtChanges.Create...)
+	filteredChanges.UpdateOld = append(filteredChanges.UpdateOld, txtChanges.UpdateOld...)
+	filteredChanges.UpdateNew = append(filteredChanges.UpdateNew, txtChanges.UpdateNew...)
+	filteredChanges.Delete = append(filteredChanges.Delete, txtChanges.Delete...)
+
+	if err := im.provider.ApplyChanges(filteredChanges); err != nil {
+		return withoutTXTRecords(err)
+	}
+
+	if im.owned != nil {
+		for _, removed := range [][]*endpoint.Endpoint{records.UpdateOld, records.Delete} {
+			for _, r := range removed {
+				delete(im.owned, typedName{r.DNSName, r.RecordType, r.SetIdentifier})
+			}
+		}
+		for _, added := range [][]*endpoint.Endpoint{records.Create, records.UpdateNew} {
+			for _, r := range added {
+				im.owned[typedName{r.DNSName, r.RecordType, r.SetIdentifier}] = true
+			}
+		}
+	}
+	if im.txtNames != nil {
+		for _, txt := range txtChanges.Create {
+			im.txtNames[recordKey{txt.DNSName, txt.SetIdentifier}] = true
+		}
+		for _, txt := range txtChanges.Delete {
+			delete(im.txtNames, recordKey{txt.DNSName, txt.SetIdentifier})
+		}
+	}
+	for _, txt := range orphans {
+		if containsRecord(txtChanges.Delete, txt) {
+			orphanedTXTRecordsRemoved.Inc()
+		}
+	}
+	return nil
 }
 
 /**
   TXT registry specific private methods
 */
 
+// newTXT returns the TXT record holding the labels of the given record. A record with a
+// routing policy has a TXT record with the same set identifier and routing policy, as
+// the provider may not allow a simple record next to records with a routing policy.
+// Other provider specific properties, e.g. health checks, aren't copied.
+// The TXT record is labeled with the name of the record, so that providers submitting
+// changes in batches keep both in the same batch.
+func (im *TXTRegistry) newTXT(mapper nameMapper, r *endpoint.Endpoint) *endpoint.Endpoint {
+	txt := endpoint.NewEndpoint(mapper.toTXTName(r.DNSName, r.RecordType), endpoint.RecordTypeTXT, r.Labels.Serialize(true))
+	txt.SetIdentifier = r.SetIdentifier
+	for _, key := range provider.RoutingPolicyProviderSpecificKeys {
+		if value, ok := r.ProviderSpecific[key]; ok {
+			txt.WithProviderSpecific(key, value)
+		}
+	}
+	txt.Labels[endpoint.OwnedRecordLabelKey] = r.DNSName
+	return txt
+}
+
+// writtenMappers returns the mappers of the TXT records written for new records.
+func (im *TXTRegistry) writtenMappers() []nameMapper {
+	switch im.format {
+	case TXTFormatMigrate:
+		return []nameMapper{im.mapper, im.typedMapper}
+	case TXTFormatTyped:
+		return []nameMapper{im.typedMapper}
+	default:
+		return []nameMapper{im.mapper}
+	}
+}
+
+// existingMappers returns the mappers of the TXT records holding the ownership of an
+// existing record. Legacy TXT records are left to the migration in the typed format,
+// as they may still be shared with records of other types.
+func (im *TXTRegistry) existingMappers(r *endpoint.Endpoint) []nameMapper {
+	mappers := []nameMapper{}
+	if im.format != TXTFormatTyped {
+		mappers = append(mappers, im.mapper)
+	}
+	if im.format != TXTFormatLegacy && im.txtNames[recordKey{im.typedMapper.toTXTName(r.DNSName, r.RecordType), r.SetIdentifier}] {
+		mappers = append(mappers, im.typedMapper)
+	}
+	return mappers
+}
+
+// legacyTXTsInUse returns the names of the legacy TXT records which hold the ownership of
+// owned records remaining after the changes. A legacy TXT record is shared by all records of
+// a name, e.g. it must be kept when only the AAAA record of a dual-stack name is deleted.
+// The TXT record of a record changing its type is updated instead of being deleted.
+func (im *TXTRegistry) legacyTXTsInUse(changes *plan.Changes) map[recordKey]bool {
+	deleted := map[typedName]bool{}
+	for _, r := range changes.Delete {
+		deleted[typedName{r.DNSName, r.RecordType, r.SetIdentifier}] = true
+	}
+
+	inUse := map[recordKey]bool{}
+	for key := range im.owned {
+		if !deleted[key] {
+			inUse[recordKey{im.mapper.toTXTName(key.dnsName, key.recordType), key.setIdentifier}] = true
+		}
+	}
+	for _, r := range changes.UpdateNew {
+		inUse[recordKey{im.mapper.toTXTName(r.DNSName, r.RecordType), r.SetIdentifier}] = true
+	}
+	return inUse
+}
+
+// addMigration adds the TXT records to create and delete for migrating to the typed
+// format unless they are already part of the changes. They are added once per call
+// to the provider's Records.
+func (im *TXTRegistry) addMigration(changes, txtChanges *plan.Changes) {
+	if im.migration == nil {
+		return
+	}
+
+	changed := map[recordKey]bool{}
+	for _, records := range [][]*endpoint.Endpoint{changes.Create, changes.UpdateOld, changes.Delete} {
+		for _, r := range records {
+			changed[recordKey{im.typedMapper.toTXTName(r.DNSName, r.RecordType), r.SetIdentifier}] = true
+		}
+	}
+	for _, records := range [][]*endpoint.Endpoint{txtChanges.Create, txtChanges.UpdateOld, txtChanges.Delete} {
+		for _, r := range records {
+			changed[recordKey{r.DNSName, r.SetIdentifier}] = true
+		}
+	}
+
+	for _, txt := range im.migration.Create {
+		if !changed[recordKey{txt.DNSName, txt.SetIdentifier}] {
+			log.Infof("Creating TXT record %s to migrate ownership to the typed format", txt.DNSName)
+			txtChanges.Create = append(txtChanges.Create, txt)
+		}
+	}
+	for _, txt := range im.migration.Delete {
+		if !changed[recordKey{txt.DNSName, txt.SetIdentifier}] {
+			log.Infof("Deleting TXT record %s of the legacy format", txt.DNSName)
+			txtChanges.Delete = append(txtChanges.Delete, txt)
+		}
+	}
+	im.migration = nil
+}
+
+// isOrphaned returns true if the TXT record is named by one of the mappers, but none of
+// the records it may hold the ownership of exists.
+func (im *TXTRegistry) isOrphaned(txt *endpoint.Endpoint, names map[recordKey]bool, typedNames map[typedName]bool) bool {
+	mapped := false
+	for _, mapper := range []nameMapper{im.mapper, im.typedMapper} {
+		endpointDNSName, recordType := mapper.toEndpointName(txt.DNSName)
+		if endpointDNSName == "" {
+			continue
+		}
+		mapped = true
+		if (recordType == "" && names[recordKey{endpointDNSName, txt.SetIdentifier}]) || typedNames[typedName{endpointDNSName, recordType, txt.SetIdentifier}] {
+			return false
+		}
+	}
+	return mapped
+}
+
+// addOrphans adds the deletions of the orphaned TXT records found by the last call to the
+// provider's Records which are allowed by the policy, and returns them.
+func (im *TXTRegistry) addOrphans(txtChanges *plan.Changes) []*endpoint.Endpoint {
+	if im.policy == nil || len(im.orphans) == 0 {
+		return nil
+	}
+
+	orphans := []*endpoint.Endpoint{}
+	for _, txt := range im.policy.Apply(&plan.Changes{Delete: im.orphans}).Delete {
+		if !containsTXT(txtChanges.Delete, txt) {
+			log.Infof("Deleting orphaned TXT record %s", txt.DNSName)
+			txtChanges.Delete = append(txtChanges.Delete, txt)
+			orphans = append(orphans, txt)
+		}
+	}
+	im.orphans = nil
+	return orphans
+}
+
 /**
   nameMapper defines interface which maps the dns name defined for the source
   to the dns name which TXT record will be created with
 */
 
 type nameMapper interface {
-	toEndpointName(string) string
-	toTXTName(string) string
+	// toEndpointName returns the name of the record the TXT record belongs to and its type,
+	// the type is empty if the TXT record is shared by the records of all types of that name
+	toEndpointName(txtDNSName string) (string, string)
+	toTXTName(endpointDNSName, recordType string) string
 }
 
 type prefixNameMapper struct {
@@ -198,17 +551,319 @@ func newPrefixNameMapper(prefix string) prefixNameMapper {
 	return prefixNameMapper{prefix: prefix}
 }
 
-func (pr prefixNameMapper) toEndpointName(txtDNSName string) string {
+func (pr prefixNameMapper) toEndpointName(txtDNSName string) (string, string) {
 	if strings.HasPrefix(txtDNSName, pr.prefix) {
-		return strings.TrimPrefix(txtDNSName, pr.prefix)
+		return strings.TrimPrefix(txtDNSName, pr.prefix), ""
 	}
-	return ""
+	return "", ""
 }
 
-func (pr prefixNameMapper) toTXTName(endpointDNSName string) string {
+func (pr prefixNameMapper) toTXTName(endpointDNSName, recordType string) string {
 	return pr.prefix + endpointDNSName
 }
 
+// typedNameMapper prepends the record type to the names of another mapper,
+// e.g. cname-txt.foo.example.org, so every record of a name has its own TXT record
+// and a CNAME record doesn't clash with its TXT record.
+type typedNameMapper struct {
+	mapper nameMapper
+}
+
+var _ nameMapper = typedNameMapper{}
+
+// typedRecordTypes are the record types which can be told apart in TXT record names
+var typedRecordTypes = []string{
+	endpoint.RecordTypeA,
+	endpoint.RecordTypeAAAA,
+	endpoint.RecordTypeCNAME,
+	endpoint.RecordTypeSRV,
+	endpoint.RecordTypeMX,
+}
+
+func newTypedNameMapper(mapper nameMapper) typedNameMapper {
+	return typedNameMapper{mapper: mapper}
+}
+
+func (tm typedNameMapper) toEndpointName(txtDNSName string) (string, string) {
+	for _, recordType := range typedRecordTypes {
+		typePrefix := strings.ToLower(recordType) + "-"
+		if !strings.HasPrefix(txtDNSName, typePrefix) {
+			continue
+		}
+		if endpointDNSName, _ := tm.mapper.toEndpointName(strings.TrimPrefix(txtDNSName, typePrefix)); endpointDNSName != "" {
+			return decodeWildcard(endpointDNSName), recordType
+		}
+	}
+	return "", ""
+}
+
+func (tm typedNameMapper) toTXTName(endpointDNSName, recordType string) string {
+	return strings.ToLower(recordType) + "-" + tm.mapper.toTXTName(encodeWildcard(endpointDNSName), recordType)
+}
+
+// suffixNameMapper appends a suffix to the first label of the names, e.g. foo-txt.example.org
+// for the suffix -txt or foo.txt.example.org for the suffix .txt.
+type suffixNameMapper struct {
+	suffix string
+}
+
+var _ nameMapper = suffixNameMapper{}
+
+func newSuffixNameMapper(suffix string) suffixNameMapper {
+	return suffixNameMapper{suffix: suffix}
+}
+
+func (sm suffixNameMapper) toEndpointName(txtDNSName string) (string, string) {
+	i := strings.Index(txtDNSName+".", sm.suffix+".")
+	if i <= 0 || strings.Contains(txtDNSName[:i], ".") {
+		return "", ""
+	}
+	return decodeWildcard(txtDNSName[:i] + txtDNSName[i+len(sm.suffix):]), ""
+}
+
+func (sm suffixNameMapper) toTXTName(endpointDNSName, recordType string) string {
+	labels := strings.SplitN(encodeWildcard(endpointDNSName), ".", 2)
+	if len(labels) == 1 {
+		return labels[0] + sm.suffix
+	}
+	return labels[0] + sm.suffix + "." + labels[1]
+}
+
+// templateNameMapper renders the names with a Go template, e.g. {{.Type}}-{{.Name}}.txt.{{.Zone}}.
+// The template is given the name of the record relative to its zone, which is empty at the apex,
+// the zone and the lower case record type. The zone is the longest of the configured zones
+// containing the name, or the parent domain of the name if none does. Empty labels are removed
+// from the rendered names.
+type templateNameMapper struct {
+	template *template.Template
+	zones    []string
+	// typed is true if the template includes the record type
+	typed bool
+}
+
+var _ nameMapper = &templateNameMapper{}
+
+type templateNameData struct {
+	Name string
+	Type string
+	Zone string
+}
+
+func newTemplateNameMapper(text string, zones []string) (*templateNameMapper, error) {
+	tmpl, err := template.New("txt-name").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TXT name template: %v", err)
+	}
+
+	// the record's name must be part of the TXT record's name and the TXT record
+	// must be placed in the record's zone
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, templateNameData{Name: "{name}", Type: "{type}", Zone: "{zone}"}); err != nil {
+		return nil, fmt.Errorf("invalid TXT name template: %v", err)
+	}
+	rendered := out.String()
+	if strings.Count(rendered, "{name}") != 1 || strings.Count(rendered, "{zone}") != 1 || !strings.HasSuffix(rendered, "{zone}") {
+		return nil, fmt.Errorf("invalid TXT name template %q: it must include {{.Name}} once and end with {{.Zone}}", text)
+	}
+
+	tm := &templateNameMapper{
+		template: tmpl,
+		typed:    strings.Contains(rendered, "{type}"),
+	}
+	for _, zone := range zones {
+		if zone = strings.Trim(strings.TrimSpace(zone), "."); zone != "" {
+			tm.zones = append(tm.zones, zone)
+		}
+	}
+	return tm, nil
+}
+
+// toEndpointName inverts the template by trying the zones and record types the TXT
+// record's name could have been rendered with, a candidate is only returned if it is
+// rendered to the same name again.
+func (tm *templateNameMapper) toEndpointName(txtDNSName string) (string, string) {
+	recordTypes := []string{""}
+	if tm.typed {
+		recordTypes = typedRecordTypes
+	}
+
+	for zone := txtDNSName; zone != ""; zone = parentDomain(zone) {
+		for _, recordType := range recordTypes {
+			candidates := []string{zone}
+			// the relative name is what is left of the TXT record's name around the rendered template
+			parts := strings.SplitN(tm.render("\x00", recordType, zone), "\x00", 2)
+			if len(parts) == 2 && len(txtDNSName) > len(parts[0])+len(parts[1]) &&
+				strings.HasPrefix(txtDNSName, parts[0]) && strings.HasSuffix(txtDNSName, parts[1]) {
+				candidates = append(candidates, txtDNSName[len(parts[0]):len(txtDNSName)-len(parts[1])]+"."+zone)
+			}
+			for _, candidate := range candidates {
+				if endpointDNSName := decodeWildcard(candidate); tm.toTXTName(endpointDNSName, recordType) == txtDNSName {
+					return endpointDNSName, recordType
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+func (tm *templateNameMapper) toTXTName(endpointDNSName, recordType string) string {
+	zone := tm.zone(endpointDNSName)
+	name := ""
+	if endpointDNSName != zone {
+		name = strings.TrimSuffix(endpointDNSName, "."+zone)
+	}
+	return tm.render(encodeWildcard(name), recordType, zone)
+}
+
+func (tm *templateNameMapper) render(name, recordType, zone string) string {
+	var out bytes.Buffer
+	// the template was executed successfully with the same data type when it was parsed
+	_ = tm.template.Execute(&out, templateNameData{Name: name, Type: strings.ToLower(recordType), Zone: zone})
+
+	labels := []string{}
+	for _, label := range strings.Split(out.String(), ".") {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// zone returns the longest configured zone containing the name, or its parent domain.
+func (tm *templateNameMapper) zone(dnsName string) string {
+	zone := ""
+	for _, z := range tm.zones {
+		if (dnsName == z || strings.HasSuffix(dnsName, "."+z)) && len(z) > len(zone) {
+			zone = z
+		}
+	}
+	if zone == "" {
+		zone = parentDomain(dnsName)
+	}
+	return zone
+}
+
+// wildcardReplacement replaces the wildcard label of names in the names of TXT records.
+// Several providers reject a wildcard which isn't the leftmost label, e.g. txt.*.example.org,
+// and a TXT record named *.example.org would be returned for any name below example.org.
+const wildcardReplacement = "_wildcard"
+
+func encodeWildcard(dnsName string) string {
+	return replaceLabel(dnsName, "*", wildcardReplacement)
+}
+
+func decodeWildcard(dnsName string) string {
+	return replaceLabel(dnsName, wildcardReplacement, "*")
+}
+
+func replaceLabel(dnsName, old, new string) string {
+	labels := strings.Split(dnsName, ".")
+	for i, label := range labels {
+		if label == old {
+			labels[i] = new
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// parentDomain returns the name without its first label, or an empty string for a single label.
+func parentDomain(dnsName string) string {
+	if i := strings.Index(dnsName, "."); i >= 0 {
+		return dnsName[i+1:]
+	}
+	return ""
+}
+
+func countNonEmpty(values ...string) int {
+	count := 0
+	for _, v := range values {
+		if v != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// recordKey identifies the records at a name, records with a routing policy
+// are told apart by their set identifier
+type recordKey struct {
+	dnsName       string
+	setIdentifier string
+}
+
+// typedName identifies the records of a type at a name
+type typedName struct {
+	dnsName       string
+	recordType    string
+	setIdentifier string
+}
+
+// appendUniqueName appends the TXT record unless one of the same name is
+// present already, e.g. for the A and AAAA records of a dual-stack name.
+func appendUniqueName(records []*endpoint.Endpoint, txt *endpoint.Endpoint) []*endpoint.Endpoint {
+	if containsTXT(records, txt) {
+		return records
+	}
+	return append(records, txt)
+}
+
+// sameTXT returns true if both TXT records have the same name and set identifier.
+func sameTXT(x, y *endpoint.Endpoint) bool {
+	return x.DNSName == y.DNSName && x.SetIdentifier == y.SetIdentifier
+}
+
+func containsTXT(records []*endpoint.Endpoint, txt *endpoint.Endpoint) bool {
+	for _, r := range records {
+		if sameTXT(r, txt) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRecord(records []*endpoint.Endpoint, record *endpoint.Endpoint) bool {
+	for _, r := range records {
+		if r == record {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUniqueNamePair is like appendUniqueName for the current and the desired
+// version of an updated TXT record.
+func appendUniqueNamePair(old, new []*endpoint.Endpoint, oldTXT, newTXT *endpoint.Endpoint) ([]*endpoint.Endpoint, []*endpoint.Endpoint) {
+	if containsTXT(old, oldTXT) {
+		return old, new
+	}
+	return append(old, oldTXT), append(new, newTXT)
+}
+
+// withoutTXTRecords drops the TXT records holding the ownership from the endpoints of
+// failed changes, they are reported by the records they belong to.
+func withoutTXTRecords(err error) error {
+	failed, ok := err.(*provider.FailedChangesError)
+	if !ok {
+		return err
+	}
+	records := []*endpoint.Endpoint{}
+	for _, ep := range failed.Endpoints {
+		if _, ok := ep.Labels[endpoint.OwnedRecordLabelKey]; !ok {
+			records = append(records, ep)
+		}
+	}
+	return &provider.FailedChangesError{Endpoints: records}
+}
+
+func containsMapper(mappers []nameMapper, mapper nameMapper) bool {
+	for _, m := range mappers {
+		if m == mapper {
+			return true
+		}
+	}
+	return false
+}
+
 func (im *TXTRegistry) addToCache(ep *endpoint.Endpoint) {
 	if im.recordsCache != nil {
 		im.recordsCache = append(im.recordsCache, ep)
@@ -222,7 +877,7 @@ func (im *TXTRegistry) removeFromCache(ep *endpoint.Endpoint) {
 	}
 
 	for i, e := range im.recordsCache {
-		if e.DNSName == ep.DNSName && e.RecordType == ep.RecordType && e.Targets.Same(ep.Targets) {
+		if e.DNSName == ep.DNSName && e.RecordType == ep.RecordType && e.SetIdentifier == ep.SetIdentifier && e.Targets.Same(ep.Targets) {
 			// We found a match delete the endpoint from the cache.
 			im.recordsCache = append(im.recordsCache[:i], im.recordsCache[i+1:]...)
 			return
@@ -18,6 +18,7 @@ package registry
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -38,14 +39,24 @@ func TestTXTRegistry(t *testing.T) {
 	t.Run("TestNewTXTRegistry", testTXTRegistryNew)
 	t.Run("TestRecords", testTXTRegistryRecords)
 	t.Run("TestApplyChanges", testTXTRegistryApplyChanges)
+	t.Run("TestTypedFormat", testTXTRegistryTypedFormat)
+	t.Run("TestMigration", testTXTRegistryMigration)
+	t.Run("TestDualStackLegacyFormat", testTXTRegistryDualStackLegacyFormat)
+	t.Run("TestWildcardWithSuffix", testTXTRegistryWildcardWithSuffix)
+	t.Run("TestGarbageCollection", testTXTRegistryGarbageCollection)
+	t.Run("TestSetIdentifiers", testTXTRegistrySetIdentifiers)
+	t.Run("TestFailedChanges", testTXTRegistryFailedChanges)
 }
 
 func testTXTRegistryNew(t *testing.T) {
 	p := provider.NewInMemoryProvider()
-	_, err := NewTXTRegistry(p, "txt", "", time.Hour)
+	_, err := NewTXTRegistry(p, "txt", "", "", "", time.Hour, TXTFormatLegacy, nil, nil)
 	require.Error(t, err)
 
-	r, err := NewTXTRegistry(p, "txt", "owner", time.Hour)
+	_, err = NewTXTRegistry(p, "txt", "", "", "owner", time.Hour, "unknown", nil, nil)
+	require.Error(t, err)
+
+	r, err := NewTXTRegistry(p, "txt", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
 	require.NoError(t, err)
 
 	_, ok := r.mapper.(prefixNameMapper)
@@ -53,11 +64,83 @@ func testTXTRegistryNew(t *testing.T) {
 	assert.Equal(t, "owner", r.ownerID)
 	assert.Equal(t, p, r.provider)
 
-	r, err = NewTXTRegistry(p, "", "owner", time.Hour)
+	r, err = NewTXTRegistry(p, "", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
 	require.NoError(t, err)
 
 	_, ok = r.mapper.(prefixNameMapper)
 	assert.True(t, ok)
+
+	r, err = NewTXTRegistry(p, "", "-txt", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
+	require.NoError(t, err)
+
+	_, ok = r.mapper.(suffixNameMapper)
+	assert.True(t, ok)
+
+	r, err = NewTXTRegistry(p, "", "", "{{.Name}}.txt.{{.Zone}}", "owner", time.Hour, TXTFormatTyped, nil, nil)
+	require.NoError(t, err)
+
+	_, ok = r.mapper.(*templateNameMapper)
+	assert.True(t, ok)
+
+	_, err = NewTXTRegistry(p, "txt.", "-txt", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewTXTRegistry(p, "", "", "{{.Name}}", "owner", time.Hour, TXTFormatLegacy, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewTXTRegistry(p, "", "", "{{.Type}}-{{.Name}}.{{.Zone}}", "owner", time.Hour, TXTFormatTyped, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestNameMappers(t *testing.T) {
+	template, err := newTemplateNameMapper("{{.Name}}.txt.{{.Zone}}", []string{"example.org", "apps.example.org"})
+	require.NoError(t, err)
+	typedTemplate, err := newTemplateNameMapper("_{{.Type}}.{{.Name}}.{{.Zone}}", []string{"example.org"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		title      string
+		mapper     nameMapper
+		dnsName    string
+		recordType string
+		txtName    string
+		typed      bool
+	}{
+		{"prefix", newPrefixNameMapper("txt."), "foo.example.org", endpoint.RecordTypeA, "txt.foo.example.org", false},
+		{"suffix", newSuffixNameMapper("-txt"), "foo.example.org", endpoint.RecordTypeA, "foo-txt.example.org", false},
+		{"suffix with label", newSuffixNameMapper(".txt"), "foo.bar.example.org", endpoint.RecordTypeA, "foo.txt.bar.example.org", false},
+		{"suffix with wildcard", newSuffixNameMapper("-txt"), "*.apps.example.org", endpoint.RecordTypeA, "_wildcard-txt.apps.example.org", false},
+		{"template", template, "foo.bar.example.org", endpoint.RecordTypeA, "foo.bar.txt.example.org", false},
+		{"template in longest zone", template, "foo.apps.example.org", endpoint.RecordTypeA, "foo.txt.apps.example.org", false},
+		{"template at apex", template, "example.org", endpoint.RecordTypeA, "txt.example.org", false},
+		{"template with wildcard", template, "*.apps.example.org", endpoint.RecordTypeA, "_wildcard.txt.apps.example.org", false},
+		{"template outside of zones", template, "foo.example.com", endpoint.RecordTypeA, "foo.txt.example.com", false},
+		{"typed template", typedTemplate, "foo.example.org", endpoint.RecordTypeCNAME, "_cname.foo.example.org", true},
+		{"typed template at apex", typedTemplate, "example.org", endpoint.RecordTypeA, "_a.example.org", true},
+		{"typed", newTypedNameMapper(newPrefixNameMapper("txt.")), "foo.example.org", endpoint.RecordTypeAAAA, "aaaa-txt.foo.example.org", true},
+		{"typed with wildcard", newTypedNameMapper(newPrefixNameMapper("")), "*.example.org", endpoint.RecordTypeA, "a-_wildcard.example.org", true},
+		{"typed suffix", newTypedNameMapper(newSuffixNameMapper("-txt")), "*.example.org", endpoint.RecordTypeA, "a-_wildcard-txt.example.org", true},
+		{"typed MX", newTypedNameMapper(newPrefixNameMapper("")), "example.org", endpoint.RecordTypeMX, "mx-example.org", true},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.txtName, tc.mapper.toTXTName(tc.dnsName, tc.recordType))
+
+			dnsName, recordType := tc.mapper.toEndpointName(tc.txtName)
+			assert.Equal(t, tc.dnsName, dnsName)
+			if tc.typed {
+				assert.Equal(t, tc.recordType, recordType)
+			} else {
+				assert.Empty(t, recordType)
+			}
+		})
+	}
+
+	for _, txtName := range []string{"foo.example.org", "txt-foo.example.org", "foo.txt"} {
+		dnsName, _ := newSuffixNameMapper("-txt").toEndpointName(txtName)
+		assert.Empty(t, dnsName, txtName)
+		dnsName, _ = template.toEndpointName(txtName)
+		assert.Empty(t, dnsName, txtName)
+	}
 }
 
 func testTXTRegistryRecords(t *testing.T) {
@@ -132,7 +215,7 @@ func testTXTRegistryRecordsPrefixed(t *testing.T) {
 		},
 	}
 
-	r, _ := NewTXTRegistry(p, "txt.", "owner", time.Hour)
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
 	records, _ := r.Records()
 
 	assert.True(t, testutils.SameEndpoints(records, expectedRecords))
@@ -206,7 +289,7 @@ func testTXTRegistryRecordsNoPrefix(t *testing.T) {
 		},
 	}
 
-	r, _ := NewTXTRegistry(p, "", "owner", time.Hour)
+	r, _ := NewTXTRegistry(p, "", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
 	records, _ := r.Records()
 
 	assert.True(t, testutils.SameEndpoints(records, expectedRecords))
@@ -215,6 +298,7 @@ func testTXTRegistryRecordsNoPrefix(t *testing.T) {
 func testTXTRegistryApplyChanges(t *testing.T) {
 	t.Run("With Prefix", testTXTRegistryApplyChangesWithPrefix)
 	t.Run("No prefix", testTXTRegistryApplyChangesNoPrefix)
+	t.Run("Type change", testTXTRegistryApplyChangesTypeChange)
 }
 
 func testTXTRegistryApplyChangesWithPrefix(t *testing.T) {
@@ -233,7 +317,7 @@ func testTXTRegistryApplyChangesWithPrefix(t *testing.T) {
 			newEndpointWithOwner("txt.foobar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
 		},
 	})
-	r, _ := NewTXTRegistry(p, "txt.", "owner", time.Hour)
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
 
 	changes := &plan.Changes{
 		Create: []*endpoint.Endpoint{
@@ -302,7 +386,7 @@ func testTXTRegistryApplyChangesNoPrefix(t *testing.T) {
 			newEndpointWithOwner("foobar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
 		},
 	})
-	r, _ := NewTXTRegistry(p, "", "owner", time.Hour)
+	r, _ := NewTXTRegistry(p, "", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
 
 	changes := &plan.Changes{
 		Create: []*endpoint.Endpoint{
@@ -349,6 +433,363 @@ func testTXTRegistryApplyChangesNoPrefix(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func testTXTRegistryApplyChangesTypeChange(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("tar.test-zone.example.org", "tar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("txt.tar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("foo.test-zone.example.org", "foo.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=other\"", endpoint.RecordTypeTXT, ""),
+		},
+	})
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", time.Hour, TXTFormatLegacy, nil, nil)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("tar.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "", "ingress/default/my-ingress"),
+			newEndpointWithOwnerResource("foo.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, "", "ingress/default/my-ingress"),
+		},
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("tar.test-zone.example.org", "tar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner"),
+			newEndpointWithOwner("foo.test-zone.example.org", "foo.loadbalancer.com", endpoint.RecordTypeCNAME, "other"),
+		},
+	}
+	expected := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("tar.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner", "ingress/default/my-ingress"),
+		},
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("tar.test-zone.example.org", "tar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			newEndpointWithOwner("txt.tar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner,external-dns/resource=ingress/default/my-ingress\"", endpoint.RecordTypeTXT, ""),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			newEndpointWithOwner("txt.tar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		},
+	}
+	p.OnApplyChanges = func(got *plan.Changes) {
+		mExpected := map[string][]*endpoint.Endpoint{
+			"Create":    expected.Create,
+			"UpdateNew": expected.UpdateNew,
+			"UpdateOld": expected.UpdateOld,
+			"Delete":    expected.Delete,
+		}
+		mGot := map[string][]*endpoint.Endpoint{
+			"Create":    got.Create,
+			"UpdateNew": got.UpdateNew,
+			"UpdateOld": got.UpdateOld,
+			"Delete":    got.Delete,
+		}
+		assert.True(t, testutils.SamePlanChanges(mGot, mExpected))
+	}
+	err := r.ApplyChanges(changes)
+	require.NoError(t, err)
+}
+
+func testTXTRegistryTypedFormat(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("a-txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("foo.test-zone.example.org", "2001:db8::1", endpoint.RecordTypeAAAA, ""),
+			newEndpointWithOwner("aaaa-txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner-2\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("cname-txt.bar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		},
+	})
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatTyped, nil, nil)
+
+	records, err := r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+		newEndpointWithOwner("foo.test-zone.example.org", "2001:db8::1", endpoint.RecordTypeAAAA, "owner-2"),
+		newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner"),
+	}))
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("new-record-1.test-zone.example.org", "new-loadbalancer-1.lb.com", endpoint.RecordTypeCNAME, "", "ingress/default/my-ingress"),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("foo.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, "owner", "service/default/foo"),
+		},
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner"),
+		},
+	}
+	expected := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("new-record-1.test-zone.example.org", "new-loadbalancer-1.lb.com", endpoint.RecordTypeCNAME, "owner", "ingress/default/my-ingress"),
+			newEndpointWithOwner("cname-txt.new-record-1.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner,external-dns/resource=ingress/default/my-ingress\"", endpoint.RecordTypeTXT, ""),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+			newEndpointWithOwner("a-txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			newEndpointWithOwnerResource("foo.test-zone.example.org", "1.2.3.5", endpoint.RecordTypeA, "owner", "service/default/foo"),
+			newEndpointWithOwner("a-txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner,external-dns/resource=service/default/foo\"", endpoint.RecordTypeTXT, ""),
+		},
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner"),
+			newEndpointWithOwner("cname-txt.bar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		},
+	}
+	p.OnApplyChanges = func(got *plan.Changes) {
+		mExpected := map[string][]*endpoint.Endpoint{
+			"Create":    expected.Create,
+			"UpdateNew": expected.UpdateNew,
+			"UpdateOld": expected.UpdateOld,
+			"Delete":    expected.Delete,
+		}
+		mGot := map[string][]*endpoint.Endpoint{
+			"Create":    got.Create,
+			"UpdateNew": got.UpdateNew,
+			"UpdateOld": got.UpdateOld,
+			"Delete":    got.Delete,
+		}
+		assert.True(t, testutils.SamePlanChanges(mGot, mExpected))
+	}
+	require.NoError(t, r.ApplyChanges(changes))
+}
+
+func testTXTRegistryMigration(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("foo.test-zone.example.org", "2001:db8::1", endpoint.RecordTypeAAAA, ""),
+			newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("txt.bar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner-2\"", endpoint.RecordTypeTXT, ""),
+		},
+	})
+	expectedRecords := []*endpoint.Endpoint{
+		newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+		newEndpointWithOwner("foo.test-zone.example.org", "2001:db8::1", endpoint.RecordTypeAAAA, "owner"),
+		newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, "owner-2"),
+	}
+
+	// the migration creates the typed TXT records of owned records and keeps the legacy ones
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatMigrate, nil, nil)
+	records, err := r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, expectedRecords))
+	require.NoError(t, r.ApplyChanges(&plan.Changes{}))
+
+	assert.Equal(t, []string{
+		"a-txt.foo.test-zone.example.org",
+		"aaaa-txt.foo.test-zone.example.org",
+		"txt.bar.test-zone.example.org",
+		"txt.foo.test-zone.example.org",
+	}, txtRecordNames(t, p))
+
+	// the typed format removes legacy TXT records once all owned records have typed ones
+	r, _ = NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatTyped, nil, nil)
+	records, err = r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, expectedRecords))
+	require.NoError(t, r.ApplyChanges(&plan.Changes{}))
+
+	assert.Equal(t, []string{
+		"a-txt.foo.test-zone.example.org",
+		"aaaa-txt.foo.test-zone.example.org",
+		"txt.bar.test-zone.example.org",
+	}, txtRecordNames(t, p))
+
+	records, err = r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, expectedRecords))
+}
+
+func testTXTRegistryDualStackLegacyFormat(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("foo.test-zone.example.org", "2001:db8::1", endpoint.RecordTypeAAAA, ""),
+			newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		},
+	})
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatLegacy, nil, nil)
+
+	// the TXT record shared with the A record is kept when only the AAAA record is deleted
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "2001:db8::1", endpoint.RecordTypeAAAA, "owner"),
+		},
+	}))
+	assert.Equal(t, []string{"txt.foo.test-zone.example.org"}, txtRecordNames(t, p))
+
+	records, err := r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+	}))
+
+	// it's deleted along with the last record of the name
+	require.NoError(t, r.ApplyChanges(&plan.Changes{Delete: records}))
+	assert.Empty(t, txtRecordNames(t, p))
+}
+
+func testTXTRegistryWildcardWithSuffix(t *testing.T) {
+	p := provider.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	r, _ := NewTXTRegistry(p, "", "-txt", "", "owner", 0, TXTFormatLegacy, nil, nil)
+
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("*.apps.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+		},
+	}))
+	assert.Equal(t, []string{"_wildcard-txt.apps.test-zone.example.org"}, txtRecordNames(t, p))
+
+	records, err := r.Records()
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		newEndpointWithOwner("*.apps.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+	}))
+
+	require.NoError(t, r.ApplyChanges(&plan.Changes{Delete: records}))
+	assert.Empty(t, txtRecordNames(t, p))
+}
+
+func testTXTRegistryGarbageCollection(t *testing.T) {
+	newProvider := func() provider.Provider {
+		p := provider.NewInMemoryProvider()
+		p.CreateZone(testZone)
+		p.ApplyChanges(&plan.Changes{
+			Create: []*endpoint.Endpoint{
+				newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+				newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+				newEndpointWithOwner("bar.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+				newEndpointWithOwner("a-txt.bar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+				newEndpointWithOwner("baz.test-zone.example.org", "baz.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+				newEndpointWithOwner("a-txt.baz.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+				newEndpointWithOwner("txt.gone.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+				newEndpointWithOwner("txt.back.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+				newEndpointWithOwner("txt.foreign.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner-2\"", endpoint.RecordTypeTXT, ""),
+				newEndpointWithOwner("txt.other.test-zone.example.org", "\"v=spf1 -all\"", endpoint.RecordTypeTXT, ""),
+			},
+		})
+		return p
+	}
+
+	// orphaned TXT records of the owner are deleted, the one of a record created again is kept
+	p := newProvider()
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatLegacy, nil, &plan.SyncPolicy{})
+	_, err := r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("back.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+		},
+	}))
+	assert.Equal(t, []string{
+		"a-txt.bar.test-zone.example.org",
+		"txt.back.test-zone.example.org",
+		"txt.foo.test-zone.example.org",
+		"txt.foreign.test-zone.example.org",
+		"txt.other.test-zone.example.org",
+	}, txtRecordNames(t, p))
+
+	// the orphans are only deleted once per call to Records
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Delete: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner"),
+		},
+	}))
+	assert.Len(t, txtRecordNames(t, p), 4)
+
+	// policies not allowing deletions keep them
+	p = newProvider()
+	r, _ = NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatLegacy, nil, &plan.UpsertOnlyPolicy{})
+	_, err = r.Records()
+	require.NoError(t, err)
+	require.NoError(t, r.ApplyChanges(&plan.Changes{}))
+	assert.Len(t, txtRecordNames(t, p), 7)
+}
+
+func testTXTRegistrySetIdentifiers(t *testing.T) {
+	var applied *plan.Changes
+	p := newInMemoryProvider([]*endpoint.Endpoint{
+		newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "").WithSetIdentifier("blue").WithProviderSpecific("aws/weight", "10"),
+		newEndpointWithOwner("foo.test-zone.example.org", "5.6.7.8", endpoint.RecordTypeA, "").WithSetIdentifier("green").WithProviderSpecific("aws/weight", "90"),
+		newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("blue"),
+		newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner-2\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("green"),
+	}, func(changes *plan.Changes) {
+		applied = changes
+	})
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatLegacy, nil, nil)
+
+	// every record is owned through the TXT record with its set identifier
+	records, err := r.Records()
+	require.NoError(t, err)
+	blue := newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "owner").WithSetIdentifier("blue").WithProviderSpecific("aws/weight", "10")
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		blue,
+		newEndpointWithOwner("foo.test-zone.example.org", "5.6.7.8", endpoint.RecordTypeA, "owner-2").WithSetIdentifier("green").WithProviderSpecific("aws/weight", "90"),
+	}))
+
+	// TXT records of the same name are created and deleted along with the record of their set identifier
+	require.NoError(t, r.ApplyChanges(&plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "9.9.9.9", endpoint.RecordTypeA, "").WithSetIdentifier("red").WithProviderSpecific("aws/weight", "0").
+				WithProviderSpecific("aws/health-check-protocol", "HTTP").WithProviderSpecific("aws/evaluate-target-health", "true"),
+		},
+		Delete: []*endpoint.Endpoint{blue},
+	}))
+	// the TXT record only gets the routing policy of the record, not its health check or alias properties
+	assert.True(t, testutils.SameEndpoints(applied.Create, []*endpoint.Endpoint{
+		newEndpointWithOwner("foo.test-zone.example.org", "9.9.9.9", endpoint.RecordTypeA, "owner").WithSetIdentifier("red").WithProviderSpecific("aws/weight", "0").
+			WithProviderSpecific("aws/health-check-protocol", "HTTP").WithProviderSpecific("aws/evaluate-target-health", "true"),
+		newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("red").WithProviderSpecific("aws/weight", "0"),
+	}))
+	assert.True(t, testutils.SameEndpoints(applied.Delete, []*endpoint.Endpoint{
+		blue,
+		newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("blue").WithProviderSpecific("aws/weight", "10"),
+	}))
+	assert.Empty(t, applied.UpdateNew)
+}
+
+// failingProvider fails to apply the changes of all created records.
+type failingProvider struct {
+	provider.Provider
+	applied *plan.Changes
+}
+
+func (p *failingProvider) ApplyChanges(changes *plan.Changes) error {
+	p.applied = changes
+	return &provider.FailedChangesError{Endpoints: changes.Create}
+}
+
+func testTXTRegistryFailedChanges(t *testing.T) {
+	p := &failingProvider{Provider: newInMemoryProvider(nil, nil)}
+	r, _ := NewTXTRegistry(p, "txt.", "", "", "owner", 0, TXTFormatLegacy, nil, nil)
+
+	record := newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "")
+	err := r.ApplyChanges(&plan.Changes{Create: []*endpoint.Endpoint{record}})
+
+	// the TXT record is labeled with the record it belongs to and only the record is reported
+	require.Len(t, p.applied.Create, 2)
+	assert.Equal(t, "foo.test-zone.example.org", p.applied.Create[1].Labels[endpoint.OwnedRecordLabelKey])
+	require.IsType(t, &provider.FailedChangesError{}, err)
+	assert.Equal(t, []*endpoint.Endpoint{record}, err.(*provider.FailedChangesError).Endpoints)
+}
+
 func TestCacheMethods(t *testing.T) {
 	cache := []*endpoint.Endpoint{
 		newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner"),
@@ -422,6 +863,20 @@ func newEndpointWithOwner(dnsName, target, recordType, ownerID string) *endpoint
 	return e
 }
 
+func txtRecordNames(t *testing.T, p provider.Provider) []string {
+	records, err := p.Records()
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, r := range records {
+		if r.RecordType == endpoint.RecordTypeTXT {
+			names = append(names, r.DNSName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func newEndpointWithOwnerResource(dnsName, target, recordType, ownerID, resource string) *endpoint.Endpoint {
 	e := endpoint.NewEndpoint(dnsName, recordType, target)
 	e.Labels[endpoint.OwnerLabelKey] = ownerID
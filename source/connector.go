@@ -63,3 +63,7 @@ func (cs *connectorSource) Endpoints() ([]*endpoint.Endpoint, error) {
 
 	return endpoints, nil
 }
+
+// AddEventHandler is a no-op, the remote server doesn't notify about changes.
+func (cs *connectorSource) AddEventHandler(handler func()) {
+}
@@ -19,6 +19,7 @@ package source
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
@@ -27,8 +28,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -39,6 +43,8 @@ type crdSource struct {
 	namespace   string
 	crdResource string
 	codec       runtime.ParameterCodec
+	// keeps a local cache of the DNSEndpoints instead of listing them on every synchronization
+	informer cache.SharedInformer
 }
 
 func addKnownTypes(scheme *runtime.Scheme, groupVersion schema.GroupVersion) error {
@@ -99,29 +105,52 @@ func NewCRDClientForAPIVersionKind(client kubernetes.Interface, kubeConfig, kube
 
 // NewCRDSource creates a new crdSource with the given config.
 func NewCRDSource(crdClient rest.Interface, namespace, kind string, scheme *runtime.Scheme) (Source, error) {
-	return &crdSource{
+	cs := &crdSource{
 		crdResource: strings.ToLower(kind) + "s",
 		namespace:   namespace,
 		crdClient:   crdClient,
 		codec:       runtime.NewParameterCodec(scheme),
-	}, nil
+	}
+
+	cs.informer = cache.NewSharedInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return cs.List(&options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return cs.Watch(&options)
+			},
+		},
+		&endpoint.DNSEndpoint{},
+		0,
+	)
+
+	// The informer lives as long as the process, the controller doesn't stop sources.
+	go cs.informer.Run(wait.NeverStop)
+
+	if err := waitForCacheSync(cs.informer.HasSynced); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
 }
 
 // Endpoints returns endpoint objects.
 func (cs *crdSource) Endpoints() ([]*endpoint.Endpoint, error) {
 	endpoints := []*endpoint.Endpoint{}
 
-	result, err := cs.List(&metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	for _, dnsEndpoint := range result.Items {
-		endpoints = append(endpoints, dnsEndpoint.Spec.Endpoints...)
-		dnsEndpoint.Status.ObservedGeneration = dnsEndpoint.Generation
+	for _, dnsEndpoint := range cs.dnsEndpoints() {
+		// the DNSEndpoints are shared with the informer cache, the endpoints are changed later on
+		for _, ep := range dnsEndpoint.Spec.Endpoints {
+			endpoints = append(endpoints, ep.DeepCopy())
+		}
+		if dnsEndpoint.Status.ObservedGeneration == dnsEndpoint.Generation {
+			continue
+		}
 		// Update the ObservedGeneration
-		_, err = cs.UpdateStatus(&dnsEndpoint)
-		if err != nil {
+		dnsEndpoint = dnsEndpoint.DeepCopy()
+		dnsEndpoint.Status.ObservedGeneration = dnsEndpoint.Generation
+		if _, err := cs.UpdateStatus(dnsEndpoint); err != nil {
 			log.Warnf("Could not update ObservedGeneration of the CRD: %v", err)
 		}
 	}
@@ -129,6 +158,37 @@ func (cs *crdSource) Endpoints() ([]*endpoint.Endpoint, error) {
 	return endpoints, nil
 }
 
+// AddEventHandler calls handler whenever the spec of a DNSEndpoint changes.
+func (cs *crdSource) AddEventHandler(handler func()) {
+	log.Debug("Adding event handler for CRD")
+
+	cs.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { handler() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldEndpoint, oldOK := oldObj.(*endpoint.DNSEndpoint)
+			newEndpoint, newOK := newObj.(*endpoint.DNSEndpoint)
+			// ignore status updates, e.g. the ObservedGeneration written by Endpoints
+			if oldOK && newOK && reflect.DeepEqual(oldEndpoint.Spec, newEndpoint.Spec) {
+				return
+			}
+			handler()
+		},
+		DeleteFunc: func(obj interface{}) { handler() },
+	})
+}
+
+// dnsEndpoints returns the DNSEndpoints from the informer cache.
+func (cs *crdSource) dnsEndpoints() []*endpoint.DNSEndpoint {
+	objs := cs.informer.GetStore().List()
+	dnsEndpoints := make([]*endpoint.DNSEndpoint, 0, len(objs))
+	for _, obj := range objs {
+		if dnsEndpoint, ok := obj.(*endpoint.DNSEndpoint); ok {
+			dnsEndpoints = append(dnsEndpoints, dnsEndpoint)
+		}
+	}
+	return dnsEndpoints
+}
+
 func (cs *crdSource) List(opts *metav1.ListOptions) (result *endpoint.DNSEndpointList, err error) {
 	result = &endpoint.DNSEndpointList{}
 	err = cs.crdClient.Get().
@@ -140,6 +200,15 @@ func (cs *crdSource) List(opts *metav1.ListOptions) (result *endpoint.DNSEndpoin
 	return
 }
 
+func (cs *crdSource) Watch(opts *metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return cs.crdClient.Get().
+		Namespace(cs.namespace).
+		Resource(cs.crdResource).
+		VersionedParams(opts, cs.codec).
+		Watch()
+}
+
 func (cs *crdSource) UpdateStatus(dnsEndpoint *endpoint.DNSEndpoint) (result *endpoint.DNSEndpoint, err error) {
 	result = &endpoint.DNSEndpoint{}
 	err = cs.crdClient.Put().
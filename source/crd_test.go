@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 	"github.com/stretchr/testify/require"
@@ -85,6 +86,9 @@ func startCRDServerToServeTargets(endpoints []*endpoint.Endpoint, apiVersion, ki
 		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
 			codec := codecFactory.LegacyCodec(groupVersion)
 			switch p, m := req.URL.Path, req.Method; {
+			case req.URL.Query().Get("watch") == "true" && m == http.MethodGet:
+				// an empty watch, the informer lists the objects again once it ended
+				return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
 			case p == "/apis/"+apiVersion+"/"+strings.ToLower(kind)+"s" && m == http.MethodGet:
 				fallthrough
 			case p == "/apis/"+apiVersion+"/namespaces/"+namespace+"/"+strings.ToLower(kind)+"s" && m == http.MethodGet:
@@ -114,6 +118,9 @@ func testCRDSourceImplementsSource(t *testing.T) {
 
 // testCRDSourceEndpoints tests various scenarios of using CRD source.
 func testCRDSourceEndpoints(t *testing.T) {
+	defer func(timeout time.Duration) { cacheSyncTimeout = timeout }(cacheSyncTimeout)
+	cacheSyncTimeout = time.Second
+
 	for _, ti := range []struct {
 		title                string
 		registeredNamespace  string
@@ -240,14 +247,16 @@ func testCRDSourceEndpoints(t *testing.T) {
 			scheme := runtime.NewScheme()
 			addKnownTypes(scheme, groupVersion)
 
-			cs, _ := NewCRDSource(restClient, ti.namespace, ti.kind, scheme)
-
-			receivedEndpoints, err := cs.Endpoints()
+			// the informer fails to list the DNSEndpoints of an invalid kind
+			cs, err := NewCRDSource(restClient, ti.namespace, ti.kind, scheme)
 			if ti.expectError {
 				require.Errorf(t, err, "Received err %v", err)
-			} else {
-				require.NoErrorf(t, err, "Received err %v", err)
+				return
 			}
+			require.NoErrorf(t, err, "Received err %v", err)
+
+			receivedEndpoints, err := cs.Endpoints()
+			require.NoErrorf(t, err, "Received err %v", err)
 
 			if len(receivedEndpoints) == 0 && !ti.expectEndpoints {
 				return
@@ -255,6 +264,14 @@ func testCRDSourceEndpoints(t *testing.T) {
 
 			// Validate received endpoints against expected endpoints.
 			validateEndpoints(t, receivedEndpoints, ti.endpoints)
+
+			// the endpoints are copies, changing them leaves the informer cache untouched
+			for _, ep := range receivedEndpoints {
+				ep.DNSName = "changed.example.org"
+			}
+			receivedEndpoints, err = cs.Endpoints()
+			require.NoError(t, err)
+			validateEndpoints(t, receivedEndpoints, ti.endpoints)
 		})
 	}
 }
@@ -43,7 +43,7 @@ func (ms *dedupSource) Endpoints() ([]*endpoint.Endpoint, error) {
 	}
 
 	for _, ep := range endpoints {
-		identifier := ep.DNSName + " / " + ep.Targets.String()
+		identifier := ep.DNSName + " / " + ep.SetIdentifier + " / " + ep.Targets.String()
 
 		if _, ok := collected[identifier]; ok {
 			log.Debugf("Removing duplicate endpoint %s", ep)
@@ -56,3 +56,8 @@ func (ms *dedupSource) Endpoints() ([]*endpoint.Endpoint, error) {
 
 	return result, nil
 }
+
+// AddEventHandler adds the handler to the wrapped source.
+func (ms *dedupSource) AddEventHandler(handler func()) {
+	ms.source.AddEventHandler(handler)
+}
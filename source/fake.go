@@ -65,6 +65,10 @@ func (sc *fakeSource) Endpoints() ([]*endpoint.Endpoint, error) {
 	return endpoints, nil
 }
 
+// AddEventHandler is a no-op, the fake endpoints are generated on every call to Endpoints.
+func (sc *fakeSource) AddEventHandler(handler func()) {
+}
+
 func (sc *fakeSource) generateEndpoint() (*endpoint.Endpoint, error) {
 	ep := endpoint.NewEndpoint(
 		generateDNSName(4, sc.dnsName),
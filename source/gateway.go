@@ -27,8 +27,13 @@ import (
 
 	istionetworking "istio.io/api/networking/v1alpha3"
 	istiomodel "istio.io/istio/pilot/pkg/model"
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 	"k8s.io/client-go/kubernetes"
@@ -38,7 +43,6 @@ import (
 // The gateway implementation uses the spec.servers.hosts values for the hostnames.
 // Use targetAnnotationKey to explicitly set Endpoint.
 type gatewaySource struct {
-	kubeClient              kubernetes.Interface
 	istioClient             istiomodel.ConfigStore
 	istioNamespace          string
 	istioIngressGatewayName string
@@ -46,6 +50,7 @@ type gatewaySource struct {
 	annotationFilter        string
 	fqdnTemplate            *template.Template
 	combineFQDNAnnotation   bool
+	serviceInformer         coreinformers.ServiceInformer
 }
 
 // NewIstioGatewaySource creates a new gatewaySource with the given config.
@@ -76,8 +81,26 @@ func NewIstioGatewaySource(
 		}
 	}
 
+	// Use a shared informer to keep a local cache of the services in the namespace
+	// of the ingress gateway instead of fetching it on every synchronization.
+	informerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 0, kubeinformers.WithNamespace(istioNamespace))
+	serviceInformer := informerFactory.Core().V1().Services()
+	synced := []cache.InformerSynced{serviceInformer.Informer().HasSynced}
+
+	// The informers live as long as the process, the controller doesn't stop sources.
+	informerFactory.Start(wait.NeverStop)
+
+	// A caching Istio client serves the gateways from its own informers as well.
+	if istioCache, ok := istioClient.(istiomodel.ConfigStoreCache); ok {
+		go istioCache.Run(wait.NeverStop)
+		synced = append(synced, istioCache.HasSynced)
+	}
+
+	if err := waitForCacheSync(synced...); err != nil {
+		return nil, err
+	}
+
 	return &gatewaySource{
-		kubeClient:              kubeClient,
 		istioClient:             istioClient,
 		istioNamespace:          istioNamespace,
 		istioIngressGatewayName: istioIngressGatewayName,
@@ -85,6 +108,7 @@ func NewIstioGatewaySource(
 		annotationFilter:        annotationFilter,
 		fqdnTemplate:            tmpl,
 		combineFQDNAnnotation:   combineFqdnAnnotation,
+		serviceInformer:         serviceInformer,
 	}, nil
 }
 
@@ -172,14 +196,14 @@ func (sc *gatewaySource) endpointsFromTemplate(config *istiomodel.Config) ([]*en
 		}
 	}
 
-	providerSpecific := getProviderSpecificAnnotations(config.Annotations)
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(config.Annotations)
 
 	var endpoints []*endpoint.Endpoint
 	// splits the FQDN template and removes the trailing periods
 	hostnameList := strings.Split(strings.Replace(hostnames, " ", "", -1), ",")
 	for _, hostname := range hostnameList {
 		hostname = strings.TrimSuffix(hostname, ".")
-		endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific)...)
+		endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific, setIdentifier)...)
 	}
 	return endpoints, nil
 }
@@ -218,11 +242,34 @@ func (sc *gatewaySource) filterByAnnotations(configs []istiomodel.Config) ([]ist
 func (sc *gatewaySource) setResourceLabel(config istiomodel.Config, endpoints []*endpoint.Endpoint) {
 	for _, ep := range endpoints {
 		ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("gateway/%s/%s", config.Namespace, config.Name)
+		setCreationTimestampLabel(ep, config.CreationTimestamp.Time)
+	}
+}
+
+// AddEventHandler calls handler whenever the ingress gateway service changes.
+// Changes to gateways are only reported if the Istio client is a ConfigStoreCache,
+// otherwise they are picked up with the next regular synchronization.
+func (sc *gatewaySource) AddEventHandler(handler func()) {
+	log.Debug("Adding event handlers for gateways and the ingress gateway service")
+
+	sc.serviceInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			// deleted objects may be wrapped in a cache.DeletedFinalStateUnknown
+			svc, ok := obj.(*v1.Service)
+			return !ok || svc.Name == sc.istioIngressGatewayName
+		},
+		Handler: eventHandlerFuncs(handler),
+	})
+
+	if istioCache, ok := sc.istioClient.(istiomodel.ConfigStoreCache); ok {
+		istioCache.RegisterEventHandler(istiomodel.Gateway.Type, func(istiomodel.Config, istiomodel.Event) {
+			handler()
+		})
 	}
 }
 
 func (sc *gatewaySource) targetsFromIstioIngressStatus() (targets endpoint.Targets, err error) {
-	if svc, e := sc.kubeClient.CoreV1().Services(sc.istioNamespace).Get(sc.istioIngressGatewayName, metav1.GetOptions{}); e != nil {
+	if svc, e := sc.serviceInformer.Lister().Services(sc.istioNamespace).Get(sc.istioIngressGatewayName); e != nil {
 		err = e
 	} else {
 		for _, lb := range svc.Status.LoadBalancer.Ingress {
@@ -258,20 +305,20 @@ func (sc *gatewaySource) endpointsFromGatewayConfig(config istiomodel.Config) ([
 
 	gateway := config.Spec.(*istionetworking.Gateway)
 
-	providerSpecific := getProviderSpecificAnnotations(config.Annotations)
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(config.Annotations)
 
 	for _, server := range gateway.Servers {
 		for _, host := range server.Hosts {
 			if host == "" {
 				continue
 			}
-			endpoints = append(endpoints, endpointsForHostname(host, targets, ttl, providerSpecific)...)
+			endpoints = append(endpoints, endpointsForHostname(host, targets, ttl, providerSpecific, setIdentifier)...)
 		}
 	}
 
 	hostnameList := getHostnamesFromAnnotations(config.Annotations)
 	for _, hostname := range hostnameList {
-		endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific)...)
+		endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific, setIdentifier)...)
 	}
 
 	return endpoints, nil
@@ -44,15 +44,6 @@ func (suite *IngressSuite) SetupTest() {
 	fakeClient := fake.NewSimpleClientset()
 	var err error
 
-	suite.sc, err = NewIngressSource(
-		fakeClient,
-		"",
-		"",
-		"{{.Name}}",
-		false,
-	)
-	suite.NoError(err, "should initialize ingress source")
-
 	suite.fooWithTargets = (fakeIngress{
 		name:      "foo-with-targets",
 		namespace: "default",
@@ -62,6 +53,15 @@ func (suite *IngressSuite) SetupTest() {
 	}).Ingress()
 	_, err = fakeClient.Extensions().Ingresses(suite.fooWithTargets.Namespace).Create(suite.fooWithTargets)
 	suite.NoError(err, "should succeed")
+
+	suite.sc, err = NewIngressSource(
+		fakeClient,
+		"",
+		"",
+		"{{.Name}}",
+		false,
+	)
+	suite.NoError(err, "should initialize ingress source")
 }
 
 func (suite *IngressSuite) TestResourceLabelIsSet() {
@@ -942,6 +942,11 @@ func testIngressEndpoints(t *testing.T) {
 			}
 
 			fakeClient := fake.NewSimpleClientset()
+			for _, ingress := range ingresses {
+				_, err := fakeClient.Extensions().Ingresses(ingress.Namespace).Create(ingress)
+				require.NoError(t, err)
+			}
+
 			ingressSource, _ := NewIngressSource(
 				fakeClient,
 				ti.targetNamespace,
@@ -949,10 +954,6 @@ func testIngressEndpoints(t *testing.T) {
 				ti.fqdnTemplate,
 				ti.combineFQDNAndAnnotation,
 			)
-			for _, ingress := range ingresses {
-				_, err := fakeClient.Extensions().Ingresses(ingress.Namespace).Create(ingress)
-				require.NoError(t, err)
-			}
 
 			res, err := ingressSource.Endpoints()
 			if ti.expectError {
@@ -961,7 +962,7 @@ func testIngressEndpoints(t *testing.T) {
 				assert.NoError(t, err)
 			}
 
-			validateEndpoints(t, res, ti.expected)
+			validateEndpoints(t, sortEndpoints(res), sortEndpoints(ti.expected))
 		})
 	}
 }
@@ -39,6 +39,13 @@ func (ms *multiSource) Endpoints() ([]*endpoint.Endpoint, error) {
 	return result, nil
 }
 
+// AddEventHandler adds the handler to all nested Sources.
+func (ms *multiSource) AddEventHandler(handler func()) {
+	for _, s := range ms.children {
+		s.AddEventHandler(handler)
+	}
+}
+
 // NewMultiSource creates a new multiSource.
 func NewMultiSource(children []Source) Source {
 	return &multiSource{children: children}
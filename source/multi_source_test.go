@@ -24,6 +24,7 @@ import (
 	"github.com/kubernetes-incubator/external-dns/internal/testutils"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,6 +32,7 @@ func TestMultiSource(t *testing.T) {
 	t.Run("Interface", testMultiSourceImplementsSource)
 	t.Run("Endpoints", testMultiSourceEndpoints)
 	t.Run("EndpointsWithError", testMultiSourceEndpointsWithError)
+	t.Run("AddEventHandler", testMultiSourceAddEventHandler)
 }
 
 // testMultiSourceImplementsSource tests that multiSource is a valid Source.
@@ -118,3 +120,23 @@ func testMultiSourceEndpointsWithError(t *testing.T) {
 	// Validate that the nested source was called.
 	src.AssertExpectations(t)
 }
+
+// testMultiSourceAddEventHandler tests that event handlers are added to all nested sources.
+func testMultiSourceAddEventHandler(t *testing.T) {
+	sources := []Source{}
+	for i := 0; i < 2; i++ {
+		src := new(testutils.MockSource)
+		src.On("AddEventHandler", mock.AnythingOfType("func()")).Return()
+
+		sources = append(sources, src)
+	}
+
+	// Create our object under test and add a handler.
+	source := NewMultiSource(sources)
+	source.AddEventHandler(func() {})
+
+	// Validate that the handler was added to the nested sources.
+	for _, src := range sources {
+		src.(*testutils.MockSource).AssertExpectations(t)
+	}
+}
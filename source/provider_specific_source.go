@@ -0,0 +1,62 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+)
+
+// providerSpecificSource is a Source that adds default provider specific properties to the
+// endpoints of its wrapped source. The planner only compares the properties set on desired
+// endpoints, with the provider's defaults it updates records that deviate from them.
+type providerSpecificSource struct {
+	source   Source
+	defaults endpoint.ProviderSpecific
+}
+
+// NewProviderSpecificSource creates a new providerSpecificSource wrapping the provided Source.
+// Properties set on the endpoints, e.g. by annotations, take precedence over the defaults.
+func NewProviderSpecificSource(source Source, defaults endpoint.ProviderSpecific) Source {
+	return &providerSpecificSource{source: source, defaults: defaults}
+}
+
+// Endpoints collects endpoints from its wrapped source and adds the default properties they're missing.
+func (ps *providerSpecificSource) Endpoints() ([]*endpoint.Endpoint, error) {
+	endpoints, err := ps.source.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ep := range endpoints {
+		// the properties may be shared with other endpoints of the same resource
+		providerSpecific := endpoint.ProviderSpecific{}
+		for key, value := range ps.defaults {
+			providerSpecific[key] = value
+		}
+		for key, value := range ep.ProviderSpecific {
+			providerSpecific[key] = value
+		}
+		ep.ProviderSpecific = providerSpecific
+	}
+
+	return endpoints, nil
+}
+
+// AddEventHandler adds the handler to the wrapped source.
+func (ps *providerSpecificSource) AddEventHandler(handler func()) {
+	ps.source.AddEventHandler(handler)
+}
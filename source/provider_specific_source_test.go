@@ -0,0 +1,55 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubernetes-incubator/external-dns/endpoint"
+	"github.com/kubernetes-incubator/external-dns/internal/testutils"
+)
+
+// Validates that providerSpecificSource is a Source
+var _ Source = &providerSpecificSource{}
+
+func TestProviderSpecificSource(t *testing.T) {
+	shared := endpoint.ProviderSpecific{"aws/weight": "10"}
+	mockSource := new(testutils.MockSource)
+	mockSource.On("Endpoints").Return([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeCNAME, "foo.elb.amazonaws.com"),
+		{DNSName: "bar.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}, ProviderSpecific: shared},
+		{DNSName: "bar.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"2001:db8::1"}, ProviderSpecific: shared},
+		endpoint.NewEndpoint("baz.example.org", endpoint.RecordTypeCNAME, "baz.elb.amazonaws.com").WithProviderSpecific("aws/dualstack", "false"),
+	}, nil)
+
+	endpoints, err := NewProviderSpecificSource(mockSource, endpoint.ProviderSpecific{"aws/dualstack": "true"}).Endpoints()
+	require.NoError(t, err)
+	mockSource.AssertExpectations(t)
+
+	// the defaults are added unless the endpoint sets the property itself
+	require.Len(t, endpoints, 4)
+	assert.Equal(t, endpoint.ProviderSpecific{"aws/dualstack": "true"}, endpoints[0].ProviderSpecific)
+	assert.Equal(t, endpoint.ProviderSpecific{"aws/dualstack": "true", "aws/weight": "10"}, endpoints[1].ProviderSpecific)
+	assert.Equal(t, endpoint.ProviderSpecific{"aws/dualstack": "true", "aws/weight": "10"}, endpoints[2].ProviderSpecific)
+	assert.Equal(t, endpoint.ProviderSpecific{"aws/dualstack": "false"}, endpoints[3].ProviderSpecific)
+
+	// properties shared by the endpoints of a resource are left as they are
+	assert.Equal(t, endpoint.ProviderSpecific{"aws/weight": "10"}, shared)
+}
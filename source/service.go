@@ -19,6 +19,7 @@ package source
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"text/template"
@@ -29,7 +30,11 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 )
@@ -44,7 +49,6 @@ const (
 // matched services' entrypoints it will return a corresponding
 // Endpoint object.
 type serviceSource struct {
-	client           kubernetes.Interface
 	namespace        string
 	annotationFilter string
 	// process Services with legacy annotations
@@ -54,6 +58,10 @@ type serviceSource struct {
 	publishInternal       bool
 	publishHostIP         bool
 	serviceTypeFilter     map[string]struct{}
+	serviceInformer       coreinformers.ServiceInformer
+	podInformer           coreinformers.PodInformer
+	// nil if we aren't allowed to list nodes
+	nodeInformer coreinformers.NodeInformer
 }
 
 // NewServiceSource creates a new serviceSource with the given config.
@@ -78,8 +86,38 @@ func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 		serviceTypes[serviceType] = struct{}{}
 	}
 
+	// Use shared informers to keep a local cache of services, pods and nodes
+	// instead of listing them from the API server on every synchronization.
+	// The resync period is disabled as changes are delivered by watches.
+	informerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 0, kubeinformers.WithNamespace(namespace))
+	serviceInformer := informerFactory.Core().V1().Services()
+	podInformer := informerFactory.Core().V1().Pods()
+	synced := []cache.InformerSynced{
+		serviceInformer.Informer().HasSynced,
+		podInformer.Informer().HasSynced,
+	}
+
+	// Nodes are cluster scoped and often not readable with namespaced permissions.
+	// Skip the node cache in that case, NodePort services will be skipped then.
+	var nodeInformer coreinformers.NodeInformer
+	if _, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{Limit: 1}); err != nil {
+		if !errors.IsForbidden(err) {
+			return nil, err
+		}
+		log.Debugf("Unable to list nodes (Forbidden), NodePort services will be skipped")
+	} else {
+		nodeInformer = informerFactory.Core().V1().Nodes()
+		synced = append(synced, nodeInformer.Informer().HasSynced)
+	}
+
+	// The informers live as long as the process, the controller doesn't stop sources.
+	informerFactory.Start(wait.NeverStop)
+
+	if err := waitForCacheSync(synced...); err != nil {
+		return nil, err
+	}
+
 	return &serviceSource{
-		client:                kubeClient,
 		namespace:             namespace,
 		annotationFilter:      annotationFilter,
 		compatibility:         compatibility,
@@ -88,23 +126,26 @@ func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 		publishInternal:       publishInternal,
 		publishHostIP:         publishHostIP,
 		serviceTypeFilter:     serviceTypes,
+		serviceInformer:       serviceInformer,
+		podInformer:           podInformer,
+		nodeInformer:          nodeInformer,
 	}, nil
 }
 
 // Endpoints returns endpoint objects for each service that should be processed.
 func (sc *serviceSource) Endpoints() ([]*endpoint.Endpoint, error) {
-	services, err := sc.client.CoreV1().Services(sc.namespace).List(metav1.ListOptions{})
+	services, err := sc.serviceInformer.Lister().Services(sc.namespace).List(labels.Everything())
 	if err != nil {
 		return nil, err
 	}
-	services.Items, err = sc.filterByAnnotations(services.Items)
+	services, err = sc.filterByAnnotations(services)
 	if err != nil {
 		return nil, err
 	}
 
 	// filter on service types if at least one has been provided
 	if len(sc.serviceTypeFilter) > 0 {
-		services.Items = sc.filterByServiceType(services.Items)
+		services = sc.filterByServiceType(services)
 	}
 
 	// get the ip addresses of all the nodes and cache them for this run
@@ -115,7 +156,7 @@ func (sc *serviceSource) Endpoints() ([]*endpoint.Endpoint, error) {
 
 	endpoints := []*endpoint.Endpoint{}
 
-	for _, svc := range services.Items {
+	for _, svc := range services {
 		// Check controller annotation to see if we are responsible.
 		controller, ok := svc.Annotations[controllerAnnotationKey]
 		if ok && controller != controllerAnnotationValue {
@@ -124,16 +165,16 @@ func (sc *serviceSource) Endpoints() ([]*endpoint.Endpoint, error) {
 			continue
 		}
 
-		svcEndpoints := sc.endpoints(&svc, nodeTargets)
+		svcEndpoints := sc.endpoints(svc, nodeTargets)
 
 		// process legacy annotations if no endpoints were returned and compatibility mode is enabled.
 		if len(svcEndpoints) == 0 && sc.compatibility != "" {
-			svcEndpoints = legacyEndpointsFromService(&svc, sc.compatibility)
+			svcEndpoints = legacyEndpointsFromService(svc, sc.compatibility)
 		}
 
 		// apply template if none of the above is found
 		if (sc.combineFQDNAnnotation || len(svcEndpoints) == 0) && sc.fqdnTemplate != nil {
-			sEndpoints, err := sc.endpointsFromTemplate(&svc, nodeTargets)
+			sEndpoints, err := sc.endpointsFromTemplate(svc, nodeTargets)
 			if err != nil {
 				return nil, err
 			}
@@ -162,16 +203,42 @@ func (sc *serviceSource) Endpoints() ([]*endpoint.Endpoint, error) {
 	return endpoints, nil
 }
 
+// AddEventHandler calls handler whenever a service, pod or node in the local cache changes.
+func (sc *serviceSource) AddEventHandler(handler func()) {
+	log.Debug("Adding event handlers for services, pods and nodes")
+
+	sc.serviceInformer.Informer().AddEventHandler(eventHandlerFuncs(handler))
+	sc.podInformer.Informer().AddEventHandler(eventHandlerFuncs(handler))
+	if sc.nodeInformer != nil {
+		sc.nodeInformer.Informer().AddEventHandler(nodeEventHandlerFuncs(handler))
+	}
+}
+
+// nodeEventHandlerFuncs is like eventHandlerFuncs but ignores node updates that
+// don't touch the addresses, e.g. the periodic status updates of the kubelet.
+func nodeEventHandlerFuncs(handler func()) cache.ResourceEventHandlerFuncs {
+	funcs := eventHandlerFuncs(handler)
+	funcs.UpdateFunc = func(oldObj, newObj interface{}) {
+		oldNode, oldOK := oldObj.(*v1.Node)
+		newNode, newOK := newObj.(*v1.Node)
+		if oldOK && newOK && reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) {
+			return
+		}
+		handler()
+	}
+	return funcs
+}
+
 func (sc *serviceSource) extractHeadlessEndpoints(svc *v1.Service, hostname string, ttl endpoint.TTL) []*endpoint.Endpoint {
 	var endpoints []*endpoint.Endpoint
 
-	pods, err := sc.client.CoreV1().Pods(svc.Namespace).List(metav1.ListOptions{LabelSelector: labels.Set(svc.Spec.Selector).AsSelectorPreValidated().String()})
+	pods, err := sc.podInformer.Lister().Pods(svc.Namespace).List(labels.Set(svc.Spec.Selector).AsSelectorPreValidated())
 	if err != nil {
 		log.Errorf("List Pods of service[%s] error:%v", svc.GetName(), err)
 		return endpoints
 	}
 
-	for _, v := range pods.Items {
+	for _, v := range pods {
 		headlessDomain := hostname
 		if v.Spec.Hostname != "" {
 			headlessDomain = v.Spec.Hostname + "." + headlessDomain
@@ -182,9 +249,9 @@ func (sc *serviceSource) extractHeadlessEndpoints(svc *v1.Service, hostname stri
 			// To reduce traffice on the DNS API only add record for running Pods. Good Idea?
 			if v.Status.Phase == v1.PodRunning {
 				if ttl.IsConfigured() {
-					endpoints = append(endpoints, endpoint.NewEndpointWithTTL(headlessDomain, endpoint.RecordTypeA, ttl, v.Status.HostIP))
+					endpoints = append(endpoints, endpoint.NewEndpointWithTTL(headlessDomain, suitableType(v.Status.HostIP), ttl, v.Status.HostIP))
 				} else {
-					endpoints = append(endpoints, endpoint.NewEndpoint(headlessDomain, endpoint.RecordTypeA, v.Status.HostIP))
+					endpoints = append(endpoints, endpoint.NewEndpoint(headlessDomain, suitableType(v.Status.HostIP), v.Status.HostIP))
 				}
 			} else {
 				log.Debugf("Pod %s is not in running phase", v.Spec.Hostname)
@@ -194,9 +261,9 @@ func (sc *serviceSource) extractHeadlessEndpoints(svc *v1.Service, hostname stri
 			// To reduce traffice on the DNS API only add record for running Pods. Good Idea?
 			if v.Status.Phase == v1.PodRunning {
 				if ttl.IsConfigured() {
-					endpoints = append(endpoints, endpoint.NewEndpointWithTTL(headlessDomain, endpoint.RecordTypeA, ttl, v.Status.PodIP))
+					endpoints = append(endpoints, endpoint.NewEndpointWithTTL(headlessDomain, suitableType(v.Status.PodIP), ttl, v.Status.PodIP))
 				} else {
-					endpoints = append(endpoints, endpoint.NewEndpoint(headlessDomain, endpoint.RecordTypeA, v.Status.PodIP))
+					endpoints = append(endpoints, endpoint.NewEndpoint(headlessDomain, suitableType(v.Status.PodIP), v.Status.PodIP))
 				}
 			} else {
 				log.Debugf("Pod %s is not in running phase", v.Spec.Hostname)
@@ -239,7 +306,7 @@ func (sc *serviceSource) endpoints(svc *v1.Service, nodeTargets endpoint.Targets
 }
 
 // filterByAnnotations filters a list of services by a given annotation selector.
-func (sc *serviceSource) filterByAnnotations(services []v1.Service) ([]v1.Service, error) {
+func (sc *serviceSource) filterByAnnotations(services []*v1.Service) ([]*v1.Service, error) {
 	labelSelector, err := metav1.ParseToLabelSelector(sc.annotationFilter)
 	if err != nil {
 		return nil, err
@@ -254,7 +321,7 @@ func (sc *serviceSource) filterByAnnotations(services []v1.Service) ([]v1.Servic
 		return services, nil
 	}
 
-	filteredList := []v1.Service{}
+	filteredList := []*v1.Service{}
 
 	for _, service := range services {
 		// convert the service's annotations to an equivalent label selector
@@ -270,8 +337,8 @@ func (sc *serviceSource) filterByAnnotations(services []v1.Service) ([]v1.Servic
 }
 
 // filterByServiceType filters services according their types
-func (sc *serviceSource) filterByServiceType(services []v1.Service) []v1.Service {
-	filteredList := []v1.Service{}
+func (sc *serviceSource) filterByServiceType(services []*v1.Service) []*v1.Service {
+	filteredList := []*v1.Service{}
 	for _, service := range services {
 		// Check if the service is of the given type or not
 		if _, ok := sc.serviceTypeFilter[string(service.Spec.Type)]; ok {
@@ -282,9 +349,10 @@ func (sc *serviceSource) filterByServiceType(services []v1.Service) []v1.Service
 	return filteredList
 }
 
-func (sc *serviceSource) setResourceLabel(service v1.Service, endpoints []*endpoint.Endpoint) {
+func (sc *serviceSource) setResourceLabel(service *v1.Service, endpoints []*endpoint.Endpoint) {
 	for _, ep := range endpoints {
 		ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("service/%s/%s", service.Namespace, service.Name)
+		setCreationTimestampLabel(ep, service.CreationTimestamp.Time)
 	}
 }
 
@@ -295,20 +363,36 @@ func (sc *serviceSource) generateEndpoints(svc *v1.Service, hostname string, nod
 		log.Warn(err)
 	}
 
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(svc.Annotations)
+
 	epA := &endpoint.Endpoint{
-		RecordTTL:  ttl,
-		RecordType: endpoint.RecordTypeA,
-		Labels:     endpoint.NewLabels(),
-		Targets:    make(endpoint.Targets, 0, defaultTargetsCapacity),
-		DNSName:    hostname,
+		RecordTTL:        ttl,
+		RecordType:       endpoint.RecordTypeA,
+		Labels:           endpoint.NewLabels(),
+		Targets:          make(endpoint.Targets, 0, defaultTargetsCapacity),
+		DNSName:          hostname,
+		ProviderSpecific: providerSpecific,
+		SetIdentifier:    setIdentifier,
+	}
+
+	epAAAA := &endpoint.Endpoint{
+		RecordTTL:        ttl,
+		RecordType:       endpoint.RecordTypeAAAA,
+		Labels:           endpoint.NewLabels(),
+		Targets:          make(endpoint.Targets, 0, defaultTargetsCapacity),
+		DNSName:          hostname,
+		ProviderSpecific: providerSpecific,
+		SetIdentifier:    setIdentifier,
 	}
 
 	epCNAME := &endpoint.Endpoint{
-		RecordTTL:  ttl,
-		RecordType: endpoint.RecordTypeCNAME,
-		Labels:     endpoint.NewLabels(),
-		Targets:    make(endpoint.Targets, 0, defaultTargetsCapacity),
-		DNSName:    hostname,
+		RecordTTL:        ttl,
+		RecordType:       endpoint.RecordTypeCNAME,
+		Labels:           endpoint.NewLabels(),
+		Targets:          make(endpoint.Targets, 0, defaultTargetsCapacity),
+		DNSName:          hostname,
+		ProviderSpecific: providerSpecific,
+		SetIdentifier:    setIdentifier,
 	}
 
 	var endpoints []*endpoint.Endpoint
@@ -331,10 +415,12 @@ func (sc *serviceSource) generateEndpoints(svc *v1.Service, hostname string, nod
 	}
 
 	for _, t := range targets {
-		if suitableType(t) == endpoint.RecordTypeA {
+		switch suitableType(t) {
+		case endpoint.RecordTypeA:
 			epA.Targets = append(epA.Targets, t)
-		}
-		if suitableType(t) == endpoint.RecordTypeCNAME {
+		case endpoint.RecordTypeAAAA:
+			epAAAA.Targets = append(epAAAA.Targets, t)
+		case endpoint.RecordTypeCNAME:
 			epCNAME.Targets = append(epCNAME.Targets, t)
 		}
 	}
@@ -342,6 +428,9 @@ func (sc *serviceSource) generateEndpoints(svc *v1.Service, hostname string, nod
 	if len(epA.Targets) > 0 {
 		endpoints = append(endpoints, epA)
 	}
+	if len(epAAAA.Targets) > 0 {
+		endpoints = append(endpoints, epAAAA)
+	}
 	if len(epCNAME.Targets) > 0 {
 		endpoints = append(endpoints, epCNAME)
 	}
@@ -378,17 +467,17 @@ func (sc *serviceSource) extractNodeTargets() (endpoint.Targets, error) {
 		externalIPs endpoint.Targets
 	)
 
-	nodes, err := sc.client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if sc.nodeInformer == nil {
+		// Return an empty list because it makes sense to continue and try other sources.
+		return endpoint.Targets{}, nil
+	}
+
+	nodes, err := sc.nodeInformer.Lister().List(labels.Everything())
 	if err != nil {
-		if errors.IsForbidden(err) {
-			// Return an empty list because it makes sense to continue and try other sources.
-			log.Debugf("Unable to list nodes (Forbidden), returning empty list of targets (NodePort services will be skipped)")
-			return endpoint.Targets{}, nil
-		}
 		return nil, err
 	}
 
-	for _, node := range nodes.Items {
+	for _, node := range nodes {
 		for _, address := range node.Status.Addresses {
 			switch address.Type {
 			case v1.NodeExternalIP:
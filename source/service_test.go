@@ -19,10 +19,15 @@ package source
 import (
 	"net"
 	"testing"
+	"time"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 
@@ -41,25 +46,15 @@ func (suite *ServiceSuite) SetupTest() {
 	fakeClient := fake.NewSimpleClientset()
 	var err error
 
-	suite.sc, err = NewServiceSource(
-		fakeClient,
-		"",
-		"",
-		"{{.Name}}",
-		false,
-		"",
-		false,
-		false,
-		[]string{},
-	)
 	suite.fooWithTargets = &v1.Service{
 		Spec: v1.ServiceSpec{
 			Type: v1.ServiceTypeLoadBalancer,
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace:   "default",
-			Name:        "foo-with-targets",
-			Annotations: map[string]string{},
+			Namespace:         "default",
+			Name:              "foo-with-targets",
+			Annotations:       map[string]string{},
+			CreationTimestamp: metav1.Date(2018, 10, 1, 12, 30, 0, 0, time.UTC),
 		},
 		Status: v1.ServiceStatus{
 			LoadBalancer: v1.LoadBalancerStatus{
@@ -71,11 +66,21 @@ func (suite *ServiceSuite) SetupTest() {
 		},
 	}
 
-	suite.NoError(err, "should initialize service source")
-
 	_, err = fakeClient.CoreV1().Services(suite.fooWithTargets.Namespace).Create(suite.fooWithTargets)
 	suite.NoError(err, "should successfully create service")
 
+	suite.sc, err = NewServiceSource(
+		fakeClient,
+		"",
+		"",
+		"{{.Name}}",
+		false,
+		"",
+		false,
+		false,
+		[]string{},
+	)
+	suite.NoError(err, "should initialize service source")
 }
 
 func (suite *ServiceSuite) TestResourceLabelIsSet() {
@@ -85,6 +90,13 @@ func (suite *ServiceSuite) TestResourceLabelIsSet() {
 	}
 }
 
+func (suite *ServiceSuite) TestCreationTimestampLabelIsSet() {
+	endpoints, _ := suite.sc.Endpoints()
+	for _, ep := range endpoints {
+		suite.Equal("2018-10-01T12:30:00Z", ep.Labels[endpoint.CreationTimestampLabelKey], "should set correct creation timestamp label")
+	}
+}
+
 func TestServiceSource(t *testing.T) {
 	suite.Run(t, new(ServiceSuite))
 	t.Run("Interface", testServiceSourceImplementsSource)
@@ -370,6 +382,29 @@ func testServiceSourceEndpoints(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"annotated services with dual-stack load balancers return A and AAAA endpoints",
+			"",
+			"",
+			"testing",
+			"foo",
+			v1.ServiceTypeLoadBalancer,
+			"",
+			"",
+			false,
+			map[string]string{},
+			map[string]string{
+				hostnameAnnotationKey: "foo.example.org.",
+			},
+			"",
+			[]string{"1.2.3.4", "2001:db8::1"},
+			[]string{},
+			[]*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"2001:db8::1"}},
+			},
+			false,
+		},
 		{
 			"our controller type is dns-controller",
 			"",
@@ -1503,8 +1538,8 @@ func TestHeadlessServices(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			// Validate returned endpoints against desired endpoints.
-			validateEndpoints(t, endpoints, tc.expected)
+			// Validate returned endpoints against desired endpoints, the pods are listed in no particular order.
+			validateEndpoints(t, sortEndpoints(endpoints), sortEndpoints(tc.expected))
 		})
 	}
 }
@@ -1704,12 +1739,83 @@ func TestHeadlessServicesHostIP(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			// Validate returned endpoints against desired endpoints.
-			validateEndpoints(t, endpoints, tc.expected)
+			// Validate returned endpoints against desired endpoints, the pods are listed in no particular order.
+			validateEndpoints(t, sortEndpoints(endpoints), sortEndpoints(tc.expected))
 		})
 	}
 }
 
+// TestServiceSourceForbiddenNodes tests that NodePort services are skipped
+// without an error if we aren't allowed to list nodes.
+func TestServiceSourceForbiddenNodes(t *testing.T) {
+	kubernetes := fake.NewSimpleClientset()
+	kubernetes.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", nil)
+	})
+
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeNodePort,
+			Ports: []v1.ServicePort{{NodePort: 30192}},
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "testing",
+			Name:      "foo",
+			Annotations: map[string]string{
+				hostnameAnnotationKey: "foo.example.org.",
+			},
+		},
+	}
+
+	_, err := kubernetes.CoreV1().Services(service.Namespace).Create(service)
+	require.NoError(t, err)
+
+	client, err := NewServiceSource(kubernetes, v1.NamespaceAll, "", "", false, "", false, false, []string{})
+	require.NoError(t, err)
+
+	endpoints, err := client.Endpoints()
+	require.NoError(t, err)
+
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
+	})
+}
+
+// TestServiceSourceAddEventHandler tests that the handler is called when a service changes.
+func TestServiceSourceAddEventHandler(t *testing.T) {
+	kubernetes := fake.NewSimpleClientset()
+
+	client, err := NewServiceSource(kubernetes, v1.NamespaceAll, "", "", false, "", false, false, []string{})
+	require.NoError(t, err)
+
+	called := make(chan struct{}, 1)
+	client.AddEventHandler(func() {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	})
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "testing",
+			Name:      "foo",
+			Annotations: map[string]string{
+				hostnameAnnotationKey: "foo.example.org.",
+			},
+		},
+	}
+
+	_, err = kubernetes.CoreV1().Services(service.Namespace).Create(service)
+	require.NoError(t, err)
+
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event handler wasn't called")
+	}
+}
+
 func BenchmarkServiceEndpoints(b *testing.B) {
 	kubernetes := fake.NewSimpleClientset()
 
@@ -17,6 +17,7 @@ limitations under the License.
 package source
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
@@ -24,6 +25,27 @@ import (
 
 // test helper functions
 
+// sortEndpoints returns a sorted copy of the endpoints, for comparing the endpoints of
+// several objects: sources built on informers return them in no particular order.
+func sortEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	sorted := make([]*endpoint.Endpoint, len(endpoints))
+	copy(sorted, endpoints)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].DNSName != sorted[j].DNSName {
+			return sorted[i].DNSName < sorted[j].DNSName
+		}
+		return sortedTargets(sorted[i].Targets) < sortedTargets(sorted[j].Targets)
+	})
+	return sorted
+}
+
+func sortedTargets(targets endpoint.Targets) string {
+	sorted := make(endpoint.Targets, len(targets))
+	copy(sorted, targets)
+	sort.Sort(sorted)
+	return sorted.String()
+}
+
 func validateEndpoints(t *testing.T, endpoints, expected []*endpoint.Endpoint) {
 	if len(endpoints) != len(expected) {
 		t.Fatalf("expected %d endpoints, got %d", len(expected), len(endpoints))
@@ -22,6 +22,10 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/kubernetes-incubator/external-dns/endpoint"
 )
@@ -37,6 +41,12 @@ const (
 	ttlAnnotationKey = "external-dns.alpha.kubernetes.io/ttl"
 	// The annotation used for switching to the alias record types e. g. AWS Alias records instead of a normal CNAME
 	aliasAnnotationKey = "external-dns.alpha.kubernetes.io/alias"
+	// The annotation used for telling apart records of the same name with a routing policy, e.g. weighted records
+	setIdentifierAnnotationKey = "external-dns.alpha.kubernetes.io/set-identifier"
+	// The prefix of annotations passed to the AWS provider, e.g. aws-weight is passed as aws/weight
+	awsAnnotationPrefix = "external-dns.alpha.kubernetes.io/aws-"
+	// The prefix of annotations passed to the CloudFlare provider, e.g. cloudflare-proxied is passed as cloudflare/proxied
+	cloudFlareAnnotationPrefix = "external-dns.alpha.kubernetes.io/cloudflare-"
 	// The value of the controller annotation so that we feel responsible
 	controllerAnnotationValue = "dns-controller"
 )
@@ -46,9 +56,15 @@ const (
 	ttlMaximum = math.MaxUint32
 )
 
+// The maximum time to wait for the informer caches to be populated, it's shortened by tests
+var cacheSyncTimeout = 60 * time.Second
+
 // Source defines the interface Endpoint sources should implement.
 type Source interface {
 	Endpoints() ([]*endpoint.Endpoint, error)
+	// AddEventHandler registers a handler that is called whenever the resources
+	// backing the source change. Sources without change notifications ignore it.
+	AddEventHandler(handler func())
 }
 
 func getTTLFromAnnotations(annotations map[string]string) (endpoint.TTL, error) {
@@ -81,11 +97,21 @@ func getAliasFromAnnotations(annotations map[string]string) bool {
 	return exists && aliasAnnotation == "true"
 }
 
-func getProviderSpecificAnnotations(annotations map[string]string) endpoint.ProviderSpecific {
+// getProviderSpecificAnnotations returns the provider specific properties and the set identifier
+// of the records requested by the annotations.
+func getProviderSpecificAnnotations(annotations map[string]string) (endpoint.ProviderSpecific, string) {
+	providerSpecific := endpoint.ProviderSpecific{}
 	if getAliasFromAnnotations(annotations) {
-		return map[string]string{"alias": "true"}
+		providerSpecific["alias"] = "true"
+	}
+	for key, value := range annotations {
+		if strings.HasPrefix(key, awsAnnotationPrefix) {
+			providerSpecific["aws/"+strings.TrimPrefix(key, awsAnnotationPrefix)] = value
+		} else if strings.HasPrefix(key, cloudFlareAnnotationPrefix) {
+			providerSpecific["cloudflare/"+strings.TrimPrefix(key, cloudFlareAnnotationPrefix)] = value
+		}
 	}
-	return map[string]string{}
+	return providerSpecific, annotations[setIdentifierAnnotationKey]
 }
 
 // getTargetsFromTargetAnnotation gets endpoints from optional "target" annotation.
@@ -107,25 +133,33 @@ func getTargetsFromTargetAnnotation(annotations map[string]string) endpoint.Targ
 }
 
 // suitableType returns the DNS resource record type suitable for the target.
-// In this case type A for IPs and type CNAME for everything else.
+// In this case type A for IPv4 addresses, type AAAA for IPv6 addresses and
+// type CNAME for everything else.
 func suitableType(target string) string {
-	if net.ParseIP(target) != nil {
-		return endpoint.RecordTypeA
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return endpoint.RecordTypeCNAME
+	}
+	if ip.To4() == nil {
+		return endpoint.RecordTypeAAAA
 	}
-	return endpoint.RecordTypeCNAME
+	return endpoint.RecordTypeA
 }
 
 // endpointsForHostname returns the endpoint objects for each host-target combination.
-func endpointsForHostname(hostname string, targets endpoint.Targets, ttl endpoint.TTL, providerSpecific endpoint.ProviderSpecific) []*endpoint.Endpoint {
+func endpointsForHostname(hostname string, targets endpoint.Targets, ttl endpoint.TTL, providerSpecific endpoint.ProviderSpecific, setIdentifier string) []*endpoint.Endpoint {
 	var endpoints []*endpoint.Endpoint
 
 	var aTargets endpoint.Targets
+	var aaaaTargets endpoint.Targets
 	var cnameTargets endpoint.Targets
 
 	for _, t := range targets {
 		switch suitableType(t) {
 		case endpoint.RecordTypeA:
 			aTargets = append(aTargets, t)
+		case endpoint.RecordTypeAAAA:
+			aaaaTargets = append(aaaaTargets, t)
 		default:
 			cnameTargets = append(cnameTargets, t)
 		}
@@ -139,10 +173,24 @@ func endpointsForHostname(hostname string, targets endpoint.Targets, ttl endpoin
 			RecordType:       endpoint.RecordTypeA,
 			Labels:           endpoint.NewLabels(),
 			ProviderSpecific: providerSpecific,
+			SetIdentifier:    setIdentifier,
 		}
 		endpoints = append(endpoints, epA)
 	}
 
+	if len(aaaaTargets) > 0 {
+		epAAAA := &endpoint.Endpoint{
+			DNSName:          strings.TrimSuffix(hostname, "."),
+			Targets:          aaaaTargets,
+			RecordTTL:        ttl,
+			RecordType:       endpoint.RecordTypeAAAA,
+			Labels:           endpoint.NewLabels(),
+			ProviderSpecific: providerSpecific,
+			SetIdentifier:    setIdentifier,
+		}
+		endpoints = append(endpoints, epAAAA)
+	}
+
 	if len(cnameTargets) > 0 {
 		epCNAME := &endpoint.Endpoint{
 			DNSName:          strings.TrimSuffix(hostname, "."),
@@ -151,9 +199,45 @@ func endpointsForHostname(hostname string, targets endpoint.Targets, ttl endpoin
 			RecordType:       endpoint.RecordTypeCNAME,
 			Labels:           endpoint.NewLabels(),
 			ProviderSpecific: providerSpecific,
+			SetIdentifier:    setIdentifier,
 		}
 		endpoints = append(endpoints, epCNAME)
 	}
 
 	return endpoints
 }
+
+// setCreationTimestampLabel records when the resource requesting the endpoint was created.
+func setCreationTimestampLabel(ep *endpoint.Endpoint, created time.Time) {
+	if created.IsZero() {
+		return
+	}
+	ep.Labels[endpoint.CreationTimestampLabelKey] = created.UTC().Format(time.RFC3339)
+}
+
+// waitForCacheSync blocks until all given informers have populated their local
+// caches or returns an error if that doesn't happen within cacheSyncTimeout.
+func waitForCacheSync(synced ...cache.InformerSynced) error {
+	err := wait.PollImmediate(100*time.Millisecond, cacheSyncTimeout, func() (bool, error) {
+		for _, hasSynced := range synced {
+			if !hasSynced() {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync informer caches: %v", err)
+	}
+	return nil
+}
+
+// eventHandlerFuncs returns informer callbacks that call handler on every
+// add, update or delete of a watched object.
+func eventHandlerFuncs(handler func()) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handler() },
+		UpdateFunc: func(oldObj, newObj interface{}) { handler() },
+		DeleteFunc: func(obj interface{}) { handler() },
+	}
+}
@@ -76,11 +76,53 @@ func TestGetTTLFromAnnotations(t *testing.T) {
 	}
 }
 
+func TestGetProviderSpecificAnnotations(t *testing.T) {
+	for _, tc := range []struct {
+		title                 string
+		annotations           map[string]string
+		expectedSpecific      endpoint.ProviderSpecific
+		expectedSetIdentifier string
+	}{
+		{
+			title:            "no annotations",
+			annotations:      map[string]string{"foo": "bar"},
+			expectedSpecific: endpoint.ProviderSpecific{},
+		},
+		{
+			title:            "alias annotation",
+			annotations:      map[string]string{aliasAnnotationKey: "true"},
+			expectedSpecific: endpoint.ProviderSpecific{"alias": "true"},
+		},
+		{
+			title: "routing policy annotations",
+			annotations: map[string]string{
+				setIdentifierAnnotationKey:                    "blue",
+				"external-dns.alpha.kubernetes.io/aws-weight": "10",
+			},
+			expectedSpecific:      endpoint.ProviderSpecific{"aws/weight": "10"},
+			expectedSetIdentifier: "blue",
+		},
+		{
+			title:            "cloudflare proxied annotation",
+			annotations:      map[string]string{"external-dns.alpha.kubernetes.io/cloudflare-proxied": "false"},
+			expectedSpecific: endpoint.ProviderSpecific{"cloudflare/proxied": "false"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			providerSpecific, setIdentifier := getProviderSpecificAnnotations(tc.annotations)
+			assert.Equal(t, tc.expectedSpecific, providerSpecific)
+			assert.Equal(t, tc.expectedSetIdentifier, setIdentifier)
+		})
+	}
+}
+
 func TestSuitableType(t *testing.T) {
 	for _, tc := range []struct {
 		target, recordType, expected string
 	}{
 		{"8.8.8.8", "", "A"},
+		{"2001:db8::1", "", "AAAA"},
+		{"::ffff:8.8.8.8", "", "A"},
 		{"foo.example.org", "", "CNAME"},
 		{"bar.eu-central-1.elb.amazonaws.com", "", "CNAME"},
 	} {
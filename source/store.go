@@ -29,6 +29,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	istiocrd "istio.io/istio/pilot/pkg/config/kube/crd"
 	istiomodel "istio.io/istio/pilot/pkg/model"
+	istiokube "istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -85,7 +86,13 @@ func (p *SingletonClientGenerator) KubeClient() (kubernetes.Interface, error) {
 func (p *SingletonClientGenerator) IstioClient() (istiomodel.ConfigStore, error) {
 	var err error
 	p.istioOnce.Do(func() {
-		p.istioClient, err = NewIstioClient(p.KubeConfig)
+		var client *istiocrd.Client
+		client, err = NewIstioClient(p.KubeConfig)
+		if err != nil {
+			return
+		}
+		// serve the Istio configs from a local cache that is kept up to date by watches
+		p.istioClient = istiocrd.NewController(client, istiokube.ControllerOptions{})
 	})
 	return p.istioClient, err
 }